@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/cache"
+	"github.com/alecthomas/kong"
+)
+
+// CacheConfig holds the commands of the cache command.
+type CacheConfig struct {
+	// Clean removes the cached findings.
+	Clean CacheCleanConfig `cmd:"" help:"Remove all cached findings."`
+}
+
+// CacheCleanConfig holds the flags of the cache clean command.
+type CacheCleanConfig struct {
+	// CacheDir is the cache directory.
+	// Defaults to the `cache-dir` of the configuration file, or $XDG_CACHE_HOME/auto-err.
+	CacheDir string `name:"cache-dir" help:"Directory of the --check cache." default:"${cache_dir}"`
+}
+
+// runCache manages the cache of check mode.
+//
+// args: Command line arguments following "cache".
+// stdout: Writer for the output.
+//
+// Returns an error if the command is unknown or the cache cannot be cleaned.
+func runCache(args []string, stdout io.Writer) error {
+	cacheDir, err := configPath("cache-dir", cache.DefaultDir())
+	if err != nil {
+		return err
+	}
+
+	var cfg CacheConfig
+	parser, err := kong.New(&cfg,
+		kong.Name("auto-err cache"),
+		kong.Description("Manage the cache of --check runs."),
+		kong.Writers(stdout, io.Discard),
+		kong.Vars{"cache_dir": cacheDir},
+	)
+	if err != nil {
+		return err
+	}
+	ctx, err := parser.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	switch ctx.Command() {
+	case "clean":
+		if cfg.Clean.CacheDir == "" {
+			return fmt.Errorf("no cache directory")
+		}
+		n, err := cache.Clean(cfg.Clean.CacheDir)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Removed %d cached packages from %s.\n", n, cfg.Clean.CacheDir)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q", ctx.Command())
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRunCache verifies that repeated checks reuse the cache and that it can be cleaned.
+func TestRunCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	cacheDir := filepath.Join(tmpDir, "cache")
+	_ = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module example.com/cache\ngo 1.22\n"), 0644)
+	_ = os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n\nimport \"os\"\n\nfunc main() {\n\tos.Remove(\"x\")\n}\n"), 0644)
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	var buf bytes.Buffer
+	if err := run([]string{"--check", "--cache-dir", cacheDir, "."}, &buf); err == nil {
+		t.Fatalf("expected the check to fail:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "Cache: reusing 0 of 1 packages.") {
+		t.Errorf("first check should miss the cache:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := run([]string{"--check", "--cache-dir", cacheDir, "."}, &buf); err == nil || !strings.Contains(err.Error(), "1 unhandled errors") {
+		t.Fatalf("cached check should report the same finding, got %v:\n%s", err, buf.String())
+	}
+	if !strings.Contains(buf.String(), "Cache: reusing 1 of 1 packages.") {
+		t.Errorf("second check should hit the cache:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := run([]string{"cache", "clean", "--cache-dir", cacheDir}, &buf); err != nil {
+		t.Fatalf("cache clean failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Removed 1 cached packages") {
+		t.Errorf("unexpected output: %s", buf.String())
+	}
+
+	if err := run([]string{"cache", "unknown"}, &buf); err == nil {
+		t.Error("expected error for an unknown cache command")
+	}
+
+	// The cache-dir of the configuration file is relative to the file, not to the subdirectory.
+	_ = os.WriteFile(filepath.Join(tmpDir, ".auto-err.yaml"), []byte("cache-dir: cache\n"), 0644)
+	if err := run([]string{"--check", "."}, &buf); err == nil {
+		t.Fatalf("expected the check to fail:\n%s", buf.String())
+	}
+	sub := filepath.Join(tmpDir, "sub")
+	_ = os.Mkdir(sub, 0755)
+	_ = os.Chdir(sub)
+	buf.Reset()
+	if err := run([]string{"cache", "clean"}, &buf); err != nil {
+		t.Fatalf("cache clean from a subdirectory failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Removed 1 cached packages") {
+		t.Errorf("unexpected output from a subdirectory: %s", buf.String())
+	}
+}
@@ -0,0 +1,17 @@
+// Command auto-err-vet runs the auto-err Analyzer as a standalone vet tool.
+//
+// Usage:
+//
+//	go vet -vettool=$(which auto-err-vet) ./...
+//	auto-err-vet -fix ./...
+package main
+
+import (
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analyzer"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+// main is the vet tool entry point.
+func main() {
+	singlechecker.Main(analyzer.Analyzer)
+}
@@ -1,6 +1,10 @@
 package main
 
-import "github.com/alecthomas/kong"
+import (
+	"reflect"
+
+	"github.com/alecthomas/kong"
+)
 
 // Config holds the complete configuration mapping to CLI flags.
 // Fields use positive logic ("Enable...") defaulting to true to ensure
@@ -32,6 +36,33 @@ type Config struct {
 	// without modifying files. Implies --dry-run.
 	Check bool `name:"check" aliases:"verify" help:"Repo verification mode. exits with 1 if unhandled errors are found. Implies --dry-run."`
 
+	// Format selects the report emitted in check mode.
+	// "sarif" emits a SARIF 2.1.0 log with one result (and proposed fix) per unhandled error.
+	Format string `name:"format" enum:"text,sarif" help:"Report format for --check: 'text' or 'sarif'." default:"text"`
+
+	// Output is the file the check mode report is written to. Defaults to stdout.
+	Output string `name:"output" short:"o" type:"path" help:"Write the --check report to FILE instead of stdout."`
+
+	// Baseline is a file of known findings (written by WriteBaseline). Check mode only fails on
+	// findings that are not in it and lists the baseline entries that are fixed.
+	Baseline string `name:"baseline" placeholder:"FILE" type:"path" help:"Only fail --check on findings not in the baseline FILE."`
+
+	// WriteBaseline records the current findings in a baseline file and exits successfully.
+	// Findings are fingerprinted by package, function, callee, statement shape and occurrence,
+	// so that they survive unrelated edits.
+	WriteBaseline string `name:"write-baseline" placeholder:"FILE" type:"path" help:"Write the current findings to the baseline FILE and exit. Implies --check."`
+
+	// FromErrcheck fixes exactly the locations reported by errcheck instead of running detection.
+	// Accepts errcheck text output or golangci-lint JSON output ("-" reads stdin).
+	FromErrcheck string `name:"from-errcheck" placeholder:"FILE|-" help:"Fix only the locations in an errcheck or golangci-lint JSON report ('-' for stdin)."`
+
+	// DiffBase restricts fixes (and check findings) to lines changed against a git revision,
+	// including uncommitted and untracked files. The diff is computed by the local git binary.
+	DiffBase string `name:"diff-base" placeholder:"REV" help:"Only fix errors in lines changed against REV (e.g. 'origin/main')."`
+
+	// NewFromPatch restricts fixes (and check findings) to lines added by a unified diff.
+	NewFromPatch string `name:"new-from-patch" placeholder:"FILE|-" help:"Only fix errors in lines added by a unified diff ('-' for stdin)."`
+
 	// ExcludeGlob is a list of file glob patterns to exclude from analysis.
 	ExcludeGlob []string `name:"exclude-glob" help:"Glob patterns to exclude files (e.g. '*_test.go')."`
 
@@ -39,7 +70,8 @@ type Config struct {
 	ExcludeSymbolGlob []string `name:"exclude-symbol-glob" help:"Glob patterns to exclude symbols (e.g. 'fmt.Println')."`
 
 	// Paths to analyze.
-	Paths []string `arg:"" optional:"" help:"Directories to analyze." default:"."`
+	// Defaults to the `paths` of the configuration file, or "." if unset.
+	Paths []string `arg:"" optional:"" help:"Directories to analyze (default: config 'paths' or '.')."`
 
 	// DryRun enables preview mode.
 	DryRun bool `name:"dry-run" help:"Print changes to stdout instead of writing files."`
@@ -50,11 +82,97 @@ type Config struct {
 	UseDefaultExclusions bool `name:"default-exclusions" help:"Use standard exclusion list (fmt, log, etc)." default:"true"`
 
 	// MainHandler strategy for entry points.
-	MainHandler string `name:"main-handler" help:"Strategy for main/init: 'log-fatal', 'os-exit', 'panic'." default:"log-fatal"`
+	MainHandler string `name:"main-handler" help:"Strategy for main/init: 'log-fatal', 'os-exit', 'panic', 'slog-error', 'slog-error-exit'." default:"log-fatal"`
 
 	// ErrorTemplate template for return statements.
-	ErrorTemplate string `name:"error-template" help:"Template for return (e.g. '{return-zero}, err')." default:"{return-zero}, err"`
+	// Accepts a preset name ("return", "wrap", "wrap-caller") or a template using the placeholders
+	// {return-zero}, {callee}, {caller}, {pkg}, {receiver}, {file}, {line}, {args} and err.
+	ErrorTemplate string `name:"error-template" help:"Template for return (e.g. '{return-zero}, err') or a preset: 'return', 'wrap', 'wrap-caller'." default:"{return-zero}, err"`
+
+	// CompatWrappers keeps the signatures of exported functions that need an error result.
+	// The body moves to a new error-returning variant (FooE) and Foo becomes a deprecated wrapper
+	// that handles the error with CompatHandler, so importers outside the analyzed packages keep compiling.
+	CompatWrappers bool `name:"compat-wrappers" help:"Keep exported signatures: add an error-returning FooE variant and keep Foo as a deprecated wrapper."`
+
+	// CompatGlob selects additional functions (exported or not) handled like CompatWrappers.
+	CompatGlob []string `name:"compat-glob" help:"Symbol globs of functions to wrap like --compat-wrappers (e.g. 'example.com/lib.Open*')."`
+
+	// CompatSuffix is appended to the name of the error-returning variant.
+	CompatSuffix string `name:"compat-suffix" help:"Suffix of the error-returning variant (FooE)." default:"E"`
+
+	// CompatHandler is the error handling inside the deprecated wrappers.
+	CompatHandler string `name:"compat-handler" enum:"log,log-fatal,os-exit,panic,slog-error,slog-error-exit" help:"Error handling in compatibility wrappers: 'log', 'log-fatal', 'os-exit', 'panic', 'slog-error', 'slog-error-exit'." default:"log"`
+
+	// EvolveInterfaces changes conflicting interfaces together with all of their implementations.
+	// Without it, errors in methods that implement an interface are logged instead of returned.
+	// Interfaces or implementations declared outside the analyzed packages still fall back to logging.
+	EvolveInterfaces bool `name:"evolve-interfaces" help:"Add the error to local interface methods and all implementations together instead of logging it."`
+
+	// GoStrategy selects the handling of go statements whose call returns an error.
+	// "handler" wraps each statement in a closure calling the main handler; "errgroup" runs the
+	// go statements of a function in a golang.org/x/sync/errgroup.Group and handles the error of Wait.
+	GoStrategy string `name:"go-strategy" enum:"handler,errgroup" help:"Handling of go statements: 'handler' (closure with --main-handler) or 'errgroup'." default:"handler"`
+
+	// LogHandler selects the logging of errors that are handled in place (log rules and
+	// fallbacks): "log" uses log.Printf, "slog-error" logs structured attributes with slog.
+	LogHandler string `name:"log-handler" enum:"log,slog-error" help:"Logging of errors handled in place: 'log' (log.Printf) or 'slog-error'." default:"log"`
+
+	// Logger is the logger expression of the slog strategies. When empty, a *slog.Logger field of
+	// the receiver or a *slog.Logger variable in scope is used, falling back to the slog package.
+	Logger string `name:"logger" help:"Logger expression of the slog strategies (e.g. 's.logger', 'slog.Default()'). Detected from scope when empty."`
+
+	// ClosePolicy selects the handling of deferred calls releasing read-only resources (e.g. the
+	// Close of a file opened with os.Open or of an HTTP response body), whose error cannot lose data.
+	// The errors of writable resources are always joined into the error result.
+	ClosePolicy string `name:"close-policy" enum:"ignore,nolint,join" help:"Deferred Close of read-only resources: 'ignore' (left alone), 'nolint' (marked //nolint:errcheck) or 'join' (joined like writable ones)." default:"ignore"`
+
+	// SyncOnClose syncs writable files before the deferred Close joining their error, so that
+	// write errors reported by fsync are not lost.
+	SyncOnClose bool `name:"sync-on-close" help:"Sync writable files before closing them in deferred calls."`
+
+	// TypeCheck type checks the rewritten files in memory before writing them. Files that no
+	// longer compile are reverted and the injection points that caused the errors are reported.
+	TypeCheck bool `name:"type-check" negatable:"" help:"Type check rewrites in memory and revert files that no longer compile." default:"true"`
+
+	// MaxIterations caps the fix iterations. Each iteration after the first reloads only the
+	// packages containing rewritten files and their importers.
+	MaxIterations int `name:"max-iterations" help:"Maximum number of fix iterations; the run reports whether it converged." default:"5"`
+
+	// Jobs is the number of packages and files processed concurrently. 0 uses GOMAXPROCS.
+	Jobs int `name:"jobs" short:"j" help:"Number of packages and files processed concurrently (0: GOMAXPROCS)." default:"0"`
+
+	// CacheDir is the directory of the check mode cache. Findings of unchanged packages are reused
+	// from it without type checking. Defaults to $XDG_CACHE_HOME/auto-err; empty disables it.
+	CacheDir string `name:"cache-dir" help:"Directory of the --check cache ('' disables it)." default:"${cache_dir}"`
+
+	// JournalDir is the directory of the undo journal. Each run that writes files records their
+	// original content under <dir>/runs/<id>, which `auto-err undo` restores. Empty disables it.
+	JournalDir string `name:"journal-dir" help:"Directory of the undo journal ('' disables it)." default:".auto-err"`
+
+	// PrintConfig prints the effective configuration (file values merged with flags) and exits.
+	PrintConfig bool `name:"print-config" help:"Print the effective configuration as YAML and exit."`
+
+	// Rules select per-callee templates or handling strategies, e.g.
+	// "(*os.File).Close=log" or 'encoding/json.*={return-zero}, fmt.Errorf("decode: %w", err)'.
+	// Rules given on the command line are evaluated before those of the configuration file.
+	Rules []string `name:"rule" sep:"none" placeholder:"SYMBOL=ACTION" help:"Per-callee handling: 'SYMBOL=log' or 'SYMBOL=TEMPLATE' (repeatable)."`
 
 	// Get the version of the package, defaults to `dev`
 	Version kong.VersionFlag `name:"version" help:"Print version information and exit."`
 }
+
+// values returns the configurable flag values keyed by flag name, as accepted in .auto-err.yaml.
+// Action flags (version, print-config) and positional arguments are omitted.
+func (c *Config) values() map[string]any {
+	out := make(map[string]any)
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("name")
+		if name == "" || name == "version" || name == "print-config" {
+			continue
+		}
+		out[name] = v.Field(i).Interface()
+	}
+	return out
+}
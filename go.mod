@@ -7,6 +7,7 @@ require (
 	github.com/dave/dst v0.27.3
 	github.com/hexops/gotextdiff v1.0.3
 	golang.org/x/tools v0.41.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
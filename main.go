@@ -5,6 +5,9 @@ import (
 	"log"
 	"os"
 
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/cache"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/config"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/rewrite"
 	"github.com/SamuelMarks/go-auto-err-handling/pkg/runner"
 	"github.com/alecthomas/kong"
 )
@@ -25,15 +28,33 @@ func main() {
 // args: Command line arguments.
 // stdout: Writer for logs and output.
 func run(args []string, stdout io.Writer) error {
+	if len(args) > 0 && args[0] == "undo" {
+		return runUndo(args[1:], stdout)
+	}
+	if len(args) > 0 && args[0] == "cache" {
+		return runCache(args[1:], stdout)
+	}
+
 	var cfg Config
-	parser, err := kong.New(&cfg,
+	options := []kong.Option{
 		kong.Name("auto-err"),
-		kong.Description("Automatically inject error handling into Go code."),
+		kong.Description("Automatically inject error handling into Go code. Use 'auto-err undo [run-id]' to revert a run and 'auto-err cache clean' to clear the --check cache."),
 		kong.Writers(stdout, io.Discard),
 		// We removed kong.Exit(func(int) {}) here.
 		// Use standard behavior (os.Exit) so --version and --help exit cleanly.
-		kong.Vars{"version": version}, // Bind the version variable
-	)
+		kong.Vars{"version": version, "cache_dir": cache.DefaultDir()}, // Bind the version variable
+	}
+
+	// Values from the nearest .auto-err.yaml act as defaults; explicit flags take precedence.
+	file, err := loadConfigFile()
+	if err != nil {
+		return err
+	}
+	if file != nil {
+		options = append(options, kong.Resolvers(file.Resolver()))
+	}
+
+	parser, err := kong.New(&cfg, options...)
 	if err != nil {
 		return err
 	}
@@ -43,8 +64,44 @@ func run(args []string, stdout io.Writer) error {
 		return err
 	}
 
+	paths := cfg.Paths
+	if len(paths) == 0 && file != nil {
+		paths = file.ResolvedPaths()
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	var rules []rewrite.Rule
+	for _, spec := range cfg.Rules {
+		rule, err := rewrite.ParseRule(spec)
+		if err != nil {
+			return err
+		}
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+		rules = append(rules, rule)
+	}
+	if file != nil {
+		rules = append(rules, file.Rules...)
+	}
+
+	if cfg.PrintConfig {
+		values := cfg.values()
+		delete(values, "rule")
+		return config.Write(stdout, values, paths, rules, file)
+	}
+
 	log.SetOutput(stdout)
-	log.Printf("Starting analysis on paths: %v", cfg.Paths)
+	if cfg.Format == runner.FormatSARIF && cfg.Output == "" {
+		// Keep stdout clean for the SARIF log.
+		log.SetOutput(os.Stderr)
+	}
+	if file != nil {
+		log.Printf("Using config file: %s", file.Path)
+	}
+	log.Printf("Starting analysis on paths: %v", paths)
 
 	// Map CLI Config to Library Options.
 	opts := runner.Options{
@@ -57,9 +114,38 @@ func run(args []string, stdout io.Writer) error {
 		ExcludeSymbolGlob:    cfg.ExcludeSymbolGlob,
 		DryRun:               cfg.DryRun,
 		UseDefaultExclusions: cfg.UseDefaultExclusions,
-		Paths:                cfg.Paths,
+		Paths:                paths,
 		MainHandler:          cfg.MainHandler,
 		ErrorTemplate:        cfg.ErrorTemplate,
+		Format:               cfg.Format,
+		Output:               cfg.Output,
+		Version:              version,
+		FromErrcheck:         cfg.FromErrcheck,
+		Rules:                rules,
+		CompatWrappers:       cfg.CompatWrappers,
+		CompatGlob:           cfg.CompatGlob,
+		CompatSuffix:         cfg.CompatSuffix,
+		CompatHandler:        cfg.CompatHandler,
+		EvolveInterfaces:     cfg.EvolveInterfaces,
+		GoStrategy:           cfg.GoStrategy,
+		LogHandler:           cfg.LogHandler,
+		Logger:               cfg.Logger,
+		ClosePolicy:          cfg.ClosePolicy,
+		SyncOnClose:          cfg.SyncOnClose,
+		NoVerify:             !cfg.TypeCheck,
+		MaxIterations:        cfg.MaxIterations,
+		Jobs:                 cfg.Jobs,
+		JournalDir:           cfg.JournalDir,
+		CacheDir:             cfg.CacheDir,
+		DiffBase:             cfg.DiffBase,
+		NewFromPatch:         cfg.NewFromPatch,
+		Baseline:             cfg.Baseline,
+		WriteBaseline:        cfg.WriteBaseline,
+	}
+	if file != nil {
+		opts.PanicToReturn = file.PanicToReturn
+		opts.Callbacks = file.Callbacks
+		opts.Overrides = file.RunnerOverrides()
 	}
 
 	// Log active modes.
@@ -69,6 +155,42 @@ func run(args []string, stdout io.Writer) error {
 	if opts.Check {
 		log.Printf("Mode: CI Check (Verification)")
 	}
+	if opts.FromErrcheck != "" {
+		log.Printf("Detection source: errcheck report (%s)", opts.FromErrcheck)
+	}
 
 	return runner.Run(opts)
 }
+
+// loadConfigFile loads the nearest configuration file above the working directory.
+//
+// Returns nil if no configuration file exists.
+func loadConfigFile() (*config.File, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	path, err := config.Find(wd)
+	if err != nil || path == "" {
+		return nil, err
+	}
+	return config.Load(path)
+}
+
+// configPath returns the file or directory setting key of the nearest configuration file, resolved
+// against the directory of the file (see config.File.PathValue).
+//
+// key: The key of the setting (e.g. "journal-dir").
+// fallback: The value if no configuration file sets key.
+func configPath(key, fallback string) (string, error) {
+	file, err := loadConfigFile()
+	if err != nil {
+		return "", err
+	}
+	if file != nil {
+		if p, ok := file.PathValue(key); ok {
+			return p, nil
+		}
+	}
+	return fallback, nil
+}
@@ -2,12 +2,17 @@ package main
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
 
 // TestRun verifies CLI parsing logic and defaults.
 func TestRun(t *testing.T) {
+	// Keep the --check cache out of the user cache directory.
+	cacheDir := t.TempDir()
+	// The runs analyze this package; --dry-run keeps them from rewriting it.
 	tests := []struct {
 		name      string
 		args      []string
@@ -16,18 +21,19 @@ func TestRun(t *testing.T) {
 	}{
 		{
 			name:     "DefaultAllEnabled",
-			args:     []string{},
+			args:     []string{"--dry-run"},
 			expected: "Active Levels: Preexisting=true, ReturnTypeChanges=true, ThirdParty=true",
 		},
 		{
 			name: "DisableThirdParty",
 			// Explicitly set bool to false to ensure parsing works regardless of negation syntax support
-			args:     []string{"--third-party=false"},
+			args:     []string{"--dry-run", "--third-party=false"},
 			expected: "ThirdParty=false",
 		},
 		{
 			name: "DisableAll",
 			args: []string{
+				"--dry-run",
 				"--local-preexisting-err=false",
 				"--return-type-changes=false",
 				"--third-party=false",
@@ -41,14 +47,19 @@ func TestRun(t *testing.T) {
 		},
 		{
 			name:     "CheckFlag",
-			args:     []string{"--check", "."},
+			args:     []string{"--check", "--cache-dir", cacheDir, "."},
 			expected: "Mode: CI Check (Verification)",
 		},
 		{
 			name:     "VerifySameAsCheck",
-			args:     []string{"--verify", "."},
+			args:     []string{"--verify", "--cache-dir", cacheDir, "."},
 			expected: "Mode: CI Check (Verification)",
 		},
+		{
+			name:      "InvalidRule",
+			args:      []string{"--rule", "os.Remove"},
+			expectErr: true,
+		},
 		{
 			name:      "UnknownFlag",
 			args:      []string{"--foo-bar"},
@@ -80,3 +91,66 @@ func TestRun(t *testing.T) {
 		})
 	}
 }
+
+// TestRun_ConfigFile verifies that .auto-err.yaml values are merged beneath CLI flags.
+func TestRun_ConfigFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := "main-handler: panic\nerror-template: custom\npaths: [./...]\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, ".auto-err.yaml"), []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(tmpDir, "sub")
+	_ = os.Mkdir(sub, 0755)
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(sub)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	var buf bytes.Buffer
+	if err := run([]string{"--print-config", "--error-template=flag"}, &buf); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"# source: " + filepath.Join(tmpDir, ".auto-err.yaml"),
+		"main-handler: panic",
+		"error-template: flag",
+		"- " + filepath.Join(tmpDir, "..."),
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q. Got:\n%s", want, out)
+		}
+	}
+
+	// Files and directories are relative to the configuration file, not to the subdirectory.
+	cfg = "journal-dir: .journal\ncache-dir: .cache\nbaseline: base.json\nwrite-baseline: out/base.json\n" +
+		"output: report.sarif\nfrom-errcheck: errcheck.json\nnew-from-patch: '-'\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, ".auto-err.yaml"), []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := run([]string{"--print-config"}, &buf); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	out = buf.String()
+	for key, want := range map[string]string{
+		"journal-dir":    filepath.Join(tmpDir, ".journal"),
+		"cache-dir":      filepath.Join(tmpDir, ".cache"),
+		"baseline":       filepath.Join(tmpDir, "base.json"),
+		"write-baseline": filepath.Join(tmpDir, "out", "base.json"),
+		"output":         filepath.Join(tmpDir, "report.sarif"),
+		"from-errcheck":  filepath.Join(tmpDir, "errcheck.json"),
+		"new-from-patch": "'-'",
+	} {
+		if !strings.Contains(out, key+": "+want+"\n") {
+			t.Errorf("output missing %s: %s. Got:\n%s", key, want, out)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, ".auto-err.yaml"), []byte("unknown-key: 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"--print-config"}, &buf); err == nil {
+		t.Error("expected error for unknown configuration key")
+	}
+}
@@ -0,0 +1,289 @@
+package analysis
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"os"
+)
+
+// CloseKind classifies the resource released by a deferred call.
+type CloseKind int
+
+const (
+	// CloseUnknown is a resource of unknown access (e.g. a file received as a parameter, or a
+	// net.Conn).
+	CloseUnknown CloseKind = iota
+	// CloseReadOnly is a resource that was only read from. Its Close error cannot lose data.
+	CloseReadOnly
+	// CloseWritable is a resource that was written to. Its Close (or Flush, Sync, Commit) error
+	// reports data that may not have been written.
+	CloseWritable
+	// CloseRollback undoes uncommitted work (the Rollback of a *sql.Tx). It fails with
+	// sql.ErrTxDone once the work is committed, so its error cannot lose data either.
+	CloseRollback
+)
+
+// String returns the name of the kind.
+func (k CloseKind) String() string {
+	switch k {
+	case CloseReadOnly:
+		return "read-only"
+	case CloseWritable:
+		return "writable"
+	case CloseRollback:
+		return "rollback"
+	}
+	return "unknown"
+}
+
+// Lossless reports whether ignoring the error of a release of this kind cannot lose data: the
+// resource is read-only or the release is a rollback.
+func (k CloseKind) Lossless() bool {
+	return k == CloseReadOnly || k == CloseRollback
+}
+
+// writableTypes are the types whose Close writes buffered data but which have no Write method to
+// tell them apart.
+var writableTypes = map[string]bool{
+	"*archive/zip.Writer": true,
+	"*archive/tar.Writer": true,
+}
+
+// readOnlyTypes are the types whose Close releases a result that was only read from.
+var readOnlyTypes = map[string]bool{
+	"*database/sql.Rows":      true,
+	"*database/sql.Stmt":      true,
+	"*archive/zip.ReadCloser": true,
+}
+
+// fileOpeners classify the *os.File returned by the functions of package os.
+var fileOpeners = map[string]CloseKind{
+	"os.Open":       CloseReadOnly,
+	"os.Create":     CloseWritable,
+	"os.CreateTemp": CloseWritable,
+	"os.OpenFile":   CloseWritable,
+}
+
+// fileWriters are the functions that write to their first argument, or wrap it in a writer.
+var fileWriters = map[string]bool{
+	"bufio.NewWriter":          true,
+	"bufio.NewWriterSize":      true,
+	"compress/gzip.NewWriter":  true,
+	"compress/zlib.NewWriter":  true,
+	"archive/zip.NewWriter":    true,
+	"archive/tar.NewWriter":    true,
+	"encoding/csv.NewWriter":   true,
+	"encoding/json.NewEncoder": true,
+	"io.Copy":                  true,
+	"io.CopyBuffer":            true,
+	"io.WriteString":           true,
+	"fmt.Fprint":               true,
+	"fmt.Fprintf":              true,
+	"fmt.Fprintln":             true,
+}
+
+// fileWriteMethods are the methods of *os.File that write to it.
+var fileWriteMethods = map[string]bool{
+	"Write":       true,
+	"WriteString": true,
+	"WriteAt":     true,
+	"ReadFrom":    true,
+	"Truncate":    true,
+}
+
+// writeFlags are the os.OpenFile flags that open a file for writing.
+const writeFlags = os.O_WRONLY | os.O_RDWR | os.O_APPEND | os.O_CREATE | os.O_TRUNC
+
+// ClassifyClose classifies the resource released by call, a deferred Close (or Flush, Sync,
+// Commit, Rollback).
+//
+// Files (*os.File) are classified by how the function opens and uses them: os.Open is read-only,
+// os.Create and os.OpenFile with write flags are writable, as is a file written to or wrapped in a
+// writer (bufio.NewWriter, gzip.NewWriter, io.Copy, ...). Other types are classified by their
+// methods: a Reader without a Write method is read-only (e.g. resp.Body or *gzip.Reader) and a
+// Writer without a Read method is writable (e.g. *gzip.Writer or *bufio.Writer). Flush and Sync are
+// writable. A deferred Rollback of a *sql.Tx is a rollback (see CloseRollback), while Commit is
+// writable.
+//
+// info: The type information of the package.
+// file: The file of call.
+// call: The deferred call.
+//
+// Returns CloseUnknown if the access to the resource cannot be determined.
+func ClassifyClose(info *types.Info, file *ast.File, call *ast.CallExpr) CloseKind {
+	if info == nil || file == nil || call == nil {
+		return CloseUnknown
+	}
+	sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !ok {
+		return CloseUnknown
+	}
+	recv := info.TypeOf(sel.X)
+	if recv == nil {
+		return CloseUnknown
+	}
+	typ := types.TypeString(recv, nil)
+
+	switch sel.Sel.Name {
+	case "Flush", "Sync":
+		return CloseWritable
+	case "Commit":
+		if typ == "*database/sql.Tx" {
+			return CloseWritable
+		}
+		return CloseUnknown
+	case "Rollback":
+		if typ == "*database/sql.Tx" {
+			return CloseRollback
+		}
+		return CloseUnknown
+	case "Close":
+	default:
+		return CloseUnknown
+	}
+
+	switch {
+	case writableTypes[typ]:
+		return CloseWritable
+	case readOnlyTypes[typ]:
+		return CloseReadOnly
+	case typ == "*os.File":
+		return classifyFile(info, outermostBody(file, call), sel.X)
+	}
+	reads, writes := hasMethod(recv, "Read"), hasMethod(recv, "Write")
+	switch {
+	case reads && !writes:
+		return CloseReadOnly
+	case writes && !reads:
+		return CloseWritable
+	}
+	return CloseUnknown
+}
+
+// hasMethod reports whether t (or *t) has a method called name.
+func hasMethod(t types.Type, name string) bool {
+	obj, _, _ := types.LookupFieldOrMethod(t, true, nil, name)
+	_, ok := obj.(*types.Func)
+	return ok
+}
+
+// outermostBody returns the body of the outermost function enclosing node, so that files opened
+// outside of a closure deferring their Close are found.
+func outermostBody(file *ast.File, node ast.Node) *ast.BlockStmt {
+	path := pathEnclosing(file, node.Pos(), node.End())
+	for k := len(path) - 1; k >= 0; k-- {
+		switch fn := path[k].(type) {
+		case *ast.FuncDecl:
+			return fn.Body
+		case *ast.FuncLit:
+			return fn.Body
+		}
+	}
+	return nil
+}
+
+// classifyFile classifies the file held by x from the assignments and uses of its variable in
+// body.
+func classifyFile(info *types.Info, body *ast.BlockStmt, x ast.Expr) CloseKind {
+	id, ok := ast.Unparen(x).(*ast.Ident)
+	if !ok || body == nil {
+		return CloseUnknown
+	}
+	v, ok := info.ObjectOf(id).(*types.Var)
+	if !ok {
+		return CloseUnknown
+	}
+	isVar := func(e ast.Expr) bool {
+		id, ok := ast.Unparen(e).(*ast.Ident)
+		return ok && info.ObjectOf(id) == v
+	}
+
+	// written is set by writable sources as well as writes.
+	opened, unknown, written := false, false, false
+	source := func(e ast.Expr) {
+		opened = true
+		call, ok := ast.Unparen(e).(*ast.CallExpr)
+		if !ok {
+			unknown = true
+			return
+		}
+		name := CalleeName(info, call)
+		k, ok := fileOpeners[name]
+		if !ok {
+			unknown = true
+			return
+		}
+		if name == "os.OpenFile" && len(call.Args) > 1 {
+			if tv := info.Types[call.Args[1]]; tv.Value != nil {
+				if flags, exact := constant.Int64Val(constant.ToInt(tv.Value)); exact && flags&int64(writeFlags) == 0 {
+					k = CloseReadOnly
+				}
+			}
+		}
+		if k == CloseWritable {
+			written = true
+		}
+	}
+
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for k, lhs := range n.Lhs {
+				if !isVar(lhs) {
+					continue
+				}
+				if len(n.Rhs) == len(n.Lhs) {
+					source(n.Rhs[k])
+				} else if len(n.Rhs) == 1 {
+					source(n.Rhs[0])
+				}
+			}
+		case *ast.ValueSpec:
+			for k, name := range n.Names {
+				if !isVar(name) {
+					continue
+				}
+				if len(n.Values) == len(n.Names) {
+					source(n.Values[k])
+				} else if len(n.Values) == 1 {
+					source(n.Values[0])
+				}
+			}
+		case *ast.CallExpr:
+			if sel, ok := ast.Unparen(n.Fun).(*ast.SelectorExpr); ok && fileWriteMethods[sel.Sel.Name] && isVar(sel.X) {
+				written = true
+			}
+			if fileWriters[CalleeName(info, n)] && len(n.Args) > 0 && isVar(n.Args[0]) {
+				written = true
+			}
+		}
+		return true
+	})
+
+	switch {
+	case written:
+		return CloseWritable
+	case !opened || unknown:
+		return CloseUnknown
+	}
+	return CloseReadOnly
+}
+
+// calleeName returns the full name of the function or method called by call (e.g. "os.Open"), or
+// "" if it is not a declared function.
+func CalleeName(info *types.Info, call *ast.CallExpr) string {
+	var id *ast.Ident
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return ""
+	}
+	if fn, ok := info.Uses[id].(*types.Func); ok {
+		return fn.FullName()
+	}
+	return ""
+}
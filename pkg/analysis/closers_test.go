@@ -0,0 +1,208 @@
+package analysis
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+)
+
+func TestClassifyClose(t *testing.T) {
+	src := `package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"io"
+	"net"
+	"net/http"
+	"os"
+)
+
+func open(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return nil
+}
+
+func create(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return nil
+}
+
+func openReadOnly(name string) error {
+	f, err := os.OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return nil
+}
+
+func openAppend(name string) error {
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return nil
+}
+
+func openFlags(name string, flags int) error {
+	f, err := os.OpenFile(name, flags, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return nil
+}
+
+func param(f *os.File) error {
+	defer f.Close()
+	return nil
+}
+
+func written(f *os.File) error {
+	defer f.Close()
+	_, err := f.WriteString("x")
+	return err
+}
+
+func buffered(name string) error {
+	var f, err = os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	return w.Flush()
+}
+
+func closure(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	func() {
+		defer f.Close()
+	}()
+	return nil
+}
+
+func body(resp *http.Response) error {
+	defer resp.Body.Close()
+	return nil
+}
+
+func reader(r io.Reader) error {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	return nil
+}
+
+func writer(w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	defer zw.Close()
+	return nil
+}
+
+func conn(c net.Conn) error {
+	defer c.Close()
+	return nil
+}
+
+func rows(db *sql.DB) error {
+	rs, err := db.Query("SELECT 1")
+	if err != nil {
+		return err
+	}
+	defer rs.Close()
+	return nil
+}
+
+func rollback(tx *sql.Tx) error {
+	defer tx.Rollback()
+	return nil
+}
+
+func flush(w *bufio.Writer) error {
+	defer w.Flush()
+	return nil
+}
+
+func other(name string) error {
+	defer os.Remove(name)
+	return nil
+}
+`
+	want := map[string]CloseKind{
+		"open":         CloseReadOnly,
+		"create":       CloseWritable,
+		"openReadOnly": CloseReadOnly,
+		"openAppend":   CloseWritable,
+		"openFlags":    CloseWritable,
+		"param":        CloseUnknown,
+		"written":      CloseWritable,
+		"buffered":     CloseWritable,
+		"closure":      CloseWritable,
+		"body":         CloseReadOnly,
+		"reader":       CloseReadOnly,
+		"writer":       CloseWritable,
+		"conn":         CloseUnknown,
+		"rows":         CloseReadOnly,
+		"rollback":     CloseRollback,
+		"flush":        CloseWritable,
+		"other":        CloseUnknown,
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "main.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	if _, err := (&types.Config{Importer: importer.Default()}).Check("main", fset, []*ast.File{file}, info); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		var call *ast.CallExpr
+		ast.Inspect(fn, func(n ast.Node) bool {
+			if d, ok := n.(*ast.DeferStmt); ok {
+				call = d.Call
+			}
+			return call == nil
+		})
+		if call == nil {
+			t.Fatalf("no defer in %s", fn.Name.Name)
+		}
+		if got := ClassifyClose(info, file, call); got != want[fn.Name.Name] {
+			t.Errorf("ClassifyClose(%s) = %v, want %v", fn.Name.Name, got, want[fn.Name.Name])
+		}
+	}
+
+	if got := ClassifyClose(nil, file, nil); got != CloseUnknown {
+		t.Errorf("ClassifyClose(nil) = %v", got)
+	}
+}
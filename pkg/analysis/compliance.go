@@ -2,6 +2,7 @@ package analysis
 
 import (
 	"fmt"
+	"go/token"
 	"go/types"
 
 	"golang.org/x/tools/go/packages"
@@ -95,10 +96,13 @@ func (r *InterfaceRegistry) scanPackage(pkg *packages.Package) {
 // 1. The method's receiver type currently implements an interface `I`.
 // 2. `I` explicitly defines a method with the same name as `method`.
 //
+// Methods of instantiated generic types are checked as their generic declaration.
+//
 // method: The function object being targeted for refactoring (must be a method).
 //
 // Returns a slice of InterfaceConflict if issues are found, or nil if safe.
 func (r *InterfaceRegistry) CheckCompliance(method *types.Func) ([]InterfaceConflict, error) {
+	method = method.Origin()
 	sig, ok := method.Type().(*types.Signature)
 	if !ok {
 		return nil, fmt.Errorf("object is not a function signature")
@@ -127,7 +131,8 @@ func (r *InterfaceRegistry) CheckCompliance(method *types.Func) ([]InterfaceConf
 		if !implements {
 			// Fallback: Check for loose structural matching (name-based) to handle
 			// cases where types come from different package instances (e.g. test variants)
-			// and strict identity fails.
+			// and strict identity fails, or where the receiver or the interface is generic
+			// and cannot be compared before instantiation.
 			implements = verifyStructuralImplementation(recvType, iface)
 		}
 
@@ -188,3 +193,116 @@ func verifyStructuralImplementation(recv types.Type, iface *types.Interface) boo
 	}
 	return true
 }
+
+// Evolution is a set of interface methods and implementing methods that must gain an error
+// result together to keep every implementation compliant.
+type Evolution struct {
+	// InterfaceMethods are the interface method declarations to change (one object per declaration).
+	InterfaceMethods []*types.Func
+	// Methods are the concrete methods implementing them, including the method the plan started from.
+	Methods []*types.Func
+}
+
+// PlanEvolution computes the interface methods and implementations affected by adding an error
+// result to method. The closure is transitive: implementations that also satisfy other interfaces
+// pull those interfaces in as well.
+//
+// method: The concrete method about to receive an error result.
+// pkgs: The loaded packages. Every affected declaration must be in their syntax.
+//
+// Returns an error if any affected interface or implementation is declared outside pkgs
+// (e.g. io.Writer), in which case the interfaces cannot be evolved safely.
+func (r *InterfaceRegistry) PlanEvolution(method *types.Func, pkgs []*packages.Package) (*Evolution, error) {
+	plan := &Evolution{}
+	seenMethods := make(map[token.Pos]bool)
+	seenIfaces := make(map[token.Pos]bool)
+	queue := []*types.Func{method}
+	seenMethods[method.Pos()] = true
+
+	for len(queue) > 0 {
+		m := queue[0]
+		queue = queue[1:]
+		if !declaredIn(pkgs, m.Pos()) {
+			return nil, fmt.Errorf("method %s is declared outside the loaded packages", m.FullName())
+		}
+		plan.Methods = append(plan.Methods, m)
+
+		conflicts, err := r.CheckCompliance(m)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range conflicts {
+			im := c.InterfaceMethod
+			if seenIfaces[im.Pos()] {
+				continue
+			}
+			seenIfaces[im.Pos()] = true
+			if !declaredIn(pkgs, im.Pos()) {
+				return nil, fmt.Errorf("interface method %s is declared outside the loaded packages", im.FullName())
+			}
+			plan.InterfaceMethods = append(plan.InterfaceMethods, im)
+
+			// Every interface containing the declaration (including embedding interfaces) constrains its implementers.
+			for _, typeName := range r.interfaces {
+				iface := typeName.Type().Underlying().(*types.Interface)
+				if ok, m := interfaceHasMethod(iface, im.Name()); !ok || m.Pos() != im.Pos() {
+					continue
+				}
+				for _, impl := range implementers(pkgs, typeName, im.Name()) {
+					if !seenMethods[impl.Pos()] {
+						seenMethods[impl.Pos()] = true
+						queue = append(queue, impl)
+					}
+				}
+			}
+		}
+	}
+	return plan, nil
+}
+
+// implementers returns the methods named name of the concrete types in pkgs implementing the
+// interface ifaceName. Generic types and interfaces are matched by method names, since they
+// cannot be compared before instantiation.
+func implementers(pkgs []*packages.Package, ifaceName *types.TypeName, name string) []*types.Func {
+	iface := ifaceName.Type().Underlying().(*types.Interface)
+	var out []*types.Func
+	for _, pkg := range pkgs {
+		if pkg.Types == nil {
+			continue
+		}
+		scope := pkg.Types.Scope()
+		for _, n := range scope.Names() {
+			typeName, ok := scope.Lookup(n).(*types.TypeName)
+			if !ok || types.IsInterface(typeName.Type()) {
+				continue
+			}
+			ptr := types.NewPointer(typeName.Type())
+			if !types.Implements(ptr, iface) && !((isGeneric(typeName) || isGeneric(ifaceName)) && verifyStructuralImplementation(ptr, iface)) {
+				continue
+			}
+			obj, _, _ := types.LookupFieldOrMethod(ptr, true, pkg.Types, name)
+			if fn, ok := obj.(*types.Func); ok {
+				out = append(out, fn)
+			}
+		}
+	}
+	return out
+}
+
+// isGeneric reports whether the type declared by typeName has type parameters.
+func isGeneric(typeName *types.TypeName) bool {
+	named, ok := typeName.Type().(*types.Named)
+	return ok && named.TypeParams().Len() > 0
+}
+
+// declaredIn reports whether pos lies within the syntax of one of pkgs.
+func declaredIn(pkgs []*packages.Package, pos token.Pos) bool {
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Syntax {
+			if f.Pos() <= pos && pos < f.End() {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -46,6 +46,7 @@ func setupComplianceEnv(t *testing.T, src string) (*types.Package, []*packages.P
 		PkgPath:   "main",
 		Types:     pkg,
 		TypesInfo: info,
+		Syntax:    []*ast.File{f},
 		Imports:   nil, // Dependencies would be mocked here if needed
 	}
 
@@ -198,3 +199,143 @@ func (s *S) Do() {}
 		t.Errorf("Expected 0 conflicts for empty interface, got %d", len(conflicts))
 	}
 }
+
+// TestPlanEvolution verifies the transitive closure of interfaces and implementations.
+func TestPlanEvolution(t *testing.T) {
+	src := `package main
+
+type Saver interface {
+	Save()
+}
+
+// Embeds Saver, so its implementers are constrained by Saver.Save too.
+type Store interface {
+	Saver
+	Load()
+}
+
+type File struct{}
+
+func (f *File) Save() {}
+func (f *File) Load() {}
+
+type Memory struct{}
+
+func (m Memory) Save() {}
+
+type Unrelated struct{}
+
+func (u *Unrelated) Load() {}
+`
+	tpkg, pkgs, _ := setupComplianceEnv(t, src)
+	registry := NewInterfaceRegistry(pkgs)
+
+	method := func(typ, name string) *types.Func {
+		obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(tpkg.Scope().Lookup(typ).Type()), true, tpkg, name)
+		return obj.(*types.Func)
+	}
+
+	plan, err := registry.PlanEvolution(method("File", "Save"), pkgs)
+	if err != nil {
+		t.Fatalf("PlanEvolution failed: %v", err)
+	}
+	if len(plan.InterfaceMethods) != 1 || plan.InterfaceMethods[0].Name() != "Save" {
+		t.Errorf("expected the single Saver.Save declaration, got %v", plan.InterfaceMethods)
+	}
+	got := map[string]bool{}
+	for _, m := range plan.Methods {
+		got[m.FullName()] = true
+	}
+	for _, want := range []string{"(*main.File).Save", "(main.Memory).Save"} {
+		if !got[want] {
+			t.Errorf("plan missing %s: %v", want, got)
+		}
+	}
+	if len(plan.Methods) != 2 {
+		t.Errorf("unexpected methods: %v", got)
+	}
+
+	// Declarations outside the loaded syntax cannot be evolved.
+	pkgs[0].Syntax = nil
+	if _, err := registry.PlanEvolution(method("File", "Save"), pkgs); err == nil {
+		t.Error("expected error for declarations outside the loaded packages")
+	}
+}
+
+// TestCheckCompliance_Generics verifies that methods of generic types, called on instances or
+// implementing generic interfaces, are checked and evolved as their generic declaration.
+func TestCheckCompliance_Generics(t *testing.T) {
+	src := `package main
+
+type Store[T any] interface {
+	Put(v T)
+}
+
+type Lener interface {
+	Len() int
+}
+
+type MemStore[T any] struct{ items []T }
+
+func (m *MemStore[T]) Put(v T) {}
+func (m *MemStore[T]) Len() int { return 0 }
+func (m *MemStore[T]) Reset()   {}
+
+type NopStore[T any] struct{}
+
+func (NopStore[T]) Put(v T) {}
+
+func use(m *MemStore[int]) {
+	m.Put(1)
+}
+`
+	tpkg, pkgs, _ := setupComplianceEnv(t, src)
+	registry := NewInterfaceRegistry(pkgs)
+
+	method := func(name string) *types.Func {
+		obj, _, _ := types.LookupFieldOrMethod(tpkg.Scope().Lookup("MemStore").Type(), true, tpkg, name)
+		return obj.(*types.Func)
+	}
+
+	for name, want := range map[string]string{"Put": "Store", "Len": "Lener", "Reset": ""} {
+		conflicts, err := registry.CheckCompliance(method(name))
+		if err != nil {
+			t.Fatalf("CheckCompliance(%s) failed: %v", name, err)
+		}
+		var got string
+		if len(conflicts) > 0 {
+			got = conflicts[0].Interface.Name()
+		}
+		if got != want || len(conflicts) > 1 {
+			t.Errorf("CheckCompliance(%s) = %v, want %q", name, conflicts, want)
+		}
+	}
+
+	// The instance used in use is resolved to the generic method.
+	var inst *types.Func
+	for id, obj := range pkgs[0].TypesInfo.Uses {
+		if fn, ok := obj.(*types.Func); ok && id.Name == "Put" {
+			inst = fn
+		}
+	}
+	if inst == nil || inst == method("Put") {
+		t.Fatalf("instance of Put not found")
+	}
+	if conflicts, _ := registry.CheckCompliance(inst); len(conflicts) != 1 || conflicts[0].Method != method("Put") {
+		t.Errorf("expected the conflict of the generic method, got %v", conflicts)
+	}
+
+	plan, err := registry.PlanEvolution(method("Put"), pkgs)
+	if err != nil {
+		t.Fatalf("PlanEvolution failed: %v", err)
+	}
+	got := map[string]bool{}
+	for _, m := range plan.Methods {
+		got[m.FullName()] = true
+	}
+	for _, want := range []string{"(*main.MemStore[T]).Put", "(main.NopStore[T]).Put"} {
+		if !got[want] {
+			t.Errorf("plan missing %s: %v", want, got)
+		}
+	}
+}
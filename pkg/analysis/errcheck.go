@@ -2,6 +2,7 @@ package analysis
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"go/ast"
 	"go/token"
@@ -9,6 +10,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/go/packages"
@@ -78,53 +80,123 @@ func (p *ErrcheckParser) Parse(reader io.Reader) ([]InjectionPoint, error) {
 			continue
 		}
 
-		// Resolve File
-		absPath, err := filepath.Abs(path)
-		if err != nil {
-			continue
+		if point, found := p.pointAt(path, lineNum, colNum); found {
+			points = append(points, point)
 		}
+	}
 
-		ctx, ok := p.fileMap[absPath]
-		if !ok {
-			// File reported by errcheck is not in the loaded package set.
-			continue
-		}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading errcheck output: %w", err)
+	}
+
+	return points, nil
+}
 
-		// Locate the exact AST node and create the InjectionPoint
-		// errcheck reports the position of the identifier being called.
-		// We need to resolve that position to a token.Pos in our FileSet.
-		//
-		// We can't trust simple line conversion because FileSet base might vary.
-		// We iterate the file's LineInfo to find the Pos.
-		// Alternatively, since we have the AST file, we can iterate or use a faster lookup if Fset/File is utilized correctly.
-		// The safest, standard way given a *token.File (which we can get from Fset) is LineStart + offset.
-		// However, ast.File doesn't expose strict offsets easily without the token.File.
-		// We use the package's Fset.
-		tokenFile := findTokenFile(ctx.pkg.Fset, absPath)
-		if tokenFile == nil {
+// golangciReport mirrors the subset of the golangci-lint JSON output (`--out-format json`) used here.
+type golangciReport struct {
+	Issues []struct {
+		FromLinter string `json:"FromLinter"`
+		Text       string `json:"Text"`
+		Pos        struct {
+			Filename string `json:"Filename"`
+			Line     int    `json:"Line"`
+			Column   int    `json:"Column"`
+		} `json:"Pos"`
+	} `json:"Issues"`
+}
+
+// ParseGolangCI reads golangci-lint JSON output and generates injection points for the issues
+// reported by the errcheck linter. Issues from other linters are ignored.
+//
+// reader: Source of the golangci-lint JSON output.
+//
+// Returns a slice of valid InjectionPoints. Issues pointing to files not currently loaded are skipped.
+func (p *ErrcheckParser) ParseGolangCI(reader io.Reader) ([]InjectionPoint, error) {
+	var rep golangciReport
+	if err := json.NewDecoder(reader).Decode(&rep); err != nil {
+		return nil, fmt.Errorf("error reading golangci-lint output: %w", err)
+	}
+
+	var points []InjectionPoint
+	for _, issue := range rep.Issues {
+		if issue.FromLinter != "errcheck" {
 			continue
 		}
+		if point, found := p.pointAt(issue.Pos.Filename, issue.Pos.Line, issue.Pos.Column); found {
+			points = append(points, point)
+		}
+	}
+	return points, nil
+}
 
-		// Calculate Pos from line/col
-		// Add line offset
-		if lineNum > tokenFile.LineCount() {
+// ParseReport detects whether the input is golangci-lint JSON or plain errcheck text
+// and dispatches to ParseGolangCI or Parse respectively.
+//
+// reader: Source of the report.
+//
+// Returns the injection points described by the report.
+func (p *ErrcheckParser) ParseReport(reader io.Reader) ([]InjectionPoint, error) {
+	br := bufio.NewReader(reader)
+	for {
+		r, _, err := br.ReadRune()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading report: %w", err)
+		}
+		if unicode.IsSpace(r) {
 			continue
 		}
-		lineStart := tokenFile.LineStart(lineNum)
-		// Col is byte offset on line usually. Pos is simply lineStart + col - 1.
-		pos := lineStart + token.Pos(colNum-1)
-
-		point, found := resolveNodeContext(ctx, pos)
-		if found {
-			points = append(points, point)
+		if err := br.UnreadRune(); err != nil {
+			return nil, err
+		}
+		if r == '{' {
+			return p.ParseGolangCI(br)
 		}
+		return p.Parse(br)
 	}
+}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading errcheck output: %w", err)
+// pointAt resolves a reported file/line/column location to an InjectionPoint.
+//
+// path: The reported file path (relative paths are resolved against the working directory).
+// lineNum: 1-based line.
+// colNum: 1-based byte column.
+//
+// Returns the InjectionPoint and true if the location maps to a call statement in a loaded file.
+func (p *ErrcheckParser) pointAt(path string, lineNum, colNum int) (InjectionPoint, bool) {
+	// Resolve File
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return InjectionPoint{}, false
 	}
 
-	return points, nil
+	ctx, ok := p.fileMap[absPath]
+	if !ok {
+		// File reported by errcheck is not in the loaded package set.
+		return InjectionPoint{}, false
+	}
+
+	// Locate the exact AST node and create the InjectionPoint
+	// errcheck reports the position of the identifier being called.
+	// We need to resolve that position to a token.Pos in our FileSet.
+	//
+	// We can't trust simple line conversion because FileSet base might vary.
+	// The safest, standard way given a *token.File (which we can get from Fset) is LineStart + offset.
+	tokenFile := findTokenFile(ctx.pkg.Fset, absPath)
+	if tokenFile == nil {
+		return InjectionPoint{}, false
+	}
+
+	if lineNum < 1 || lineNum > tokenFile.LineCount() || colNum < 1 {
+		return InjectionPoint{}, false
+	}
+	lineStart := tokenFile.LineStart(lineNum)
+	// Col is byte offset on line usually. Pos is simply lineStart + col - 1.
+	pos := lineStart + token.Pos(colNum-1)
+
+	return resolveNodeContext(ctx, pos)
 }
 
 // findTokenFile locates the token.File correspondence for a filename in a FileSet.
@@ -202,3 +202,86 @@ func main() {
 		// We rely on the fact that Parse checks `findTokenFile` returning nil.
 	})
 }
+
+// TestErrcheckParser_ParseGolangCI verifies that golangci-lint JSON output is mapped to AST nodes
+// and that issues from other linters are ignored.
+func TestErrcheckParser_ParseGolangCI(t *testing.T) {
+	src := `package main
+
+func fail() error { return nil }
+
+func main() {
+	fail()
+	_ = fail()
+}
+`
+	tmpDir := t.TempDir()
+	filename := filepath.Join(tmpDir, "main.go")
+	pkgs := createMockPackage(t, filename, src)
+	parserInstance := NewErrcheckParser(pkgs)
+
+	input := fmt.Sprintf(`{
+  "Issues": [
+    {"FromLinter": "errcheck", "Text": "Error return value is not checked", "Pos": {"Filename": %q, "Line": 6, "Column": 2}},
+    {"FromLinter": "govet", "Text": "unrelated", "Pos": {"Filename": %q, "Line": 7, "Column": 6}},
+    {"FromLinter": "errcheck", "Text": "missing file", "Pos": {"Filename": "/missing.go", "Line": 1, "Column": 1}}
+  ]
+}`, filename, filename)
+
+	points, err := parserInstance.ParseGolangCI(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseGolangCI error: %v", err)
+	}
+	if len(points) != 1 {
+		t.Fatalf("Expected 1 injection point, got %d", len(points))
+	}
+	if _, ok := points[0].Stmt.(*ast.ExprStmt); !ok {
+		t.Errorf("Expected ExprStmt, got %T", points[0].Stmt)
+	}
+
+	if _, err := parserInstance.ParseGolangCI(strings.NewReader("{not json")); err == nil {
+		t.Error("Expected error for malformed JSON")
+	}
+}
+
+// TestErrcheckParser_ParseReport verifies format sniffing between errcheck text and golangci-lint JSON.
+func TestErrcheckParser_ParseReport(t *testing.T) {
+	src := `package main
+
+func fail() error { return nil }
+
+func main() {
+	_ = fail()
+}
+`
+	tmpDir := t.TempDir()
+	filename := filepath.Join(tmpDir, "main.go")
+	pkgs := createMockPackage(t, filename, src)
+	parserInstance := NewErrcheckParser(pkgs)
+
+	inputs := map[string]string{
+		"Text": fmt.Sprintf("%s:6:6:\t_ = fail()\n", filename),
+		"JSON": fmt.Sprintf("\n  {\"Issues\": [{\"FromLinter\": \"errcheck\", \"Pos\": {\"Filename\": %q, \"Line\": 6, \"Column\": 6}}]}", filename),
+	}
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			points, err := parserInstance.ParseReport(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("ParseReport error: %v", err)
+			}
+			if len(points) != 1 {
+				t.Fatalf("Expected 1 injection point, got %d", len(points))
+			}
+			if points[0].Assign == nil {
+				t.Error("Expected assignment statement to be resolved")
+			}
+		})
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		points, err := parserInstance.ParseReport(strings.NewReader("  \n"))
+		if err != nil || len(points) != 0 {
+			t.Errorf("Expected no points and no error, got %d, %v", len(points), err)
+		}
+	})
+}
@@ -0,0 +1,767 @@
+package analysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// FuncValues is the set of declarations that change together with a function gaining an error
+// result while it is used as a value (e.g. "cb := s.Load", "handlers["x"] = load",
+// "Task{Run: load}" or "retry(load)"): the variables, struct fields and parameters holding the
+// function, and the other values stored in them.
+type FuncValues struct {
+	// TypeExprs are the function types to add the error result to: the declared types of the slots
+	// and the types of composite literals and make calls. For maps, slices and arrays of functions
+	// they are the element types.
+	TypeExprs []*ast.FuncType
+	// Slots are the variables, struct fields and parameters holding the values. Their calls (or the
+	// calls of their elements) return the error after the change.
+	Slots []*types.Var
+	// Funcs are the other declared functions stored in the slots, which gain the error result as
+	// well.
+	Funcs []*types.Func
+	// Lits are the function literals stored in the slots.
+	Lits []*ast.FuncLit
+}
+
+// valuePlanner computes FuncValues by following the values from use to slot and from slot to the
+// other values stored in it.
+type valuePlanner struct {
+	pkgs []*packages.Package
+	plan *FuncValues
+	// seen holds the declarations of the planned functions and slots.
+	seen map[token.Pos]bool
+	// nodes holds the planned type expressions, literals and composite literals.
+	nodes map[ast.Node]bool
+	// queue holds the functions and slots whose uses are not followed yet.
+	queue []types.Object
+}
+
+// PlanFuncValues computes the declarations that must change when fn gains an error result, so
+// that the places fn is stored in (and calls through them) keep compiling. Calls of fn itself are
+// not part of the plan.
+//
+// fn: The function about to receive an error result.
+// pkgs: The loaded packages. Every affected declaration must be in their syntax.
+//
+// Returns an error if a value of fn reaches a place whose type cannot change: a declaration
+// outside pkgs (e.g. the handler parameter of http.HandleFunc), a named function type, a return
+// statement or a conversion.
+func PlanFuncValues(fn *types.Func, pkgs []*packages.Package) (*FuncValues, error) {
+	p := newValuePlanner(pkgs)
+	p.seen[fn.Pos()] = true
+	p.queue = append(p.queue, fn)
+	return p.run()
+}
+
+// PlanFuncLit computes the declarations that must change when the function literal lit gains an
+// error result, like PlanFuncValues. The plan includes lit itself; its immediate invocation (e.g.
+// "func() { ... }()") is not part of the plan.
+//
+// lit: The function literal about to receive an error result.
+// pkgs: The loaded packages. Every affected declaration must be in their syntax.
+//
+// Returns an error if the literal reaches a place whose type cannot change (e.g. it is passed to
+// http.HandleFunc).
+func PlanFuncLit(lit *ast.FuncLit, pkgs []*packages.Package) (*FuncValues, error) {
+	p := newValuePlanner(pkgs)
+	pkg, file := p.fileAt(lit.Pos())
+	if file == nil {
+		return nil, fmt.Errorf("file of the function literal not found")
+	}
+	p.nodes[lit] = true
+	p.plan.Lits = append(p.plan.Lits, lit)
+	path, _ := astutil.PathEnclosingInterval(file, lit.Pos(), lit.End())
+	if err := p.flow(pkg, lit, path[1:]); err != nil {
+		return nil, err
+	}
+	return p.run()
+}
+
+// newValuePlanner returns an empty planner for pkgs.
+func newValuePlanner(pkgs []*packages.Package) *valuePlanner {
+	return &valuePlanner{
+		pkgs:  pkgs,
+		plan:  &FuncValues{},
+		seen:  make(map[token.Pos]bool),
+		nodes: make(map[ast.Node]bool),
+	}
+}
+
+// run follows the uses of the queued functions and slots.
+func (p *valuePlanner) run() (*FuncValues, error) {
+	for len(p.queue) > 0 {
+		obj := p.queue[0]
+		p.queue = p.queue[1:]
+		for _, use := range p.uses(obj) {
+			if err := p.use(use.pkg, use.path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return p.plan, nil
+}
+
+// valueUse is an identifier referring to a planned function or slot.
+type valueUse struct {
+	pkg  *packages.Package
+	path []ast.Node
+}
+
+// uses returns the uses of obj in source order. Every package variant has its own objects, so
+// they are matched by declaration; shared syntax is reported once.
+func (p *valuePlanner) uses(obj types.Object) []valueUse {
+	var out []valueUse
+	seen := make(map[*ast.Ident]bool)
+	for _, pkg := range p.pkgs {
+		if pkg.TypesInfo == nil {
+			continue
+		}
+		for id, o := range pkg.TypesInfo.Uses {
+			if seen[id] || o.Pos() != obj.Pos() || o.Name() != obj.Name() {
+				continue
+			}
+			file := syntaxFile(pkg, id.Pos())
+			if file == nil {
+				continue
+			}
+			seen[id] = true
+			path, _ := astutil.PathEnclosingInterval(file, id.Pos(), id.End())
+			out = append(out, valueUse{pkg: pkg, path: path})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].path[0].Pos() < out[j].path[0].Pos() })
+	return out
+}
+
+// use follows the identifier path[0] to the place its value is stored in, or to the value
+// stored into it.
+func (p *valuePlanner) use(pkg *packages.Package, path []ast.Node) error {
+	expr := path[0].(ast.Expr)
+	i := 1
+	// "pkg.Fn", "s.Method" and "x.Field" are used as a whole.
+	if i < len(path) {
+		if sel, ok := path[i].(*ast.SelectorExpr); ok && sel.Sel == expr {
+			expr = sel
+			i++
+		}
+	}
+	return p.flow(pkg, expr, path[i:])
+}
+
+// flow follows the value of expr, whose enclosing nodes are parents (innermost first).
+func (p *valuePlanner) flow(pkg *packages.Package, expr ast.Expr, parents []ast.Node) error {
+	if len(parents) == 0 {
+		return nil
+	}
+	switch n := parents[0].(type) {
+	case *ast.ParenExpr:
+		return p.flow(pkg, n, parents[1:])
+
+	case *ast.CallExpr:
+		if n.Fun == expr {
+			// A call, handled with the calls of the function.
+			return nil
+		}
+		return p.argument(pkg, n, expr, parents[1:])
+
+	case *ast.IndexExpr:
+		if n.X != expr {
+			return nil
+		}
+		// An element of a map, slice or array, or an instance of a generic function.
+		return p.flow(pkg, n, parents[1:])
+
+	case *ast.IndexListExpr:
+		if n.X != expr {
+			return nil
+		}
+		return p.flow(pkg, n, parents[1:])
+
+	case *ast.AssignStmt:
+		if i := exprIndex(n.Lhs, expr); i >= 0 {
+			if len(n.Lhs) != len(n.Rhs) {
+				return p.errorf(pkg, expr, "%s is assigned a value of unknown origin", types.ExprString(expr))
+			}
+			return p.source(pkg, n.Rhs[i])
+		}
+		i := exprIndex(n.Rhs, expr)
+		if len(n.Lhs) == len(n.Rhs) {
+			return p.dest(pkg, n.Lhs[i])
+		}
+		// "h, ok := handlers[name]"
+		return p.dest(pkg, n.Lhs[0])
+
+	case *ast.ValueSpec:
+		i := exprIndex(n.Values, expr)
+		if i < 0 {
+			return nil
+		}
+		if len(n.Names) == len(n.Values) {
+			return p.dest(pkg, n.Names[i])
+		}
+		return p.dest(pkg, n.Names[0])
+
+	case *ast.KeyValueExpr:
+		if n.Value != expr || len(parents) < 2 {
+			return nil
+		}
+		lit, ok := parents[1].(*ast.CompositeLit)
+		if !ok {
+			return nil
+		}
+		return p.element(pkg, lit, n, parents[2:])
+
+	case *ast.CompositeLit:
+		return p.element(pkg, n, expr, parents[1:])
+
+	case *ast.RangeStmt:
+		if n.Value == expr {
+			return p.source(pkg, n.X)
+		}
+		if n.X != expr {
+			return nil
+		}
+		if _, ok := pkg.TypesInfo.TypeOf(expr).Underlying().(*types.Signature); ok {
+			return p.errorf(pkg, expr, "%s is ranged over", types.ExprString(expr))
+		}
+		if n.Value == nil {
+			return nil
+		}
+		return p.dest(pkg, n.Value)
+
+	case *ast.BinaryExpr:
+		// Compared to nil.
+		return nil
+
+	case *ast.ReturnStmt:
+		return p.errorf(pkg, expr, "%s is returned", types.ExprString(expr))
+	}
+	return p.errorf(pkg, expr, "%s is used in an unsupported expression", types.ExprString(expr))
+}
+
+// argument follows expr passed to call.
+func (p *valuePlanner) argument(pkg *packages.Package, call *ast.CallExpr, expr ast.Expr, parents []ast.Node) error {
+	info := pkg.TypesInfo
+	if id, ok := ast.Unparen(call.Fun).(*ast.Ident); ok {
+		if b, ok := info.Uses[id].(*types.Builtin); ok {
+			switch b.Name() {
+			case "append":
+				// The elements are stored where the result is stored.
+				return p.flow(pkg, call, parents)
+			case "len", "cap", "delete", "clear":
+				return nil
+			}
+			return p.errorf(pkg, expr, "%s is passed to %s", types.ExprString(expr), b.Name())
+		}
+	}
+	if tv, ok := info.Types[call.Fun]; ok && tv.IsType() {
+		return p.errorf(pkg, expr, "%s is converted to %s", types.ExprString(expr), types.ExprString(call.Fun))
+	}
+	sig, ok := info.TypeOf(call.Fun).Underlying().(*types.Signature)
+	if !ok {
+		return p.errorf(pkg, expr, "%s is passed to an unknown function", types.ExprString(expr))
+	}
+
+	i := exprIndex(call.Args, expr)
+	params := sig.Params()
+	k := i
+	paramType := func() types.Type {
+		if sig.Variadic() && i >= params.Len()-1 {
+			k = params.Len() - 1
+			if !call.Ellipsis.IsValid() {
+				return params.At(k).Type().(*types.Slice).Elem()
+			}
+		}
+		return params.At(k).Type()
+	}()
+	if types.IsInterface(paramType) {
+		// e.g. fmt.Println(fn)
+		return nil
+	}
+
+	callee := calledFunc(info, call)
+	if callee != nil && !declaredIn(p.pkgs, callee.Pos()) {
+		return p.errorf(pkg, expr, "%s is passed to %s, which is declared outside the loaded packages", types.ExprString(expr), callee.FullName())
+	}
+	if callee == nil {
+		return p.errorf(pkg, expr, "%s is passed to the function value %s", types.ExprString(expr), types.ExprString(call.Fun))
+	}
+	return p.addSlot(callee.Origin().Type().(*types.Signature).Params().At(k))
+}
+
+// element follows expr (an element or a key-value pair) of the composite literal lit.
+func (p *valuePlanner) element(pkg *packages.Package, lit *ast.CompositeLit, expr ast.Expr, parents []ast.Node) error {
+	switch t := pkg.TypesInfo.TypeOf(lit).Underlying().(type) {
+	case *types.Struct:
+		if kv, ok := expr.(*ast.KeyValueExpr); ok {
+			key, _ := kv.Key.(*ast.Ident)
+			if key == nil {
+				return p.errorf(pkg, expr, "%s is stored in an unknown field", types.ExprString(expr))
+			}
+			field, _ := pkg.TypesInfo.Uses[key].(*types.Var)
+			if field == nil {
+				return p.errorf(pkg, expr, "%s is stored in an unknown field", types.ExprString(expr))
+			}
+			return p.addSlot(field)
+		}
+		return p.addSlot(t.Field(exprIndex(lit.Elts, expr)))
+	case *types.Map, *types.Slice, *types.Array:
+		if err := p.addLit(pkg, lit); err != nil {
+			return err
+		}
+		return p.flow(pkg, lit, parents)
+	}
+	return p.errorf(pkg, expr, "%s is stored in a composite literal of type %s", types.ExprString(expr), pkg.TypesInfo.TypeOf(lit))
+}
+
+// dest adds the slot assigned by the left hand side lhs.
+func (p *valuePlanner) dest(pkg *packages.Package, lhs ast.Expr) error {
+	switch e := ast.Unparen(lhs).(type) {
+	case *ast.Ident:
+		if e.Name == "_" {
+			return nil
+		}
+		if v, ok := pkg.TypesInfo.ObjectOf(e).(*types.Var); ok {
+			return p.addSlot(v)
+		}
+	case *ast.SelectorExpr:
+		if v, ok := pkg.TypesInfo.ObjectOf(e.Sel).(*types.Var); ok {
+			return p.addSlot(v)
+		}
+	case *ast.IndexExpr:
+		return p.dest(pkg, e.X)
+	}
+	return p.errorf(pkg, lhs, "%s cannot hold a function with an error result", types.ExprString(lhs))
+}
+
+// source adds the value expr stored into a planned slot.
+func (p *valuePlanner) source(pkg *packages.Package, expr ast.Expr) error {
+	info := pkg.TypesInfo
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.FuncLit:
+		if !p.nodes[e] {
+			p.nodes[e] = true
+			p.plan.Lits = append(p.plan.Lits, e)
+		}
+		return nil
+
+	case *ast.CompositeLit:
+		return p.addLit(pkg, e)
+
+	case *ast.CallExpr:
+		if id, ok := ast.Unparen(e.Fun).(*ast.Ident); ok {
+			if b, ok := info.Uses[id].(*types.Builtin); ok {
+				switch b.Name() {
+				case "make":
+					return p.addTypeExpr(pkg, e.Args[0])
+				case "append":
+					for _, arg := range e.Args {
+						if err := p.source(pkg, arg); err != nil {
+							return err
+						}
+					}
+					return nil
+				}
+			}
+		}
+
+	case *ast.IndexExpr:
+		// An element of a planned container, or an instance of a generic function.
+		return p.source(pkg, e.X)
+
+	case *ast.IndexListExpr:
+		return p.source(pkg, e.X)
+
+	case *ast.Ident, *ast.SelectorExpr:
+		id, ok := e.(*ast.Ident)
+		if !ok {
+			id = e.(*ast.SelectorExpr).Sel
+		}
+		switch obj := info.ObjectOf(id).(type) {
+		case *types.Nil:
+			return nil
+		case *types.Func:
+			return p.addFunc(obj)
+		case *types.Var:
+			return p.addSlot(obj)
+		}
+	}
+	return p.errorf(pkg, expr, "%s cannot gain an error result", types.ExprString(expr))
+}
+
+// addFunc plans the error result of the declared function fn.
+func (p *valuePlanner) addFunc(fn *types.Func) error {
+	fn = fn.Origin()
+	if p.seen[fn.Pos()] {
+		return nil
+	}
+	p.seen[fn.Pos()] = true
+	if !declaredIn(p.pkgs, fn.Pos()) {
+		return fmt.Errorf("function %s is declared outside the loaded packages", fn.FullName())
+	}
+	p.plan.Funcs = append(p.plan.Funcs, fn)
+	p.queue = append(p.queue, fn)
+	return nil
+}
+
+// addLit plans the type of the map, slice or array literal lit and adds its elements.
+func (p *valuePlanner) addLit(pkg *packages.Package, lit *ast.CompositeLit) error {
+	if p.nodes[lit] {
+		return nil
+	}
+	p.nodes[lit] = true
+	if lit.Type == nil {
+		return p.errorf(pkg, lit, "the composite literal has an elided type")
+	}
+	if err := p.addTypeExpr(pkg, lit.Type); err != nil {
+		return err
+	}
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			elt = kv.Value
+		}
+		if err := p.source(pkg, elt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addTypeExpr plans the function type in expr, a function type or a map, slice or array of them.
+func (p *valuePlanner) addTypeExpr(pkg *packages.Package, expr ast.Expr) error {
+	ft := funcTypeIn(expr)
+	if ft == nil {
+		return p.errorf(pkg, expr, "the type %s is not a function type literal", types.ExprString(expr))
+	}
+	if !p.nodes[ft] {
+		p.nodes[ft] = true
+		p.plan.TypeExprs = append(p.plan.TypeExprs, ft)
+	}
+	return nil
+}
+
+// addSlot plans the variable, struct field or parameter v: its declared type, the values it is
+// initialized with and (for parameters) the arguments passed to it.
+func (p *valuePlanner) addSlot(v *types.Var) error {
+	v = v.Origin()
+	if p.seen[v.Pos()] {
+		return nil
+	}
+	p.seen[v.Pos()] = true
+	pkg, file := p.fileAt(v.Pos())
+	if file == nil {
+		return fmt.Errorf("%s of package %s is declared outside the loaded packages", v.Name(), v.Pkg().Path())
+	}
+	p.plan.Slots = append(p.plan.Slots, v)
+	p.queue = append(p.queue, v)
+
+	path, _ := astutil.PathEnclosingInterval(file, v.Pos(), v.Pos())
+	for i, n := range path {
+		switch decl := n.(type) {
+		case *ast.Field:
+			if err := p.addTypeExpr(pkg, decl.Type); err != nil {
+				return err
+			}
+			if err := p.addNames(pkg, decl.Names); err != nil {
+				return err
+			}
+			return p.arguments(pkg, v, decl, path[i+1:])
+
+		case *ast.ValueSpec:
+			if decl.Type != nil {
+				if err := p.addTypeExpr(pkg, decl.Type); err != nil {
+					return err
+				}
+				if err := p.addNames(pkg, decl.Names); err != nil {
+					return err
+				}
+			}
+			for j, name := range decl.Names {
+				if name.Pos() == v.Pos() && len(decl.Values) == len(decl.Names) {
+					return p.source(pkg, decl.Values[j])
+				}
+			}
+			if len(decl.Values) > 0 {
+				return p.errorf(pkg, decl, "%s is initialized with a value of unknown origin", v.Name())
+			}
+			return nil
+
+		case *ast.AssignStmt:
+			for j, lhs := range decl.Lhs {
+				if lhs.Pos() == v.Pos() && len(decl.Lhs) == len(decl.Rhs) {
+					return p.source(pkg, decl.Rhs[j])
+				}
+			}
+			return p.errorf(pkg, decl, "%s is initialized with a value of unknown origin", v.Name())
+
+		case *ast.RangeStmt:
+			return p.source(pkg, decl.X)
+		}
+	}
+	return fmt.Errorf("declaration of %s not found", v.Name())
+}
+
+// addNames adds the other variables declared with the same type expression.
+func (p *valuePlanner) addNames(pkg *packages.Package, names []*ast.Ident) error {
+	for _, name := range names {
+		if v, ok := pkg.TypesInfo.Defs[name].(*types.Var); ok {
+			if err := p.addSlot(v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// arguments adds the arguments passed to the parameter v declared by field, whose enclosing nodes
+// are parents. Struct fields have no arguments.
+func (p *valuePlanner) arguments(pkg *packages.Package, v *types.Var, field *ast.Field, parents []ast.Node) error {
+	if len(parents) < 2 {
+		return nil
+	}
+	list, ok := parents[0].(*ast.FieldList)
+	if !ok {
+		return nil
+	}
+	ftype, ok := parents[1].(*ast.FuncType)
+	if !ok {
+		return nil
+	}
+	if ftype.Params != list {
+		return fmt.Errorf("%s is a result of a function type", v.Name())
+	}
+	var decl *ast.FuncDecl
+	if len(parents) > 2 {
+		decl, _ = parents[2].(*ast.FuncDecl)
+	}
+	if decl == nil || decl.Type != ftype {
+		return p.errorf(pkg, field, "%s is a parameter of a function type", v.Name())
+	}
+	fn, ok := pkg.TypesInfo.Defs[decl.Name].(*types.Func)
+	if !ok {
+		return fmt.Errorf("declaration of %s not found", decl.Name.Name)
+	}
+	sig := fn.Type().(*types.Signature)
+	k := -1
+	for j := 0; j < sig.Params().Len(); j++ {
+		if sig.Params().At(j).Pos() == v.Pos() {
+			k = j
+		}
+	}
+	if k < 0 {
+		return fmt.Errorf("parameter %s not found", v.Name())
+	}
+	variadic := sig.Variadic() && k == sig.Params().Len()-1
+
+	for _, use := range p.uses(fn) {
+		expr := use.path[0].(ast.Expr)
+		parents := use.path[1:]
+		if sel, ok := parents[0].(*ast.SelectorExpr); ok && sel.Sel == expr {
+			expr, parents = sel, parents[1:]
+		}
+		for {
+			if _, ok := parents[0].(*ast.IndexExpr); ok {
+				expr, parents = parents[0].(ast.Expr), parents[1:]
+			} else if _, ok := parents[0].(*ast.IndexListExpr); ok {
+				expr, parents = parents[0].(ast.Expr), parents[1:]
+			} else if paren, ok := parents[0].(*ast.ParenExpr); ok {
+				expr, parents = paren, parents[1:]
+			} else {
+				break
+			}
+		}
+		call, ok := parents[0].(*ast.CallExpr)
+		if !ok || call.Fun != expr {
+			return p.errorf(use.pkg, expr, "%s has a function parameter and is used as a value", types.ExprString(expr))
+		}
+		for j, arg := range call.Args {
+			if j == k || (variadic && j > k) {
+				if err := p.source(use.pkg, arg); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// fileAt returns the package and file whose syntax contains pos.
+func (p *valuePlanner) fileAt(pos token.Pos) (*packages.Package, *ast.File) {
+	for _, pkg := range p.pkgs {
+		if f := syntaxFile(pkg, pos); f != nil {
+			return pkg, f
+		}
+	}
+	return nil, nil
+}
+
+// errorf returns an error at the position of node.
+func (p *valuePlanner) errorf(pkg *packages.Package, node ast.Node, format string, args ...any) error {
+	return fmt.Errorf("%s: %s", pkg.Fset.Position(node.Pos()), fmt.Sprintf(format, args...))
+}
+
+// syncCallbacks are the functions calling their function arguments before they return, without
+// a way to return the errors of the callbacks.
+var syncCallbacks = map[string]bool{
+	"(*sync.Once).Do":         true,
+	"(*sync.Map).Range":       true,
+	"sort.Search":             true,
+	"sort.Slice":              true,
+	"sort.SliceStable":        true,
+	"slices.ContainsFunc":     true,
+	"slices.DeleteFunc":       true,
+	"slices.IndexFunc":        true,
+	"slices.SortFunc":         true,
+	"slices.SortStableFunc":   true,
+	"strings.FieldsFunc":      true,
+	"strings.IndexFunc":       true,
+	"strings.Map":             true,
+	"strings.TrimFunc":        true,
+	"bytes.FieldsFunc":        true,
+	"bytes.IndexFunc":         true,
+	"bytes.Map":               true,
+	"maps.DeleteFunc":         true,
+	"slices.CompactFunc":      true,
+	"slices.EqualFunc":        true,
+	"slices.BinarySearchFunc": true,
+}
+
+// onceCallbacks are the synchronous callbacks (see syncCallbacks) calling their function argument at
+// most once.
+var onceCallbacks = map[string]bool{
+	"(*sync.Once).Do": true,
+}
+
+// CallsOnce reports whether call, returned by SyncCallbackCall, calls its function argument at most
+// once, so that the literal can return on the first error. Other functions may call the literal
+// again after an error.
+//
+// info: The type information of the package.
+// call: The call passing the literal.
+func CallsOnce(info *types.Info, call *ast.CallExpr) bool {
+	fn := calledFunc(info, call)
+	return fn != nil && onceCallbacks[fn.Origin().FullName()]
+}
+
+// SyncCallbackCall returns the call passing lit to a function that calls it before returning but
+// cannot return its error (e.g. sync.Once.Do or sort.Slice), and the statement containing the call.
+// Errors of such literals can be stored in a variable declared before the statement and checked
+// after it.
+//
+// info: The type information of the package.
+// file: The file containing lit.
+// lit: The function literal.
+//
+// Returns nil if lit is not passed to such a function, or the statement is not an expression
+// statement, assignment or declaration in a statement list.
+func SyncCallbackCall(info *types.Info, file *ast.File, lit *ast.FuncLit) (*ast.CallExpr, ast.Stmt) {
+	path, _ := astutil.PathEnclosingInterval(file, lit.Pos(), lit.End())
+	var expr ast.Node = lit
+	k := 1
+	for ; k < len(path); k++ {
+		if _, ok := path[k].(*ast.ParenExpr); !ok {
+			break
+		}
+		expr = path[k]
+	}
+	if k >= len(path) {
+		return nil, nil
+	}
+	call, ok := path[k].(*ast.CallExpr)
+	if !ok || call.Fun == expr {
+		return nil, nil
+	}
+	fn := calledFunc(info, call)
+	if fn == nil || !syncCallbacks[fn.Origin().FullName()] {
+		return nil, nil
+	}
+	for j := k + 1; j+1 < len(path); j++ {
+		stmt, ok := path[j].(ast.Stmt)
+		if !ok {
+			continue
+		}
+		// The error is checked after the statement, so the statement must end with the call.
+		switch stmt.(type) {
+		case *ast.ExprStmt, *ast.AssignStmt, *ast.DeclStmt:
+		default:
+			return nil, nil
+		}
+		switch path[j+1].(type) {
+		case *ast.BlockStmt, *ast.CaseClause, *ast.CommClause:
+			return call, stmt
+		}
+		return nil, nil
+	}
+	return nil, nil
+}
+
+// syntaxFile returns the file of pkg containing pos, or nil.
+func syntaxFile(pkg *packages.Package, pos token.Pos) *ast.File {
+	for _, f := range pkg.Syntax {
+		if f.Pos() <= pos && pos < f.End() {
+			return f
+		}
+	}
+	return nil
+}
+
+// funcTypeIn returns the function type literal of expr, a function type or a (nested) map, slice
+// or array of them, or nil if the function type is named.
+func funcTypeIn(expr ast.Expr) *ast.FuncType {
+	for {
+		switch t := expr.(type) {
+		case *ast.FuncType:
+			return t
+		case *ast.ParenExpr:
+			expr = t.X
+		case *ast.ArrayType:
+			expr = t.Elt
+		case *ast.MapType:
+			expr = t.Value
+		case *ast.Ellipsis:
+			expr = t.Elt
+		default:
+			return nil
+		}
+	}
+}
+
+// calledFunc returns the declared function or method called by call, or nil for calls of function
+// values.
+func calledFunc(info *types.Info, call *ast.CallExpr) *types.Func {
+	fun := ast.Unparen(call.Fun)
+	switch e := fun.(type) {
+	case *ast.IndexExpr:
+		fun = e.X
+	case *ast.IndexListExpr:
+		fun = e.X
+	}
+	var id *ast.Ident
+	switch e := fun.(type) {
+	case *ast.Ident:
+		id = e
+	case *ast.SelectorExpr:
+		id = e.Sel
+	default:
+		return nil
+	}
+	fn, _ := info.Uses[id].(*types.Func)
+	return fn
+}
+
+// exprIndex returns the index of expr in list, or -1.
+func exprIndex(list []ast.Expr, expr ast.Expr) int {
+	for i, e := range list {
+		if e == expr {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,233 @@
+package analysis
+
+import (
+	"go/ast"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/loader"
+	"golang.org/x/tools/go/packages"
+)
+
+// loadFuncValues loads src as the package "values" and returns it with the function named name.
+func loadFuncValues(t *testing.T, src, name string) (*packages.Package, *types.Func) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module values\ngo 1.22\n"), 0644)
+	_ = os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte(src), 0644)
+	pkgs, err := loader.LoadPackages([]string{"."}, tmpDir)
+	if err != nil || len(pkgs) == 0 {
+		t.Fatalf("load: %v", err)
+	}
+	fn, ok := pkgs[0].Types.Scope().Lookup(name).(*types.Func)
+	if !ok {
+		t.Fatalf("function %s not found", name)
+	}
+	return pkgs[0], fn
+}
+
+// TestPlanFuncValues verifies that the variables, fields and parameters storing a function are
+// followed to the other functions and literals stored in them.
+func TestPlanFuncValues(t *testing.T) {
+	pkg, fn := loadFuncValues(t, `package values
+
+func load() {}
+func noop() {}
+func other() {}
+
+type Task struct {
+	Run func()
+}
+
+var handlers = map[string]func(){"noop": noop}
+
+func retry(f func()) { f() }
+
+func use(name string) {
+	cb := load
+	cb()
+	t := Task{Run: load}
+	t.Run()
+	handlers["load"] = load
+	handlers["lit"] = func() {}
+	retry(load)
+	retry(other)
+	if cb != nil {
+		println(len(handlers))
+	}
+}
+`, "load")
+
+	plan, err := PlanFuncValues(fn, []*packages.Package{pkg})
+	if err != nil {
+		t.Fatalf("PlanFuncValues: %v", err)
+	}
+	var slots, funcs []string
+	for _, v := range plan.Slots {
+		slots = append(slots, v.Name())
+	}
+	for _, f := range plan.Funcs {
+		funcs = append(funcs, f.Name())
+	}
+	sort.Strings(slots)
+	sort.Strings(funcs)
+	if got := strings.Join(slots, ","); got != "Run,cb,f,handlers" {
+		t.Errorf("slots = %s, want Run,cb,f,handlers", got)
+	}
+	if got := strings.Join(funcs, ","); got != "noop,other" {
+		t.Errorf("funcs = %s, want noop,other", got)
+	}
+	// Task.Run, the map literal and the parameter of retry.
+	if len(plan.TypeExprs) != 3 {
+		t.Errorf("expected 3 type expressions, got %d", len(plan.TypeExprs))
+	}
+	if len(plan.Lits) != 1 {
+		t.Errorf("expected 1 literal, got %d", len(plan.Lits))
+	}
+}
+
+// TestPlanFuncValues_Unsupported verifies that values reaching types that cannot change are
+// reported.
+func TestPlanFuncValues_Unsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"External", `time.AfterFunc(time.Second, load)`, "load is passed to time.AfterFunc, which is declared outside the loaded packages"},
+		{"NamedType", `var h Hook = load; h()`, "the type Hook is not a function type literal"},
+		{"Conversion", `_ = Hook(load)`, "load is converted to Hook"},
+		{"Returned", `_ = func() func() { return load }`, "load is returned"},
+		{"Address", `p := &handler; *p = load`, "*p cannot hold a function with an error result"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkg, fn := loadFuncValues(t, `package values
+
+import "time"
+
+type Hook func()
+
+var handler func()
+
+func load() {}
+
+func use() {
+	`+tt.body+`
+	_ = time.Now
+}
+`, "load")
+			_, err := PlanFuncValues(fn, []*packages.Package{pkg})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestPlanFuncLit verifies that a literal is followed to the variables and parameters storing it.
+func TestPlanFuncLit(t *testing.T) {
+	pkg, fn := loadFuncValues(t, `package values
+
+func retry(f func()) { f() }
+
+func use() {
+	clean := func() {}
+	retry(clean)
+	clean()
+}
+`, "use")
+
+	lit := findFuncLit(t, pkg, fn)
+	plan, err := PlanFuncLit(lit, []*packages.Package{pkg})
+	if err != nil {
+		t.Fatalf("PlanFuncLit: %v", err)
+	}
+	var slots []string
+	for _, v := range plan.Slots {
+		slots = append(slots, v.Name())
+	}
+	sort.Strings(slots)
+	if got := strings.Join(slots, ","); got != "clean,f" {
+		t.Errorf("slots = %s, want clean,f", got)
+	}
+	if len(plan.Lits) != 1 || plan.Lits[0] != lit {
+		t.Errorf("expected the literal in the plan, got %d literals", len(plan.Lits))
+	}
+	// The parameter of retry.
+	if len(plan.TypeExprs) != 1 {
+		t.Errorf("expected 1 type expression, got %d", len(plan.TypeExprs))
+	}
+}
+
+// TestSyncCallbackCall verifies that literals passed to functions calling them synchronously are
+// recognized with their statement.
+func TestSyncCallbackCall(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+		once bool
+	}{
+		{"Once", `once.Do(func() {})`, true, true},
+		{"Sort", `sort.Slice(names, (func(i, j int) bool { return i < j }))`, true, false},
+		{"Handler", `http.HandleFunc("/", func(http.ResponseWriter, *http.Request) {})`, false, false},
+		{"Init", `if sort.Search(3, func(int) bool { return true }) > 0 {}`, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkg, fn := loadFuncValues(t, `package values
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+)
+
+var once sync.Once
+
+func use(names []string) {
+	`+tt.body+`
+	_, _ = http.Get, sort.Ints
+}
+`, "use")
+			call, stmt := SyncCallbackCall(pkg.TypesInfo, pkg.Syntax[0], findFuncLit(t, pkg, fn))
+			if (call != nil) != tt.want {
+				t.Fatalf("call = %v, want found = %v", call, tt.want)
+			}
+			if call != nil {
+				if es, ok := stmt.(*ast.ExprStmt); !ok || es.X != call {
+					t.Errorf("statement = %T, want the expression statement of the call", stmt)
+				}
+				if got := CallsOnce(pkg.TypesInfo, call); got != tt.once {
+					t.Errorf("CallsOnce = %v, want %v", got, tt.once)
+				}
+			}
+		})
+	}
+}
+
+// findFuncLit returns the first function literal in the declaration of fn.
+func findFuncLit(t *testing.T, pkg *packages.Package, fn *types.Func) *ast.FuncLit {
+	t.Helper()
+	var lit *ast.FuncLit
+	for _, f := range pkg.Syntax {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if d, ok := n.(*ast.FuncDecl); ok && pkg.TypesInfo.Defs[d.Name] != fn {
+				return false
+			}
+			if l, ok := n.(*ast.FuncLit); ok && lit == nil {
+				lit = l
+			}
+			return lit == nil
+		})
+	}
+	if lit == nil {
+		t.Fatal("function literal not found")
+	}
+	return lit
+}
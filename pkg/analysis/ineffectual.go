@@ -0,0 +1,299 @@
+package analysis
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/filter"
+	"golang.org/x/tools/go/cfg"
+	"golang.org/x/tools/go/packages"
+)
+
+// noReturnFuncs are the functions after which control never continues.
+var noReturnFuncs = map[string]bool{
+	"os.Exit":     true,
+	"log.Fatal":   true,
+	"log.Fatalf":  true,
+	"log.Fatalln": true,
+	"log.Panic":   true,
+	"log.Panicf":  true,
+	"log.Panicln": true,
+}
+
+// varSet is a set of local variables.
+type varSet map[*types.Var]bool
+
+// detectIneffectual finds errors that are assigned to a local variable but never checked: the
+// variable is overwritten before it is read (e.g. "x, err := f(); y, err := g()") or not read at
+// all after the assignment.
+//
+// Each function body is analyzed with a liveness pass over its control flow graph. Only local
+// variables of type error are tracked; named results (which are read by every return),
+// parameters and variables that are captured by function literals or whose address is taken are
+// assumed to be read. Only assignments that are elements of a statement list are reported, so that
+// the check can be inserted after them.
+//
+// pkg: The package containing file.
+// file: The file to analyze.
+// cmap: The comment map of file, for the ignore directive.
+// flt: The filter rules to exclude specific files or symbols.
+// debug: If true, prints verbose reasons why assignments are ignored.
+//
+// Returns the points, with ErrIdent set to the variable receiving the error.
+func detectIneffectual(pkg *packages.Package, file *ast.File, cmap ast.CommentMap, flt *filter.Filter, debug bool) []InjectionPoint {
+	if pkg.TypesInfo == nil {
+		return nil
+	}
+	var points []InjectionPoint
+	ast.Inspect(file, func(n ast.Node) bool {
+		var body *ast.BlockStmt
+		switch fn := n.(type) {
+		case *ast.FuncDecl:
+			body = fn.Body
+		case *ast.FuncLit:
+			body = fn.Body
+		}
+		if body == nil {
+			return true
+		}
+		for _, u := range ineffectualAssigns(pkg.TypesInfo, body) {
+			if shouldInclude(pkg, file, u.call, u.assign, cmap, flt, debug) {
+				points = append(points, InjectionPoint{
+					Pkg:      pkg,
+					File:     file,
+					Call:     u.call,
+					Assign:   u.assign,
+					Stmt:     u.assign,
+					Pos:      u.call.Pos(),
+					ErrIdent: u.ident,
+				})
+			}
+		}
+		return true
+	})
+	return points
+}
+
+// uncheckedAssign is an assignment of the error result of call to ident that is never read.
+type uncheckedAssign struct {
+	assign *ast.AssignStmt
+	call   *ast.CallExpr
+	ident  *ast.Ident
+}
+
+// ineffectualAssigns returns the unchecked error assignments of a function body, in source order.
+// Nested function literals are analyzed separately.
+func ineffectualAssigns(info *types.Info, body *ast.BlockStmt) []uncheckedAssign {
+	tracked := trackedErrVars(info, body)
+	if len(tracked) == 0 {
+		return nil
+	}
+
+	// Statements the check can be inserted after.
+	inList := make(map[ast.Stmt]bool)
+	ast.Inspect(body, func(n ast.Node) bool {
+		var list []ast.Stmt
+		switch s := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.BlockStmt:
+			list = s.List
+		case *ast.CaseClause:
+			list = s.Body
+		case *ast.CommClause:
+			list = s.Body
+		}
+		for _, stmt := range list {
+			inList[stmt] = true
+		}
+		return true
+	})
+
+	g := cfg.New(body, func(call *ast.CallExpr) bool { return mayReturn(info, call) })
+
+	// Backward liveness: live[b] holds the variables read before being written on some path
+	// starting at the end of block b.
+	liveOut := make([]varSet, len(g.Blocks))
+	liveIn := make([]varSet, len(g.Blocks))
+	for changed := true; changed; {
+		changed = false
+		for k := len(g.Blocks) - 1; k >= 0; k-- {
+			b := g.Blocks[k]
+			out := make(varSet)
+			for _, succ := range b.Succs {
+				for v := range liveIn[succ.Index] {
+					out[v] = true
+				}
+			}
+			in := make(varSet, len(out))
+			for v := range out {
+				in[v] = true
+			}
+			for n := len(b.Nodes) - 1; n >= 0; n-- {
+				transfer(info, b.Nodes[n], tracked, in)
+			}
+			liveOut[k] = out
+			if len(in) != len(liveIn[k]) {
+				changed = true
+			}
+			liveIn[k] = in
+		}
+	}
+
+	var found []uncheckedAssign
+	for k, b := range g.Blocks {
+		if !b.Live {
+			continue
+		}
+		live := liveOut[k]
+		for n := len(b.Nodes) - 1; n >= 0; n-- {
+			node := b.Nodes[n]
+			if assign, ok := node.(*ast.AssignStmt); ok && inList[assign] {
+				for _, u := range errorAssigns(info, assign) {
+					if v, ok := info.ObjectOf(u.ident).(*types.Var); ok && tracked[v] && !live[v] {
+						found = append(found, u)
+					}
+				}
+			}
+			transfer(info, node, tracked, live)
+		}
+	}
+
+	// Blocks are visited in creation order and nodes backwards; restore the source order.
+	sort.Slice(found, func(a, b int) bool { return found[a].call.Pos() < found[b].call.Pos() })
+	return found
+}
+
+// trackedErrVars returns the error variables declared in body (outside function literals) whose
+// reads are all visible to the liveness pass.
+func trackedErrVars(info *types.Info, body *ast.BlockStmt) varSet {
+	tracked := make(varSet)
+	ast.Inspect(body, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FuncLit); ok {
+			return false
+		}
+		if id, ok := n.(*ast.Ident); ok && id.Name != "_" {
+			if v, ok := info.Defs[id].(*types.Var); ok && isErrorType(v.Type()) {
+				tracked[v] = true
+			}
+		}
+		return true
+	})
+
+	// Variables read by closures or through pointers may be read anywhere.
+	var escape func(n ast.Node, inLit bool) bool
+	escape = func(n ast.Node, inLit bool) bool {
+		switch e := n.(type) {
+		case *ast.FuncLit:
+			ast.Inspect(e.Body, func(m ast.Node) bool { return escape(m, true) })
+			return false
+		case *ast.UnaryExpr:
+			if id, ok := ast.Unparen(e.X).(*ast.Ident); ok && e.Op == token.AND {
+				if v, ok := info.ObjectOf(id).(*types.Var); ok {
+					delete(tracked, v)
+				}
+			}
+		case *ast.Ident:
+			if inLit {
+				if v, ok := info.ObjectOf(e).(*types.Var); ok {
+					delete(tracked, v)
+				}
+			}
+		}
+		return true
+	}
+	ast.Inspect(body, func(n ast.Node) bool { return escape(n, false) })
+	return tracked
+}
+
+// transfer updates live from after node to before node: the tracked variables written by node are
+// removed, then those read by it are added.
+func transfer(info *types.Info, node ast.Node, tracked, live varSet) {
+	var writes []*ast.Ident
+	var reads []ast.Node
+	switch s := node.(type) {
+	case *ast.AssignStmt:
+		for _, lhs := range s.Lhs {
+			id, ok := ast.Unparen(lhs).(*ast.Ident)
+			if !ok {
+				reads = append(reads, lhs)
+				continue
+			}
+			if s.Tok != token.ASSIGN && s.Tok != token.DEFINE {
+				reads = append(reads, id) // e.g. "x += y"
+			}
+			writes = append(writes, id)
+		}
+		for _, rhs := range s.Rhs {
+			reads = append(reads, rhs)
+		}
+	case *ast.ValueSpec:
+		writes = s.Names
+		for _, value := range s.Values {
+			reads = append(reads, value)
+		}
+	default:
+		reads = []ast.Node{node}
+	}
+
+	for _, id := range writes {
+		if v, ok := info.ObjectOf(id).(*types.Var); ok && tracked[v] {
+			delete(live, v)
+		}
+	}
+	for _, r := range reads {
+		ast.Inspect(r, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				if v, ok := info.Uses[id].(*types.Var); ok && tracked[v] {
+					live[v] = true
+				}
+			}
+			return true
+		})
+	}
+}
+
+// errorAssigns returns the error results of calls stored by assign into a variable, pairing the
+// left and right hand sides like detectPackage.
+func errorAssigns(info *types.Info, assign *ast.AssignStmt) []uncheckedAssign {
+	if assign.Tok != token.ASSIGN && assign.Tok != token.DEFINE {
+		return nil
+	}
+	var out []uncheckedAssign
+	for i, rhs := range assign.Rhs {
+		call, ok := rhs.(*ast.CallExpr)
+		if !ok {
+			continue
+		}
+		isErr, errorIndex := isErrorReturningCall(info, call)
+		if !isErr {
+			continue
+		}
+		var lhs ast.Expr
+		if len(assign.Lhs) == len(assign.Rhs) {
+			lhs = assign.Lhs[i]
+		} else if errorIndex < len(assign.Lhs) {
+			lhs = assign.Lhs[errorIndex]
+		}
+		if id, ok := lhs.(*ast.Ident); ok && id.Name != "_" {
+			out = append(out, uncheckedAssign{assign: assign, call: call, ident: id})
+		}
+	}
+	return out
+}
+
+// mayReturn reports whether control may continue after call, i.e. it is not a call to panic,
+// os.Exit or the fatal functions of package log.
+func mayReturn(info *types.Info, call *ast.CallExpr) bool {
+	if id, ok := ast.Unparen(call.Fun).(*ast.Ident); ok {
+		if _, ok := info.Uses[id].(*types.Builtin); ok && id.Name == "panic" {
+			return false
+		}
+	}
+	if fn := getCalledFunction(info, call); fn != nil && fn.Pkg() != nil {
+		return !noReturnFuncs[fn.FullName()]
+	}
+	return true
+}
@@ -0,0 +1,172 @@
+package analysis
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/loader"
+)
+
+// TestDetect_Ineffectual verifies that errors assigned to variables that are overwritten or never
+// read are detected, while checked, returned and escaping variables are not.
+func TestDetect_Ineffectual(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := []byte(`package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+func pair() (int, error) { return 0, nil }
+func fail() error        { return nil }
+
+func overwritten() (int, error) {
+	x, err := pair() // reported
+	y, err := pair()
+	if err != nil {
+		return 0, err
+	}
+	return x + y, nil
+}
+
+func neverRead() {
+	err := fail()
+	fmt.Println(err)
+	err = fail() // reported
+}
+
+func checked() error {
+	err := fail()
+	if err != nil {
+		return err
+	}
+	err = fail()
+	return err
+}
+
+func loop() error {
+	var err error
+	for i := 0; i < 3; i++ {
+		err = fail()
+	}
+	return err
+}
+
+func branches(ok bool) error {
+	err := fail()
+	if ok {
+		err = fail() // reported: the first error is read on the other path only
+		err = fail()
+	}
+	return err
+}
+
+func captured() {
+	err := fail()
+	defer func() { fmt.Println(err) }()
+}
+
+func pointer() {
+	err := fail()
+	_ = errors.Unwrap(*(&err))
+}
+
+func named() (err error) {
+	err = fail()
+	return
+}
+
+func fatal() {
+	err := fail()
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = fail() // reported
+	log.Fatal("stop")
+	fmt.Println(err)
+}
+
+func ignored() {
+	err := fail()
+	fmt.Println(err)
+	err = fail() // auto-err:ignore
+}
+
+func main() {}
+`)
+	_ = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module ineffectual\ngo 1.22\n"), 0644)
+	_ = os.WriteFile(filepath.Join(tmpDir, "main.go"), src, 0644)
+
+	pkgs, err := loader.LoadPackages([]string{"."}, tmpDir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	points, err := Detect(pkgs, nil, false)
+	if err != nil {
+		t.Fatalf("Detect error: %v", err)
+	}
+
+	var want []int
+	for i, line := range strings.Split(string(src), "\n") {
+		if strings.HasSuffix(line, "// reported") || strings.Contains(line, "// reported:") {
+			want = append(want, i+1)
+		}
+	}
+	var got []int
+	for _, p := range points {
+		if p.ErrIdent == nil {
+			continue
+		}
+		line := pkgs[0].Fset.Position(p.Pos).Line
+		if p.Stmt != p.Assign || p.Assign == nil {
+			t.Errorf("point at line %d: Stmt must be the assignment", line)
+		}
+		if p.ErrIdent.Name != "err" {
+			t.Errorf("point at line %d: ErrIdent = %s, want err", line, p.ErrIdent.Name)
+		}
+		got = append(got, line)
+	}
+	sort.Ints(got)
+	if len(want) != 4 || fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("reported lines %v, want %v", got, want)
+	}
+}
+
+// TestInjectionPoint_Message verifies the diagnostic of unchecked assignments.
+func TestInjectionPoint_Message(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := []byte(`package main
+func fail() error { return nil }
+func main() {
+	err := fail()
+	println(err)
+	err = fail()
+	fail()
+}`)
+	_ = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module msg\ngo 1.22\n"), 0644)
+	_ = os.WriteFile(filepath.Join(tmpDir, "main.go"), src, 0644)
+
+	pkgs, _ := loader.LoadPackages([]string{"."}, tmpDir)
+	points, err := Detect(pkgs, nil, false)
+	if err != nil {
+		t.Fatalf("Detect error: %v", err)
+	}
+	var msgs []string
+	for _, p := range points {
+		msgs = append(msgs, p.Message())
+	}
+	sort.Strings(msgs)
+	want := []string{
+		"error returned by msg.fail is assigned to err but never checked",
+		"error returned by msg.fail is not handled",
+	}
+	if len(msgs) != len(want) || msgs[0] != want[0] || msgs[1] != want[1] {
+		t.Errorf("messages = %q, want %q", msgs, want)
+	}
+}
@@ -0,0 +1,96 @@
+package analysis
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// FindLogger looks for a *slog.Logger available at pos, for use by the slog handling strategies.
+//
+// The candidates are, in order:
+// 1. A field of the receiver of the enclosing method (e.g. "s.logger").
+// 2. A variable visible at pos, from the innermost scope outwards: locals, parameters and
+// package-level variables (e.g. "logger").
+//
+// pkg: The package containing file, with type information.
+// file: The file containing pos.
+// pos: The position where the logger is used.
+//
+// Returns the source of the logger expression, or "" if none is available, in which case the
+// functions of the slog package (the default logger) should be used.
+func FindLogger(pkg *packages.Package, file *ast.File, pos token.Pos) string {
+	if pkg == nil || pkg.Types == nil || pkg.TypesInfo == nil || file == nil {
+		return ""
+	}
+	info := pkg.TypesInfo
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+
+	// 1. Receiver fields.
+	for _, n := range path {
+		decl, ok := n.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		if decl.Recv == nil || len(decl.Recv.List) == 0 || len(decl.Recv.List[0].Names) == 0 {
+			break
+		}
+		recv := decl.Recv.List[0].Names[0]
+		obj := info.ObjectOf(recv)
+		if recv.Name == "_" || obj == nil {
+			break
+		}
+		// The receiver must not be shadowed at pos.
+		if scope := pkg.Types.Scope().Innermost(pos); scope != nil {
+			if _, found := scope.LookupParent(recv.Name, pos); found != obj {
+				break
+			}
+		}
+		t := obj.Type()
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		if st, ok := t.Underlying().(*types.Struct); ok {
+			for k := 0; k < st.NumFields(); k++ {
+				if f := st.Field(k); IsSlogLogger(f.Type()) {
+					return recv.Name + "." + f.Name()
+				}
+			}
+		}
+		break
+	}
+
+	// 2. Visible variables.
+	inner := pkg.Types.Scope().Innermost(pos)
+	for scope := inner; scope != nil && scope != types.Universe; scope = scope.Parent() {
+		names := scope.Names()
+		sort.Strings(names)
+		for _, name := range names {
+			v, ok := scope.Lookup(name).(*types.Var)
+			if !ok || !IsSlogLogger(v.Type()) {
+				continue
+			}
+			// Locals must be declared before pos and must not be shadowed.
+			if _, found := inner.LookupParent(name, pos); found == v {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// IsSlogLogger reports whether t is slog.Logger or *slog.Logger.
+func IsSlogLogger(t types.Type) bool {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+	return named.Obj().Pkg().Path() == "log/slog" && named.Obj().Name() == "Logger"
+}
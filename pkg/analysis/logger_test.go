@@ -0,0 +1,130 @@
+package analysis
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// setupLoggerEnv type checks src, resolving standard library imports from source.
+//
+// t: Testing context.
+// src: The source code to parse.
+//
+// Returns the package wrapper and its single file.
+func setupLoggerEnv(t *testing.T, src string) (*packages.Package, *ast.File) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("parser failed: %v", err)
+	}
+	info := &types.Info{
+		Types:  make(map[ast.Expr]types.TypeAndValue),
+		Defs:   make(map[*ast.Ident]types.Object),
+		Uses:   make(map[*ast.Ident]types.Object),
+		Scopes: make(map[ast.Node]*types.Scope),
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := conf.Check("main", fset, []*ast.File{f}, info)
+	if err != nil {
+		t.Fatalf("type check failed: %v", err)
+	}
+	return &packages.Package{PkgPath: "main", Fset: fset, Types: pkg, TypesInfo: info, Syntax: []*ast.File{f}}, f
+}
+
+func TestFindLogger(t *testing.T) {
+	src := `package main
+
+import "log/slog"
+
+var logger = slog.Default()
+
+type server struct {
+	name string
+	log  *slog.Logger
+}
+
+func (s *server) close() {
+	_ = 1 // MARK recv
+}
+
+func (s *server) shadowed() {
+	{
+		s := 1
+		_ = s // MARK shadowed
+	}
+}
+
+func local() {
+	_ = 1 // MARK before
+	l := slog.Default()
+	_ = l // MARK local
+}
+
+func param(custom *slog.Logger) {
+	_ = custom // MARK param
+}
+`
+	pkg, f := setupLoggerEnv(t, src)
+
+	tests := []struct {
+		mark string
+		want string
+	}{
+		{"recv", "s.log"},
+		{"shadowed", "logger"},
+		{"before", "logger"},
+		{"local", "l"},
+		{"param", "custom"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.mark, func(t *testing.T) {
+			pos := markPos(t, pkg.Fset, f, src, "// MARK "+tc.mark)
+			if got := FindLogger(pkg, f, pos); got != tc.want {
+				t.Errorf("FindLogger() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFindLogger_None(t *testing.T) {
+	src := `package main
+
+func main() {
+	_ = 1 // MARK main
+}
+`
+	pkg, f := setupLoggerEnv(t, src)
+	if got := FindLogger(pkg, f, markPos(t, pkg.Fset, f, src, "// MARK main")); got != "" {
+		t.Errorf("FindLogger() = %q, want \"\"", got)
+	}
+	if got := FindLogger(nil, f, f.Pos()); got != "" {
+		t.Errorf("FindLogger(nil) = %q, want \"\"", got)
+	}
+}
+
+// markPos returns the position of the statement on the line holding mark.
+func markPos(t *testing.T, fset *token.FileSet, f *ast.File, src, mark string) token.Pos {
+	idx := strings.Index(src, mark)
+	if idx < 0 {
+		t.Fatalf("mark %q not found", mark)
+	}
+	line := strings.Count(src[:idx], "\n") + 1
+	var pos token.Pos
+	ast.Inspect(f, func(n ast.Node) bool {
+		if s, ok := n.(*ast.AssignStmt); ok && pos == token.NoPos && fset.Position(s.Pos()).Line == line {
+			pos = s.Pos()
+		}
+		return pos == token.NoPos
+	})
+	if pos == token.NoPos {
+		t.Fatalf("no statement on line of %q", mark)
+	}
+	return pos
+}
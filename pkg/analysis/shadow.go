@@ -0,0 +1,164 @@
+package analysis
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/filter"
+	"golang.org/x/tools/go/packages"
+)
+
+// detectShadowed finds errors assigned with ":=" to a variable that shadows a named error result
+// of the enclosing function, so that the failure never reaches the caller: the shadowing variable
+// is only compared to nil (e.g. "if err != nil { break }") while the function returns the named
+// result (e.g. by a bare return, or after a deferred errors.Join produced by RewriteDefers).
+//
+// Shadowing variables whose value is used otherwise (returned, wrapped, logged or captured) are
+// handled deliberately and not reported.
+//
+// pkg: The package containing file.
+// file: The file to analyze.
+// cmap: The comment map of file, for the ignore directive.
+// flt: The filter rules to exclude specific files or symbols.
+// debug: If true, prints verbose reasons why assignments are ignored.
+//
+// Returns the points, with Shadows set to the hidden result.
+func detectShadowed(pkg *packages.Package, file *ast.File, cmap ast.CommentMap, flt *filter.Filter, debug bool) []InjectionPoint {
+	info := pkg.TypesInfo
+	if info == nil {
+		return nil
+	}
+	var points []InjectionPoint
+	ast.Inspect(file, func(n ast.Node) bool {
+		var ftype *ast.FuncType
+		var body *ast.BlockStmt
+		switch fn := n.(type) {
+		case *ast.FuncDecl:
+			ftype, body = fn.Type, fn.Body
+		case *ast.FuncLit:
+			ftype, body = fn.Type, fn.Body
+		}
+		if body == nil {
+			return true
+		}
+		results := namedErrResults(info, ftype)
+		if len(results) == 0 {
+			return true
+		}
+		returned := returnedResults(info, body, results)
+
+		inspectBody(body, func(n ast.Node) {
+			assign, ok := n.(*ast.AssignStmt)
+			if !ok || assign.Tok != token.DEFINE {
+				return
+			}
+			for _, u := range errorAssigns(info, assign) {
+				v, ok := info.Defs[u.ident].(*types.Var)
+				if !ok || v.Parent() == nil || v.Parent().Parent() == nil {
+					continue
+				}
+				_, outer := v.Parent().Parent().LookupParent(v.Name(), v.Pos())
+				result, ok := outer.(*types.Var)
+				if !ok || !results[result] || !returned[result] || !onlyNilChecked(info, body, v) {
+					continue
+				}
+				if shouldInclude(pkg, file, u.call, assign, cmap, flt, debug) {
+					points = append(points, InjectionPoint{
+						Pkg:     pkg,
+						File:    file,
+						Call:    u.call,
+						Assign:  assign,
+						Stmt:    assign,
+						Pos:     u.call.Pos(),
+						Shadows: result,
+					})
+				}
+			}
+		})
+		return true
+	})
+	return points
+}
+
+// namedErrResults returns the named results of type error of ftype.
+func namedErrResults(info *types.Info, ftype *ast.FuncType) map[*types.Var]bool {
+	out := make(map[*types.Var]bool)
+	if ftype.Results == nil {
+		return out
+	}
+	for _, field := range ftype.Results.List {
+		for _, name := range field.Names {
+			if v, ok := info.Defs[name].(*types.Var); ok && name.Name != "_" && isErrorType(v.Type()) {
+				out[v] = true
+			}
+		}
+	}
+	return out
+}
+
+// returnedResults returns the results that reach the caller with the value they hold: by a bare
+// return, or by a return statement reading them.
+func returnedResults(info *types.Info, body *ast.BlockStmt, results map[*types.Var]bool) map[*types.Var]bool {
+	out := make(map[*types.Var]bool)
+	inspectBody(body, func(n ast.Node) {
+		ret, ok := n.(*ast.ReturnStmt)
+		if !ok {
+			return
+		}
+		if len(ret.Results) == 0 {
+			for v := range results {
+				out[v] = true
+			}
+			return
+		}
+		for _, r := range ret.Results {
+			ast.Inspect(r, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok {
+					if v, ok := info.Uses[id].(*types.Var); ok && results[v] {
+						out[v] = true
+					}
+				}
+				return true
+			})
+		}
+	})
+	return out
+}
+
+// onlyNilChecked reports whether every use of v in body (including function literals) compares it
+// to nil.
+func onlyNilChecked(info *types.Info, body *ast.BlockStmt, v *types.Var) bool {
+	checked := make(map[*ast.Ident]bool)
+	ok := true
+	ast.Inspect(body, func(n ast.Node) bool {
+		if bin, isBin := n.(*ast.BinaryExpr); isBin && (bin.Op == token.EQL || bin.Op == token.NEQ) {
+			for _, pair := range [][2]ast.Expr{{bin.X, bin.Y}, {bin.Y, bin.X}} {
+				id, isIdent := ast.Unparen(pair[0]).(*ast.Ident)
+				other, isNil := ast.Unparen(pair[1]).(*ast.Ident)
+				if isIdent && isNil && other.Name == "nil" {
+					checked[id] = true
+				}
+			}
+		}
+		if id, isIdent := n.(*ast.Ident); isIdent && info.Uses[id] == v && !checked[id] {
+			ok = false
+		}
+		return ok
+	})
+	return ok
+}
+
+// inspectBody calls fn for the nodes of body, without descending into function literals (which
+// are analyzed on their own).
+func inspectBody(body *ast.BlockStmt, fn func(ast.Node)) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FuncLit); ok {
+			return false
+		}
+		if n != nil {
+			fn(n)
+		}
+		return true
+	})
+}
@@ -0,0 +1,131 @@
+package analysis
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/loader"
+)
+
+// TestDetect_Shadowed verifies that errors hidden from a named result by a shadowing ":=" are
+// detected, while propagated, used and unreturned ones are not.
+func TestDetect_Shadowed(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := []byte(`package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+func loop(items []string) (n int, err error) {
+	for _, s := range items {
+		v, err := strconv.Atoi(s) // reported
+		if err != nil {
+			break
+		}
+		n += v
+	}
+	return
+}
+
+func deferred(name string) (err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, f.Close())
+	}()
+	if name != "" {
+		_, err := f.Stat() // reported
+		if err == nil {
+			fmt.Println("ok")
+		}
+	}
+	return err
+}
+
+func propagated(s string) (n int, err error) {
+	if s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, err
+		}
+		n = v
+	}
+	return
+}
+
+func logged(s string) (err error) {
+	if s != "" {
+		_, err := strconv.Atoi(s)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	return
+}
+
+func explicit(s string) (n int, err error) {
+	if s != "" {
+		_, err := strconv.Atoi(s)
+		if err != nil {
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func ignored(s string) (err error) {
+	if s != "" {
+		_, err := strconv.Atoi(s) // auto-err:ignore
+		if err != nil {
+			return nil
+		}
+	}
+	return
+}
+
+func main() {}
+`)
+	_ = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module shadowed\ngo 1.22\n"), 0644)
+	_ = os.WriteFile(filepath.Join(tmpDir, "main.go"), src, 0644)
+
+	pkgs, err := loader.LoadPackages([]string{"."}, tmpDir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	points, err := Detect(pkgs, nil, false)
+	if err != nil {
+		t.Fatalf("Detect error: %v", err)
+	}
+
+	var want []int
+	for i, line := range strings.Split(string(src), "\n") {
+		if strings.HasSuffix(line, "// reported") {
+			want = append(want, i+1)
+		}
+	}
+	var got []int
+	for _, p := range points {
+		if p.Shadows == nil {
+			continue
+		}
+		line := pkgs[0].Fset.Position(p.Pos).Line
+		if p.Shadows.Name() != "err" || p.Stmt != p.Assign {
+			t.Errorf("point at line %d: Shadows = %v, Stmt = %T", line, p.Shadows, p.Stmt)
+		}
+		got = append(got, line)
+	}
+	sort.Ints(got)
+	if len(want) != 2 || fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("reported lines %v, want %v", got, want)
+	}
+}
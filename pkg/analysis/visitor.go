@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/SamuelMarks/go-auto-err-handling/pkg/filter"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/parallel"
 	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/go/packages"
 )
@@ -28,13 +29,56 @@ type InjectionPoint struct {
 	Stmt ast.Stmt
 	// Pos is the position of the error return (usually the call site).
 	Pos token.Pos
+	// ErrIdent is the variable the error is assigned to when it is never checked afterwards
+	// (e.g. "err" in "x, err := f()" followed by "y, err := g()"). Nil if the error is discarded.
+	// The handling is inserted after Stmt, which is left unchanged.
+	ErrIdent *ast.Ident
+	// Shadows is the named error result hidden by the variable that Stmt (a ":=" assignment)
+	// declares for the error, when that variable is only compared to nil. Nil otherwise.
+	Shadows *types.Var
+}
+
+// Callee resolves the function symbol invoked by the injection point's call.
+//
+// Returns nil if the callee cannot be resolved (e.g. calls to function-valued expressions).
+func (p InjectionPoint) Callee() *types.Func {
+	if p.Pkg == nil || p.Call == nil {
+		return nil
+	}
+	return getCalledFunction(p.Pkg.TypesInfo, p.Call)
+}
+
+// CalleeName returns the fully qualified name of the called function,
+// e.g. "os.Remove" or "(*os.File).Close".
+//
+// Returns "func" if the callee cannot be resolved.
+func (p InjectionPoint) CalleeName() string {
+	fn := p.Callee()
+	if fn == nil {
+		return "func"
+	}
+	return fn.FullName()
+}
+
+// Message describes the unhandled error for diagnostics, e.g.
+// "error returned by os.Remove is not handled".
+func (p InjectionPoint) Message() string {
+	if p.ErrIdent != nil {
+		return fmt.Sprintf("error returned by %s is assigned to %s but never checked", p.CalleeName(), p.ErrIdent.Name)
+	}
+	if p.Shadows != nil {
+		return fmt.Sprintf("error returned by %s is assigned to a variable shadowing the result %s and never returned", p.CalleeName(), p.Shadows.Name())
+	}
+	return fmt.Sprintf("error returned by %s is not handled", p.CalleeName())
 }
 
 // Detect scans the provided packages for unhandled errors.
 // It detects calls processing errors that are ignored via blank identifier,
 // treated as expression statements, ignored in defer/go statements,
 // embedded in control structures, ignored in global variable initializers,
-// or hidden within method chains (`foo().bar()`).
+// or hidden within method chains (`foo().bar()`). Errors assigned to a variable that is
+// never read afterwards, or to a variable shadowing a named error result, are detected too (see
+// InjectionPoint.ErrIdent and InjectionPoint.Shadows).
 //
 // It respects the "// auto-err:ignore" directive. If this text appears in comments
 // associated with the statement, the injection point is skipped.
@@ -45,168 +89,201 @@ type InjectionPoint struct {
 //
 // Returns a slice of detected points where error handling is missing.
 func Detect(pkgs []*packages.Package, flt *filter.Filter, debug bool) ([]InjectionPoint, error) {
+	return DetectParallel(pkgs, flt, debug, 1)
+}
+
+// DetectParallel is Detect analyzing up to jobs packages concurrently (GOMAXPROCS if <= 0).
+// The points are returned in the same order as by Detect.
+//
+// pkgs: The list of packages to analyze.
+// flt: The filter rules to exclude specific files or symbols.
+// debug: If true, prints verbose reasons why calls are ignored.
+// jobs: The maximum number of packages analyzed at a time.
+//
+// Returns a slice of detected points where error handling is missing.
+func DetectParallel(pkgs []*packages.Package, flt *filter.Filter, debug bool, jobs int) ([]InjectionPoint, error) {
+	found := make([][]InjectionPoint, len(pkgs))
+	_ = parallel.ForEach(len(pkgs), jobs, func(i int) error {
+		found[i] = detectPackage(pkgs[i], flt, debug)
+		return nil
+	})
+
 	var injectionPoints []InjectionPoint
+	for _, points := range found {
+		injectionPoints = append(injectionPoints, points...)
+	}
+	return injectionPoints, nil
+}
 
-	for _, pkg := range pkgs {
-		for _, file := range pkg.Syntax {
-			// generate comment map for this file to support directives
-			cmap := ast.NewCommentMap(pkg.Fset, file, file.Comments)
-
-			ast.Inspect(file, func(node ast.Node) bool {
-				// Helper to register points
-				addPoint := func(call *ast.CallExpr, stmt ast.Stmt, assign *ast.AssignStmt) {
-					if shouldInclude(pkg, file, call, stmt, cmap, flt, debug) {
-						injectionPoints = append(injectionPoints, InjectionPoint{
-							Pkg:    pkg,
-							File:   file,
-							Call:   call,
-							Stmt:   stmt,
-							Assign: assign,
-							Pos:    call.Pos(),
-						})
-					}
+// detectPackage scans a single package for unhandled errors (see Detect).
+func detectPackage(pkg *packages.Package, flt *filter.Filter, debug bool) []InjectionPoint {
+	var injectionPoints []InjectionPoint
+
+	for _, file := range pkg.Syntax {
+		// generate comment map for this file to support directives
+		cmap := ast.NewCommentMap(pkg.Fset, file, file.Comments)
+
+		ast.Inspect(file, func(node ast.Node) bool {
+			// Helper to register points
+			addPoint := func(call *ast.CallExpr, stmt ast.Stmt, assign *ast.AssignStmt) {
+				if shouldInclude(pkg, file, call, stmt, cmap, flt, debug) {
+					injectionPoints = append(injectionPoints, InjectionPoint{
+						Pkg:    pkg,
+						File:   file,
+						Call:   call,
+						Stmt:   stmt,
+						Assign: assign,
+						Pos:    call.Pos(),
+					})
 				}
+			}
 
-				// Case 1: Expression Statement (Bare call)
-				if exprStmt, ok := node.(*ast.ExprStmt); ok {
-					// Check root call
-					if call, ok := exprStmt.X.(*ast.CallExpr); ok {
-						if isUnhandledError(pkg.TypesInfo, call) {
-							addPoint(call, exprStmt, nil)
-						} else if debug {
-							logDebug(pkg, call, "ExprStmt call does not return error")
-						}
+			// Case 1: Expression Statement (Bare call)
+			if exprStmt, ok := node.(*ast.ExprStmt); ok {
+				// Check root call
+				if call, ok := exprStmt.X.(*ast.CallExpr); ok {
+					if isUnhandledError(pkg.TypesInfo, call) {
+						addPoint(call, exprStmt, nil)
+					} else if debug {
+						logDebug(pkg, call, "ExprStmt call does not return error")
 					}
-					// Check chains (e.g. foo().bar())
-					checkForChains(pkg.TypesInfo, exprStmt.X, func(c *ast.CallExpr) {
-						addPoint(c, exprStmt, nil)
-					})
-					return false
 				}
+				// Check chains (e.g. foo().bar())
+				checkForChains(pkg.TypesInfo, exprStmt.X, func(c *ast.CallExpr) {
+					addPoint(c, exprStmt, nil)
+				})
+				// Function literals in the statement are visited on their own.
+				return true
+			}
 
-				// Case 2: Assignment Statement (Assigned to _)
-				if assignStmt, ok := node.(*ast.AssignStmt); ok {
-					// Handle Tuple Assignment (1 Call -> N Vars)
-					// Handle N:N Assignment (N Calls -> N Vars)
-
-					// Iterate over RHS to handle N:N or 1:N cases
-					for i, rhs := range assignStmt.Rhs {
-						if call, ok := rhs.(*ast.CallExpr); ok {
-							if checksOut, errorIndex := isErrorReturningCall(pkg.TypesInfo, call); checksOut {
-
-								// Determine which LHS corresponds to the error
-								var lhsExpr ast.Expr
-
-								if len(assignStmt.Lhs) == len(assignStmt.Rhs) {
-									// N:N Case. e.g. x, y = a(), b()
-									// Here, each RHS returns exactly 1 value (one of them is error)
-									lhsExpr = assignStmt.Lhs[i]
-								} else {
-									// 1:N Case (Tuple). e.g. x, y = f()
-									// RHS has 1, LHS has N.
-									// errorIndex indicates position in the tuple (0-indexed)
-									if errorIndex < len(assignStmt.Lhs) {
-										lhsExpr = assignStmt.Lhs[errorIndex]
-									}
+			// Case 2: Assignment Statement (Assigned to _)
+			if assignStmt, ok := node.(*ast.AssignStmt); ok {
+				// Handle Tuple Assignment (1 Call -> N Vars)
+				// Handle N:N Assignment (N Calls -> N Vars)
+
+				// Iterate over RHS to handle N:N or 1:N cases
+				for i, rhs := range assignStmt.Rhs {
+					if call, ok := rhs.(*ast.CallExpr); ok {
+						if checksOut, errorIndex := isErrorReturningCall(pkg.TypesInfo, call); checksOut {
+
+							// Determine which LHS corresponds to the error
+							var lhsExpr ast.Expr
+
+							if len(assignStmt.Lhs) == len(assignStmt.Rhs) {
+								// N:N Case. e.g. x, y = a(), b()
+								// Here, each RHS returns exactly 1 value (one of them is error)
+								lhsExpr = assignStmt.Lhs[i]
+							} else {
+								// 1:N Case (Tuple). e.g. x, y = f()
+								// RHS has 1, LHS has N.
+								// errorIndex indicates position in the tuple (0-indexed)
+								if errorIndex < len(assignStmt.Lhs) {
+									lhsExpr = assignStmt.Lhs[errorIndex]
 								}
+							}
 
-								// If the matching LHS is a blank identifier, it's a hole.
-								if isBlankIdentifier(lhsExpr) {
-									addPoint(call, assignStmt, assignStmt)
-								} else if debug {
-									logDebug(pkg, call, "Error not assigned to blank identifier")
-								}
+							// If the matching LHS is a blank identifier, it's a hole.
+							if isBlankIdentifier(lhsExpr) {
+								addPoint(call, assignStmt, assignStmt)
 							} else if debug {
-								logDebug(pkg, call, "AssignStmt RHS does not return error")
+								logDebug(pkg, call, "Error not assigned to blank identifier")
 							}
+						} else if debug {
+							logDebug(pkg, call, "AssignStmt RHS does not return error")
 						}
-
-						// Check chains in RHS
-						checkForChains(pkg.TypesInfo, rhs, func(c *ast.CallExpr) {
-							addPoint(c, assignStmt, assignStmt)
-						})
 					}
-					return false
-				}
 
-				// Case 3: Defer Statement
-				if deferStmt, ok := node.(*ast.DeferStmt); ok {
-					if isUnhandledError(pkg.TypesInfo, deferStmt.Call) {
-						addPoint(deferStmt.Call, deferStmt, nil)
-					} else if debug {
-						logDebug(pkg, deferStmt.Call, "Defer statement does not return error")
-					}
-					// Check chains in defer
-					checkForChains(pkg.TypesInfo, deferStmt.Call, func(c *ast.CallExpr) {
-						addPoint(c, deferStmt, nil)
+					// Check chains in RHS
+					checkForChains(pkg.TypesInfo, rhs, func(c *ast.CallExpr) {
+						addPoint(c, assignStmt, assignStmt)
 					})
-					return false
 				}
+				// Function literals in the statement are visited on their own.
+				return true
+			}
 
-				// Case 4: Go Statement
-				if goStmt, ok := node.(*ast.GoStmt); ok {
-					if isUnhandledError(pkg.TypesInfo, goStmt.Call) {
-						addPoint(goStmt.Call, goStmt, nil)
-					} else if debug {
-						logDebug(pkg, goStmt.Call, "Go statement call does not return error")
-					}
-					// Check chains in go stmt
-					checkForChains(pkg.TypesInfo, goStmt.Call, func(c *ast.CallExpr) {
-						addPoint(c, goStmt, nil)
-					})
-					return false
+			// Case 3: Defer Statement
+			if deferStmt, ok := node.(*ast.DeferStmt); ok {
+				if isUnhandledError(pkg.TypesInfo, deferStmt.Call) {
+					addPoint(deferStmt.Call, deferStmt, nil)
+				} else if debug {
+					logDebug(pkg, deferStmt.Call, "Defer statement does not return error")
 				}
+				// Check chains in defer
+				checkForChains(pkg.TypesInfo, deferStmt.Call, func(c *ast.CallExpr) {
+					addPoint(c, deferStmt, nil)
+				})
+				// Function literals in the statement are visited on their own.
+				return true
+			}
 
-				// Case 5: If Statement (Embedded call in condition)
-				if ifStmt, ok := node.(*ast.IfStmt); ok {
-					if call := findSafeEmbeddedCall(ifStmt.Cond); call != nil {
-						if isUnhandledError(pkg.TypesInfo, call) {
-							addPoint(call, ifStmt, nil)
-						} else if debug {
-							logDebug(pkg, call, "Embedded if-condition call does not return error")
-						}
+			// Case 4: Go Statement
+			if goStmt, ok := node.(*ast.GoStmt); ok {
+				if isUnhandledError(pkg.TypesInfo, goStmt.Call) {
+					addPoint(goStmt.Call, goStmt, nil)
+				} else if debug {
+					logDebug(pkg, goStmt.Call, "Go statement call does not return error")
+				}
+				// Check chains in go stmt
+				checkForChains(pkg.TypesInfo, goStmt.Call, func(c *ast.CallExpr) {
+					addPoint(c, goStmt, nil)
+				})
+				// Function literals in the statement are visited on their own.
+				return true
+			}
+
+			// Case 5: If Statement (Embedded call in condition)
+			if ifStmt, ok := node.(*ast.IfStmt); ok {
+				if call := findSafeEmbeddedCall(ifStmt.Cond); call != nil {
+					if isUnhandledError(pkg.TypesInfo, call) {
+						addPoint(call, ifStmt, nil)
+					} else if debug {
+						logDebug(pkg, call, "Embedded if-condition call does not return error")
 					}
-					// Chains inside condition? Probably too complex for "SafeEmbeddedCall" logic.
-					return true
 				}
+				// Chains inside condition? Probably too complex for "SafeEmbeddedCall" logic.
+				return true
+			}
 
-				// Case 6: Switch Statement (Embedded call in tag)
-				if switchStmt, ok := node.(*ast.SwitchStmt); ok {
-					if call := findSafeEmbeddedCall(switchStmt.Tag); call != nil {
-						if isUnhandledError(pkg.TypesInfo, call) {
-							addPoint(call, switchStmt, nil)
-						}
+			// Case 6: Switch Statement (Embedded call in tag)
+			if switchStmt, ok := node.(*ast.SwitchStmt); ok {
+				if call := findSafeEmbeddedCall(switchStmt.Tag); call != nil {
+					if isUnhandledError(pkg.TypesInfo, call) {
+						addPoint(call, switchStmt, nil)
 					}
-					return true
 				}
+				return true
+			}
 
-				// Case 7: GenDecl (Global Variable Init)
-				if genDecl, ok := node.(*ast.GenDecl); ok && genDecl.Tok == token.VAR {
-					for _, spec := range genDecl.Specs {
-						if vSpec, ok := spec.(*ast.ValueSpec); ok {
-							for i, rhs := range vSpec.Values {
-								// Check Root Call
-								if call, ok := rhs.(*ast.CallExpr); ok {
-									if isGlobalErrorIgnored(pkg.TypesInfo, vSpec, i, call) {
-										addPoint(call, nil, nil)
-									}
+			// Case 7: GenDecl (Global Variable Init)
+			if genDecl, ok := node.(*ast.GenDecl); ok && genDecl.Tok == token.VAR {
+				for _, spec := range genDecl.Specs {
+					if vSpec, ok := spec.(*ast.ValueSpec); ok {
+						for i, rhs := range vSpec.Values {
+							// Check Root Call
+							if call, ok := rhs.(*ast.CallExpr); ok {
+								if isGlobalErrorIgnored(pkg.TypesInfo, vSpec, i, call) {
+									addPoint(call, nil, nil)
 								}
-								// Check Chains in Global Init
-								checkForChains(pkg.TypesInfo, rhs, func(c *ast.CallExpr) {
-									addPoint(c, nil, nil)
-								})
 							}
+							// Check Chains in Global Init
+							checkForChains(pkg.TypesInfo, rhs, func(c *ast.CallExpr) {
+								addPoint(c, nil, nil)
+							})
 						}
 					}
-					return true
 				}
-
 				return true
-			})
-		}
+			}
+
+			return true
+		})
+
+		injectionPoints = append(injectionPoints, detectIneffectual(pkg, file, cmap, flt, debug)...)
+		injectionPoints = append(injectionPoints, detectShadowed(pkg, file, cmap, flt, debug)...)
 	}
 
-	return injectionPoints, nil
+	return injectionPoints
 }
 
 // checkForChains inspects an expression tree for SelectorExpr nodes where the X (receiver)
@@ -217,6 +294,10 @@ func Detect(pkgs []*packages.Package, flt *filter.Filter, debug bool) ([]Injecti
 // callback: Function to invoke when a broken chain call is found.
 func checkForChains(info *types.Info, root ast.Expr, callback func(*ast.CallExpr)) {
 	ast.Inspect(root, func(n ast.Node) bool {
+		// Function literals are checked with their own statements.
+		if _, ok := n.(*ast.FuncLit); ok {
+			return false
+		}
 		// Stop if we hit a different expression root (block execution order complexity)
 		// but SelectorExpr/CallExpr/IndexExpr etc are fine to traverse.
 		if sel, ok := n.(*ast.SelectorExpr); ok {
@@ -443,7 +524,9 @@ func isBlankIdentifier(expr ast.Expr) bool {
 // returning a *types.Func object representing the symbol.
 //
 // If the call target is a variable (e.g. `myFunc()` where `myFunc` is a var), it synthesizes via
-// types.NewFunc to ensure filters work against the variable name.
+// types.NewFunc to ensure filters work against the variable name. Instantiations of generic
+// functions and methods (e.g. `Map[int](xs)`, `s.Pop()` on a `Stack[int]`) resolve to their generic
+// declaration.
 //
 // info: Type info.
 // call: Call expression.
@@ -452,7 +535,16 @@ func isBlankIdentifier(expr ast.Expr) bool {
 func getCalledFunction(info *types.Info, call *ast.CallExpr) *types.Func {
 	var obj types.Object
 
-	switch fun := call.Fun.(type) {
+	fun := call.Fun
+	// Explicit instantiation: Map[int](xs) or Pair[string, int](k, v)
+	switch x := fun.(type) {
+	case *ast.IndexExpr:
+		fun = x.X
+	case *ast.IndexListExpr:
+		fun = x.X
+	}
+
+	switch fun := fun.(type) {
 	// Direct Identifier call: foo()
 	case *ast.Ident:
 		obj = info.ObjectOf(fun)
@@ -467,7 +559,7 @@ func getCalledFunction(info *types.Info, call *ast.CallExpr) *types.Func {
 
 	// Case 1: Properly typed function/method (e.g. func Foo() {})
 	if fn, ok := obj.(*types.Func); ok {
-		return fn
+		return fn.Origin()
 	}
 
 	// Case 2: Variable of function type (e.g. var f func(), or local closure)
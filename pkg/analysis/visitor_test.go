@@ -319,6 +319,105 @@ func main() {
 	}
 }
 
+// TestDetect_FuncLits verifies that calls in function literals nested in statements are detected
+// once, with the statement of the literal rather than the enclosing one.
+func TestDetect_FuncLits(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := []byte(`package main
+
+import "os"
+
+func run(f func()) { f() }
+
+func main() {
+	run(func() {
+		os.Remove("a")
+	})
+	clean := func() {
+		os.Remove("b").Error()
+	}
+	func() {
+		_ = os.Remove("c")
+	}()
+	go func() {
+		os.Remove("d")
+	}()
+	clean()
+}
+`)
+	_ = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module lits\ngo 1.22\n"), 0644)
+	_ = os.WriteFile(filepath.Join(tmpDir, "main.go"), src, 0644)
+
+	pkgs, _ := loader.LoadPackages([]string{"."}, tmpDir)
+	points, err := Detect(pkgs, nil, false)
+	if err != nil {
+		t.Fatalf("Detect error: %v", err)
+	}
+	var lines []int
+	for _, p := range points {
+		stmtLine := p.Pkg.Fset.Position(p.Stmt.Pos()).Line
+		if callLine := p.Pkg.Fset.Position(p.Call.Pos()).Line; callLine != stmtLine {
+			t.Errorf("call at line %d reported with the statement at line %d", callLine, stmtLine)
+		}
+		lines = append(lines, stmtLine)
+	}
+	sort.Ints(lines)
+	if len(lines) != 4 || lines[0] != 9 || lines[1] != 12 || lines[2] != 15 || lines[3] != 18 {
+		t.Errorf("points at lines %v, want [9 12 15 18]", lines)
+	}
+}
+
+// TestDetect_Generics verifies that calls of instantiated generic functions and methods are
+// detected and resolved to their generic declaration for filtering.
+func TestDetect_Generics(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := []byte(`package main
+
+type Box[T any] struct{ v T }
+
+func (b *Box[T]) Save() error { return nil }
+
+func Decode[T any](s string) (T, error) {
+	var v T
+	return v, nil
+}
+
+func Convert[From, To any](f From) (To, error) {
+	var t To
+	return t, nil
+}
+
+func main() {
+	b := &Box[int]{}
+	b.Save()
+	Decode[int]("1")
+	Convert[int, string](1)
+}
+`)
+	_ = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module generics\ngo 1.22\n"), 0644)
+	_ = os.WriteFile(filepath.Join(tmpDir, "main.go"), src, 0644)
+
+	pkgs, _ := loader.LoadPackages([]string{"."}, tmpDir)
+	points, err := Detect(pkgs, nil, false)
+	if err != nil {
+		t.Fatalf("Detect error: %v", err)
+	}
+	if len(points) != 3 {
+		t.Fatalf("expected 3 points, got %d", len(points))
+	}
+	for _, p := range points {
+		fn := getCalledFunction(p.Pkg.TypesInfo, p.Call)
+		if fn == nil || fn.Origin() != fn {
+			t.Errorf("call at %s not resolved to its generic declaration: %v", p.Pkg.Fset.Position(p.Call.Pos()), fn)
+		}
+	}
+
+	flt := filter.New(nil, []string{"(*generics.Box).Save", "generics.Decode", "generics.Convert"})
+	if points, _ := Detect(pkgs, flt, false); len(points) != 0 {
+		t.Errorf("expected the generic calls to be filtered, got %d points", len(points))
+	}
+}
+
 // TestDetect_Directives verifies that the "// auto-err:ignore" comment excludes calls from detection.
 func TestDetect_Directives(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -372,3 +471,45 @@ func (a byPos) Less(i, j int) bool { return a[i].Pos < a[j].Pos }
 func sortPoints(p []InjectionPoint) {
 	sort.Sort(byPos(p))
 }
+
+// TestInjectionPoint_CalleeName verifies that callee symbols are rendered with their receiver.
+func TestInjectionPoint_CalleeName(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := []byte(`package main
+type S struct{}
+func (s *S) Fail() error { return nil }
+func fail() error { return nil }
+func main() {
+	s := &S{}
+	s.Fail()
+	fail()
+}`)
+	_ = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module callee\ngo 1.22\n"), 0644)
+	_ = os.WriteFile(filepath.Join(tmpDir, "main.go"), src, 0644)
+
+	pkgs, _ := loader.LoadPackages([]string{"."}, tmpDir)
+	points, err := Detect(pkgs, nil, false)
+	if err != nil {
+		t.Fatalf("Detect error: %v", err)
+	}
+
+	var names []string
+	for _, p := range points {
+		names = append(names, p.CalleeName())
+	}
+	sort.Strings(names)
+
+	expected := []string{"(*callee.S).Fail", "callee.fail"}
+	if len(names) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("Expected %q, got %q", expected[i], names[i])
+		}
+	}
+
+	if (InjectionPoint{}).CalleeName() != "func" {
+		t.Error("Expected fallback name for empty point")
+	}
+}
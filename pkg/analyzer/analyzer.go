@@ -0,0 +1,157 @@
+// Package analyzer exposes the unhandled error detection as a golang.org/x/tools/go/analysis Analyzer.
+//
+// This allows the checks to run inside `go vet -vettool`, golangci-lint or gopls. Each detected
+// InjectionPoint is reported as a Diagnostic, and where the Injector can rewrite the statement in place
+// (i.e. the enclosing function already returns an error, or the statement is a `go`/`defer`), the rewrite
+// is attached as a SuggestedFix.
+package analyzer
+
+import (
+	"os"
+	"strings"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/filter"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/rewrite"
+	goanalysis "golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+// Analyzer reports calls whose error result is ignored and suggests the injected handling as a fix.
+var Analyzer = &goanalysis.Analyzer{
+	Name: "autoerr",
+	Doc:  "report unhandled errors and suggest idiomatic error handling",
+	URL:  "https://github.com/SamuelMarks/go-auto-err-handling",
+	Run:  run,
+}
+
+// config holds the values bound to the Analyzer flags.
+var config = struct {
+	excludeGlob          globList
+	excludeSymbolGlob    globList
+	useDefaultExclusions bool
+	errorTemplate        string
+	mainHandler          string
+	logHandler           string
+	logger               string
+	closePolicy          string
+}{
+	useDefaultExclusions: true,
+	errorTemplate:        "{return-zero}, err",
+	mainHandler:          "log-fatal",
+	logHandler:           rewrite.LogHandlerLog,
+	closePolicy:          rewrite.ClosePolicyIgnore,
+}
+
+// globList is a flag.Value accepting a comma separated list of glob patterns.
+type globList []string
+
+// String implements flag.Value.
+func (g *globList) String() string {
+	return strings.Join(*g, ",")
+}
+
+// Set implements flag.Value. Repeated flags accumulate.
+func (g *globList) Set(v string) error {
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			*g = append(*g, part)
+		}
+	}
+	return nil
+}
+
+// init declares the Analyzer flags, mirroring the equivalent CLI options.
+func init() {
+	fs := &Analyzer.Flags
+	fs.Var(&config.excludeGlob, "exclude-glob", "comma separated glob patterns to exclude files")
+	fs.Var(&config.excludeSymbolGlob, "exclude-symbol-glob", "comma separated glob patterns to exclude symbols")
+	fs.BoolVar(&config.useDefaultExclusions, "default-exclusions", config.useDefaultExclusions, "use standard exclusion list (fmt, log, etc)")
+	fs.StringVar(&config.errorTemplate, "error-template", config.errorTemplate, "template for return statements, or a preset: 'return', 'wrap', 'wrap-caller'")
+	fs.StringVar(&config.mainHandler, "main-handler", config.mainHandler, "strategy for terminal handlers: 'log-fatal', 'os-exit', 'panic', 'slog-error', 'slog-error-exit'")
+	fs.StringVar(&config.logHandler, "log-handler", config.logHandler, "logging of errors handled in place: 'log' or 'slog-error'")
+	fs.StringVar(&config.logger, "logger", config.logger, "logger expression of the slog strategies, detected from scope when empty")
+	fs.StringVar(&config.closePolicy, "close-policy", config.closePolicy, "deferred Close of read-only resources: 'ignore', 'nolint' or 'join'")
+}
+
+// run implements the Analyzer. It adapts the pass to a packages.Package so the existing
+// detection and rewriting machinery can be reused unchanged.
+func run(pass *goanalysis.Pass) (interface{}, error) {
+	pkg := &packages.Package{
+		ID:         pass.Pkg.Path(),
+		Name:       pass.Pkg.Name(),
+		PkgPath:    pass.Pkg.Path(),
+		Fset:       pass.Fset,
+		Syntax:     pass.Files,
+		Types:      pass.Pkg,
+		TypesInfo:  pass.TypesInfo,
+		TypesSizes: pass.TypesSizes,
+	}
+
+	globs := append([]string{}, config.excludeSymbolGlob...)
+	if config.useDefaultExclusions {
+		globs = append(globs, filter.GetDefaults()...)
+	}
+	flt := filter.New(config.excludeGlob, globs)
+
+	points, err := analysis.Detect([]*packages.Package{pkg}, flt, false)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range points {
+		if rewrite.LeavesClose(p, config.closePolicy) {
+			continue
+		}
+		diag := goanalysis.Diagnostic{
+			Pos:      p.Call.Pos(),
+			End:      p.Call.End(),
+			Category: "unhandled-error",
+			Message:  p.Message(),
+		}
+		if edits, ok := suggestEdits(pass, pkg, p); ok {
+			diag.SuggestedFixes = []goanalysis.SuggestedFix{{
+				Message:   "Handle error",
+				TextEdits: edits,
+			}}
+		}
+		pass.Report(diag)
+	}
+	return nil, nil
+}
+
+// suggestEdits computes the Injector's rewrite of the point as analysis TextEdits.
+//
+// Returns the edits and true if a fix could be computed.
+func suggestEdits(pass *goanalysis.Pass, pkg *packages.Package, p analysis.InjectionPoint) ([]goanalysis.TextEdit, bool) {
+	tokFile := pass.Fset.File(p.File.Pos())
+	if tokFile == nil {
+		return nil, false
+	}
+
+	var src []byte
+	var err error
+	if pass.ReadFile != nil {
+		src, err = pass.ReadFile(tokFile.Name())
+	} else {
+		src, err = os.ReadFile(tokFile.Name())
+	}
+	if err != nil {
+		return nil, false
+	}
+
+	injector := rewrite.NewInjector(pkg, config.errorTemplate, config.mainHandler)
+	injector.LogHandler = config.logHandler
+	injector.Logger = config.logger
+	injector.ClosePolicy = config.closePolicy
+	fix, err := injector.SuggestFix(p, src)
+	if err != nil || len(fix) == 0 {
+		return nil, false
+	}
+
+	edits := make([]goanalysis.TextEdit, 0, len(fix))
+	for _, e := range fix {
+		edits = append(edits, goanalysis.TextEdit{Pos: e.Pos, End: e.End, NewText: e.NewText})
+	}
+	return edits, true
+}
@@ -0,0 +1,22 @@
+package analyzer
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+// TestAnalyzer verifies diagnostics and suggested fixes against the golden files in testdata.
+func TestAnalyzer(t *testing.T) {
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), Analyzer, "a")
+}
+
+// TestGlobList verifies comma separated flag parsing.
+func TestGlobList(t *testing.T) {
+	var g globList
+	_ = g.Set("fmt.*, os.Remove")
+	_ = g.Set("io.*")
+	if got := g.String(); got != "fmt.*,os.Remove,io.*" {
+		t.Errorf("Unexpected globs %q", got)
+	}
+}
@@ -0,0 +1,49 @@
+package a
+
+func fail() error { return nil }
+
+func pair() (int, error) { return 0, nil }
+
+func preexisting() error {
+	fail() // want `error returned by a.fail is not handled`
+	return nil
+}
+
+func blank() (int, error) {
+	_, _ = pair() // want `error returned by a.pair is not handled`
+	return 0, nil
+}
+
+func void() {
+	fail() // want `error returned by a.fail is not handled`
+}
+
+func ignored() error {
+	fail() // auto-err:ignore
+	return nil
+}
+
+func named() (err error) {
+	defer fail() // want `error returned by a.fail is not handled`
+	return nil
+}
+
+func overwritten() (int, error) {
+	x, err := pair() // want `error returned by a.pair is assigned to err but never checked`
+	y, err := pair()
+	if err != nil {
+		return 0, err
+	}
+	return x + y, nil
+}
+
+func shadowed(items []string) (n int, err error) {
+	for range items {
+		_, err := pair() // want `error returned by a.pair is assigned to a variable shadowing the result err and never returned`
+		if err != nil {
+			break
+		}
+		n++
+	}
+	return
+}
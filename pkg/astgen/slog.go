@@ -0,0 +1,58 @@
+package astgen
+
+import (
+	"go/token"
+	"strconv"
+	"strings"
+
+	"github.com/dave/dst"
+)
+
+// SlogPath is the import path of the structured logging package.
+const SlogPath = "log/slog"
+
+// SlogErrorDST generates a structured log of an error at the Error level:
+//
+//	logger.Error(msg, "op", op, "err", err)
+//
+// logger: The logger expression (e.g. "s.logger" or "slog.Default()"), or "" to use the
+// functions of the slog package.
+// msg: The log message.
+// op: The failed operation, or "" to omit the attribute.
+// errName: The name of the error variable.
+//
+// Returns the statement, or an error if logger is not a valid expression.
+func SlogErrorDST(logger, msg, op, errName string) (dst.Stmt, error) {
+	var recv dst.Expr = dst.NewIdent("slog")
+	if logger != "" {
+		var err error
+		if recv, err = parseDstExpr(logger); err != nil {
+			return nil, err
+		}
+	}
+
+	args := []dst.Expr{&dst.BasicLit{Kind: token.STRING, Value: strconv.Quote(msg)}}
+	if op != "" {
+		args = append(args,
+			&dst.BasicLit{Kind: token.STRING, Value: `"op"`},
+			&dst.BasicLit{Kind: token.STRING, Value: strconv.Quote(op)},
+		)
+	}
+	args = append(args,
+		&dst.BasicLit{Kind: token.STRING, Value: `"err"`},
+		dst.NewIdent(errName),
+	)
+
+	return &dst.ExprStmt{X: &dst.CallExpr{
+		Fun:  &dst.SelectorExpr{X: recv, Sel: dst.NewIdent("Error")},
+		Args: args,
+	}}, nil
+}
+
+// SlogNeedsImport reports whether the code generated by SlogErrorDST for logger refers to the
+// slog package.
+//
+// logger: The logger expression, or "" for the functions of the slog package.
+func SlogNeedsImport(logger string) bool {
+	return logger == "" || strings.HasPrefix(logger, "slog.")
+}
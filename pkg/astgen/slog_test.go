@@ -0,0 +1,44 @@
+package astgen
+
+import (
+	"testing"
+
+	"github.com/dave/dst"
+)
+
+func TestSlogErrorDST(t *testing.T) {
+	tests := []struct {
+		name   string
+		logger string
+		op     string
+		want   string
+	}{
+		{"package", "", "Close", `slog.Error("ignored error", "op", "Close", "err", err)`},
+		{"field", "s.logger", "Close", `s.logger.Error("ignored error", "op", "Close", "err", err)`},
+		{"default", "slog.Default()", "", `slog.Default().Error("ignored error", "err", err)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt, err := SlogErrorDST(tt.logger, "ignored error", tt.op, "err")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := renderDstNode(t, stmt.(*dst.ExprStmt).X)
+			if normalize(got) != normalize(tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := SlogErrorDST("s.(", "msg", "", "err"); err == nil {
+		t.Error("expected error for invalid logger expression")
+	}
+}
+
+func TestSlogNeedsImport(t *testing.T) {
+	for logger, want := range map[string]bool{"": true, "slog.Default()": true, "s.logger": false} {
+		if got := SlogNeedsImport(logger); got != want {
+			t.Errorf("SlogNeedsImport(%q) = %v, want %v", logger, got, want)
+		}
+	}
+}
@@ -96,6 +96,43 @@ func ZeroExprDST(t types.Type, ctx ZeroCtx) (dst.Expr, error) {
 	}
 }
 
+// TypeExprDST generates a dst.Expr denoting the type t, e.g. for a variable declaration.
+//
+// t: The type.
+// q: Formats package names, e.g. as imported by the file the expression is inserted into.
+//
+// Returns an error if the type cannot be written in source (e.g. an unnamed struct with
+// unexported fields of another package).
+func TypeExprDST(t types.Type, q types.Qualifier) (dst.Expr, error) {
+	if t == nil {
+		return nil, fmt.Errorf("type is nil")
+	}
+	typeStr := types.TypeString(t, q)
+	expr, err := parseDstType(typeStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse type string '%s': %w", typeStr, err)
+	}
+	return expr, nil
+}
+
+// ZeroVarDST generates "var name T", declaring a variable holding the zero value of t. Type
+// parameters have no zero value literal (see ZeroExprDST), so their zero values are declared.
+//
+// name: The name of the variable.
+// t: The type of the variable.
+//
+// Returns an error if the type cannot be written in source.
+func ZeroVarDST(name string, t types.Type) (dst.Stmt, error) {
+	typ, err := TypeExprDST(t, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &dst.DeclStmt{Decl: &dst.GenDecl{
+		Tok:   token.VAR,
+		Specs: []dst.Spec{&dst.ValueSpec{Names: []*dst.Ident{dst.NewIdent(name)}, Type: typ}},
+	}}, nil
+}
+
 // --- AST Implementations ---
 
 func basicZeroAST(b *types.Basic) (ast.Expr, error) {
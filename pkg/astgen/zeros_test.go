@@ -280,3 +280,53 @@ func normalize(s string) string {
 	s = strings.ReplaceAll(s, " ", "")
 	return s
 }
+
+func TestTypeExprDST(t *testing.T) {
+	pkg := types.NewPackage("example.com/foo", "foo")
+	named := types.NewNamed(types.NewTypeName(token.NoPos, pkg, "Bar", nil), types.NewStruct(nil, nil), nil)
+	q := func(p *types.Package) string { return "baz" }
+
+	tests := []struct {
+		typ  types.Type
+		want string
+	}{
+		{types.Typ[types.Int], "int"},
+		{types.NewSlice(types.NewPointer(named)), "[]*baz.Bar"},
+		{types.NewMap(types.Typ[types.String], named), "map[string]baz.Bar"},
+	}
+	for _, tt := range tests {
+		expr, err := TypeExprDST(tt.typ, q)
+		if err != nil {
+			t.Fatalf("TypeExprDST(%s): %v", tt.typ, err)
+		}
+		if got := renderDstNode(t, expr); normalize(got) != normalize(tt.want) {
+			t.Errorf("TypeExprDST(%s) = %s, want %s", tt.typ, got, tt.want)
+		}
+	}
+	if _, err := TypeExprDST(nil, q); err == nil {
+		t.Error("expected an error for a nil type")
+	}
+}
+
+func TestZeroVarDST(t *testing.T) {
+	param := types.NewTypeParam(types.NewTypeName(token.NoPos, nil, "T", nil), types.NewInterfaceType(nil, nil))
+
+	stmt, err := ZeroVarDST("zero", param)
+	if err != nil {
+		t.Fatalf("ZeroVarDST: %v", err)
+	}
+	decl, ok := stmt.(*dst.DeclStmt)
+	if !ok {
+		t.Fatalf("expected a declaration, got %T", stmt)
+	}
+	spec := decl.Decl.(*dst.GenDecl).Specs[0].(*dst.ValueSpec)
+	if spec.Names[0].Name != "zero" || len(spec.Values) != 0 {
+		t.Errorf("expected var zero without a value, got %v", spec.Names)
+	}
+	if got := renderDstNode(t, spec.Type); normalize(got) != "T" {
+		t.Errorf("type = %s, want T", got)
+	}
+	if _, err := ZeroVarDST("zero", nil); err == nil {
+		t.Error("expected an error for a nil type")
+	}
+}
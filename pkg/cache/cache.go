@@ -0,0 +1,213 @@
+// Package cache stores the findings of check mode per package on disk, so that repeated checks
+// (e.g. in CI or pre-commit hooks) skip parsing and type checking the packages that did not
+// change.
+//
+// Entries are keyed by PackageKeys: a hash of the files of the package, the keys of its
+// dependencies and a salt describing the tool version and the effective options. A changed file
+// therefore invalidates its package and every package importing it. Entries are never updated in
+// place; stale entries are simply not looked up again and are removed by Clean.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/journal"
+	"golang.org/x/tools/go/packages"
+)
+
+// entriesDir is the directory of the entries below the cache directory. It changes with the
+// format of the keys or entries, so that old entries are never read.
+const entriesDir = "v1"
+
+// DefaultDir returns the default cache directory, "auto-err" in the user cache directory
+// ($XDG_CACHE_HOME or ~/.cache on Linux), or "" if the user cache directory is unknown.
+func DefaultDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "auto-err")
+}
+
+// Cache is a directory of entries.
+type Cache struct {
+	dir string
+}
+
+// Open returns the cache in dir. The directory is created by the first Put.
+//
+// dir: The cache directory.
+func Open(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// path returns the file of the entry with key.
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, entriesDir, key[:2], key+".json")
+}
+
+// Get reads the entry with key into v.
+//
+// key: The key of the entry (see PackageKeys).
+// v: A pointer to the value to decode the entry into.
+//
+// Returns false if there is no valid entry.
+func (c *Cache) Get(key string, v any) bool {
+	if len(key) < 2 {
+		return false
+	}
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, v) == nil
+}
+
+// Put stores v as the entry with key. The write is atomic, so that concurrent runs never read a
+// partial entry.
+//
+// key: The key of the entry (see PackageKeys).
+// v: The value to encode as JSON.
+//
+// Returns an error if the entry cannot be written.
+func (c *Cache) Put(key string, v any) error {
+	if len(key) < 2 {
+		return fmt.Errorf("invalid cache key %q", key)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return journal.WriteFile(path, data, 0o644)
+}
+
+// Clean removes all entries of the cache directory dir.
+//
+// dir: The cache directory.
+//
+// Returns the number of entries removed.
+func Clean(dir string) (int, error) {
+	root := filepath.Join(dir, entriesDir)
+	n := 0
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && filepath.Ext(path) == ".json" {
+			n++
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read cache: %w", err)
+	}
+	if err := os.RemoveAll(root); err != nil {
+		return 0, fmt.Errorf("failed to clean cache: %w", err)
+	}
+	return n, nil
+}
+
+// PackageKeys computes the cache keys of pkgs and their dependencies.
+//
+// The key of a package hashes salt, its ID and path, its files and the keys of its imports. Files
+// of the main module and of modules replaced by local directories are hashed by content; files of
+// the standard library and the module cache by name, size and modification time, which is enough
+// for files that are not edited in place.
+//
+// pkgs: Packages with their files and whole import graph (see loader.ListPackages).
+// salt: The tool version and options the findings depend on.
+//
+// Returns the keys by package ID. A package whose files cannot be read has no key.
+func PackageKeys(pkgs []*packages.Package, salt string) map[string]string {
+	keys := make(map[string]string)
+	var visit func(pkg *packages.Package) string
+	visit = func(pkg *packages.Package) string {
+		if key, ok := keys[pkg.ID]; ok {
+			return key
+		}
+		keys[pkg.ID] = "" // import cycles (e.g. invalid code) are not cached
+
+		h := sha256.New()
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00", salt, pkg.ID, pkg.PkgPath)
+		local := isLocal(pkg)
+		for _, name := range append(append([]string{}, pkg.GoFiles...), pkg.OtherFiles...) {
+			if err := hashFile(h, name, local); err != nil {
+				return ""
+			}
+		}
+
+		paths := make([]string, 0, len(pkg.Imports))
+		for path := range pkg.Imports {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			dep := visit(pkg.Imports[path])
+			if dep == "" {
+				return ""
+			}
+			fmt.Fprintf(h, "import %s %s\x00", path, dep)
+		}
+
+		key := hex.EncodeToString(h.Sum(nil))
+		keys[pkg.ID] = key
+		return key
+	}
+	for _, pkg := range pkgs {
+		visit(pkg)
+	}
+	return keys
+}
+
+// isLocal reports whether the files of pkg may be edited in place: those of the main module and
+// of modules without a version (e.g. replaced by a local directory).
+func isLocal(pkg *packages.Package) bool {
+	mod := pkg.Module
+	if mod == nil {
+		return false
+	}
+	if mod.Replace != nil {
+		mod = mod.Replace
+	}
+	return mod.Main || mod.Version == ""
+}
+
+// hashFile writes the identity of the file to h: its content if byContent, otherwise its size
+// and modification time.
+func hashFile(h io.Writer, name string, byContent bool) error {
+	fmt.Fprintf(h, "file %s\x00", name)
+	if !byContent {
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%d %d\x00", info.Size(), info.ModTime().UnixNano())
+		return nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	_, err = h.Write([]byte{0})
+	return err
+}
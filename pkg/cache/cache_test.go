@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// TestCache_PutGet verifies that entries round trip and that Clean removes them.
+func TestCache_PutGet(t *testing.T) {
+	dir := t.TempDir()
+	c := Open(dir)
+
+	var got []string
+	if c.Get("abcdef", &got) {
+		t.Fatal("expected a miss in an empty cache")
+	}
+	if err := c.Put("abcdef", []string{"x", "y"}); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Get("abcdef", &got) || len(got) != 2 || got[1] != "y" {
+		t.Fatalf("unexpected entry %v", got)
+	}
+	if err := c.Put("a", nil); err == nil {
+		t.Error("expected an error for a short key")
+	}
+
+	n, err := Clean(dir)
+	if err != nil || n != 1 {
+		t.Fatalf("Clean = %d, %v", n, err)
+	}
+	if c.Get("abcdef", &got) {
+		t.Error("expected a miss after Clean")
+	}
+	if n, err := Clean(filepath.Join(dir, "missing")); err != nil || n != 0 {
+		t.Errorf("Clean of a missing directory = %d, %v", n, err)
+	}
+}
+
+// TestPackageKeys verifies that a changed file invalidates its package and its importers only.
+func TestPackageKeys(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	main := &packages.Module{Path: "example.com/m", Main: true}
+	a := &packages.Package{ID: "a", PkgPath: "a", Module: main, GoFiles: []string{write("a.go", "package a")}}
+	b := &packages.Package{ID: "b", PkgPath: "b", Module: main, GoFiles: []string{write("b.go", "package b")}, Imports: map[string]*packages.Package{"a": a}}
+	c := &packages.Package{ID: "c", PkgPath: "c", Module: main, GoFiles: []string{write("c.go", "package c")}}
+	pkgs := []*packages.Package{b, c}
+
+	before := PackageKeys(pkgs, "salt")
+	if len(before) != 3 || before["a"] == "" {
+		t.Fatalf("unexpected keys %v", before)
+	}
+	if again := PackageKeys(pkgs, "salt"); again["b"] != before["b"] {
+		t.Error("keys should be stable")
+	}
+	if salted := PackageKeys(pkgs, "other"); salted["c"] == before["c"] {
+		t.Error("the salt should change the keys")
+	}
+
+	write("a.go", "package a // changed")
+	after := PackageKeys(pkgs, "salt")
+	if after["a"] == before["a"] || after["b"] == before["b"] {
+		t.Error("a and its importer b should be invalidated")
+	}
+	if after["c"] != before["c"] {
+		t.Error("c should keep its key")
+	}
+
+	c.GoFiles = append(c.GoFiles, filepath.Join(dir, "missing.go"))
+	if keys := PackageKeys(pkgs, "salt"); keys["c"] != "" {
+		t.Error("a package with unreadable files should have no key")
+	}
+}
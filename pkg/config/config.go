@@ -0,0 +1,363 @@
+// Package config implements discovery and parsing of the project configuration file (.auto-err.yaml).
+//
+// Top-level keys use the same names as the CLI flags (e.g. `error-template`, `exclude-symbol-glob`)
+// and act as defaults that explicit flags override. The `paths` key supplies the default analysis
+// targets and `overrides` adjusts settings for specific packages or directories:
+//
+//	error-template: '{return-zero}, fmt.Errorf("{func_name}: %w", err)'
+//	exclude-symbol-glob: ["os.Remove*"]
+//	overrides:
+//	  - packages: ["internal/api/..."]
+//	    return-type-changes: false
+//	    main-handler: panic
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/rewrite"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/runner"
+	"github.com/alecthomas/kong"
+	"gopkg.in/yaml.v3"
+)
+
+// FileNames lists the configuration file names searched for, in order of preference.
+var FileNames = []string{".auto-err.yaml", ".auto-err.yml"}
+
+// reservedKeys are top-level keys that do not correspond to CLI flags.
+var reservedKeys = map[string]bool{
+	"paths":           true,
+	"overrides":       true,
+	"panic-to-return": true,
+	"rules":           true,
+	"callbacks":       true,
+}
+
+// pathKeys are the top-level keys naming files or directories, resolved against the directory of
+// the configuration file. "-" (stdin) is kept.
+var pathKeys = map[string]bool{
+	"journal-dir":    true,
+	"cache-dir":      true,
+	"baseline":       true,
+	"write-baseline": true,
+	"output":         true,
+	"from-errcheck":  true,
+	"new-from-patch": true,
+}
+
+// File is a parsed configuration file.
+type File struct {
+	// Path is the absolute path of the file the configuration was loaded from.
+	Path string `yaml:"-"`
+	// Values holds the top-level flag values, keyed by flag name.
+	Values map[string]any `yaml:"-"`
+	// Paths are the default package patterns analyzed when none are given on the command line.
+	Paths []string `yaml:"paths,omitempty"`
+	// PanicToReturn enables rewriting panic calls into error returns. It has no CLI equivalent.
+	PanicToReturn bool `yaml:"panic-to-return,omitempty"`
+	// Rules select per-callee templates or handling strategies. The first matching rule wins.
+	Rules []rewrite.Rule `yaml:"rules,omitempty"`
+	// Callbacks are fixed-signature shapes handled in place, matched before the built-in ones
+	// (see rewrite.DefaultCallbacks).
+	Callbacks []rewrite.Callback `yaml:"callbacks,omitempty"`
+	// Overrides are per-package adjustments, applied in order (later entries win).
+	Overrides []Override `yaml:"overrides,omitempty"`
+}
+
+// Override adjusts settings for packages matching Packages.
+// Unset fields inherit the top-level (or CLI) value.
+type Override struct {
+	// Packages are patterns relative to the configuration file directory (e.g. "internal/api/...")
+	// or full import path patterns (e.g. "example.com/mod/cmd/*").
+	Packages             []string `yaml:"packages"`
+	ErrorTemplate        *string  `yaml:"error-template,omitempty"`
+	MainHandler          *string  `yaml:"main-handler,omitempty"`
+	ExcludeGlob          []string `yaml:"exclude-glob,omitempty"`
+	ExcludeSymbolGlob    []string `yaml:"exclude-symbol-glob,omitempty"`
+	EnablePreexistingErr *bool    `yaml:"local-preexisting-err,omitempty"`
+	EnableNonExistingErr *bool    `yaml:"return-type-changes,omitempty"`
+	EnableThirdPartyErr  *bool    `yaml:"third-party,omitempty"`
+	EnableTestRefactor   *bool    `yaml:"test-func-changes,omitempty"`
+	UseDefaultExclusions *bool    `yaml:"default-exclusions,omitempty"`
+	CompatWrappers       *bool    `yaml:"compat-wrappers,omitempty"`
+	CompatGlob           []string `yaml:"compat-glob,omitempty"`
+	CompatHandler        *string  `yaml:"compat-handler,omitempty"`
+	EvolveInterfaces     *bool    `yaml:"evolve-interfaces,omitempty"`
+	GoStrategy           *string  `yaml:"go-strategy,omitempty"`
+	LogHandler           *string  `yaml:"log-handler,omitempty"`
+	Logger               *string  `yaml:"logger,omitempty"`
+	ClosePolicy          *string  `yaml:"close-policy,omitempty"`
+	SyncOnClose          *bool    `yaml:"sync-on-close,omitempty"`
+	// Rules are evaluated before the top-level rules for matching packages.
+	Rules []rewrite.Rule `yaml:"rules,omitempty"`
+}
+
+// Find searches dir and its parents for a configuration file.
+//
+// dir: The directory to start from (usually the working directory).
+//
+// Returns the absolute path of the nearest configuration file, or "" if none exists.
+func Find(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		for _, name := range FileNames {
+			candidate := filepath.Join(abs, name)
+			if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+				return candidate, nil
+			}
+		}
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return "", nil
+		}
+		abs = parent
+	}
+}
+
+// Load reads and parses the configuration file at path.
+//
+// path: The file to load.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	f.Path = abs
+	return f, nil
+}
+
+// Parse decodes a configuration document.
+//
+// r: The YAML source.
+func Parse(r io.Reader) (*File, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{}
+	if err := yaml.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	raw := map[string]any{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	f.Values = make(map[string]any, len(raw))
+	for k, v := range raw {
+		if !reservedKeys[k] {
+			f.Values[k] = v
+		}
+	}
+
+	for i, r := range f.Rules {
+		if err := r.Validate(); err != nil {
+			return nil, fmt.Errorf("rules[%d]: %w", i, err)
+		}
+	}
+	for i, c := range f.Callbacks {
+		if err := c.Validate(); err != nil {
+			return nil, fmt.Errorf("callbacks[%d]: %w", i, err)
+		}
+	}
+	for i, o := range f.Overrides {
+		if len(o.Packages) == 0 {
+			return nil, fmt.Errorf("overrides[%d]: 'packages' is required", i)
+		}
+		for j, r := range o.Rules {
+			if err := r.Validate(); err != nil {
+				return nil, fmt.Errorf("overrides[%d].rules[%d]: %w", i, j, err)
+			}
+		}
+	}
+	return f, nil
+}
+
+// Dir returns the directory containing the configuration file, against which relative
+// override patterns are resolved.
+func (f *File) Dir() string {
+	if f.Path == "" {
+		return "."
+	}
+	return filepath.Dir(f.Path)
+}
+
+// PathValue returns the file or directory setting key (see pathKeys), resolved against Dir if it
+// is relative. "-" (stdin) is kept.
+//
+// key: The key of the setting (e.g. "journal-dir").
+//
+// Returns false if the setting is not a non-empty string.
+func (f *File) PathValue(key string) (string, bool) {
+	p, ok := f.Values[key].(string)
+	if !ok || p == "" {
+		return "", false
+	}
+	if p == "-" || filepath.IsAbs(p) {
+		return p, true
+	}
+	return filepath.Join(f.Dir(), p), true
+}
+
+// ResolvedPaths returns Paths with relative directory patterns (e.g. "./...") anchored at the
+// configuration file directory, so they are independent of the working directory.
+func (f *File) ResolvedPaths() []string {
+	out := make([]string, 0, len(f.Paths))
+	for _, p := range f.Paths {
+		if p == "." || p == ".." || strings.HasPrefix(p, "./") || strings.HasPrefix(p, "../") {
+			// The loader accepts absolute directory patterns (e.g. "/repo/...").
+			p = filepath.Join(f.Dir(), p)
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// Resolver exposes the top-level values as a kong.Resolver. Flags explicitly set on the
+// command line take precedence over resolved values. Relative files and directories (e.g.
+// `baseline` or `journal-dir`) are anchored at the configuration file directory, like Paths.
+func (f *File) Resolver() kong.Resolver {
+	return &resolver{file: f}
+}
+
+// RunnerOverrides converts the overrides into runner.Override values anchored at the file directory.
+func (f *File) RunnerOverrides() []runner.Override {
+	out := make([]runner.Override, 0, len(f.Overrides))
+	for _, o := range f.Overrides {
+		ro := runner.Override{
+			Patterns:             o.Packages,
+			Dir:                  f.Dir(),
+			ExcludeGlob:          o.ExcludeGlob,
+			ExcludeSymbolGlob:    o.ExcludeSymbolGlob,
+			EnablePreexistingErr: o.EnablePreexistingErr,
+			EnableNonExistingErr: o.EnableNonExistingErr,
+			EnableThirdPartyErr:  o.EnableThirdPartyErr,
+			EnableTestRefactor:   o.EnableTestRefactor,
+			UseDefaultExclusions: o.UseDefaultExclusions,
+			CompatWrappers:       o.CompatWrappers,
+			CompatGlob:           o.CompatGlob,
+			EvolveInterfaces:     o.EvolveInterfaces,
+			SyncOnClose:          o.SyncOnClose,
+			Rules:                o.Rules,
+		}
+		if o.ErrorTemplate != nil {
+			ro.ErrorTemplate = *o.ErrorTemplate
+		}
+		if o.MainHandler != nil {
+			ro.MainHandler = *o.MainHandler
+		}
+		if o.CompatHandler != nil {
+			ro.CompatHandler = *o.CompatHandler
+		}
+		if o.GoStrategy != nil {
+			ro.GoStrategy = *o.GoStrategy
+		}
+		if o.LogHandler != nil {
+			ro.LogHandler = *o.LogHandler
+		}
+		if o.Logger != nil {
+			ro.Logger = *o.Logger
+		}
+		if o.ClosePolicy != nil {
+			ro.ClosePolicy = *o.ClosePolicy
+		}
+		out = append(out, ro)
+	}
+	return out
+}
+
+// Write prints the effective configuration as YAML.
+//
+// w: The output writer.
+// values: The effective top-level flag values, keyed by flag name.
+// paths: The effective analysis paths.
+// rules: The effective rules (CLI rules followed by file rules).
+// f: The loaded configuration file, or nil if none was found.
+func Write(w io.Writer, values map[string]any, paths []string, rules []rewrite.Rule, f *File) error {
+	doc := make(map[string]any, len(values)+4)
+	for k, v := range values {
+		doc[k] = v
+	}
+	doc["paths"] = paths
+	if len(rules) > 0 {
+		doc["rules"] = rules
+	}
+	if f != nil {
+		doc["panic-to-return"] = f.PanicToReturn
+		if len(f.Callbacks) > 0 {
+			doc["callbacks"] = f.Callbacks
+		}
+		if len(f.Overrides) > 0 {
+			doc["overrides"] = f.Overrides
+		}
+		if _, err := fmt.Fprintf(w, "# source: %s\n", f.Path); err != nil {
+			return err
+		}
+	} else {
+		if _, err := fmt.Fprintln(w, "# source: (none, defaults and flags only)"); err != nil {
+			return err
+		}
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// resolver implements kong.Resolver over File.Values.
+type resolver struct {
+	file *File
+}
+
+// Validate reports keys that do not correspond to any flag of the application.
+func (r *resolver) Validate(app *kong.Application) error {
+	known := make(map[string]bool)
+	for _, flag := range app.Flags {
+		known[flag.Name] = true
+	}
+	var unknown []string
+	for k := range r.file.Values {
+		if !known[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("%s: unknown configuration keys: %s", r.file.Path, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// Resolve returns the configured value for the flag, or nil if unset.
+func (r *resolver) Resolve(_ *kong.Context, _ *kong.Path, flag *kong.Flag) (any, error) {
+	v, ok := r.file.Values[flag.Name]
+	if !ok {
+		return nil, nil
+	}
+	if _, isStr := v.(string); isStr && pathKeys[flag.Name] {
+		if p, ok := r.file.PathValue(flag.Name); ok {
+			return p, nil
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,248 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kong"
+)
+
+// TestFind verifies the upward search for the configuration file.
+func TestFind(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Find(nested)
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	// The temp dir may itself sit below a config file on odd systems; only assert it is not inside root.
+	if strings.HasPrefix(got, root) {
+		t.Errorf("expected no config inside %s, got %s", root, got)
+	}
+
+	cfgPath := filepath.Join(root, ".auto-err.yml")
+	if err := os.WriteFile(cfgPath, []byte("dry-run: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = Find(nested)
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if got != cfgPath {
+		t.Errorf("expected %s, got %s", cfgPath, got)
+	}
+
+	// .auto-err.yaml is preferred over .auto-err.yml in the same directory.
+	preferred := filepath.Join(root, ".auto-err.yaml")
+	if err := os.WriteFile(preferred, []byte("dry-run: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = Find(nested)
+	if got != preferred {
+		t.Errorf("expected %s, got %s", preferred, got)
+	}
+}
+
+// TestParse verifies the separation of flag values, paths and overrides.
+func TestParse(t *testing.T) {
+	src := `
+error-template: "{return-zero}, err"
+exclude-symbol-glob: ["os.Remove*", "io.*"]
+panic-to-return: true
+paths: ["./..."]
+rules:
+  - symbol: "(*os.File).Close"
+    action: log
+  - symbol: "encoding/json.*"
+    template: '{return-zero}, fmt.Errorf("decode: %w", err)'
+callbacks:
+  - name: echo handler
+    params: ["github.com/labstack/echo/v4.Context"]
+    results: ["error"]
+    handle: 'return {0}.String({"net/http"}.StatusInternalServerError, err.Error())'
+overrides:
+  - packages: ["internal/api/..."]
+    return-type-changes: false
+    main-handler: panic
+    compat-wrappers: true
+    compat-handler: panic
+    go-strategy: errgroup
+    log-handler: slog-error
+    logger: s.logger
+    close-policy: nolint
+    sync-on-close: true
+    rules:
+      - symbol: "database/sql.*"
+        template: "{return-zero}, dberr.Wrap(err)"
+`
+	f, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if _, ok := f.Values["paths"]; ok {
+		t.Error("reserved key 'paths' leaked into Values")
+	}
+	if f.Values["error-template"] != "{return-zero}, err" {
+		t.Errorf("unexpected error-template: %v", f.Values["error-template"])
+	}
+	if !f.PanicToReturn {
+		t.Error("expected PanicToReturn")
+	}
+	if !reflect.DeepEqual(f.Paths, []string{"./..."}) {
+		t.Errorf("unexpected paths: %v", f.Paths)
+	}
+	if len(f.Rules) != 2 || f.Rules[0].Action != "log" || f.Rules[1].Template == "" {
+		t.Errorf("unexpected rules: %+v", f.Rules)
+	}
+	if _, ok := f.Values["rules"]; ok {
+		t.Error("reserved key 'rules' leaked into Values")
+	}
+	if len(f.Callbacks) != 1 || f.Callbacks[0].Params[0] != "github.com/labstack/echo/v4.Context" || len(f.Callbacks[0].Results) != 1 {
+		t.Errorf("unexpected callbacks: %+v", f.Callbacks)
+	}
+	if _, ok := f.Values["callbacks"]; ok {
+		t.Error("reserved key 'callbacks' leaked into Values")
+	}
+	if len(f.Overrides) != 1 {
+		t.Fatalf("expected 1 override, got %d", len(f.Overrides))
+	}
+
+	ro := f.RunnerOverrides()[0]
+	if ro.MainHandler != "panic" || ro.EnableNonExistingErr == nil || *ro.EnableNonExistingErr {
+		t.Errorf("unexpected runner override: %+v", ro)
+	}
+	if len(ro.Rules) != 1 || ro.Rules[0].Symbol != "database/sql.*" {
+		t.Errorf("override rules not converted: %+v", ro.Rules)
+	}
+	if ro.CompatWrappers == nil || !*ro.CompatWrappers || ro.CompatHandler != "panic" {
+		t.Errorf("compat settings not converted: %+v", ro)
+	}
+	if ro.GoStrategy != "errgroup" {
+		t.Errorf("go-strategy not converted: %q", ro.GoStrategy)
+	}
+	if ro.LogHandler != "slog-error" || ro.Logger != "s.logger" {
+		t.Errorf("slog settings not converted: %q, %q", ro.LogHandler, ro.Logger)
+	}
+	if ro.ClosePolicy != "nolint" || ro.SyncOnClose == nil || !*ro.SyncOnClose {
+		t.Errorf("close settings not converted: %q, %v", ro.ClosePolicy, ro.SyncOnClose)
+	}
+	if ro.EnablePreexistingErr != nil {
+		t.Error("unset override field should remain nil")
+	}
+}
+
+// TestParse_Errors verifies invalid documents are rejected.
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"InvalidYAML", "error-template: [unclosed"},
+		{"OverrideWithoutPackages", "overrides:\n  - main-handler: panic\n"},
+		{"UnknownRuleAction", "rules:\n  - symbol: os.*\n    action: retry\n"},
+		{"BadRuleGlob", "overrides:\n  - packages: [x]\n    rules:\n      - symbol: '[os'\n"},
+		{"CallbackWithoutParams", "callbacks:\n  - name: x\n    handle: return\n"},
+		{"BadCallbackHandle", "callbacks:\n  - name: x\n    params: [string]\n    handle: 'log.Print(err'\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(strings.NewReader(tt.src)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+// TestResolver verifies that file values act as defaults and CLI flags take precedence.
+func TestResolver(t *testing.T) {
+	type cli struct {
+		Template string   `name:"error-template" default:"{return-zero}, err"`
+		Handler  string   `name:"main-handler" default:"log-fatal"`
+		Globs    []string `name:"exclude-symbol-glob"`
+		Defaults bool     `name:"default-exclusions" default:"true"`
+	}
+
+	f, err := Parse(strings.NewReader("main-handler: panic\nerror-template: custom\nexclude-symbol-glob: [a.*, b.*]\ndefault-exclusions: false\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var c cli
+	parser, err := kong.New(&c, kong.Resolvers(f.Resolver()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.Parse([]string{"--main-handler=os-exit"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if c.Handler != "os-exit" {
+		t.Errorf("flag should override file: got %q", c.Handler)
+	}
+	if c.Template != "custom" {
+		t.Errorf("file should override default: got %q", c.Template)
+	}
+	if !reflect.DeepEqual(c.Globs, []string{"a.*", "b.*"}) {
+		t.Errorf("unexpected globs: %v", c.Globs)
+	}
+	if c.Defaults {
+		t.Error("expected default-exclusions=false from file")
+	}
+
+	// Unknown keys are rejected.
+	bad, _ := Parse(strings.NewReader("no-such-flag: 1\n"))
+	parser, err = kong.New(&cli{}, kong.Resolvers(bad.Resolver()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.Parse(nil); err == nil || !strings.Contains(err.Error(), "no-such-flag") {
+		t.Errorf("expected unknown key error, got %v", err)
+	}
+}
+
+// TestResolvedPaths verifies relative paths are anchored at the config directory.
+func TestResolvedPaths(t *testing.T) {
+	f := &File{Path: filepath.Join("/repo", ".auto-err.yaml"), Paths: []string{"./...", ".", "example.com/mod/..."}}
+	got := f.ResolvedPaths()
+	want := []string{filepath.Join("/repo", "..."), "/repo", "example.com/mod/..."}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+// TestWrite verifies the effective configuration output.
+func TestWrite(t *testing.T) {
+	f, err := Parse(strings.NewReader("overrides:\n  - packages: [cmd/*]\n    error-template: custom\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Path = "/repo/.auto-err.yaml"
+
+	var buf bytes.Buffer
+	if err := Write(&buf, map[string]any{"dry-run": true}, []string{"."}, f.Rules, f); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"# source: /repo/.auto-err.yaml", "dry-run: true", "paths:", "error-template: custom", "- cmd/*"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	if err := Write(&buf, nil, []string{"."}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "# source: (none") {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,92 @@
+// Package dstmap records the correspondence between go/ast and dave/dst nodes of decorated files.
+//
+// Rewrites insert and replace statements in the DST, so a structural mapping (by field and list
+// index) of the unchanged AST drifts after the first multi-statement rewrite in a block. Files
+// decorated with Decorate keep the exact node table of the decorator for lookups.
+package dstmap
+
+import (
+	"errors"
+	"go/ast"
+	"go/token"
+	"sync"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+)
+
+var (
+	// ErrNotDecorated reports a file not created by Decorate, or a node created after decoration.
+	ErrNotDecorated = errors.New("node was not recorded during decoration")
+	// ErrDetached reports a node that has been removed from the tree (e.g. replaced by a rewrite).
+	ErrDetached = errors.New("node is no longer part of the file")
+)
+
+// files maps *dst.File to the map[ast.Node]dst.Node recorded during decoration.
+var files sync.Map
+
+// Decorate converts astFile into a DST and records the node correspondence.
+//
+// fset: The FileSet astFile was parsed with.
+// astFile: The file to decorate.
+//
+// Returns the decorated file.
+func Decorate(fset *token.FileSet, astFile *ast.File) (*dst.File, error) {
+	dec := decorator.NewDecorator(fset)
+	f, err := dec.DecorateFile(astFile)
+	if err != nil {
+		return nil, err
+	}
+	files.Store(f, dec.Dst.Nodes)
+	return f, nil
+}
+
+// Forget drops the node table of file. Lookups on it fail afterwards.
+//
+// file: A file returned by Decorate.
+func Forget(file *dst.File) {
+	files.Delete(file)
+}
+
+// Lookup returns the DST node decorated from node and its current parent in file.
+//
+// file: A file returned by Decorate.
+// node: The AST node to map.
+//
+// Returns ErrNotDecorated or ErrDetached if the node cannot be mapped.
+func Lookup(file *dst.File, node ast.Node) (n, parent dst.Node, err error) {
+	v, found := files.Load(file)
+	if !found {
+		return nil, nil, ErrNotDecorated
+	}
+	n, found = v.(map[ast.Node]dst.Node)[node]
+	if !found {
+		return nil, nil, ErrNotDecorated
+	}
+	if n == file {
+		return n, nil, nil
+	}
+
+	ok := false
+	var stack []dst.Node
+	dst.Inspect(file, func(c dst.Node) bool {
+		if ok {
+			return false
+		}
+		if c == nil {
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		if c == n {
+			parent = stack[len(stack)-1]
+			ok = true
+			return false
+		}
+		stack = append(stack, c)
+		return true
+	})
+	if !ok {
+		return nil, nil, ErrDetached
+	}
+	return n, parent, nil
+}
@@ -0,0 +1,59 @@
+package dstmap
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/dave/dst"
+)
+
+// TestLookup verifies that nodes are found after statements are inserted before them and that
+// replaced nodes are reported as missing.
+func TestLookup(t *testing.T) {
+	src := `package p
+
+func f() {
+	a()
+	b()
+}
+`
+	fset := token.NewFileSet()
+	astFile, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := Decorate(fset, astFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	astBody := astFile.Decls[0].(*ast.FuncDecl).Body
+	body := file.Decls[0].(*dst.FuncDecl).Body
+
+	// Shift b() to index 2.
+	body.List = append([]dst.Stmt{&dst.EmptyStmt{Implicit: true}}, body.List...)
+
+	n, parent, err := Lookup(file, astBody.List[1])
+	if err != nil || n != body.List[2] || parent != body {
+		t.Errorf("b() mapped to %T (parent %T, err %v)", n, parent, err)
+	}
+	if n, parent, err := Lookup(file, astFile); err != nil || n != file || parent != nil {
+		t.Error("file should map to itself without parent")
+	}
+
+	// Replace a().
+	body.List[1] = &dst.EmptyStmt{Implicit: true}
+	if _, _, err := Lookup(file, astBody.List[0]); !errors.Is(err, ErrDetached) {
+		t.Errorf("replaced statement: got %v, want ErrDetached", err)
+	}
+	if _, _, err := Lookup(file, &ast.EmptyStmt{}); !errors.Is(err, ErrNotDecorated) {
+		t.Errorf("unknown node: got %v, want ErrNotDecorated", err)
+	}
+
+	Forget(file)
+	if _, _, err := Lookup(file, astBody.List[1]); !errors.Is(err, ErrNotDecorated) {
+		t.Errorf("forgotten file: got %v, want ErrNotDecorated", err)
+	}
+}
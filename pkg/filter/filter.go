@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 )
 
 // generatedCodeRegexp matches the standard header line for generated files.
@@ -120,30 +121,56 @@ func isGeneratedFile(path string) (b bool, err error) {
 }
 
 // MatchesSymbol checks if the provided function symbol is excluded.
-// It constructs the fully qualified name (<package-path>.<function-name>) and checks against symbol globs.
+// The symbol globs are matched against the names returned by SymbolNames.
 //
 // fn: The function object to check.
 func (f *Filter) MatchesSymbol(fn *types.Func) bool {
-	if fn == nil {
-		return false
+	for _, pattern := range f.symbolGlobs {
+		if MatchSymbol(pattern, fn) {
+			return true
+		}
 	}
+	return false
+}
 
-	pkgName := ""
-	if fn.Pkg() != nil {
-		pkgName = fn.Pkg().Path()
+// MatchSymbol reports whether a symbol glob matches the function.
+//
+// pattern: A glob pattern (e.g. "os.Remove*", "database/sql.*", "(*os.File).Close").
+// fn: The function object to check.
+func MatchSymbol(pattern string, fn *types.Func) bool {
+	for _, name := range SymbolNames(fn) {
+		matched, err := filepath.Match(pattern, name)
+		if err == nil && matched {
+			return true
+		}
 	}
+	return false
+}
 
-	fullName := fn.Name()
-	if pkgName != "" {
-		fullName = fmt.Sprintf("%s.%s", pkgName, fn.Name())
+// SymbolNames returns the names a symbol glob is matched against: the package qualified
+// name (<package-path>.<function-name>, e.g. "os.Close") and, for methods, the fully
+// qualified name including the receiver (e.g. "(*os.File).Close"). Methods of generic types are
+// also matched without the type parameters (e.g. "(*pkg.Stack).Pop" for "(*pkg.Stack[T]).Pop").
+//
+// fn: The function object.
+func SymbolNames(fn *types.Func) []string {
+	if fn == nil {
+		return nil
 	}
 
-	for _, pattern := range f.symbolGlobs {
-		matched, err := filepath.Match(pattern, fullName)
-		if err == nil && matched {
-			return true
+	fullName := fn.Name()
+	if fn.Pkg() != nil {
+		fullName = fmt.Sprintf("%s.%s", fn.Pkg().Path(), fn.Name())
+	}
+	names := []string{fullName}
+
+	if qualified := fn.FullName(); qualified != fullName {
+		names = append(names, qualified)
+		if open := strings.Index(qualified, "["); open >= 0 {
+			if end := strings.Index(qualified[open:], "]"); end >= 0 {
+				names = append(names, qualified[:open]+qualified[open+end+1:])
+			}
 		}
 	}
-
-	return false
+	return names
 }
@@ -184,12 +184,29 @@ func TestMatchesSymbol(t *testing.T) {
 	fnRun := types.NewFunc(token.NoPos, pkgMy, "Run", nil)
 	fnInternal := types.NewFunc(token.NoPos, nil, "panic", nil)
 
+	pkgOs := types.NewPackage("os", "os")
+	fileType := types.NewNamed(types.NewTypeName(token.NoPos, pkgOs, "File", nil), types.NewStruct(nil, nil), nil)
+	recv := types.NewVar(token.NoPos, pkgOs, "f", types.NewPointer(fileType))
+	fnClose := types.NewFunc(token.NoPos, pkgOs, "Close", types.NewSignatureType(recv, nil, nil, nil, nil, false))
+
 	tests := []struct {
 		name      string
 		globs     []string
 		fn        *types.Func
 		wantMatch bool
 	}{
+		{
+			name:      "MethodReceiverQualified",
+			globs:     []string{"(*os.File).Close"},
+			fn:        fnClose,
+			wantMatch: true,
+		},
+		{
+			name:      "MethodPackageQualified",
+			globs:     []string{"os.Close"},
+			fn:        fnClose,
+			wantMatch: true,
+		},
 		{
 			name:      "NoGlobs",
 			globs:     nil,
@@ -249,3 +266,25 @@ func TestMatchesSymbol(t *testing.T) {
 		})
 	}
 }
+
+// TestSymbolNames_Generic verifies that methods of generic types are also named without the type
+// parameters.
+func TestSymbolNames_Generic(t *testing.T) {
+	pkg := types.NewPackage("example.com/coll", "coll")
+	named := types.NewNamed(types.NewTypeName(token.NoPos, pkg, "Stack", nil), nil, nil)
+	named.SetTypeParams([]*types.TypeParam{
+		types.NewTypeParam(types.NewTypeName(token.NoPos, pkg, "K", nil), types.NewInterfaceType(nil, nil)),
+		types.NewTypeParam(types.NewTypeName(token.NoPos, pkg, "V", nil), types.NewInterfaceType(nil, nil)),
+	})
+	named.SetUnderlying(types.NewStruct(nil, nil))
+	recv := types.NewVar(token.NoPos, pkg, "s", types.NewPointer(named))
+	fnPop := types.NewFunc(token.NoPos, pkg, "Pop", types.NewSignatureType(recv, nil, nil, nil, nil, false))
+
+	got := SymbolNames(fnPop)
+	if len(got) != 3 || got[2] != "(*example.com/coll.Stack).Pop" {
+		t.Errorf("SymbolNames() = %v, want the receiver without type parameters last", got)
+	}
+	if !MatchSymbol("(*example.com/coll.Stack).*", fnPop) {
+		t.Error("expected the glob without type parameters to match")
+	}
+}
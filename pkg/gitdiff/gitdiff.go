@@ -0,0 +1,289 @@
+// Package gitdiff computes the lines changed by a unified diff, to restrict fixes to new code.
+//
+// The changes are read from a patch file (e.g. the output of `git diff`) or computed against a
+// revision of the local git repository. Only added and modified lines count; pure deletions do
+// not change any line of the new file.
+package gitdiff
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hexops/gotextdiff"
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+)
+
+// Changes holds the changed lines per file.
+type Changes struct {
+	// lines maps absolute file paths to their changed (1-based) lines.
+	lines map[string]map[int]bool
+	// whole holds the files that are new as a whole (e.g. untracked files).
+	whole map[string]bool
+}
+
+// newChanges returns an empty set of changes.
+func newChanges() *Changes {
+	return &Changes{lines: make(map[string]map[int]bool), whole: make(map[string]bool)}
+}
+
+// Files returns the number of changed files.
+func (c *Changes) Files() int {
+	n := len(c.whole)
+	for path := range c.lines {
+		if !c.whole[path] {
+			n++
+		}
+	}
+	return n
+}
+
+// Overlaps reports whether any line of [from, to] of file changed.
+//
+// file: The absolute path of the file.
+// from: The first line of the range.
+// to: The last line of the range.
+func (c *Changes) Overlaps(file string, from, to int) bool {
+	file = filepath.Clean(file)
+	if c.whole[file] {
+		return true
+	}
+	lines := c.lines[file]
+	for l := from; l <= to; l++ {
+		if lines[l] {
+			return true
+		}
+	}
+	return false
+}
+
+// Remap moves the changed lines of file through an edit from before to after (e.g. a rewrite of
+// the file), so that they keep pointing at the same code. Lines inserted by the edit count as
+// changed, since they handle code in changed lines.
+//
+// file: The absolute path of the file.
+// before: The content the changed lines refer to.
+// after: The new content of the file.
+func (c *Changes) Remap(file string, before, after []byte) {
+	file = filepath.Clean(file)
+	if c.whole[file] {
+		return
+	}
+	old := c.lines[file]
+	if len(old) == 0 {
+		return
+	}
+
+	edits := myers.ComputeEdits(span.URIFromPath(file), string(before), string(after))
+	unified := gotextdiff.ToUnified(file, file, string(before), edits)
+
+	moved := make(map[int]bool)
+	from, to := 1, 1
+	carry := func(until int) {
+		for ; from < until; from, to = from+1, to+1 {
+			if old[from] {
+				moved[to] = true
+			}
+		}
+	}
+	for _, h := range unified.Hunks {
+		carry(h.FromLine)
+		for _, l := range h.Lines {
+			switch l.Kind {
+			case gotextdiff.Equal:
+				if old[from] {
+					moved[to] = true
+				}
+				from++
+				to++
+			case gotextdiff.Delete:
+				from++
+			case gotextdiff.Insert:
+				moved[to] = true
+				to++
+			}
+		}
+	}
+	for l := range old {
+		if l >= from {
+			moved[l-from+to] = true
+		}
+	}
+	c.lines[file] = moved
+}
+
+// Parse reads the changed lines of a unified diff.
+//
+// r: The diff, e.g. the output of `git diff`.
+// root: The directory relative file names of the diff are resolved against (the repository root
+// for git diffs).
+//
+// Returns the changes keyed by absolute path, or an error if a hunk header is malformed.
+func Parse(r io.Reader, root string) (*Changes, error) {
+	c := newChanges()
+	var (
+		file       string
+		line       int
+		oldN, newN int // lines left in the current hunk
+		scanner    = bufio.NewScanner(r)
+	)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if oldN > 0 || newN > 0 {
+			switch {
+			case strings.HasPrefix(text, "+"):
+				if file != "" {
+					if c.lines[file] == nil {
+						c.lines[file] = make(map[int]bool)
+					}
+					c.lines[file][line] = true
+				}
+				line++
+				newN--
+			case strings.HasPrefix(text, "-"):
+				oldN--
+			case strings.HasPrefix(text, `\`):
+				// "\ No newline at end of file"
+			default:
+				// Context line; some tools strip the leading space of empty lines.
+				line++
+				oldN--
+				newN--
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(text, "+++ "):
+			file = resolve(root, diffName(strings.TrimPrefix(text, "+++ ")))
+		case strings.HasPrefix(text, "@@ "):
+			var err error
+			if line, oldN, newN, err = parseHunk(text); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read diff: %w", err)
+	}
+	return c, nil
+}
+
+// FromGit computes the changes of the working tree (including uncommitted and untracked files)
+// against rev in the git repository containing dir. It runs git locally; nothing is fetched.
+//
+// dir: A directory inside the repository.
+// rev: The base revision (e.g. "origin/main" or "HEAD~1").
+//
+// Returns the changes, or an error if git fails (e.g. unknown revision or not a repository).
+func FromGit(dir, rev string) (*Changes, error) {
+	root, err := Root(dir)
+	if err != nil {
+		return nil, err
+	}
+	out, err := git(root, "diff", "--no-color", "--no-ext-diff", "--unified=0", rev, "--")
+	if err != nil {
+		return nil, err
+	}
+	c, err := Parse(bytes.NewReader(out), root)
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := git(root, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range strings.Split(string(untracked), "\x00") {
+		if name != "" {
+			c.whole[resolve(root, name)] = true
+		}
+	}
+	return c, nil
+}
+
+// Root returns the top-level directory of the git repository containing dir.
+func Root(dir string) (string, error) {
+	out, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// git runs a git command in dir and returns its output.
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// diffName returns the file name of a "+++" line, without the "b/" prefix and timestamp.
+// It returns "" for deleted files ("/dev/null").
+func diffName(name string) string {
+	if i := strings.IndexByte(name, '\t'); i >= 0 {
+		name = name[:i]
+	}
+	if unquoted, err := strconv.Unquote(name); err == nil {
+		name = unquoted
+	}
+	if name == "/dev/null" {
+		return ""
+	}
+	if rest, ok := strings.CutPrefix(name, "b/"); ok {
+		return rest
+	}
+	return name
+}
+
+// resolve returns the absolute path of name, relative to root.
+func resolve(root, name string) string {
+	if name == "" {
+		return ""
+	}
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(root, filepath.FromSlash(name))
+	}
+	return filepath.Clean(name)
+}
+
+// parseHunk parses a hunk header ("@@ -a,b +c,d @@").
+//
+// Returns the first line of the new file and the number of old and new lines of the hunk.
+func parseHunk(header string) (start, oldN, newN int, err error) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return 0, 0, 0, fmt.Errorf("malformed hunk header %q", header)
+	}
+	if _, oldN, err = parseRange(fields[1][1:]); err != nil {
+		return 0, 0, 0, fmt.Errorf("malformed hunk header %q", header)
+	}
+	if start, newN, err = parseRange(fields[2][1:]); err != nil {
+		return 0, 0, 0, fmt.Errorf("malformed hunk header %q", header)
+	}
+	return start, oldN, newN, nil
+}
+
+// parseRange parses a range of a hunk header ("c,d", or "c" for a single line).
+func parseRange(r string) (start, n int, err error) {
+	first, count, ok := strings.Cut(r, ",")
+	if start, err = strconv.Atoi(first); err != nil {
+		return 0, 0, err
+	}
+	if !ok {
+		return start, 1, nil
+	}
+	n, err = strconv.Atoi(count)
+	return start, n, err
+}
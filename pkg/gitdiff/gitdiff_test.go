@@ -0,0 +1,115 @@
+package gitdiff
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	patch := `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -3,4 +3,5 @@ import "os"
+ func main() {
+-	os.Remove("a")
++	os.Remove("b")
++	os.Remove("c")
+ }
+
+@@ -20,0 +22 @@ func other() {
++	os.Chdir("/")
+diff --git a/gone.go b/gone.go
+deleted file mode 100644
+--- a/gone.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package main
+--- plain.go	2026-10-16 07:00:00
++++ plain.go	2026-10-16 07:01:00
+@@ -1,2 +1,2 @@
+ package main
+-var x = 1
++var x = 2
+`
+	c, err := Parse(strings.NewReader(patch), "/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	main := filepath.Join("/repo", "main.go")
+	for line, want := range map[int]bool{3: false, 4: true, 5: true, 6: false, 22: true, 23: false} {
+		if got := c.Overlaps(main, line, line); got != want {
+			t.Errorf("main.go:%d changed = %v, want %v", line, got, want)
+		}
+	}
+	if !c.Overlaps(main, 1, 4) {
+		t.Error("range overlapping line 4 should be changed")
+	}
+	if !c.Overlaps(filepath.Join("/repo", "plain.go"), 2, 2) {
+		t.Error("plain.go:2 should be changed")
+	}
+	if c.Files() != 2 {
+		t.Errorf("Files() = %d, want 2", c.Files())
+	}
+
+	if _, err := Parse(strings.NewReader("+++ b/a.go\n@@ bad @@\n"), "/repo"); err == nil {
+		t.Error("expected error for malformed hunk header")
+	}
+}
+
+func TestRemap(t *testing.T) {
+	before := "a\nb\nc\nd\n"
+	after := "a\nx\nb\nc\ny\nd\n"
+	c, err := Parse(strings.NewReader("+++ b/f.go\n@@ -3 +3 @@\n-old\n+c\n"), "/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join("/repo", "f.go")
+	c.Remap(file, []byte(before), []byte(after))
+
+	// "c" moved from line 3 to 4; the inserted lines 2 and 5 count as changed.
+	for line, want := range map[int]bool{1: false, 2: true, 3: false, 4: true, 5: true, 6: false} {
+		if got := c.Overlaps(file, line, line); got != want {
+			t.Errorf("line %d changed = %v, want %v", line, got, want)
+		}
+	}
+}
+
+func TestFromGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	run("init", "-q")
+	_ = os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n\nvar x = 1\n"), 0644)
+	run("add", "a.go")
+	run("commit", "-q", "-m", "base")
+	_ = os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n\nvar x = 1\nvar y = 2\n"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "b.go"), []byte("package a\n"), 0644)
+
+	c, err := FromGit(dir, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, _ := Root(dir)
+	if !c.Overlaps(filepath.Join(root, "a.go"), 4, 4) || c.Overlaps(filepath.Join(root, "a.go"), 3, 3) {
+		t.Error("only line 4 of a.go should be changed")
+	}
+	if !c.Overlaps(filepath.Join(root, "b.go"), 1, 100) {
+		t.Error("untracked b.go should be changed as a whole")
+	}
+
+	if _, err := FromGit(dir, "no-such-rev"); err == nil {
+		t.Error("expected error for unknown revision")
+	}
+}
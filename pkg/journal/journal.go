@@ -0,0 +1,330 @@
+// Package journal implements atomic file writes and the undo journal of a run.
+//
+// Every run that writes files records the original content and the SHA-256 hashes before and
+// after the rewrite under <root>/runs/<id>:
+//
+//	.auto-err/runs/20261016-071053/manifest.json
+//	.auto-err/runs/20261016-071053/files/0
+//
+// Undo restores the original content of the files that still have the content written by the
+// run, so that later manual edits are never overwritten.
+package journal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DefaultRoot is the default journal directory, relative to the working directory.
+const DefaultRoot = ".auto-err"
+
+// manifestName is the name of the manifest file of a run.
+const manifestName = "manifest.json"
+
+// Entry records a file written by a run.
+type Entry struct {
+	// Path is the absolute path of the file.
+	Path string `json:"path"`
+	// Mode is the permission of the file.
+	Mode fs.FileMode `json:"mode"`
+	// Before is the SHA-256 hash of the original content.
+	Before string `json:"before"`
+	// After is the SHA-256 hash of the content written by the run.
+	After string `json:"after"`
+	// Backup is the name of the file holding the original content, relative to the run directory.
+	Backup string `json:"backup"`
+}
+
+// Run is the journal of a run.
+type Run struct {
+	// ID identifies the run; it is the name of the run directory.
+	ID string `json:"id"`
+	// Created is the start time of the run.
+	Created time.Time `json:"created"`
+	// Files are the files written by the run, in the order of their first write.
+	Files []Entry `json:"files"`
+	// Undone is set once the run has been undone.
+	Undone bool `json:"undone,omitempty"`
+
+	dir string
+}
+
+// Dir returns the directory of the run.
+func (r *Run) Dir() string {
+	return r.dir
+}
+
+// Journal records the files written by one run. The run directory is created by the first Record.
+type Journal struct {
+	root    string
+	created time.Time
+	run     *Run
+	index   map[string]int
+}
+
+// New returns a journal for a new run under root.
+//
+// root: The journal directory (e.g. DefaultRoot).
+func New(root string) *Journal {
+	return &Journal{root: root, created: time.Now(), index: make(map[string]int)}
+}
+
+// Run returns the recorded run, or nil if nothing was recorded.
+func (j *Journal) Run() *Run {
+	return j.run
+}
+
+// Record records that path is about to be rewritten from before to after, and saves the manifest.
+// The original content is only stored for the first write of path, so that undo restores the
+// content from before the run even if the file is written by several iterations.
+//
+// path: The absolute path of the file.
+// before: The current content of the file.
+// after: The content about to be written.
+// mode: The permission of the file.
+//
+// Returns an error if the journal cannot be written; the file must not be written then.
+func (j *Journal) Record(path string, before, after []byte, mode fs.FileMode) error {
+	if j.run == nil {
+		if err := j.begin(); err != nil {
+			return err
+		}
+	}
+	if i, ok := j.index[path]; ok {
+		j.run.Files[i].After = Hash(after)
+		return j.save()
+	}
+
+	backup := filepath.Join("files", strconv.Itoa(len(j.run.Files)))
+	if err := WriteFile(filepath.Join(j.run.dir, backup), before, 0600); err != nil {
+		return fmt.Errorf("journal backup of %s: %w", path, err)
+	}
+	j.index[path] = len(j.run.Files)
+	j.run.Files = append(j.run.Files, Entry{
+		Path:   path,
+		Mode:   mode,
+		Before: Hash(before),
+		After:  Hash(after),
+		Backup: backup,
+	})
+	return j.save()
+}
+
+// begin creates the run directory, named after the start time of the run.
+func (j *Journal) begin() error {
+	runs := filepath.Join(j.root, "runs")
+	if err := os.MkdirAll(runs, 0755); err != nil {
+		return fmt.Errorf("failed to create journal: %w", err)
+	}
+	base := j.created.Format("20060102-150405")
+	id := base
+	for n := 2; ; n++ {
+		err := os.Mkdir(filepath.Join(runs, id), 0755)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("failed to create journal: %w", err)
+		}
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+	dir := filepath.Join(runs, id)
+	if err := os.Mkdir(filepath.Join(dir, "files"), 0755); err != nil {
+		return fmt.Errorf("failed to create journal: %w", err)
+	}
+	j.run = &Run{ID: id, Created: j.created, Files: []Entry{}, dir: dir}
+	return nil
+}
+
+// save writes the manifest of the run.
+func (j *Journal) save() error {
+	return saveManifest(j.run)
+}
+
+// saveManifest writes the manifest of run atomically.
+func saveManifest(run *Run) error {
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := WriteFile(filepath.Join(run.dir, manifestName), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
+
+// List returns the runs recorded under root, oldest first.
+//
+// root: The journal directory.
+//
+// Returns an empty list if root does not exist.
+func List(root string) ([]*Run, error) {
+	dirs, err := os.ReadDir(filepath.Join(root, "runs"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var runs []*Run
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		run, err := Load(root, d.Name())
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	sort.SliceStable(runs, func(a, b int) bool {
+		if !runs[a].Created.Equal(runs[b].Created) {
+			return runs[a].Created.Before(runs[b].Created)
+		}
+		return runs[a].ID < runs[b].ID
+	})
+	return runs, nil
+}
+
+// Load reads the run id recorded under root.
+//
+// root: The journal directory.
+// id: The run ID.
+//
+// Returns an error if the run does not exist or its manifest is invalid.
+func Load(root, id string) (*Run, error) {
+	dir := filepath.Join(root, "runs", id)
+	data, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("run %q not found in %s", id, root)
+		}
+		return nil, err
+	}
+	var run Run
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("invalid journal %s: %w", dir, err)
+	}
+	run.dir = dir
+	return &run, nil
+}
+
+// Latest returns the most recent run under root that has not been undone.
+//
+// Returns an error if there is none.
+func Latest(root string) (*Run, error) {
+	runs, err := List(root)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(runs) - 1; i >= 0; i-- {
+		if !runs[i].Undone {
+			return runs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no run to undo in %s", root)
+}
+
+// UndoResult lists the outcome of Undo per file.
+type UndoResult struct {
+	// Restored are the files restored to their original content.
+	Restored []string
+	// Skipped are the files left untouched because they were edited after the run (or removed).
+	Skipped []string
+}
+
+// Undo restores the files of run that still have the content written by it. Files that already
+// have their original content are considered restored. The run is marked as undone.
+//
+// run: The run to undo (see Load and Latest).
+//
+// Returns the restored and skipped files, or an error if a file cannot be restored.
+func Undo(run *Run) (UndoResult, error) {
+	var res UndoResult
+	if run.Undone {
+		return res, fmt.Errorf("run %s has already been undone", run.ID)
+	}
+	for _, e := range run.Files {
+		current, err := os.ReadFile(e.Path)
+		if err != nil {
+			res.Skipped = append(res.Skipped, e.Path)
+			continue
+		}
+		switch Hash(current) {
+		case e.Before:
+			res.Restored = append(res.Restored, e.Path)
+			continue
+		case e.After:
+		default:
+			res.Skipped = append(res.Skipped, e.Path)
+			continue
+		}
+		orig, err := os.ReadFile(filepath.Join(run.dir, e.Backup))
+		if err != nil {
+			return res, fmt.Errorf("missing backup of %s: %w", e.Path, err)
+		}
+		if Hash(orig) != e.Before {
+			return res, fmt.Errorf("corrupt backup of %s", e.Path)
+		}
+		if err := WriteFile(e.Path, orig, e.Mode); err != nil {
+			return res, err
+		}
+		res.Restored = append(res.Restored, e.Path)
+	}
+	run.Undone = true
+	return res, saveManifest(run)
+}
+
+// Hash returns the hex encoded SHA-256 hash of data.
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// WriteFile writes data to path atomically: it writes a temporary file in the same directory and
+// renames it over path, so readers (and interrupted runs) see either the old or the new content.
+//
+// path: The file to write.
+// data: The content.
+// perm: The permission of the file.
+//
+// Returns an error if the file cannot be written; path is unchanged then.
+func WriteFile(path string, data []byte, perm fs.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, perm); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.go")
+	if err := os.WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(path, []byte("new"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(path)
+	if string(got) != "new" {
+		t.Errorf("got %q, want %q", got, "new")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0640 {
+		t.Errorf("mode = %v, want 0640", info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+
+	if err := WriteFile(filepath.Join(dir, "missing", "b.go"), nil, 0644); err == nil {
+		t.Error("expected error for missing directory")
+	}
+}
+
+func TestJournal_RecordAndUndo(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, DefaultRoot)
+	a := filepath.Join(dir, "a.go")
+	b := filepath.Join(dir, "b.go")
+	_ = os.WriteFile(a, []byte("a0"), 0644)
+	_ = os.WriteFile(b, []byte("b0"), 0644)
+
+	j := New(root)
+	if j.Run() != nil {
+		t.Fatal("run should be created lazily")
+	}
+	// a is written by two iterations; undo restores the content from before the run.
+	for _, w := range []struct {
+		path          string
+		before, after string
+	}{{a, "a0", "a1"}, {b, "b0", "b1"}, {a, "a1", "a2"}} {
+		if err := j.Record(w.path, []byte(w.before), []byte(w.after), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_ = os.WriteFile(w.path, []byte(w.after), 0644)
+	}
+	run := j.Run()
+	if run == nil || len(run.Files) != 2 {
+		t.Fatalf("expected 2 journaled files, got %+v", run)
+	}
+
+	// b is edited after the run and must not be overwritten.
+	_ = os.WriteFile(b, []byte("manual"), 0644)
+
+	latest, err := Latest(root)
+	if err != nil || latest.ID != run.ID {
+		t.Fatalf("Latest = %v, %v; want run %s", latest, err, run.ID)
+	}
+	res, err := Undo(latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Restored) != 1 || res.Restored[0] != a || len(res.Skipped) != 1 || res.Skipped[0] != b {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	if got, _ := os.ReadFile(a); string(got) != "a0" {
+		t.Errorf("a = %q, want a0", got)
+	}
+	if got, _ := os.ReadFile(b); string(got) != "manual" {
+		t.Errorf("b = %q, want manual", got)
+	}
+
+	reloaded, err := Load(root, run.ID)
+	if err != nil || !reloaded.Undone {
+		t.Fatalf("run should be marked undone: %+v, %v", reloaded, err)
+	}
+	if _, err := Undo(reloaded); err == nil {
+		t.Error("expected error when undoing twice")
+	}
+	if _, err := Latest(root); err == nil {
+		t.Error("expected no run to undo")
+	}
+}
+
+func TestList(t *testing.T) {
+	root := filepath.Join(t.TempDir(), DefaultRoot)
+	runs, err := List(root)
+	if err != nil || len(runs) != 0 {
+		t.Fatalf("List of missing journal = %v, %v", runs, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "a.go")
+	for i := 0; i < 2; i++ {
+		if err := New(root).Record(path, []byte("x"), []byte("y"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runs, err = List(root)
+	if err != nil || len(runs) != 2 {
+		t.Fatalf("expected 2 runs, got %v, %v", runs, err)
+	}
+	if runs[0].ID == runs[1].ID {
+		t.Errorf("run IDs should be unique: %s", runs[0].ID)
+	}
+	if _, err := Load(root, "missing"); err == nil {
+		t.Error("expected error for missing run")
+	}
+}
@@ -22,6 +22,39 @@ import (
 //
 // Returns a slice of loaded packages or an error if the loader tool itself fails.
 func LoadPackages(patterns []string, dir string) ([]*packages.Package, error) {
+	pkgs, err := LoadPackagesOverlay(patterns, dir, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	logPackageErrors(pkgs)
+	return pkgs, nil
+}
+
+// logPackageErrors logs the errors of pkgs as warnings.
+//
+// packages.Load is permissive; it often returns a package struct even if type checking fails.
+// We iterate checking for errors to warn the user, as incomplete types cause silent analysis failures.
+func logPackageErrors(pkgs []*packages.Package) {
+	for _, pkg := range pkgs {
+		for _, e := range pkg.Errors {
+			// We log as warning. The analysis might still be able to proceed partially,
+			// or the user might be running on a dirty tree.
+			log.Printf("[WARN] Package %q error: %v", pkg.PkgPath, e)
+		}
+	}
+}
+
+// LoadPackagesOverlay loads packages like LoadPackages, reading the files of overlay from memory
+// instead of disk. This allows type checking rewritten files before they are written.
+// Unlike LoadPackages, it does not log the errors of the packages.
+//
+// patterns: A list of package patterns to load.
+// dir: The working directory for the build system.
+// overlay: The contents of files, keyed by absolute path, replacing those on disk (may be nil).
+//
+// Returns the loaded packages, with type errors in Package.Errors.
+func LoadPackagesOverlay(patterns []string, dir string, overlay map[string][]byte) ([]*packages.Package, error) {
 	// Mode determines what information is loaded.
 	// We need Name, Files, and Imports for basic structure.
 	// We need Types and TypesInfo for type checking (essential for identifying error interfaces).
@@ -36,12 +69,35 @@ func LoadPackages(patterns []string, dir string) ([]*packages.Package, error) {
 		packages.NeedModule
 
 	cfg := &packages.Config{
-		Mode:  mode,
+		Mode:    mode,
+		Dir:     dir,
+		Tests:   true, // Analyze test files as well
+		Env:     os.Environ(),
+		Overlay: overlay,
+	}
+	return load(cfg, patterns, dir)
+}
+
+// ListPackages lists the packages matching patterns like LoadPackages, with their files and
+// the whole import graph, but without parsing or type checking them. It is much cheaper than
+// LoadPackages, e.g. to decide which packages need a full load.
+//
+// patterns: A list of package patterns to list.
+// dir: The working directory for the build system.
+//
+// Returns the root packages; their dependencies are reachable through Imports.
+func ListPackages(patterns []string, dir string) ([]*packages.Package, error) {
+	cfg := &packages.Config{
+		Mode:  packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps | packages.NeedModule,
 		Dir:   dir,
-		Tests: true, // Analyze test files as well
+		Tests: true,
 		Env:   os.Environ(),
 	}
+	return load(cfg, patterns, dir)
+}
 
+// load runs packages.Load with cfg and applies Smart Module Recursion (see LoadPackages).
+func load(cfg *packages.Config, patterns []string, dir string) ([]*packages.Package, error) {
 	pkgs, err := packages.Load(cfg, patterns...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to call packages.Load: %w", err)
@@ -77,18 +133,6 @@ func LoadPackages(patterns []string, dir string) ([]*packages.Package, error) {
 		}
 	}
 
-	// packages.Load is permissive; it often returns a package struct even if type checking fails.
-	// We iterate checking for errors to warn the user, as incomplete types cause silent analysis failures.
-	for _, pkg := range pkgs {
-		if len(pkg.Errors) > 0 {
-			for _, e := range pkg.Errors {
-				// We log as warning. The analysis might still be able to proceed partially,
-				// or the user might be running on a dirty tree.
-				log.Printf("[WARN] Package %q error: %v", pkg.PkgPath, e)
-			}
-		}
-	}
-
 	return pkgs, nil
 }
 
@@ -3,6 +3,7 @@ package loader
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -177,3 +178,62 @@ func TestShouldRetryRecursive_Logic(t *testing.T) {
 	// Explicitly ignore unused var to pass lint check until we mock packages.Package type internally.
 	_ = tmpDir
 }
+
+// TestLoadPackagesOverlay verifies that overlay contents replace the files on disk.
+func TestLoadPackagesOverlay(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module example.com/overlay\n\ngo 1.22\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(tmpDir, "main.go")
+	if err := os.WriteFile(path, []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	overlay := map[string][]byte{path: []byte("package main\n\nfunc main() { undefined() }\n")}
+	pkgs, err := LoadPackagesOverlay([]string{"."}, tmpDir, overlay)
+	if err != nil {
+		t.Fatalf("LoadPackagesOverlay failed: %v", err)
+	}
+	if len(pkgs) == 0 || len(pkgs[0].Errors) == 0 {
+		t.Fatal("expected a type error from the overlay")
+	}
+	if !strings.Contains(pkgs[0].Errors[0].Msg, "undefined") {
+		t.Errorf("unexpected error: %v", pkgs[0].Errors[0])
+	}
+
+	// The file on disk is unchanged.
+	pkgs, err = LoadPackagesOverlay([]string{"."}, tmpDir, nil)
+	if err != nil || len(pkgs) == 0 || len(pkgs[0].Errors) != 0 {
+		t.Errorf("expected the file on disk to compile: %v", err)
+	}
+}
+
+func TestListPackages(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module example.com/list\n\ngo 1.22\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A type error does not matter, since nothing is type checked.
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n\nimport \"os\"\n\nfunc main() { undefined(os.Args) }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs, err := ListPackages([]string{"."}, tmpDir)
+	if err != nil {
+		t.Fatalf("ListPackages failed: %v", err)
+	}
+	if len(pkgs) != 1 {
+		t.Fatalf("expected 1 package, got %d", len(pkgs))
+	}
+	pkg := pkgs[0]
+	if pkg.Types != nil || pkg.Syntax != nil || len(pkg.Errors) != 0 {
+		t.Error("expected no syntax, types or type errors")
+	}
+	if len(pkg.GoFiles) != 1 || pkg.Imports["os"] == nil || len(pkg.Imports["os"].Imports) == 0 {
+		t.Errorf("expected files and the import graph, got %v", pkg.Imports)
+	}
+	if pkg.Module == nil || !pkg.Module.Main {
+		t.Error("expected the main module")
+	}
+}
@@ -0,0 +1,412 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/types"
+	"os"
+	"sort"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/parallel"
+	"golang.org/x/tools/go/packages"
+)
+
+// errUnresolved reports an import that cannot be resolved from the loaded packages.
+var errUnresolved = errors.New("import not in the loaded packages")
+
+// Session keeps the loaded packages between the iterations of a run and updates them
+// incrementally: after files change, only the packages containing them and the packages that
+// import those (transitively) are parsed and type checked again. The other packages, including
+// all dependencies outside the patterns, keep their type information.
+//
+// Rechecked packages import the current *types.Package of every other package, so type identity
+// holds across the whole set (e.g. for interface satisfaction and callers of changed functions).
+// When a rewrite adds an import that no loaded package provides, the session falls back to a
+// full load.
+//
+// Packages that do not import each other are checked concurrently.
+type Session struct {
+	patterns []string
+	dir      string
+	jobs     int
+	pkgs     []*packages.Package
+}
+
+// NewSession loads the packages matching patterns (see LoadPackages).
+//
+// patterns: A list of package patterns to load.
+// dir: The working directory for the build system.
+// jobs: The maximum number of packages type checked at a time (GOMAXPROCS if <= 0).
+//
+// Returns the session, or an error if the loader fails.
+func NewSession(patterns []string, dir string, jobs int) (*Session, error) {
+	pkgs, err := LoadPackages(patterns, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{patterns: patterns, dir: dir, jobs: jobs, pkgs: pkgs}, nil
+}
+
+// Packages returns the current packages.
+func (s *Session) Packages() []*packages.Package {
+	return s.pkgs
+}
+
+// Reload updates the packages after files changed on disk, and logs their errors like
+// LoadPackages.
+//
+// changed: The absolute paths of the changed files.
+//
+// Returns the current packages and the number of packages that were type checked again.
+func (s *Session) Reload(changed []string) ([]*packages.Package, int, error) {
+	pkgs, n, err := s.check(changed, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	s.pkgs = pkgs
+	logPackageErrors(pkgs)
+	return pkgs, n, nil
+}
+
+// Check type checks the packages as if the files of overlay replaced those on disk, without
+// changing the session (see LoadPackagesOverlay).
+//
+// overlay: The contents of files, keyed by absolute path.
+//
+// Returns the packages with the overlay applied, with type errors in Package.Errors.
+func (s *Session) Check(overlay map[string][]byte) ([]*packages.Package, error) {
+	changed := make([]string, 0, len(overlay))
+	for path := range overlay {
+		changed = append(changed, path)
+	}
+	pkgs, _, err := s.check(changed, overlay)
+	return pkgs, err
+}
+
+// check type checks the packages affected by changed, reading overlay before the disk.
+func (s *Session) check(changed []string, overlay map[string][]byte) ([]*packages.Package, int, error) {
+	order := s.affected(changed)
+	if len(order) == 0 {
+		return s.pkgs, 0, nil
+	}
+
+	files, err := s.parse(order, overlay)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	current := make(map[string]*packages.Package, len(order))
+	for _, level := range levels(order) {
+		// The packages of a level only import packages of earlier levels, which are in current.
+		next := make([]*packages.Package, len(level))
+		errs := make([]error, len(level))
+		_ = parallel.ForEach(len(level), s.jobs, func(i int) error {
+			next[i], errs[i] = s.recheck(level[i], current, files)
+			return nil
+		})
+		for _, err := range errs {
+			if errors.Is(err, errUnresolved) {
+				pkgs, err := LoadPackagesOverlay(s.patterns, s.dir, overlay)
+				return pkgs, len(pkgs), err
+			}
+		}
+		for i, pkg := range level {
+			if errs[i] != nil {
+				return nil, 0, errs[i]
+			}
+			current[pkg.ID] = next[i]
+		}
+	}
+
+	out := make([]*packages.Package, len(s.pkgs))
+	for i, pkg := range s.pkgs {
+		if next, ok := current[pkg.ID]; ok {
+			out[i] = next
+		} else {
+			out[i] = pkg
+		}
+	}
+	return out, len(order), nil
+}
+
+// affected returns the packages containing one of files and the packages importing them,
+// transitively, with dependencies before the packages importing them.
+func (s *Session) affected(files []string) []*packages.Package {
+	changed := make(map[string]bool, len(files))
+	for _, f := range files {
+		changed[f] = true
+	}
+
+	importers := make(map[string][]*packages.Package)
+	for _, pkg := range s.pkgs {
+		for _, imp := range pkg.Imports {
+			importers[imp.ID] = append(importers[imp.ID], pkg)
+		}
+	}
+
+	marked := make(map[string]bool)
+	var queue []*packages.Package
+	for _, pkg := range s.pkgs {
+		for _, f := range pkg.CompiledGoFiles {
+			if changed[f] {
+				marked[pkg.ID] = true
+				queue = append(queue, pkg)
+				break
+			}
+		}
+	}
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+		for _, imp := range importers[pkg.ID] {
+			if !marked[imp.ID] {
+				marked[imp.ID] = true
+				queue = append(queue, imp)
+			}
+		}
+	}
+
+	// Order the marked packages topologically; the load order breaks ties deterministically.
+	var order []*packages.Package
+	done := make(map[string]bool)
+	var visit func(pkg *packages.Package)
+	visit = func(pkg *packages.Package) {
+		if done[pkg.ID] || !marked[pkg.ID] {
+			return
+		}
+		done[pkg.ID] = true
+		paths := make([]string, 0, len(pkg.Imports))
+		for path := range pkg.Imports {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			visit(pkg.Imports[path])
+		}
+		order = append(order, pkg)
+	}
+	for _, pkg := range s.pkgs {
+		visit(pkg)
+	}
+	return order
+}
+
+// levels groups the topologically ordered packages so that each package only imports packages
+// of earlier groups, keeping their order within a group.
+func levels(order []*packages.Package) [][]*packages.Package {
+	depth := make(map[string]int, len(order))
+	var out [][]*packages.Package
+	for _, pkg := range order {
+		d := 0
+		for _, imp := range pkg.Imports {
+			if di, ok := depth[imp.ID]; ok && di+1 > d {
+				d = di + 1
+			}
+		}
+		depth[pkg.ID] = d
+		if d == len(out) {
+			out = append(out, nil)
+		}
+		out[d] = append(out[d], pkg)
+	}
+	return out
+}
+
+// parsedFile is a file parsed once for all the packages containing it.
+type parsedFile struct {
+	file *ast.File
+	errs []packages.Error
+}
+
+// parse parses the files of pkgs, once per file: package variants (e.g. "p" and "p [p.test]")
+// share their syntax, as after a full load, so that a node found in one variant is the node of
+// the others.
+//
+// pkgs: The packages to check again.
+// overlay: The contents of files replacing those on disk (may be nil).
+//
+// Returns the parsed files by name, or an error if a file cannot be read.
+func (s *Session) parse(pkgs []*packages.Package, overlay map[string][]byte) (map[string]parsedFile, error) {
+	var names []string
+	seen := make(map[string]bool)
+	for _, pkg := range pkgs {
+		for _, name := range pkg.CompiledGoFiles {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	fset := pkgs[0].Fset
+	parsed := make([]parsedFile, len(names))
+	err := parallel.ForEach(len(names), s.jobs, func(i int) error {
+		src, ok := overlay[names[i]]
+		if !ok {
+			var err error
+			if src, err = os.ReadFile(names[i]); err != nil {
+				return fmt.Errorf("failed to read %s: %w", names[i], err)
+			}
+		}
+		f, err := parser.ParseFile(fset, names[i], src, parser.AllErrors|parser.ParseComments)
+		parsed[i].file = f
+		if err != nil {
+			parsed[i].errs = parseErrors(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]parsedFile, len(names))
+	for i, name := range names {
+		files[name] = parsed[i]
+	}
+	return files, nil
+}
+
+// recheck type checks pkg again.
+//
+// pkg: The package to check.
+// current: The packages already checked again, by ID.
+// files: The files of the package, parsed again (see parse).
+//
+// Returns a copy of pkg with new syntax, types and errors, or errUnresolved if an import is
+// not available.
+func (s *Session) recheck(pkg *packages.Package, current map[string]*packages.Package, files map[string]parsedFile) (*packages.Package, error) {
+	next := *pkg
+	next.Errors = nil
+	next.Syntax = make([]*ast.File, 0, len(pkg.CompiledGoFiles))
+	for _, name := range pkg.CompiledGoFiles {
+		parsed := files[name]
+		if parsed.file != nil {
+			next.Syntax = append(next.Syntax, parsed.file)
+		}
+		next.Errors = append(next.Errors, parsed.errs...)
+	}
+
+	next.Imports = make(map[string]*packages.Package, len(pkg.Imports))
+	for path, imp := range pkg.Imports {
+		if cur, ok := current[imp.ID]; ok {
+			imp = cur
+		}
+		next.Imports[path] = imp
+	}
+
+	var unresolved error
+	importer := importerFunc(func(path string) (*types.Package, error) {
+		if path == "unsafe" {
+			return types.Unsafe, nil
+		}
+		if imp, ok := next.Imports[path]; ok && imp.Types != nil {
+			return imp.Types, nil
+		}
+		// A rewrite added the import (e.g. "fmt" for wrapped errors); reuse a loaded copy.
+		if imp := s.find(path, current); imp != nil {
+			next.Imports[path] = imp
+			return imp.Types, nil
+		}
+		unresolved = fmt.Errorf("%w: %s", errUnresolved, path)
+		return nil, unresolved
+	})
+
+	next.Types = types.NewPackage(pkg.PkgPath, pkg.Name)
+	next.TypesInfo = &types.Info{
+		Types:        make(map[ast.Expr]types.TypeAndValue),
+		Defs:         make(map[*ast.Ident]types.Object),
+		Uses:         make(map[*ast.Ident]types.Object),
+		Implicits:    make(map[ast.Node]types.Object),
+		Instances:    make(map[*ast.Ident]types.Instance),
+		Scopes:       make(map[ast.Node]*types.Scope),
+		Selections:   make(map[*ast.SelectorExpr]*types.Selection),
+		FileVersions: make(map[*ast.File]string),
+	}
+	tc := &types.Config{
+		Importer: importer,
+		Sizes:    pkg.TypesSizes,
+		Error: func(err error) {
+			next.Errors = append(next.Errors, typeError(err))
+		},
+	}
+	if pkg.Module != nil && pkg.Module.GoVersion != "" {
+		tc.GoVersion = "go" + pkg.Module.GoVersion
+	}
+	_ = types.NewChecker(tc, pkg.Fset, next.Types, next.TypesInfo).Files(next.Syntax)
+	if unresolved != nil {
+		return nil, unresolved
+	}
+	next.IllTyped = len(next.Errors) > 0
+	return &next, nil
+}
+
+// find returns a loaded package with the import path, preferring the current version of the
+// packages of the session, or nil if none is loaded.
+func (s *Session) find(path string, current map[string]*packages.Package) *packages.Package {
+	seen := make(map[string]bool)
+	var found *packages.Package
+	var walk func(pkg *packages.Package) bool
+	walk = func(pkg *packages.Package) bool {
+		if seen[pkg.ID] {
+			return false
+		}
+		seen[pkg.ID] = true
+		if cur, ok := current[pkg.ID]; ok {
+			pkg = cur
+		}
+		// Test variants ("p [p.test]") carry the path too; only accept the plain package.
+		// Dependencies that were not loaded only have a placeholder for their types.
+		if pkg.PkgPath == path && pkg.ID == path && pkg.Types != nil && pkg.Types.Complete() {
+			found = pkg
+			return true
+		}
+		for _, imp := range pkg.Imports {
+			if walk(imp) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, pkg := range s.pkgs {
+		if walk(pkg) {
+			return found
+		}
+	}
+	return nil
+}
+
+// importerFunc implements types.Importer with a function.
+type importerFunc func(path string) (*types.Package, error)
+
+// Import imports the package with the path.
+func (f importerFunc) Import(path string) (*types.Package, error) {
+	return f(path)
+}
+
+// parseErrors converts the error of parser.ParseFile into package errors.
+func parseErrors(err error) []packages.Error {
+	var list scanner.ErrorList
+	if !errors.As(err, &list) {
+		return []packages.Error{{Pos: "-", Msg: err.Error(), Kind: packages.ParseError}}
+	}
+	out := make([]packages.Error, 0, len(list))
+	for _, e := range list {
+		out = append(out, packages.Error{Pos: e.Pos.String(), Msg: e.Msg, Kind: packages.ParseError})
+	}
+	return out
+}
+
+// typeError converts an error of the type checker into a package error.
+func typeError(err error) packages.Error {
+	var te types.Error
+	if errors.As(err, &te) {
+		return packages.Error{Pos: te.Fset.Position(te.Pos).String(), Msg: te.Msg, Kind: packages.TypeError}
+	}
+	return packages.Error{Pos: "-", Msg: err.Error(), Kind: packages.TypeError}
+}
@@ -0,0 +1,215 @@
+package loader
+
+import (
+	"go/ast"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// writeSessionModule writes a module where b imports a and c is independent.
+func writeSessionModule(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":    "module example.com/session\n\ngo 1.22\n",
+		"a/a.go":    "package a\n\ntype Doer interface{ Do() }\n\nfunc Load() {}\n",
+		"b/b.go":    "package b\n\nimport \"example.com/session/a\"\n\ntype T struct{}\n\nfunc (T) Do() {}\n\nvar _ a.Doer = T{}\n\nfunc Run() { a.Load() }\n",
+		"c/c.go":    "package c\n\nimport \"strings\"\n\nvar S = strings.ToUpper(\"c\")\n",
+		"c/util.go": "package c\n\nimport \"fmt\"\n\nvar F = fmt.Sprint(1)\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		_ = os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func byPath(pkgs []*packages.Package) map[string]*packages.Package {
+	out := make(map[string]*packages.Package)
+	for _, p := range pkgs {
+		out[p.ID] = p
+	}
+	return out
+}
+
+func TestSession_Reload(t *testing.T) {
+	dir := writeSessionModule(t)
+	s, err := NewSession([]string{"./..."}, dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := byPath(s.Packages())
+
+	// Load gains an error result and a "fmt" import, which a does not import yet; the copy
+	// loaded for c is reused.
+	aFile := filepath.Join(dir, "a", "a.go")
+	_ = os.WriteFile(aFile, []byte("package a\n\nimport \"fmt\"\n\ntype Doer interface{ Do() }\n\nfunc Load() error { return fmt.Errorf(\"x\") }\n"), 0644)
+
+	pkgs, n, err := s.Reload([]string{aFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected a and b to be checked again, got %d packages", n)
+	}
+	after := byPath(pkgs)
+	a, b, c := after["example.com/session/a"], after["example.com/session/b"], after["example.com/session/c"]
+	if c != before["example.com/session/c"] {
+		t.Error("the independent package should be reused")
+	}
+	if b.Imports["example.com/session/a"].Types != a.Types {
+		t.Error("b should import the new types of a")
+	}
+	load := a.Types.Scope().Lookup("Load")
+	if load == nil || !strings.Contains(load.Type().String(), "error") {
+		t.Errorf("Load should return an error, got %v", load)
+	}
+	if len(b.Errors) != 0 {
+		t.Errorf("b should still compile (the result is dropped): %v", b.Errors)
+	}
+	for _, f := range b.Syntax {
+		if b.Fset.File(f.Pos()) == nil {
+			t.Error("rechecked syntax should be in the shared file set")
+		}
+	}
+}
+
+// TestSession_ReloadUnresolvedImport verifies the full load when a new import is not loaded.
+func TestSession_ReloadUnresolvedImport(t *testing.T) {
+	dir := writeSessionModule(t)
+	s, err := NewSession([]string{"./..."}, dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aFile := filepath.Join(dir, "a", "a.go")
+	_ = os.WriteFile(aFile, []byte("package a\n\nimport \"encoding/json\"\n\ntype Doer interface{ Do() }\n\nfunc Load() { _, _ = json.Marshal(1) }\n"), 0644)
+
+	pkgs, n, err := s.Reload([]string{aFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(pkgs) {
+		t.Errorf("expected a full load, got %d of %d packages", n, len(pkgs))
+	}
+	if a := byPath(pkgs)["example.com/session/a"]; a == nil || len(a.Errors) != 0 {
+		t.Errorf("a should load without errors: %v", a)
+	}
+}
+
+// TestSession_CheckIndirectImport verifies that an import added by a rewrite that is only an
+// indirect dependency of the loaded packages (e.g. "errors" through "fmt") is fully type checked.
+func TestSession_CheckIndirectImport(t *testing.T) {
+	dir := writeSessionModule(t)
+	s, err := NewSession([]string{"./..."}, dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aFile := filepath.Join(dir, "a", "a.go")
+	pkgs, err := s.Check(map[string][]byte{
+		aFile: []byte("package a\n\nimport \"errors\"\n\ntype Doer interface{ Do() }\n\nfunc Load() error { return errors.Join(nil) }\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := byPath(pkgs)["example.com/session/a"]; a == nil || len(a.Errors) != 0 {
+		t.Errorf("a should check without errors: %v", a.Errors)
+	}
+}
+
+// TestSession_ReloadVariants verifies that the variants of a package with tests share the syntax of
+// their common files after a reload, as after the full load.
+func TestSession_ReloadVariants(t *testing.T) {
+	dir := writeSessionModule(t)
+	aTest := filepath.Join(dir, "a", "a_test.go")
+	if err := os.WriteFile(aTest, []byte("package a\n\nimport \"testing\"\n\nfunc TestLoad(t *testing.T) { Load() }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSession([]string{"./..."}, dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aFile := filepath.Join(dir, "a", "a.go")
+	_ = os.WriteFile(aFile, []byte("package a\n\ntype Doer interface{ Do() }\n\nfunc Load() error { return nil }\n"), 0644)
+
+	pkgs, _, err := s.Reload([]string{aFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := byPath(pkgs)
+	a, aTested := after["example.com/session/a"], after["example.com/session/a [example.com/session/a.test]"]
+	if a == nil || aTested == nil {
+		t.Fatalf("expected both variants of a, got %v", pkgs)
+	}
+	syntax := func(pkg *packages.Package) *ast.File {
+		for _, f := range pkg.Syntax {
+			if pkg.Fset.File(f.Pos()).Name() == aFile {
+				return f
+			}
+		}
+		return nil
+	}
+	if f := syntax(a); f == nil || f != syntax(aTested) {
+		t.Error("the variants of a should share the syntax of a.go")
+	}
+	if len(aTested.Errors) != 0 {
+		t.Errorf("the test variant should check without errors: %v", aTested.Errors)
+	}
+}
+
+func TestSession_Check(t *testing.T) {
+	dir := writeSessionModule(t)
+	s, err := NewSession([]string{"./..."}, dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bFile := filepath.Join(dir, "b", "b.go")
+	overlay := map[string][]byte{
+		bFile: []byte("package b\n\nimport \"example.com/session/a\"\n\nfunc Run() error { return a.Load() }\n"),
+	}
+	pkgs, err := s.Check(overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := byPath(pkgs)["example.com/session/b"]
+	if len(b.Errors) == 0 || !strings.Contains(b.Errors[0].Msg, "a.Load()") || !strings.HasPrefix(b.Errors[0].Pos, bFile+":5:") {
+		t.Errorf("expected a type error at b.go:5, got %v", b.Errors)
+	}
+	if byPath(s.Packages())["example.com/session/b"].Errors != nil {
+		t.Error("Check should not change the session")
+	}
+
+	overlay[bFile] = []byte("package b\n\nfunc Run( {\n")
+	pkgs, err = s.Check(overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := byPath(pkgs)["example.com/session/b"].Errors; len(errs) == 0 || errs[0].Kind != packages.ParseError {
+		t.Errorf("expected a parse error, got %v", errs)
+	}
+}
+
+func TestLevels(t *testing.T) {
+	a := &packages.Package{ID: "a"}
+	b := &packages.Package{ID: "b", Imports: map[string]*packages.Package{"a": a}}
+	c := &packages.Package{ID: "c"}
+	d := &packages.Package{ID: "d", Imports: map[string]*packages.Package{"b": b, "c": c, "fmt": {ID: "fmt"}}}
+
+	var got []string
+	for _, level := range levels([]*packages.Package{a, b, c, d}) {
+		var ids []string
+		for _, pkg := range level {
+			ids = append(ids, pkg.ID)
+		}
+		got = append(got, strings.Join(ids, ","))
+	}
+	if want := "a,c b d"; strings.Join(got, " ") != want {
+		t.Errorf("levels = %q, want %q", strings.Join(got, " "), want)
+	}
+}
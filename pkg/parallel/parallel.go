@@ -0,0 +1,61 @@
+// Package parallel runs independent work items on a bounded number of goroutines.
+//
+// Results are written by index, so callers merge them in input order and the output does not
+// depend on scheduling.
+package parallel
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Jobs returns the number of workers for the requested value: n if positive, otherwise
+// GOMAXPROCS.
+func Jobs(n int) int {
+	if n > 0 {
+		return n
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// ForEach calls fn for every index in [0, n), running at most jobs calls at a time (see Jobs).
+// With a single job, the calls run in order on the calling goroutine.
+//
+// n: The number of items.
+// jobs: The maximum number of concurrent calls (GOMAXPROCS if <= 0).
+// fn: The work for one item. It must only write state owned by its index.
+//
+// Returns the error of the lowest failing index, so that the error is deterministic. All items
+// are processed even if one fails.
+func ForEach(n, jobs int, fn func(i int) error) error {
+	errs := make([]error, n)
+	jobs = min(Jobs(jobs), n)
+	if jobs <= 1 {
+		for i := 0; i < n; i++ {
+			errs[i] = fn(i)
+		}
+	} else {
+		next := make(chan int)
+		var wg sync.WaitGroup
+		for w := 0; w < jobs; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range next {
+					errs[i] = fn(i)
+				}
+			}()
+		}
+		for i := 0; i < n; i++ {
+			next <- i
+		}
+		close(next)
+		wg.Wait()
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
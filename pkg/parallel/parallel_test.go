@@ -0,0 +1,71 @@
+package parallel
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+)
+
+// TestJobs verifies the default number of workers.
+func TestJobs(t *testing.T) {
+	if got := Jobs(3); got != 3 {
+		t.Errorf("Jobs(3) = %d", got)
+	}
+	if got := Jobs(0); got != runtime.GOMAXPROCS(0) {
+		t.Errorf("Jobs(0) = %d, want GOMAXPROCS", got)
+	}
+}
+
+// TestForEach verifies that every item is processed once and that concurrency is bounded.
+func TestForEach(t *testing.T) {
+	for _, jobs := range []int{1, 2, 8} {
+		t.Run(fmt.Sprint(jobs), func(t *testing.T) {
+			out := make([]int, 100)
+			var running, peak atomic.Int32
+			err := ForEach(len(out), jobs, func(i int) error {
+				n := running.Add(1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				runtime.Gosched()
+				out[i] = i * i
+				running.Add(-1)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, v := range out {
+				if v != i*i {
+					t.Fatalf("item %d = %d", i, v)
+				}
+			}
+			if p := int(peak.Load()); p > jobs {
+				t.Errorf("%d concurrent calls, want at most %d", p, jobs)
+			}
+		})
+	}
+}
+
+// TestForEach_Error verifies that the error of the lowest index is returned after all items ran.
+func TestForEach_Error(t *testing.T) {
+	var calls atomic.Int32
+	err := ForEach(10, 4, func(i int) error {
+		calls.Add(1)
+		if i == 3 || i == 7 {
+			return fmt.Errorf("item %d: %w", i, errors.ErrUnsupported)
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "item 3: unsupported operation" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if calls.Load() != 10 {
+		t.Errorf("%d calls, want 10", calls.Load())
+	}
+}
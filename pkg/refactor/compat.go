@@ -0,0 +1,212 @@
+package refactor
+
+import (
+	"fmt"
+	"go/token"
+
+	"github.com/dave/dst"
+)
+
+// HandlerLog uses log.Printf and continues. It is only valid for compatibility wrappers,
+// where the error cannot be returned.
+const HandlerLog MainHandlerStrategy = "log"
+
+// AddCompatWrapperDST publishes an error-returning variant of a function without changing its API.
+//
+// decl must already return the error (see AddErrorToSignatureDST) and contain the handled body.
+// A copy of decl named decl.Name+suffix is appended to the file, and decl is turned into a
+// deprecated wrapper with the original signature, which calls the new variant and handles the
+// error according to strategy.
+//
+// file: The DST file containing decl.
+// decl: The function declaration, already rewritten to return an error.
+// orig: A clone of the function type before the error result was added.
+// suffix: The suffix of the variant name (e.g. "E" for FooE).
+// strategy: HandlerLog, HandlerLogFatal, HandlerOsExit, HandlerPanic, HandlerSlogError or
+// HandlerSlogErrorExit.
+// logger: The logger expression of the slog strategies, or "" for the slog package functions.
+//
+// Returns the new variant declaration.
+func AddCompatWrapperDST(file *dst.File, decl *dst.FuncDecl, orig *dst.FuncType, suffix string, strategy MainHandlerStrategy, logger string) (*dst.FuncDecl, error) {
+	if file == nil || decl == nil || orig == nil {
+		return nil, fmt.Errorf("nil file, declaration or original signature")
+	}
+	if suffix == "" {
+		return nil, fmt.Errorf("empty variant suffix for %s", decl.Name.Name)
+	}
+
+	name := decl.Name.Name
+	variantName := name + suffix
+
+	// 1. The variant keeps the rewritten signature and body.
+	variant := dst.Clone(decl).(*dst.FuncDecl)
+	variant.Name = dst.NewIdent(variantName)
+	variant.Decs.Start.Replace(fmt.Sprintf("// %s is like %s but returns the error instead of handling it.", variantName, name))
+	variant.Decs.End.Clear()
+	variant.Decs.Before = dst.EmptyLine
+	variant.Decs.After = dst.NewLine
+
+	// 2. The wrapper restores the original signature, naming parameters where necessary.
+	used := reservedWrapperNames(orig, variantName)
+	decl.Type = orig
+
+	recvName := ""
+	if decl.Recv != nil && len(decl.Recv.List) > 0 {
+		field := decl.Recv.List[0]
+		if len(field.Names) == 0 || field.Names[0].Name == "_" {
+			field.Names = []*dst.Ident{dst.NewIdent(used.fresh(nameForDstExpr(field.Type)))}
+		}
+		recvName = field.Names[0].Name
+		used[recvName] = true
+	}
+
+	var args []dst.Expr
+	variadic := false
+	if orig.Params != nil {
+		for _, field := range orig.Params.List {
+			if len(field.Names) == 0 {
+				field.Names = []*dst.Ident{dst.NewIdent(used.fresh(paramNameDST(field.Type)))}
+			}
+			for k, n := range field.Names {
+				if n.Name == "_" {
+					field.Names[k] = dst.NewIdent(used.fresh(paramNameDST(field.Type)))
+				}
+				args = append(args, dst.NewIdent(field.Names[k].Name))
+			}
+			_, variadic = field.Type.(*dst.Ellipsis)
+		}
+	}
+
+	// 3. Build the call to the variant.
+	var fun dst.Expr = dst.NewIdent(variantName)
+	if recvName != "" {
+		fun = &dst.SelectorExpr{X: dst.NewIdent(recvName), Sel: dst.NewIdent(variantName)}
+	} else if orig.TypeParams != nil {
+		// Type arguments may not be inferable from the arguments (e.g. when only used in results).
+		var targs []dst.Expr
+		for _, field := range orig.TypeParams.List {
+			for _, n := range field.Names {
+				targs = append(targs, dst.NewIdent(n.Name))
+			}
+		}
+		if len(targs) == 1 {
+			fun = &dst.IndexExpr{X: fun, Index: targs[0]}
+		} else if len(targs) > 1 {
+			fun = &dst.IndexListExpr{X: fun, Indices: targs}
+		}
+	}
+	call := &dst.CallExpr{Fun: fun, Args: args, Ellipsis: variadic}
+
+	var results []dst.Expr
+	if orig.Results != nil {
+		for _, field := range orig.Results.List {
+			count := len(field.Names)
+			if count == 0 {
+				count = 1
+			}
+			for k := 0; k < count; k++ {
+				results = append(results, dst.NewIdent(used.fresh(nameForDstExpr(field.Type))))
+			}
+		}
+	}
+	errName := used.fresh("err")
+
+	check := &dst.IfStmt{
+		Cond: &dst.BinaryExpr{X: dst.NewIdent(errName), Op: token.NEQ, Y: dst.NewIdent("nil")},
+		Body: compatHandlerBody(name, errName, strategy, logger),
+	}
+
+	var body []dst.Stmt
+	if len(results) == 0 {
+		check.Init = &dst.AssignStmt{Lhs: []dst.Expr{dst.NewIdent(errName)}, Tok: token.DEFINE, Rhs: []dst.Expr{call}}
+		body = []dst.Stmt{check}
+	} else {
+		lhs := make([]dst.Expr, 0, len(results)+1)
+		ret := make([]dst.Expr, 0, len(results))
+		for _, r := range results {
+			lhs = append(lhs, dst.Clone(r).(dst.Expr))
+			ret = append(ret, r)
+		}
+		lhs = append(lhs, dst.NewIdent(errName))
+		body = []dst.Stmt{
+			&dst.AssignStmt{Lhs: lhs, Tok: token.DEFINE, Rhs: []dst.Expr{call}},
+			check,
+			&dst.ReturnStmt{Results: ret},
+		}
+	}
+	decl.Body = &dst.BlockStmt{List: body}
+
+	// 4. Point readers of the wrapper at the variant.
+	deprecation := fmt.Sprintf("// Deprecated: Use %s, which returns the error instead of handling it.", variantName)
+	if len(decl.Decs.Start.All()) > 0 {
+		decl.Decs.Start.Append("//", deprecation)
+	} else {
+		decl.Decs.Start.Append(deprecation)
+	}
+
+	file.Decls = append(file.Decls, variant)
+	return variant, nil
+}
+
+// compatHandlerBody generates the error handling of a compatibility wrapper.
+func compatHandlerBody(funcName, errName string, strategy MainHandlerStrategy, logger string) *dst.BlockStmt {
+	if strategy == HandlerLog || strategy == "" {
+		return &dst.BlockStmt{List: []dst.Stmt{
+			&dst.ExprStmt{X: &dst.CallExpr{
+				Fun: &dst.SelectorExpr{X: dst.NewIdent("log"), Sel: dst.NewIdent("Printf")},
+				Args: []dst.Expr{
+					&dst.BasicLit{Kind: token.STRING, Value: fmt.Sprintf(`"%s: %%v"`, funcName)},
+					dst.NewIdent(errName),
+				},
+			}},
+		}}
+	}
+	body := generateDstTerminalBody(strategy, "", logger, funcName)
+	if errName != "err" {
+		dst.Inspect(body, func(n dst.Node) bool {
+			if id, ok := n.(*dst.Ident); ok && id.Name == "err" {
+				id.Name = errName
+			}
+			return true
+		})
+	}
+	return body
+}
+
+// paramNameDST names a parameter after its type, using the element type of variadic parameters.
+func paramNameDST(expr dst.Expr) string {
+	if e, ok := expr.(*dst.Ellipsis); ok {
+		return nameForDstExpr(e.Elt)
+	}
+	return nameForDstExpr(expr)
+}
+
+// wrapperNames tracks identifiers in use within a generated wrapper.
+type wrapperNames map[string]bool
+
+// reservedWrapperNames collects the names a wrapper must not shadow or redeclare: its parameters,
+// type parameters, named results, the variant and the packages referenced by the handlers.
+func reservedWrapperNames(ft *dst.FuncType, variantName string) wrapperNames {
+	used := wrapperNames{variantName: true, "log": true, "fmt": true, "os": true}
+	for _, list := range []*dst.FieldList{ft.TypeParams, ft.Params, ft.Results} {
+		if list == nil {
+			continue
+		}
+		for _, field := range list.List {
+			for _, n := range field.Names {
+				used[n.Name] = true
+			}
+		}
+	}
+	return used
+}
+
+// fresh returns base, or base with a numeric suffix, such that the name is unused and not a keyword.
+func (w wrapperNames) fresh(base string) string {
+	name := base
+	for k := 1; w[name] || token.IsKeyword(name) || name == "_"; k++ {
+		name = fmt.Sprintf("%s%d", base, k)
+	}
+	w[name] = true
+	return name
+}
@@ -0,0 +1,113 @@
+package refactor
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+)
+
+// TestAddCompatWrapperDST verifies the variant and wrapper generated for various signatures.
+func TestAddCompatWrapperDST(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		strategy MainHandlerStrategy
+		logger   string
+		want     []string
+	}{
+		{
+			name:     "Void",
+			src:      "// Close closes.\nfunc Close(name string) {\n\tclean()\n}",
+			strategy: HandlerLog,
+			want: []string{
+				"// Close closes.\n//\n// Deprecated: Use CloseE, which returns the error instead of handling it.\nfunc Close(name string) {",
+				"if err := CloseE(name); err != nil {\n\t\tlog.Printf(\"Close: %v\", err)",
+				"// CloseE is like Close but returns the error instead of handling it.\nfunc CloseE(name string) error {",
+			},
+		},
+		{
+			name:     "ResultsAndUnnamedParams",
+			src:      "func Count(string, ...int) int {\n\treturn 0\n}",
+			strategy: HandlerPanic,
+			want: []string{
+				"func Count(s string, i ...int) int {",
+				"i1, err := CountE(s, i...)",
+				"panic(err)",
+				"return i1",
+			},
+		},
+		{
+			name:     "Method",
+			src:      "func (*Store) Get(err string) (v string) {\n\treturn err\n}",
+			strategy: HandlerLogFatal,
+			want: []string{
+				"func (store *Store) Get(err string) (v string) {",
+				"s, err1 := store.GetE(err)",
+				"log.Fatal(err1)",
+				"func (*Store) GetE(err string)",
+			},
+		},
+		{
+			name:     "Generic",
+			src:      "func Zero[T any]() T {\n\tvar z T\n\treturn z\n}",
+			strategy: HandlerLog,
+			want:     []string{"t, err := ZeroE[T]()"},
+		},
+		{
+			name:     "SlogLogger",
+			src:      "func (s *Server) Stop() {\n\ts.close()\n}",
+			strategy: HandlerSlogErrorExit,
+			logger:   "s.logger",
+			want: []string{
+				"if err := s.StopE(); err != nil {\n\t\ts.logger.Error(\"operation failed\", \"op\", \"Stop\", \"err\", err)\n\t\tos.Exit(1)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := decorator.Parse("package p\n\n" + tt.src + "\n")
+			if err != nil {
+				t.Fatal(err)
+			}
+			decl := file.Decls[0].(*dst.FuncDecl)
+			orig := dst.Clone(decl.Type).(*dst.FuncType)
+			if _, err := AddErrorToSignatureDST(decl); err != nil {
+				t.Fatal(err)
+			}
+
+			variant, err := AddCompatWrapperDST(file, decl, orig, "E", tt.strategy, tt.logger)
+			if err != nil {
+				t.Fatalf("AddCompatWrapperDST failed: %v", err)
+			}
+			if file.Decls[len(file.Decls)-1] != variant {
+				t.Error("variant should be appended to the file")
+			}
+
+			var buf bytes.Buffer
+			if err := decorator.Fprint(&buf, file); err != nil {
+				t.Fatal(err)
+			}
+			out := buf.String()
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("output missing %q:\n%s", want, out)
+				}
+			}
+		})
+	}
+}
+
+// TestAddCompatWrapperDST_Errors verifies invalid arguments are rejected.
+func TestAddCompatWrapperDST_Errors(t *testing.T) {
+	decl := parseDstFuncDecl(t, "func F() {}")
+	if _, err := AddCompatWrapperDST(nil, decl, decl.Type, "E", HandlerLog, ""); err == nil {
+		t.Error("expected error for nil file")
+	}
+	if _, err := AddCompatWrapperDST(&dst.File{}, decl, decl.Type, "", HandlerLog, ""); err == nil {
+		t.Error("expected error for empty suffix")
+	}
+}
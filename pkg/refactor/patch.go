@@ -46,30 +46,7 @@ func PatchSignature(info *types.Info, decl *ast.FuncDecl, pkg *types.Package) er
 	// 2. Construct New Signature
 	// We rely on oldSig for everything except the extra result.
 	// This preserves parameter types and imports.
-
-	params := oldSig.Params()
-	oldResults := oldSig.Results()
-
-	var newVars []*types.Var
-
-	// Copy existing results
-	// Check for nil to avoid iterating a nil tuple pointer if strictly void
-	if oldResults != nil {
-		for i := 0; i < oldResults.Len(); i++ {
-			newVars = append(newVars, oldResults.At(i))
-		}
-	}
-
-	// Create Error Var
-	// We use Universe 'error'
-	errType := types.Universe.Lookup("error").Type()
-	errVar := types.NewVar(token.NoPos, pkg, "", errType)
-	newVars = append(newVars, errVar)
-
-	newResults := types.NewTuple(newVars...)
-
-	// Create Signature
-	newSig := types.NewSignature(oldSig.Recv(), params, newResults, oldSig.Variadic())
+	newSig := WithErrorResult(oldSig, pkg)
 
 	// 3. Create New Func Object
 	// We reuse position and package from old object
@@ -88,14 +65,46 @@ func PatchSignature(info *types.Info, decl *ast.FuncDecl, pkg *types.Package) er
 	}
 
 	// 6. Update Uses
-	// Iterate over all uses in the package. If any use pointed to the old 'fnObj',
-	// point it to 'newFnObj'. This ensures PropagateCallers can follow the chain
-	// without needing a full reload.
+	// Iterate over all uses in the package. If any use pointed to the old 'fnObj' (or to one of
+	// its instances, for generic functions and methods), point it to 'newFnObj'. This ensures
+	// PropagateCallers can follow the chain without needing a full reload.
 	for id, usedObj := range info.Uses {
-		if usedObj == fnObj {
+		if OriginOf(usedObj) == fnObj {
 			info.Uses[id] = newFnObj
 		}
 	}
 
 	return nil
 }
+
+// OriginOf returns the generic declaration of obj if obj is an instance of a generic function or
+// method, or a parameter or field of an instantiated type (see types.Func.Origin), and obj itself
+// otherwise.
+//
+// obj: The object, e.g. from types.Info.Uses.
+func OriginOf(obj types.Object) types.Object {
+	switch o := obj.(type) {
+	case *types.Func:
+		return o.Origin()
+	case *types.Var:
+		return o.Origin()
+	}
+	return obj
+}
+
+// WithErrorResult returns a copy of sig with an 'error' result appended.
+// Receiver, parameters and variadic-ness are preserved.
+//
+// sig: The original signature.
+// pkg: The package owning the new result variable.
+func WithErrorResult(sig *types.Signature, pkg *types.Package) *types.Signature {
+	var vars []*types.Var
+	if res := sig.Results(); res != nil {
+		for i := 0; i < res.Len(); i++ {
+			vars = append(vars, res.At(i))
+		}
+	}
+	errType := types.Universe.Lookup("error").Type()
+	vars = append(vars, types.NewVar(token.NoPos, pkg, "", errType))
+	return types.NewSignature(sig.Recv(), sig.Params(), types.NewTuple(vars...), sig.Variadic())
+}
@@ -87,3 +87,81 @@ func TestPatchSignature_NilInputs(t *testing.T) {
 		t.Error("Expected error for nil inputs")
 	}
 }
+
+// TestWithErrorResult verifies the error result is appended and the rest preserved.
+func TestWithErrorResult(t *testing.T) {
+	pkg := types.NewPackage("p", "p")
+	params := types.NewTuple(types.NewVar(token.NoPos, pkg, "xs", types.NewSlice(types.Typ[types.Int])))
+	results := types.NewTuple(types.NewVar(token.NoPos, pkg, "", types.Typ[types.String]))
+	sig := types.NewSignatureType(nil, nil, nil, params, results, true)
+
+	got := WithErrorResult(sig, pkg)
+	if got.String() != "func(xs ...int) (string, error)" {
+		t.Errorf("unexpected signature: %s", got)
+	}
+	if sig.Results().Len() != 1 {
+		t.Error("original signature must not be modified")
+	}
+}
+
+// TestPatchSignature_Generic verifies that uses of instantiations are redirected to the patched
+// generic function.
+func TestPatchSignature_Generic(t *testing.T) {
+	src := `package main
+type Stack[T any] struct{ items []T }
+func (s *Stack[T]) Pop() T { var v T; return v }
+func use() { s := &Stack[int]{}; s.Pop() }
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "main.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	pkg, err := (&types.Config{}).Check("main", fset, []*ast.File{f}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pop *ast.FuncDecl
+	var use *ast.Ident
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if n.Name.Name == "Pop" {
+				pop = n
+			}
+		case *ast.SelectorExpr:
+			use = n.Sel
+		}
+		return true
+	})
+	before := info.ObjectOf(pop.Name)
+	if inst := info.Uses[use]; inst == before || OriginOf(inst) != before {
+		t.Fatalf("expected the use to be an instance of Pop, got %v", inst)
+	}
+
+	pop.Type.Results.List = append(pop.Type.Results.List, &ast.Field{Type: ast.NewIdent("error")})
+	if err := PatchSignature(info, pop, pkg); err != nil {
+		t.Fatalf("PatchSignature failed: %v", err)
+	}
+	if after := info.ObjectOf(pop.Name); info.Uses[use] != after {
+		t.Errorf("use not redirected to the patched method: %v", info.Uses[use])
+	}
+}
+
+// TestOriginOf verifies that objects which are not instances are returned unchanged.
+func TestOriginOf(t *testing.T) {
+	v := types.NewVar(token.NoPos, nil, "x", types.Typ[types.Int])
+	if OriginOf(v) != v {
+		t.Error("expected the variable itself")
+	}
+	c := types.NewConst(token.NoPos, nil, "c", types.Typ[types.Int], nil)
+	if OriginOf(c) != c {
+		t.Error("expected the constant itself")
+	}
+}
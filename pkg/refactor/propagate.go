@@ -1,13 +1,17 @@
 package refactor
 
 import (
+	"errors"
 	"fmt"
 	"go/ast"
 	"go/token"
 	"go/types"
 	"reflect"
+	"strconv"
 
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
 	"github.com/SamuelMarks/go-auto-err-handling/pkg/astgen"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/dstmap"
 	"github.com/SamuelMarks/go-auto-err-handling/pkg/filter"
 	"github.com/dave/dst"
 	"github.com/dave/dst/decorator"
@@ -25,8 +29,15 @@ const (
 	HandlerOsExit MainHandlerStrategy = "os-exit"
 	// HandlerPanic uses panic(err).
 	HandlerPanic MainHandlerStrategy = "panic"
+	// HandlerSlogError logs the error with slog at the Error level and continues.
+	HandlerSlogError MainHandlerStrategy = "slog-error"
+	// HandlerSlogErrorExit logs the error with slog at the Error level followed by os.Exit(1).
+	HandlerSlogErrorExit MainHandlerStrategy = "slog-error-exit"
 )
 
+// terminalSlogMsg is the message of the slog strategies.
+const terminalSlogMsg = "operation failed"
+
 // PropagateCallers updates all call sites of a modified function to match its new signature
 // (assuming the signature acquired an extra 'error' return value).
 //
@@ -70,7 +81,7 @@ func PropagateCallers(pkgs []*packages.Package, initialTarget *types.Func, strat
 			// Find AST Identifiers referring to current target object
 			var callsToUpdate []*ast.Ident
 			for id, obj := range pkg.TypesInfo.Uses {
-				if obj == target {
+				if OriginOf(obj) == target {
 					callsToUpdate = append(callsToUpdate, id)
 				}
 			}
@@ -207,9 +218,14 @@ func processCallSiteDST(pkg *packages.Package, astFile *ast.File, dstFile *dst.F
 		}
 	}
 
+	logger := ""
+	if isTerminal && testParam == "" && MainHandlerStrategy(strategy).IsSlog() {
+		logger = analysis.FindLogger(pkg, astFile, enclosingStmt.Pos())
+	}
+
 	// Perform DST Rewrite of the call site
 	// We need the enclosing signature (potentially updated).
-	if err := refactorCallSiteDST(dstStmt, dstParent, sig, isTerminal, MainHandlerStrategy(strategy), testParam); err != nil {
+	if err := refactorCallSiteDST(dstStmt, dstParent, sig, isTerminal, MainHandlerStrategy(strategy), testParam, logger); err != nil {
 		return 0, nil, err
 	}
 
@@ -217,7 +233,22 @@ func processCallSiteDST(pkg *packages.Package, astFile *ast.File, dstFile *dst.F
 }
 
 // HandleEntryPoint injects terminal error handling for a call site within an entry point (main/init) using DST.
+// The slog strategies use the logger found in scope by analysis.FindLogger.
 func HandleEntryPoint(pkg *packages.Package, dstFile *dst.File, call *ast.CallExpr, stmt ast.Stmt, strategy string) error {
+	return HandleEntryPointLogger(pkg, dstFile, call, stmt, strategy, "")
+}
+
+// HandleEntryPointLogger is HandleEntryPoint with an explicit logger for the slog strategies.
+//
+// pkg: The package containing stmt.
+// dstFile: The DST of the file containing stmt.
+// call: The call whose error is handled.
+// stmt: The statement containing call.
+// strategy: The MainHandlerStrategy.
+// logger: The logger expression (e.g. "s.logger"), or "" to detect it from scope.
+//
+// Returns an error if the statement cannot be rewritten.
+func HandleEntryPointLogger(pkg *packages.Package, dstFile *dst.File, call *ast.CallExpr, stmt ast.Stmt, strategy, logger string) error {
 	// Need AST file to map. Assuming caller has context, or we re-find it.
 	// For simplicity in this helper, we need astFile.
 	// We'll rely on finding it in pkg.
@@ -230,11 +261,15 @@ func HandleEntryPoint(pkg *packages.Package, dstFile *dst.File, call *ast.CallEx
 	if dstStmt == nil {
 		return fmt.Errorf("failed to locate entry point statement in DST")
 	}
-	return refactorCallSiteDST(dstStmt, dstParent, nil, true, MainHandlerStrategy(strategy), "")
+	if logger == "" && MainHandlerStrategy(strategy).IsSlog() {
+		logger = analysis.FindLogger(pkg, astFile, stmt.Pos())
+	}
+	return refactorCallSiteDST(dstStmt, dstParent, nil, true, MainHandlerStrategy(strategy), "", logger)
 }
 
 // refactorCallSiteDST modifies the DST to handle the extra error return.
-func refactorCallSiteDST(stmt dst.Node, parent dst.Node, enclosingSig *types.Signature, isTerminal bool, strategy MainHandlerStrategy, testParam string) error {
+// logger is the logger expression of the slog strategies, or "" for the slog package functions.
+func refactorCallSiteDST(stmt dst.Node, parent dst.Node, enclosingSig *types.Signature, isTerminal bool, strategy MainHandlerStrategy, testParam, logger string) error {
 	// Identify Statement Type
 	switch s := stmt.(type) {
 	case *dst.ExprStmt:
@@ -242,7 +277,7 @@ func refactorCallSiteDST(stmt dst.Node, parent dst.Node, enclosingSig *types.Sig
 		// call() -> if err := call(); err != nil ...
 		call := s.X
 		// Generate Check Block
-		block := generateCheckBlock(call, enclosingSig, isTerminal, strategy, testParam)
+		block := generateCheckBlock(call, enclosingSig, isTerminal, strategy, testParam, logger)
 
 		replaceInParent(parent, stmt, block)
 
@@ -258,7 +293,11 @@ func refactorCallSiteDST(stmt dst.Node, parent dst.Node, enclosingSig *types.Sig
 		}
 
 		// 2. Construct Check
-		check := generateBasicCheck(enclosingSig, isTerminal, strategy, testParam)
+		op := ""
+		if len(s.Rhs) == 1 {
+			op = callOpName(s.Rhs[0])
+		}
+		check := generateBasicCheck(enclosingSig, isTerminal, strategy, testParam, logger, op)
 
 		// 3. Insert Check After Assignment
 		insertAfterInParent(parent, stmt, check)
@@ -293,7 +332,7 @@ func insertAfterInParent(parent, target, toInsert dst.Node) {
 	}
 }
 
-func generateBasicCheck(sig *types.Signature, isTerminal bool, strategy MainHandlerStrategy, testParam string) *dst.IfStmt {
+func generateBasicCheck(sig *types.Signature, isTerminal bool, strategy MainHandlerStrategy, testParam, logger, op string) *dst.IfStmt {
 	cond := &dst.BinaryExpr{
 		X:  dst.NewIdent("err"),
 		Op: token.NEQ,
@@ -302,7 +341,7 @@ func generateBasicCheck(sig *types.Signature, isTerminal bool, strategy MainHand
 
 	var body *dst.BlockStmt
 	if isTerminal {
-		body = generateDstTerminalBody(strategy, testParam)
+		body = generateDstTerminalBody(strategy, testParam, logger, op)
 	} else {
 		body = generateDstReturnBody(sig)
 	}
@@ -313,7 +352,7 @@ func generateBasicCheck(sig *types.Signature, isTerminal bool, strategy MainHand
 	}
 }
 
-func generateCheckBlock(callExpr dst.Expr, sig *types.Signature, isTerminal bool, strategy MainHandlerStrategy, testParam string) *dst.IfStmt {
+func generateCheckBlock(callExpr dst.Expr, sig *types.Signature, isTerminal bool, strategy MainHandlerStrategy, testParam, logger string) *dst.IfStmt {
 	// Call expression needs to be cloned to be moved?
 	// It is `s.X`. Since we replace the ExprStmt, we can take ownership or clone.
 	// Cloning is safer.
@@ -323,30 +362,52 @@ func generateCheckBlock(callExpr dst.Expr, sig *types.Signature, isTerminal bool
 		Rhs: []dst.Expr{dst.Clone(callExpr).(dst.Expr)},
 	}
 
-	ifStmt := generateBasicCheck(sig, isTerminal, strategy, testParam)
+	ifStmt := generateBasicCheck(sig, isTerminal, strategy, testParam, logger, callOpName(callExpr))
 	// Collapse: if err := call(); err != nil
 	ifStmt.Init = assign
 
 	return ifStmt
 }
 
-func generateDstTerminalBody(strategy MainHandlerStrategy, testParam string) *dst.BlockStmt {
+// generateDstTerminalBody generates the handling of err where it cannot be returned.
+//
+// strategy: The MainHandlerStrategy, unless testParam is set.
+// testParam: The name of the *testing.T parameter, or "" outside tests.
+// logger: The logger expression of the slog strategies, or "" for the slog package functions.
+// op: The failed operation logged by the slog strategies and t.Fatalf, or "".
+//
+// Returns the body of the error check.
+func generateDstTerminalBody(strategy MainHandlerStrategy, testParam, logger, op string) *dst.BlockStmt {
 	var stmts []dst.Stmt
 	arg := dst.NewIdent("err")
 
 	if testParam != "" {
-		// t.Fatal(err)
+		// t.Fatalf("op: %v", err), or t.Fatal(err) if the operation is unknown
+		fatal, args := "Fatal", []dst.Expr{arg}
+		if op != "" {
+			fatal, args = "Fatalf", []dst.Expr{&dst.BasicLit{Kind: token.STRING, Value: strconv.Quote(op + ": %v")}, arg}
+		}
 		stmts = append(stmts, &dst.ExprStmt{
 			X: &dst.CallExpr{
 				Fun: &dst.SelectorExpr{
 					X:   dst.NewIdent(testParam),
-					Sel: dst.NewIdent("Fatal"),
+					Sel: dst.NewIdent(fatal),
 				},
-				Args: []dst.Expr{arg},
+				Args: args,
 			},
 		})
 	} else {
 		switch strategy {
+		case HandlerSlogError, HandlerSlogErrorExit:
+			stmts = []dst.Stmt{slogErrorStmt(logger, terminalSlogMsg, op, "err")}
+			if strategy == HandlerSlogErrorExit {
+				stmts = append(stmts, &dst.ExprStmt{
+					X: &dst.CallExpr{
+						Fun:  &dst.SelectorExpr{X: dst.NewIdent("os"), Sel: dst.NewIdent("Exit")},
+						Args: []dst.Expr{&dst.BasicLit{Kind: token.INT, Value: "1"}},
+					},
+				})
+			}
 		case HandlerPanic:
 			stmts = []dst.Stmt{
 				&dst.ExprStmt{
@@ -386,6 +447,37 @@ func generateDstTerminalBody(strategy MainHandlerStrategy, testParam string) *ds
 	return &dst.BlockStmt{List: stmts}
 }
 
+// IsSlog reports whether the strategy logs with slog.
+func (s MainHandlerStrategy) IsSlog() bool {
+	return s == HandlerSlogError || s == HandlerSlogErrorExit
+}
+
+// slogErrorStmt generates the slog call of the slog strategies (see astgen.SlogErrorDST).
+// The logger is validated by the runner; an invalid expression falls back to the slog package.
+func slogErrorStmt(logger, msg, op, errName string) dst.Stmt {
+	stmt, err := astgen.SlogErrorDST(logger, msg, op, errName)
+	if err != nil {
+		stmt, _ = astgen.SlogErrorDST("", msg, op, errName)
+	}
+	return stmt
+}
+
+// callOpName returns the name of the function called by expr (e.g. "Close" for f.Close()),
+// or "" if expr is not a call of a named function.
+func callOpName(expr dst.Expr) string {
+	call, ok := expr.(*dst.CallExpr)
+	if !ok {
+		return ""
+	}
+	switch fun := call.Fun.(type) {
+	case *dst.Ident:
+		return fun.Name
+	case *dst.SelectorExpr:
+		return fun.Sel.Name
+	}
+	return ""
+}
+
 func generateDstReturnBody(sig *types.Signature) *dst.BlockStmt {
 	var results []dst.Expr
 	if sig != nil {
@@ -409,6 +501,9 @@ func generateDstReturnBody(sig *types.Signature) *dst.BlockStmt {
 // mapAstToDst finds the DST node corresponding to an AST node.
 // It duplicates logic from pkg/rewrite/mapper.go to avoid import cycle.
 func mapAstToDst(astFile *ast.File, dstFile *dst.File, targetNode ast.Node) (dst.Node, dst.Node) {
+	if n, parent, err := dstmap.Lookup(dstFile, targetNode); !errors.Is(err, dstmap.ErrNotDecorated) {
+		return n, parent
+	}
 	path, _ := astutil.PathEnclosingInterval(astFile, targetNode.Pos(), targetNode.End())
 	if len(path) == 0 || path[len(path)-1] != astFile {
 		return nil, nil
@@ -1,13 +1,17 @@
 package refactor
 
 import (
+	"bytes"
 	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/token"
 	"go/types"
+	"strings"
 	"testing"
 
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
 	"golang.org/x/tools/go/packages"
 	"golang.org/x/tools/imports"
 )
@@ -161,3 +165,72 @@ func renderCode(t *testing.T, src string) string {
 	}
 	return string(res)
 }
+
+// TestHandleEntryPointLogger verifies the slog strategies in entry points.
+func TestHandleEntryPointLogger(t *testing.T) {
+	src := `package main
+func run() error { return nil }
+func main() {
+	run()
+}
+`
+	tests := []struct {
+		strategy string
+		logger   string
+		want     string
+	}{
+		{"slog-error", "", "if err := run(); err != nil {\n\t\tslog.Error(\"operation failed\", \"op\", \"run\", \"err\", err)\n\t}"},
+		{"slog-error-exit", "slog.Default()", "slog.Default().Error(\"operation failed\", \"op\", \"run\", \"err\", err)\n\t\tos.Exit(1)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			_, pkg, _ := setupPropagateEnvActual(t, src, "run")
+			file := pkg.Syntax[0]
+			stmt := file.Decls[1].(*ast.FuncDecl).Body.List[0]
+			call := stmt.(*ast.ExprStmt).X.(*ast.CallExpr)
+
+			dstFile, err := decorator.NewDecorator(pkg.Fset).DecorateFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := HandleEntryPointLogger(pkg, dstFile, call, stmt, tt.strategy, tt.logger); err != nil {
+				t.Fatalf("HandleEntryPointLogger failed: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := decorator.Fprint(&buf, dstFile); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("output missing %q:\n%s", tt.want, buf.String())
+			}
+		})
+	}
+}
+
+// TestGenerateDstTerminalBody_Test verifies that tests report the failed operation with t.Fatalf.
+func TestGenerateDstTerminalBody_Test(t *testing.T) {
+	tests := []struct {
+		op, want string
+	}{
+		{"Close", `t.Fatalf("Close: %v", err)`},
+		{"", "t.Fatal(err)"},
+	}
+	for _, tt := range tests {
+		body := generateDstTerminalBody(HandlerLogFatal, "t", "", tt.op)
+		call := body.List[0].(*dst.ExprStmt).X.(*dst.CallExpr)
+		args := make([]string, len(call.Args))
+		for k, arg := range call.Args {
+			switch a := arg.(type) {
+			case *dst.BasicLit:
+				args[k] = a.Value
+			case *dst.Ident:
+				args[k] = a.Name
+			}
+		}
+		sel := call.Fun.(*dst.SelectorExpr)
+		if got := fmt.Sprintf("%s.%s(%s)", sel.X.(*dst.Ident).Name, sel.Sel.Name, strings.Join(args, ", ")); got != tt.want {
+			t.Errorf("op %q: got %s, want %s", tt.op, got, tt.want)
+		}
+	}
+}
@@ -151,7 +151,13 @@ func AddErrorToSignature(fset *token.FileSet, decl *ast.FuncDecl) (bool, error)
 			if !isNaked && (len(ret.Results) > 0 || wasVoid) {
 				// We append explicit nil. Semantic zero values for other types would be better,
 				// but without type info here, we rely on the fact we only appended error.
-				ret.Results = append(ret.Results, &ast.Ident{Name: "nil"})
+				// Placed after the other results, the return keeps its extent for later lookups
+				// of the calls it contains.
+				nilIdent := &ast.Ident{Name: "nil"}
+				if len(ret.Results) > 0 {
+					nilIdent.NamePos = ret.Results[len(ret.Results)-1].End()
+				}
+				ret.Results = append(ret.Results, nilIdent)
 			}
 			return false
 		}
@@ -326,6 +332,75 @@ func AddErrorToSignatureDST(decl *dst.FuncDecl) (bool, error) {
 	return true, nil
 }
 
+// AddErrorToInterfaceMethodDST appends an error result to an interface method declaration.
+// Named results get a named error ("err", or a numbered variant on collision).
+//
+// field: The method field of the interface type.
+//
+// Returns true if changed.
+func AddErrorToInterfaceMethodDST(field *dst.Field) (bool, error) {
+	if field == nil {
+		return false, fmt.Errorf("interface method is nil")
+	}
+	ft, ok := field.Type.(*dst.FuncType)
+	if !ok {
+		return false, fmt.Errorf("field is not an interface method")
+	}
+	return AddErrorToFuncTypeDST(ft)
+}
+
+// AddErrorToFuncTypeDST appends an error result to a function type without body, such as the type
+// of a variable, struct field or parameter. Named results get a named error ("err", or a numbered
+// variant on collision).
+//
+// ft: The function type.
+//
+// Returns true if changed.
+func AddErrorToFuncTypeDST(ft *dst.FuncType) (bool, error) {
+	if ft == nil {
+		return false, fmt.Errorf("function type is nil")
+	}
+	if ft.Results == nil {
+		ft.Results = &dst.FieldList{}
+	}
+
+	newField := &dst.Field{Type: dst.NewIdent("error")}
+	if len(ft.Results.List) > 0 && len(ft.Results.List[0].Names) > 0 {
+		usedNames := make(map[string]bool)
+		for _, list := range []*dst.FieldList{ft.Params, ft.Results} {
+			if list == nil {
+				continue
+			}
+			for _, f := range list.List {
+				for _, n := range f.Names {
+					usedNames[n.Name] = true
+				}
+			}
+		}
+		name := "err"
+		for count := 1; usedNames[name]; count++ {
+			name = fmt.Sprintf("err%d", count)
+		}
+		newField.Names = []*dst.Ident{dst.NewIdent(name)}
+	}
+	ft.Results.List = append(ft.Results.List, newField)
+	return true, nil
+}
+
+// AddErrorToFuncLitDST adds an error result to a function literal, like AddErrorToSignatureDST
+// does for declarations.
+//
+// lit: The DST function literal.
+//
+// Returns true if changed.
+func AddErrorToFuncLitDST(lit *dst.FuncLit) (bool, error) {
+	if lit == nil {
+		return false, fmt.Errorf("function literal is nil")
+	}
+	// The declaration shares the type and body, so the literal is changed in place.
+	return AddErrorToSignatureDST(&dst.FuncDecl{Name: dst.NewIdent("_"), Type: lit.Type, Body: lit.Body})
+}
+
 // EnsureNamedReturns checks AST function declarations for unnamed return values and names them.
 // This is critical for rewrites that introduce defer closures which capture return values.
 //
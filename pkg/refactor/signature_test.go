@@ -340,3 +340,83 @@ func getEnsureNamedTests() []namedTest {
 func normalize(s string) string {
 	return strings.Join(strings.Fields(s), " ")
 }
+
+// TestAddErrorToInterfaceMethodDST verifies error results on interface method declarations.
+func TestAddErrorToInterfaceMethodDST(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"Void", "Save(key string)", "Save(key string) error"},
+		{"Single", "Load(key string) []byte", "Load(key string) ([]byte, error)"},
+		{"Named", "Stat(err string) (size int)", "Stat(err string) (size int, err1 error)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := decorator.Parse("package p\n\ntype I interface {\n\t" + tt.src + "\n}\n")
+			if err != nil {
+				t.Fatal(err)
+			}
+			iface := file.Decls[0].(*dst.GenDecl).Specs[0].(*dst.TypeSpec).Type.(*dst.InterfaceType)
+			if changed, err := AddErrorToInterfaceMethodDST(iface.Methods.List[0]); !changed || err != nil {
+				t.Fatalf("expected change, got %v, %v", changed, err)
+			}
+			var buf bytes.Buffer
+			if err := decorator.Fprint(&buf, file); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("expected %q in:\n%s", tt.want, buf.String())
+			}
+		})
+	}
+
+	if _, err := AddErrorToInterfaceMethodDST(&dst.Field{Type: dst.NewIdent("Embedded")}); err == nil {
+		t.Error("expected error for embedded interface")
+	}
+}
+
+// TestAddErrorToFuncTypeDST verifies error results on function types of variables and fields.
+func TestAddErrorToFuncTypeDST(t *testing.T) {
+	file, err := decorator.Parse("package p\n\nvar handlers map[string]func(name string) (n int)\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := file.Decls[0].(*dst.GenDecl).Specs[0].(*dst.ValueSpec)
+	ft := spec.Type.(*dst.MapType).Value.(*dst.FuncType)
+	if changed, err := AddErrorToFuncTypeDST(ft); !changed || err != nil {
+		t.Fatalf("expected change, got %v, %v", changed, err)
+	}
+	var buf bytes.Buffer
+	if err := decorator.Fprint(&buf, file); err != nil {
+		t.Fatal(err)
+	}
+	if want := "map[string]func(name string) (n int, err error)"; !strings.Contains(buf.String(), want) {
+		t.Errorf("expected %q in:\n%s", want, buf.String())
+	}
+
+	if _, err := AddErrorToFuncTypeDST(nil); err == nil {
+		t.Error("expected error for nil type")
+	}
+}
+
+// TestAddErrorToFuncLitDST verifies that function literals gain the error result and a final
+// return.
+func TestAddErrorToFuncLitDST(t *testing.T) {
+	file, err := decorator.Parse("package p\n\nvar run = func() {\n\tprintln()\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lit := file.Decls[0].(*dst.GenDecl).Specs[0].(*dst.ValueSpec).Values[0].(*dst.FuncLit)
+	if changed, err := AddErrorToFuncLitDST(lit); !changed || err != nil {
+		t.Fatalf("expected change, got %v, %v", changed, err)
+	}
+	var buf bytes.Buffer
+	if err := decorator.Fprint(&buf, file); err != nil {
+		t.Fatal(err)
+	}
+	if want := "var run = func() error {\n\tprintln()\n\treturn nil\n}"; !strings.Contains(buf.String(), want) {
+		t.Errorf("expected %q in:\n%s", want, buf.String())
+	}
+}
@@ -0,0 +1,137 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// baselineVersion is the version of the baseline file format.
+const baselineVersion = 1
+
+// Fingerprint identifies a finding independently of its position, so that it survives edits
+// elsewhere in the file.
+type Fingerprint struct {
+	// Package is the import path of the package containing the call.
+	Package string `json:"package"`
+	// Function is the enclosing function ("Type.Method" for methods), or "" at package level.
+	Function string `json:"function,omitempty"`
+	// Callee is the fully qualified symbol whose error is ignored (e.g. "(*os.File).Close").
+	Callee string `json:"callee"`
+	// Shape is the kind of statement ignoring the error: "call", "assign", "defer", "go", "if",
+	// "switch", "decl", "unchecked" for an error assigned to a variable that is never read, or
+	// "shadow" for an error assigned to a variable shadowing a named result.
+	Shape string `json:"shape"`
+	// Index is the occurrence of the same package, function, callee and shape, in source order.
+	Index int `json:"index"`
+	// Location is the position when the fingerprint was recorded ("file:line"). It is
+	// informational and not used for matching.
+	Location string `json:"location,omitempty"`
+}
+
+// key returns the identity of f, without the informational location.
+func (f Fingerprint) key() Fingerprint {
+	f.Location = ""
+	return f
+}
+
+// String formats f for logs, e.g. "example.com/app.Load: os.Remove (call #0) at main.go:12".
+func (f Fingerprint) String() string {
+	s := f.Package
+	if f.Function != "" {
+		s += "." + f.Function
+	}
+	s += fmt.Sprintf(": %s (%s #%d)", f.Callee, f.Shape, f.Index)
+	if f.Location != "" {
+		s += " at " + f.Location
+	}
+	return s
+}
+
+// Baseline is a set of known findings that do not fail check mode.
+type Baseline struct {
+	// Version is the file format version.
+	Version int `json:"version"`
+	// Findings are the known findings, sorted by package, function, callee, shape and index.
+	Findings []Fingerprint `json:"findings"`
+}
+
+// NewBaseline returns a baseline of findings.
+//
+// findings: The fingerprints of the current findings.
+func NewBaseline(findings []Fingerprint) *Baseline {
+	sorted := make([]Fingerprint, len(findings))
+	copy(sorted, findings)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		if a.Function != b.Function {
+			return a.Function < b.Function
+		}
+		if a.Callee != b.Callee {
+			return a.Callee < b.Callee
+		}
+		if a.Shape != b.Shape {
+			return a.Shape < b.Shape
+		}
+		return a.Index < b.Index
+	})
+	return &Baseline{Version: baselineVersion, Findings: sorted}
+}
+
+// ReadBaseline reads the baseline file at path.
+//
+// Returns an error if the file cannot be read or has an unsupported version.
+func ReadBaseline(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline: %w", err)
+	}
+	var b Baseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("invalid baseline %s: %w", path, err)
+	}
+	if b.Version != baselineVersion {
+		return nil, fmt.Errorf("unsupported baseline version %d in %s", b.Version, path)
+	}
+	return &b, nil
+}
+
+// Write serializes the baseline as indented JSON.
+//
+// w: The writer to output the JSON to.
+func (b *Baseline) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(b)
+}
+
+// Compare matches findings against the baseline.
+//
+// findings: The fingerprints of the current findings.
+//
+// Returns the indices of the findings that are not in the baseline, and the baseline entries that
+// no longer match any finding (i.e. have been fixed).
+func (b *Baseline) Compare(findings []Fingerprint) (fresh []int, fixed []Fingerprint) {
+	known := make(map[Fingerprint]bool, len(b.Findings))
+	for _, f := range b.Findings {
+		known[f.key()] = true
+	}
+	found := make(map[Fingerprint]bool, len(findings))
+	for i, f := range findings {
+		found[f.key()] = true
+		if !known[f.key()] {
+			fresh = append(fresh, i)
+		}
+	}
+	for _, f := range b.Findings {
+		if !found[f.key()] {
+			fixed = append(fixed, f)
+		}
+	}
+	return fresh, fixed
+}
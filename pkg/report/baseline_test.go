@@ -0,0 +1,61 @@
+package report
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBaseline_RoundTrip(t *testing.T) {
+	findings := []Fingerprint{
+		{Package: "example.com/b", Function: "Load", Callee: "os.Remove", Shape: "call", Location: "b.go:3"},
+		{Package: "example.com/a", Callee: "os.Chdir", Shape: "decl", Location: "a.go:9"},
+	}
+	b := NewBaseline(findings)
+	if b.Findings[0].Package != "example.com/a" {
+		t.Errorf("findings should be sorted, got %v", b.Findings)
+	}
+
+	var buf bytes.Buffer
+	if err := b.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	_ = os.WriteFile(path, buf.Bytes(), 0644)
+	read, err := ReadBaseline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Findings) != 2 || read.Findings[1] != b.Findings[1] {
+		t.Errorf("round trip mismatch: %+v", read.Findings)
+	}
+
+	_ = os.WriteFile(path, []byte(`{"version": 99}`), 0644)
+	if _, err := ReadBaseline(path); err == nil || !strings.Contains(err.Error(), "version") {
+		t.Errorf("expected version error, got %v", err)
+	}
+}
+
+func TestBaseline_Compare(t *testing.T) {
+	known := Fingerprint{Package: "p", Function: "F", Callee: "os.Remove", Shape: "call", Location: "a.go:3"}
+	gone := Fingerprint{Package: "p", Function: "G", Callee: "os.Remove", Shape: "call"}
+	b := NewBaseline([]Fingerprint{known, gone})
+
+	moved := known
+	moved.Location = "a.go:30"
+	second := known
+	second.Index = 1
+	fresh, fixed := b.Compare([]Fingerprint{moved, second})
+
+	if len(fresh) != 1 || fresh[0] != 1 {
+		t.Errorf("fresh = %v, want [1]", fresh)
+	}
+	if len(fixed) != 1 || fixed[0] != gone {
+		t.Errorf("fixed = %v, want [%v]", fixed, gone)
+	}
+	if got := known.String(); got != "p.F: os.Remove (call #0) at a.go:3" {
+		t.Errorf("String() = %q", got)
+	}
+}
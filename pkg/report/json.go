@@ -16,6 +16,37 @@ type Data struct {
 	ErrorsHandled int `json:"errors_handled"`
 	// Skipped is the count of injection points that were filtered out or ignored.
 	Skipped int `json:"skipped"`
+	// Reverted lists the rewrites that were dropped because the result did not compile.
+	Reverted []Regression `json:"reverted,omitempty"`
+	// Rejected is the count of unhandled errors left as is because their handling did not compile.
+	Rejected int `json:"rejected,omitempty"`
+	// Iterations is the number of detect-and-fix iterations that ran.
+	Iterations int `json:"iterations,omitempty"`
+	// Converged is false if the iteration cap was reached before the code was stable, or if
+	// unhandled errors were rejected.
+	Converged bool `json:"converged"`
+	// Cache holds the cache statistics of check mode, or nil if the cache is not used.
+	Cache *CacheStats `json:"cache,omitempty"`
+	// BaselineFixed lists the baseline entries that no longer match a finding.
+	BaselineFixed []Fingerprint `json:"baseline_fixed,omitempty"`
+}
+
+// CacheStats counts the packages whose findings were reused from the cache.
+type CacheStats struct {
+	// Hits is the number of packages whose findings were reused.
+	Hits int `json:"hits"`
+	// Misses is the number of packages that were analyzed.
+	Misses int `json:"misses"`
+}
+
+// Regression describes a file whose rewrite was dropped because it did not type check.
+type Regression struct {
+	// File is the path of the reverted file.
+	File string `json:"file"`
+	// Points are the injection points whose handling caused the errors, as "file:line:col (callee)".
+	Points []string `json:"points"`
+	// Errors are the type errors of the rewritten file.
+	Errors []string `json:"errors"`
 }
 
 // Reporter collects statistics during the refactoring process and generates structured output.
@@ -64,6 +95,58 @@ func (r *Reporter) IncSkipped() {
 	r.data.Skipped++
 }
 
+// AddRegression records a rewrite that was dropped because it did not compile.
+//
+// reg: The reverted file, with its causes and errors.
+func (r *Reporter) AddRegression(reg Regression) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.data.Reverted = append(r.data.Reverted, reg)
+}
+
+// SetIterations records the number of iterations and whether the run converged.
+//
+// n: The number of iterations that ran.
+// converged: False if the iteration cap was reached first.
+func (r *Reporter) SetIterations(n int, converged bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.data.Iterations = n
+	r.data.Converged = converged
+}
+
+// SetRejected records the count of unhandled errors whose handling did not compile. The run has
+// not converged if any are left.
+//
+// n: The number of rejected errors.
+func (r *Reporter) SetRejected(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.data.Rejected = n
+	if n > 0 {
+		r.data.Converged = false
+	}
+}
+
+// SetCacheStats records the cache statistics of check mode.
+//
+// hits: The number of packages whose findings were reused.
+// misses: The number of packages that were analyzed.
+func (r *Reporter) SetCacheStats(hits, misses int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.data.Cache = &CacheStats{Hits: hits, Misses: misses}
+}
+
+// AddBaselineFixed records baseline entries that no longer match a finding.
+//
+// fixed: The fixed baseline entries.
+func (r *Reporter) AddBaselineFixed(fixed []Fingerprint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.data.BaselineFixed = append(r.data.BaselineFixed, fixed...)
+}
+
 // WriteJSON serializes the collected statistics to the provided writer in indented JSON format.
 // Validates that the file list is sorted before writing to ensure deterministic output.
 //
@@ -92,9 +175,30 @@ func (r *Reporter) GetData() Data {
 	copy(files, r.data.FilesModified)
 	sort.Strings(files)
 
+	reverted := make([]Regression, len(r.data.Reverted))
+	copy(reverted, r.data.Reverted)
+
+	var baselineFixed []Fingerprint
+	if len(r.data.BaselineFixed) > 0 {
+		baselineFixed = make([]Fingerprint, len(r.data.BaselineFixed))
+		copy(baselineFixed, r.data.BaselineFixed)
+	}
+
+	var cache *CacheStats
+	if r.data.Cache != nil {
+		stats := *r.data.Cache
+		cache = &stats
+	}
+
 	return Data{
 		FilesModified: files,
 		ErrorsHandled: r.data.ErrorsHandled,
 		Skipped:       r.data.Skipped,
+		Reverted:      reverted,
+		Rejected:      r.data.Rejected,
+		Iterations:    r.data.Iterations,
+		Converged:     r.data.Converged,
+		Cache:         cache,
+		BaselineFixed: baselineFixed,
 	}
 }
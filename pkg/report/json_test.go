@@ -134,3 +134,28 @@ func TestReporter_Empty(t *testing.T) {
 		t.Error("Expected empty files list")
 	}
 }
+
+// TestReporter_Regressions verifies that reverted rewrites are reported.
+func TestReporter_Regressions(t *testing.T) {
+	r := New()
+	r.AddRegression(Regression{
+		File:   "main.go",
+		Points: []string{"main.go:4:2 (os.Chdir)"},
+		Errors: []string{"main.go:5:10: undefined: wrap"},
+	})
+
+	data := r.GetData()
+	if len(data.Reverted) != 1 || data.Reverted[0].File != "main.go" {
+		t.Fatalf("unexpected regressions: %+v", data.Reverted)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range []string{`"reverted": [`, `"main.go:4:2 (os.Chdir)"`, `undefined: wrap`} {
+		if !strings.Contains(buf.String(), part) {
+			t.Errorf("JSON output missing part %q. Got:\n%s", part, buf.String())
+		}
+	}
+}
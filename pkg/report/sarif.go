@@ -0,0 +1,210 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// SARIF constants describing the emitted log.
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	// RuleUnhandledError is the SARIF rule identifier for ignored error results.
+	RuleUnhandledError = "unhandled-error"
+)
+
+// Finding describes a single unhandled error in a tool-agnostic form suitable for
+// machine-readable reports.
+type Finding struct {
+	// File is the path of the file containing the finding (relative paths are preferred).
+	File string
+	// Line is the 1-based start line of the call.
+	Line int
+	// Column is the 1-based start column of the call.
+	Column int
+	// EndLine is the 1-based end line of the call.
+	EndLine int
+	// EndColumn is the 1-based end column (exclusive) of the call.
+	EndColumn int
+	// Callee is the fully qualified symbol whose error is ignored (e.g. "(*os.File).Close").
+	Callee string
+	// Message is the human readable description.
+	Message string
+	// Fix is the proposed rewrite. Nil if no in-place fix is available.
+	Fix []Replacement
+}
+
+// Replacement describes a proposed change to the finding's file.
+// Lines and columns are 1-based; the deleted region is [Start, End).
+type Replacement struct {
+	StartLine   int
+	StartColumn int
+	EndLine     int
+	EndColumn   int
+	// Text is the content inserted in place of the deleted region.
+	Text string
+}
+
+// sarifLog mirrors the subset of the SARIF 2.1.0 object model emitted by the tool.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations"`
+	Fixes      []sarifFix        `json:"fixes,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion   `json:"deletedRegion"`
+	InsertedContent *sarifMessage `json:"insertedContent,omitempty"`
+}
+
+// WriteSARIF serializes the findings as a SARIF 2.1.0 log with one result per finding.
+// Results are sorted by file and position to ensure deterministic output.
+//
+// w: The writer to output the log to.
+// toolVersion: The version reported for the tool driver (may be empty).
+// findings: The findings to report.
+func WriteSARIF(w io.Writer, toolVersion string, findings []Finding) error {
+	sorted := make([]Finding, len(findings))
+	copy(sorted, findings)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
+	results := make([]sarifResult, 0, len(sorted))
+	for _, f := range sorted {
+		artifact := sarifArtifactLocation{URI: f.File}
+		res := sarifResult{
+			RuleID:  RuleUnhandledError,
+			Level:   "error",
+			Message: sarifMessage{Text: f.Message},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: artifact,
+					Region: sarifRegion{
+						StartLine:   f.Line,
+						StartColumn: f.Column,
+						EndLine:     f.EndLine,
+						EndColumn:   f.EndColumn,
+					},
+				},
+			}},
+			Properties: map[string]string{"callee": f.Callee},
+		}
+
+		if len(f.Fix) > 0 {
+			change := sarifArtifactChange{ArtifactLocation: artifact}
+			for _, r := range f.Fix {
+				rep := sarifReplacement{
+					DeletedRegion: sarifRegion{
+						StartLine:   r.StartLine,
+						StartColumn: r.StartColumn,
+						EndLine:     r.EndLine,
+						EndColumn:   r.EndColumn,
+					},
+				}
+				if r.Text != "" {
+					rep.InsertedContent = &sarifMessage{Text: r.Text}
+				}
+				change.Replacements = append(change.Replacements, rep)
+			}
+			res.Fixes = []sarifFix{{
+				Description:     sarifMessage{Text: "Handle the error returned by " + f.Callee},
+				ArtifactChanges: []sarifArtifactChange{change},
+			}}
+		}
+		results = append(results, res)
+	}
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "auto-err",
+				Version:        toolVersion,
+				InformationURI: "https://github.com/SamuelMarks/go-auto-err-handling",
+				Rules: []sarifRule{{
+					ID:               RuleUnhandledError,
+					ShortDescription: sarifMessage{Text: "Error result is not handled"},
+				}},
+			}},
+			Results: results,
+		}},
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
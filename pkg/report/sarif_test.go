@@ -0,0 +1,84 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// TestWriteSARIF verifies the structure of the generated SARIF log, ordering of results,
+// and the encoding of proposed fixes.
+func TestWriteSARIF(t *testing.T) {
+	findings := []Finding{
+		{
+			File: "b.go", Line: 3, Column: 2, EndLine: 3, EndColumn: 8,
+			Callee: "b.fail", Message: "error returned by b.fail is not handled",
+		},
+		{
+			File: "a.go", Line: 7, Column: 2, EndLine: 7, EndColumn: 12,
+			Callee: "(*os.File).Close", Message: "error returned by (*os.File).Close is not handled",
+			Fix: []Replacement{{StartLine: 7, StartColumn: 1, EndLine: 8, EndColumn: 1, Text: "\tif err := f.Close(); err != nil {\n"}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSARIF(&buf, "1.2.3", findings); err != nil {
+		t.Fatalf("WriteSARIF failed: %v", err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+
+	if log.Version != "2.1.0" {
+		t.Errorf("Expected version 2.1.0, got %q", log.Version)
+	}
+	if len(log.Runs) != 1 {
+		t.Fatalf("Expected 1 run, got %d", len(log.Runs))
+	}
+	run := log.Runs[0]
+	if run.Tool.Driver.Name != "auto-err" || run.Tool.Driver.Version != "1.2.3" {
+		t.Errorf("Unexpected driver: %+v", run.Tool.Driver)
+	}
+	if len(run.Results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(run.Results))
+	}
+
+	first := run.Results[0]
+	if uri := first.Locations[0].PhysicalLocation.ArtifactLocation.URI; uri != "a.go" {
+		t.Errorf("Expected results sorted by file, got %q first", uri)
+	}
+	if first.Properties["callee"] != "(*os.File).Close" {
+		t.Errorf("Expected callee property, got %v", first.Properties)
+	}
+	if len(first.Fixes) != 1 {
+		t.Fatalf("Expected 1 fix, got %d", len(first.Fixes))
+	}
+	rep := first.Fixes[0].ArtifactChanges[0].Replacements[0]
+	if rep.DeletedRegion.StartLine != 7 || rep.DeletedRegion.EndLine != 8 {
+		t.Errorf("Unexpected deleted region: %+v", rep.DeletedRegion)
+	}
+	if rep.InsertedContent == nil || rep.InsertedContent.Text == "" {
+		t.Error("Expected inserted content")
+	}
+
+	if second := run.Results[1]; len(second.Fixes) != 0 {
+		t.Errorf("Expected no fixes for second result, got %d", len(second.Fixes))
+	}
+}
+
+// TestWriteSARIF_Empty verifies that an empty result set is still a valid log.
+func TestWriteSARIF_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSARIF(&buf, "", nil); err != nil {
+		t.Fatalf("WriteSARIF failed: %v", err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if log.Runs[0].Results == nil {
+		t.Error("Expected results to be an empty array, not null")
+	}
+}
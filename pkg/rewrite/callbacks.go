@@ -0,0 +1,308 @@
+package rewrite
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/astgen"
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+)
+
+// Callback is a function signature imposed by a framework (e.g. an http.HandlerFunc), which can
+// never gain an error result. Errors in functions and literals of the shape are handled in place
+// by the statements of Handle.
+type Callback struct {
+	// Name describes the shape in logs (e.g. "net/http handler").
+	Name string `yaml:"name"`
+	// Params are the parameter types, written with full package paths by types.TypeString
+	// (e.g. "net/http.ResponseWriter", "*net/http.Request"). "any" matches interface{}.
+	Params []string `yaml:"params"`
+	// Results are the result types, in the same form.
+	Results []string `yaml:"results,omitempty"`
+	// Handle is the body of the error check. Besides the error template placeholders ({return-zero},
+	// {callee}, err, ...), {0}, {1}, ... are the names of the parameters and {"path"} is the name of
+	// the package imported from path, e.g.:
+	//
+	//	{"net/http"}.Error({0}, err.Error(), {"net/http"}.StatusInternalServerError)
+	//	return
+	Handle string `yaml:"handle"`
+	// Writers are the indices of the parameters writing the response (e.g. the http.ResponseWriter).
+	// Handle cannot report an error once the response has started, so the errors of calls using
+	// them are logged instead.
+	Writers []int `yaml:"writers,omitempty"`
+}
+
+// DefaultCallbacks are the built-in callback shapes, matched after the configured ones. Tests and
+// their helpers are handled by Injector.HandleTest.
+var DefaultCallbacks = []Callback{
+	{
+		Name:    "net/http handler",
+		Params:  []string{"net/http.ResponseWriter", "*net/http.Request"},
+		Handle:  "{\"net/http\"}.Error({0}, err.Error(), {\"net/http\"}.StatusInternalServerError)\nreturn",
+		Writers: []int{0},
+	},
+	{
+		Name:   "cobra command",
+		Params: []string{"*github.com/spf13/cobra.Command", "[]string"},
+		Handle: `{"github.com/spf13/cobra"}.CheckErr(err)`,
+	},
+	{
+		Name:    "gRPC unary server interceptor",
+		Params:  []string{"context.Context", "any", "*google.golang.org/grpc.UnaryServerInfo", "google.golang.org/grpc.UnaryHandler"},
+		Results: []string{"any", "error"},
+		Handle:  grpcHandle,
+	},
+	{
+		Name:    "gRPC stream server interceptor",
+		Params:  []string{"any", "google.golang.org/grpc.ServerStream", "*google.golang.org/grpc.StreamServerInfo", "google.golang.org/grpc.StreamHandler"},
+		Results: []string{"error"},
+		Handle:  grpcHandle,
+	},
+}
+
+// grpcHandle returns an Internal status from server interceptors.
+const grpcHandle = `return {return-zero}, {"google.golang.org/grpc/status"}.Error({"google.golang.org/grpc/codes"}.Internal, err.Error())`
+
+var (
+	// paramPlaceholder matches the parameter placeholders of Callback.Handle.
+	paramPlaceholder = regexp.MustCompile(`\{([0-9]+)\}`)
+	// importPlaceholder matches the package placeholders of Callback.Handle.
+	importPlaceholder = regexp.MustCompile(`\{("[^"{}]+")\}`)
+)
+
+// Validate checks the shape for missing fields and a malformed Handle.
+func (c Callback) Validate() error {
+	if len(c.Params) == 0 {
+		return fmt.Errorf("callback %q: params are required", c.Name)
+	}
+	if strings.TrimSpace(c.Handle) == "" {
+		return fmt.Errorf("callback %q: handle is required", c.Name)
+	}
+	for _, k := range c.Writers {
+		if k < 0 || k >= len(c.Params) {
+			return fmt.Errorf("callback %q: writer %d is not a parameter", c.Name, k)
+		}
+	}
+	names := make([]string, len(c.Params))
+	for k := range names {
+		names[k] = "p" + strconv.Itoa(k)
+	}
+	src := c.expand(names, func(path string) string { return "pkg" }, "nil", TemplateVars{}, "err")
+	if _, err := parseStmtsDST(src); err != nil {
+		return fmt.Errorf("callback %q: %w", c.Name, err)
+	}
+	return nil
+}
+
+// Matches reports whether sig has the parameter and result types of the shape. The receiver of
+// methods is ignored.
+//
+// sig: The signature of a function or literal.
+func (c Callback) Matches(sig *types.Signature) bool {
+	if sig == nil || sig.Params().Len() != len(c.Params) || sig.Results().Len() != len(c.Results) {
+		return false
+	}
+	for k, want := range c.Params {
+		if callbackTypeString(sig.Params().At(k).Type()) != want {
+			return false
+		}
+	}
+	for k, want := range c.Results {
+		if callbackTypeString(sig.Results().At(k).Type()) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// callbackTypeString writes t in the form of Callback.Params.
+func callbackTypeString(t types.Type) string {
+	if s := types.TypeString(t, nil); s != "interface{}" {
+		return s
+	}
+	return "any"
+}
+
+// CallbackFor returns the first shape of Callbacks matching sig, or nil.
+//
+// sig: The signature of the function or literal containing an error.
+func (i *Injector) CallbackFor(sig *types.Signature) *Callback {
+	for k := range i.Callbacks {
+		if i.Callbacks[k].Matches(sig) {
+			return &i.Callbacks[k]
+		}
+	}
+	return nil
+}
+
+// HandleCallback handles point in place, in a function or literal of the shape cb:
+//
+//	func handle(w http.ResponseWriter, r *http.Request) {
+//		if err := save(r); err != nil {
+//			http.Error(w, err.Error(), http.StatusInternalServerError)
+//			return
+//		}
+//	}
+//
+// Calls of the function parameters of a shape with an error result (the next handler of a
+// middleware) already return errors of the framework, and are left to the regular handling. Errors
+// of calls using a Writers parameter (w.Write, fmt.Fprintf(w, ...)) are logged (see LogFallback):
+// the response may have started.
+//
+// dstFile: The DST of the file.
+// astFile: The AST of the file.
+// point: The injection point.
+// cb: The shape of the enclosing function (see CallbackFor).
+//
+// Returns false if the point cannot be handled: it is not a plain call or assignment, a parameter
+// used by Handle is unnamed, or a package to import has the name of another import of the file.
+func (i *Injector) HandleCallback(dstFile *dst.File, astFile *ast.File, point analysis.InjectionPoint, cb Callback) (bool, error) {
+	if !inPlaceCandidate(point) {
+		return false, nil
+	}
+	sig := i.getEnclosingContext(point).sig
+	if !cb.Matches(sig) || (hasErrorResult(sig) && callsParam(i.Pkg.TypesInfo, point.Call, sig)) {
+		return false, nil
+	}
+	if usesParam(i.Pkg.TypesInfo, point.Call, sig, cb.Writers) {
+		return i.LogFallback(dstFile, astFile, point)
+	}
+
+	names := make([]string, sig.Params().Len())
+	for k := range names {
+		names[k] = sig.Params().At(k).Name()
+	}
+	for _, m := range paramPlaceholder.FindAllStringSubmatch(cb.Handle, -1) {
+		k, _ := strconv.Atoi(m[1])
+		if k >= len(names) || names[k] == "" || names[k] == "_" {
+			return false, nil
+		}
+	}
+
+	q := &fileQualifier{pkg: i.Pkg, file: astFile}
+	pkgName := func(path string) string {
+		return q.qualify(types.NewPackage(path, path[strings.LastIndex(path, "/")+1:]))
+	}
+	for _, m := range importPlaceholder.FindAllStringSubmatch(cb.Handle, -1) {
+		if path, err := strconv.Unquote(m[1]); err == nil {
+			pkgName(path)
+		}
+	}
+	if q.conflict {
+		return false, nil
+	}
+	zeros, zeroDecls, err := i.zeroResultsDST(sig, i.getScope(point.Pos, astFile))
+	if err != nil {
+		return false, err
+	}
+	zerosStr, err := renderExprsDST(zeros)
+	if err != nil {
+		return false, err
+	}
+	vars := i.templateVars(point)
+
+	applied, err := i.handleInPlace(dstFile, astFile, point, func(point analysis.InjectionPoint, dstStmt dst.Stmt) ([]dst.Stmt, error) {
+		return i.generateInPlaceDST(dstFile, point, dstStmt, func(errName string) ([]dst.Stmt, error) {
+			body, err := parseStmtsDST(cb.expand(names, pkgName, zerosStr, vars, errName))
+			if err != nil {
+				return nil, fmt.Errorf("callback %q: %w", cb.Name, err)
+			}
+			return append(zeroDecls, body...), nil
+		})
+	})
+	if applied {
+		for _, path := range q.missing {
+			i.addImportDST(dstFile, path)
+		}
+	}
+	return applied, err
+}
+
+// expand substitutes the placeholders of Handle.
+//
+// names: The names of the parameters.
+// pkgName: Returns the name of the package imported from a path.
+// zeros: The rendered zero values of the results, excluding the error.
+// vars: The call-site context.
+// errName: The name of the error variable.
+//
+// Returns the source of the statements.
+func (c Callback) expand(names []string, pkgName func(path string) string, zeros string, vars TemplateVars, errName string) string {
+	src := applyTemplateReplacement(c.Handle, zeros, vars, errName)
+	src = importPlaceholder.ReplaceAllStringFunc(src, func(m string) string {
+		path, err := strconv.Unquote(m[1 : len(m)-1])
+		if err != nil {
+			return m
+		}
+		return pkgName(path)
+	})
+	return paramPlaceholder.ReplaceAllStringFunc(src, func(m string) string {
+		k, _ := strconv.Atoi(m[1 : len(m)-1])
+		if k < len(names) {
+			return names[k]
+		}
+		return m
+	})
+}
+
+// parseStmtsDST parses src as a list of statements.
+func parseStmtsDST(src string) ([]dst.Stmt, error) {
+	file, err := decorator.Parse("package p\n\nfunc _() {\n" + src + "\n}\n")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse statements %q: %w", src, err)
+	}
+	stmts := file.Decls[0].(*dst.FuncDecl).Body.List
+	for _, s := range stmts {
+		astgen.ClearDecorations(s)
+	}
+	return stmts, nil
+}
+
+// hasErrorResult reports whether the last result of sig is an error.
+func hasErrorResult(sig *types.Signature) bool {
+	n := sig.Results().Len()
+	return n > 0 && types.Identical(sig.Results().At(n-1).Type(), types.Universe.Lookup("error").Type())
+}
+
+// callsParam reports whether call calls a parameter of sig.
+func callsParam(info *types.Info, call *ast.CallExpr, sig *types.Signature) bool {
+	id, ok := ast.Unparen(call.Fun).(*ast.Ident)
+	if !ok || info == nil {
+		return false
+	}
+	obj := info.Uses[id]
+	for k := 0; k < sig.Params().Len(); k++ {
+		if sig.Params().At(k) == obj {
+			return true
+		}
+	}
+	return false
+}
+
+// usesParam reports whether call refers to one of the parameters of sig at the indices params,
+// as its receiver or in its arguments.
+func usesParam(info *types.Info, call *ast.CallExpr, sig *types.Signature, params []int) bool {
+	if info == nil || len(params) == 0 {
+		return false
+	}
+	objs := make(map[types.Object]bool, len(params))
+	for _, k := range params {
+		if k < sig.Params().Len() {
+			objs[sig.Params().At(k)] = true
+		}
+	}
+	found := false
+	ast.Inspect(call, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && objs[info.Uses[id]] {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
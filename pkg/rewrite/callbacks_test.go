@@ -0,0 +1,248 @@
+package rewrite
+
+import (
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+func TestCallback_Matches(t *testing.T) {
+	httpPkg := types.NewPackage("net/http", "http")
+	writer := types.NewNamed(types.NewTypeName(token.NoPos, httpPkg, "ResponseWriter", nil), types.NewInterfaceType(nil, nil), nil)
+	request := types.NewNamed(types.NewTypeName(token.NoPos, httpPkg, "Request", nil), types.NewStruct(nil, nil), nil)
+	errType := types.Universe.Lookup("error").Type()
+
+	params := func(ts ...types.Type) *types.Tuple {
+		vars := make([]*types.Var, len(ts))
+		for k, typ := range ts {
+			vars[k] = types.NewParam(token.NoPos, nil, "", typ)
+		}
+		return types.NewTuple(vars...)
+	}
+	handler := DefaultCallbacks[0]
+	interceptor := Callback{Params: []string{"any"}, Results: []string{"any", "error"}, Handle: "return"}
+	empty := types.NewInterfaceType(nil, nil)
+
+	tests := []struct {
+		name string
+		cb   Callback
+		sig  *types.Signature
+		want bool
+	}{
+		{"Handler", handler, types.NewSignatureType(nil, nil, nil, params(writer, types.NewPointer(request)), nil, false), true},
+		{"ValueRequest", handler, types.NewSignatureType(nil, nil, nil, params(writer, request), nil, false), false},
+		{"ExtraResult", handler, types.NewSignatureType(nil, nil, nil, params(writer, types.NewPointer(request)), params(errType), false), false},
+		{"EmptyInterfaceIsAny", interceptor, types.NewSignatureType(nil, nil, nil, params(empty), params(empty, errType), false), true},
+		{"Nil", handler, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cb.Matches(tt.sig); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallback_Validate(t *testing.T) {
+	for _, cb := range DefaultCallbacks {
+		if err := cb.Validate(); err != nil {
+			t.Errorf("default callback %q: %v", cb.Name, err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		cb   Callback
+	}{
+		{"NoParams", Callback{Name: "x", Handle: "return"}},
+		{"NoHandle", Callback{Name: "x", Params: []string{"string"}}},
+		{"Malformed", Callback{Name: "x", Params: []string{"string"}, Handle: `{0}.Fail(err`}},
+		{"BadWriter", Callback{Name: "x", Params: []string{"string"}, Handle: "return", Writers: []int{1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cb.Validate(); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestHandleCallback(t *testing.T) {
+	src := `package main
+
+import "net/http"
+
+func save(r *http.Request) error { return nil }
+
+func load(r *http.Request) (string, error) { return "", nil }
+
+func handle(w http.ResponseWriter, r *http.Request) {
+	save(r)
+	name, _ := load(r)
+	_ = name
+}
+`
+	injector, dstFile, astFile := setupInjectorTest(t, src)
+	cb := injector.CallbackFor(injector.getEnclosingContext(findPoint(t, astFile, "save")).sig)
+	if cb == nil || cb.Name != "net/http handler" {
+		t.Fatalf("expected the net/http handler shape, got %v", cb)
+	}
+
+	for _, name := range []string{"save", "load"} {
+		applied, err := injector.HandleCallback(dstFile, astFile, findPoint(t, astFile, name), *cb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !applied {
+			t.Errorf("expected the call of %s to be handled", name)
+		}
+	}
+
+	out := render(t, dstFile)
+	for _, want := range []string{
+		"if err := save(r); err != nil {",
+		"name, err := load(r)",
+		"http.Error(w, err.Error(), http.StatusInternalServerError)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "return\n") != 2 {
+		t.Errorf("expected a return after each http.Error:\n%s", out)
+	}
+}
+
+// TestHandleCallback_Writes verifies that errors of writes to the response are logged: http.Error
+// cannot replace a response that has started.
+func TestHandleCallback_Writes(t *testing.T) {
+	src := `package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+func save(r *http.Request) error { return nil }
+
+func handle(w http.ResponseWriter, r *http.Request) {
+	save(r)
+	w.Write([]byte("saved"))
+	fmt.Fprintf(w, "%s\n", r.URL)
+}
+`
+	injector, dstFile, astFile := setupInjectorTest(t, src)
+	for _, name := range []string{"save", "Write", "Fprintf"} {
+		pt := findPoint(t, astFile, name)
+		cb := injector.CallbackFor(injector.getEnclosingContext(pt).sig)
+		if cb == nil {
+			t.Fatalf("no callback for the call of %s", name)
+		}
+		applied, err := injector.HandleCallback(dstFile, astFile, pt, *cb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !applied {
+			t.Errorf("expected the call of %s to be handled", name)
+		}
+	}
+
+	out := render(t, dstFile)
+	for _, want := range []string{
+		"if err := save(r); err != nil {\n\t\thttp.Error(w, err.Error(), http.StatusInternalServerError)",
+		"if _, err := w.Write([]byte(\"saved\")); err != nil {\n\t\tlog.Printf(",
+		"if _, err := fmt.Fprintf(w, \"%s\\n\", r.URL); err != nil {\n\t\tlog.Printf(",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "http.Error(") != 1 {
+		t.Errorf("expected http.Error for the save error only:\n%s", out)
+	}
+}
+
+func TestHandleCallback_Refused(t *testing.T) {
+	src := `package main
+
+import (
+	"context"
+	"net/http"
+)
+
+func save(r *http.Request) error { return nil }
+
+func anonymous(_ http.ResponseWriter, r *http.Request) {
+	save(r)
+}
+
+type Handler func(ctx context.Context) error
+
+func middleware(ctx context.Context, next Handler) error {
+	next(ctx)
+	return nil
+}
+`
+	injector, dstFile, astFile := setupInjectorTest(t, src)
+	injector.Callbacks = append([]Callback{{
+		Name:    "middleware",
+		Params:  []string{"context.Context", "main.Handler"},
+		Results: []string{"error"},
+		Handle:  "return err",
+	}}, injector.Callbacks...)
+
+	for _, name := range []string{"save", "next"} {
+		pt := findPoint(t, astFile, name)
+		cb := injector.CallbackFor(injector.getEnclosingContext(pt).sig)
+		if cb == nil {
+			t.Fatalf("no callback for the call of %s", name)
+		}
+		applied, err := injector.HandleCallback(dstFile, astFile, pt, *cb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if applied {
+			t.Errorf("expected the call of %s to be left alone", name)
+		}
+	}
+}
+
+func TestHandleCallback_Imports(t *testing.T) {
+	src := `package main
+
+func run() error { return nil }
+
+type Job struct{}
+
+func work(j *Job) {
+	run()
+}
+`
+	injector, dstFile, astFile := setupInjectorTest(t, src)
+	cb := Callback{
+		Name:   "job",
+		Params: []string{"*main.Job"},
+		Handle: `{"log/slog"}.Error("{callee} failed", "err", err)`,
+	}
+	if err := cb.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := injector.HandleCallback(dstFile, astFile, findPoint(t, astFile, "run"), cb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !applied {
+		t.Fatal("expected the call to be handled")
+	}
+	out := render(t, dstFile)
+	if !strings.Contains(out, `slog.Error("run failed", "err", err)`) {
+		t.Errorf("unexpected handling:\n%s", out)
+	}
+	if !strings.Contains(out, `import "log/slog"`) {
+		t.Errorf("import log/slog missing:\n%s", out)
+	}
+}
@@ -0,0 +1,206 @@
+package rewrite
+
+import (
+	"go/ast"
+	"go/token"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/dave/dst"
+	"github.com/dave/dst/dstutil"
+)
+
+// CaptureName returns a name for the variable capturing the errors of the function literal
+// containing point (see CaptureFile), unused in the scope of the point and its parents.
+func (i *Injector) CaptureName(astFile *ast.File, point analysis.InjectionPoint) string {
+	return analysis.GenerateUniqueName(i.getScope(point.Pos, astFile), "err")
+}
+
+// CaptureFile handles point, inside a function literal passed to a function that calls it before
+// returning (see analysis.SyncCallbackCall), by storing the error in a variable of the enclosing
+// function and leaving the literal:
+//
+//	var err error
+//	once.Do(func() {
+//		if err = c.connect(); err != nil {
+//			return
+//		}
+//	})
+//
+// The caller checks the variable after outer. A literal the function may call more than once (e.g.
+// sort.Slice) keeps running and stores only the first error, so that a later success does not
+// clear it:
+//
+//	var err error
+//	sort.Slice(s, func(i, j int) bool {
+//		if e := check(s[i]); e != nil && err == nil {
+//			err = e
+//		}
+//		return s[i] < s[j]
+//	})
+//
+// dstFile: The DST of the file.
+// astFile: The AST of the file.
+// point: The injection point, inside the literal.
+// outer: The statement passing the literal, an element of a statement list.
+// errName: The name of the variable (see CaptureName).
+// declare: Whether to declare the variable before outer; false for the further points of the same
+// statement.
+// once: Whether the literal is called at most once (see analysis.CallsOnce).
+//
+// Returns false if the point cannot be captured: the statement declares variables, or errName refers
+// to another variable at the point.
+func (i *Injector) CaptureFile(dstFile *dst.File, astFile *ast.File, point analysis.InjectionPoint, outer ast.Stmt, errName string, declare, once bool) (bool, error) {
+	if point.Stmt == nil || point.Call == nil || point.ErrIdent != nil || point.Shadows != nil {
+		return false, nil
+	}
+	if point.Assign != nil {
+		if point.Assign.Tok != token.ASSIGN {
+			return false, nil
+		}
+	} else if _, ok := point.Stmt.(*ast.ExprStmt); !ok {
+		return false, nil
+	}
+	if scope := i.getScope(point.Pos, astFile); scope != nil {
+		if _, obj := scope.LookupParent(errName, token.NoPos); obj != nil {
+			return false, nil
+		}
+	}
+	if declare && !inStmtList(astFile, outer) {
+		return false, nil
+	}
+
+	res, err := FindDstNode(i.Fset, dstFile, astFile, point.Stmt)
+	if err != nil {
+		return false, err
+	}
+	dstStmt, ok := res.Node.(dst.Stmt)
+	if !ok {
+		return false, nil
+	}
+	var dstOuter dst.Stmt
+	if declare {
+		outerRes, err := FindDstNode(i.Fset, dstFile, astFile, outer)
+		if err != nil {
+			return false, err
+		}
+		if dstOuter, ok = outerRes.Node.(dst.Stmt); !ok {
+			return false, nil
+		}
+	}
+	call := i.extractDstCall(dstStmt)
+	if call == nil {
+		return false, nil
+	}
+
+	callClone := dst.Clone(call).(*dst.CallExpr)
+	callClone.Decorations().Before = dst.None
+	callClone.Decorations().After = dst.None
+	var stmts []dst.Stmt
+	if once {
+		stmts, err = i.captureReturnDST(astFile, point, callClone, errName)
+	} else {
+		stmts, err = i.captureFirstDST(astFile, point, callClone, errName)
+	}
+	if err != nil || stmts == nil {
+		return false, err
+	}
+
+	applied := false
+	dstutil.Apply(dstFile, func(c *dstutil.Cursor) bool {
+		switch c.Node() {
+		case dstStmt:
+			if c.Index() < 0 {
+				stmts = collapseInit(stmts)
+			}
+			i.transferTrivia(dstStmt, stmts)
+			c.Replace(stmts[0])
+			for k := len(stmts) - 1; k > 0; k-- {
+				c.InsertAfter(stmts[k])
+			}
+			applied = true
+			return false
+		case dstOuter:
+			if c.Index() < 0 {
+				return true
+			}
+			// The declaration takes over the comments of the statement.
+			decl := &dst.DeclStmt{Decl: &dst.GenDecl{
+				Tok:   token.VAR,
+				Specs: []dst.Spec{&dst.ValueSpec{Names: []*dst.Ident{dst.NewIdent(errName)}, Type: dst.NewIdent("error")}},
+			}}
+			decl.Decorations().Before = dstOuter.Decorations().Before
+			decl.Decorations().Start = dstOuter.Decorations().Start
+			decl.Decorations().After = dst.NewLine
+			dstOuter.Decorations().Before = dst.NewLine
+			dstOuter.Decorations().Start.Clear()
+			c.InsertBefore(decl)
+		}
+		return true
+	}, nil)
+	return applied, nil
+}
+
+// captureReturnDST returns the statements storing the error of call in errName and leaving the
+// literal, which returns the zero values of its results.
+func (i *Injector) captureReturnDST(astFile *ast.File, point analysis.InjectionPoint, call *dst.CallExpr, errName string) ([]dst.Stmt, error) {
+	zeros, zeroDecls, err := i.zeroResultsDST(i.getEnclosingContext(point).sig, i.getScope(point.Pos, astFile))
+	if err != nil {
+		return nil, err
+	}
+	assign, err := i.generateAssignmentDST(point, call, errName, token.ASSIGN)
+	if err != nil {
+		return nil, err
+	}
+	stmts := checkAfterDST(assign, errName, &dst.BlockStmt{List: append(zeroDecls, &dst.ReturnStmt{Results: zeros})})
+	if as, ok := assign.(*dst.AssignStmt); ok && assignsOnlyErr(as, errName) {
+		stmts = collapseInit(stmts)
+	}
+	return stmts, nil
+}
+
+// captureFirstDST returns the statements storing the error of call in errName unless it already
+// holds one, through a variable local to the literal.
+//
+// Returns nil if the statement keeps other results but is not in a statement list, where the
+// declaration of the local variable cannot go.
+func (i *Injector) captureFirstDST(astFile *ast.File, point analysis.InjectionPoint, call *dst.CallExpr, errName string) ([]dst.Stmt, error) {
+	scope := i.getScope(point.Pos, astFile)
+	local := analysis.GenerateUniqueName(scope, "e")
+	if local == errName {
+		local = analysis.GenerateUniqueName(scope, errName+"1")
+	}
+	assign, err := i.generateAssignmentDST(point, call, local, token.DEFINE)
+	if err != nil {
+		return nil, err
+	}
+	check := &dst.IfStmt{
+		Cond: &dst.BinaryExpr{
+			X:  &dst.BinaryExpr{X: dst.NewIdent(local), Op: token.NEQ, Y: dst.NewIdent("nil")},
+			Op: token.LAND,
+			Y:  &dst.BinaryExpr{X: dst.NewIdent(errName), Op: token.EQL, Y: dst.NewIdent("nil")},
+		},
+		Body: &dst.BlockStmt{List: []dst.Stmt{&dst.AssignStmt{
+			Lhs: []dst.Expr{dst.NewIdent(errName)},
+			Tok: token.ASSIGN,
+			Rhs: []dst.Expr{dst.NewIdent(local)},
+		}}},
+	}
+	as, ok := assign.(*dst.AssignStmt)
+	if !ok {
+		return nil, nil
+	}
+	if assignsOnlyErr(as, local) {
+		check.Init = as
+		return []dst.Stmt{check}, nil
+	}
+	// The other results keep their variables, which ":=" could redeclare in the literal.
+	if !inStmtList(astFile, point.Stmt) {
+		return nil, nil
+	}
+	as.Tok = token.ASSIGN
+	decl := &dst.DeclStmt{Decl: &dst.GenDecl{
+		Tok:   token.VAR,
+		Specs: []dst.Spec{&dst.ValueSpec{Names: []*dst.Ident{dst.NewIdent(local)}, Type: dst.NewIdent("error")}},
+	}}
+	return []dst.Stmt{decl, as, check}, nil
+}
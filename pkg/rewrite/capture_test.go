@@ -0,0 +1,111 @@
+package rewrite
+
+import (
+	"go/ast"
+	"strings"
+	"testing"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestCaptureFile(t *testing.T) {
+	src := `package main
+
+import (
+	"os"
+	"sync"
+)
+
+var once sync.Once
+
+func open() int {
+	// open once
+	once.Do(func() {
+		os.Remove("a")
+		os.Remove("b")
+	})
+	return 1
+}
+`
+	injector, _, astFile := setupInjectorTest(t, src)
+	// The declaration shifts the statements; the node table of the decorator keeps them apart.
+	dstFile, err := DecorateFile(injector.Fset, astFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	points, err := analysis.Detect([]*packages.Package{injector.Pkg}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("expected 2 points, got %d", len(points))
+	}
+	var lit *ast.FuncLit
+	ast.Inspect(astFile, func(n ast.Node) bool {
+		if l, ok := n.(*ast.FuncLit); ok {
+			lit = l
+		}
+		return lit == nil
+	})
+	api, outer := analysis.SyncCallbackCall(injector.Pkg.TypesInfo, astFile, lit)
+	if api == nil {
+		t.Fatal("once.Do not recognized as a synchronous callback")
+	}
+
+	name := injector.CaptureName(astFile, points[0])
+	for k, p := range points {
+		applied, err := injector.CaptureFile(dstFile, astFile, p, outer, name, k == 0, true)
+		if err != nil || !applied {
+			t.Fatalf("CaptureFile failed: applied=%v err=%v", applied, err)
+		}
+	}
+	out := render(t, dstFile)
+	for _, want := range []string{
+		"\t// open once\n\tvar err error\n\tonce.Do(func() {\n",
+		"if err = os.Remove(\"a\"); err != nil {\n\t\t\treturn\n\t\t}",
+		"if err = os.Remove(\"b\"); err != nil {\n\t\t\treturn\n\t\t}",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "var err error") != 1 {
+		t.Errorf("expected a single declaration:\n%s", out)
+	}
+}
+
+func TestCaptureFile_Refused(t *testing.T) {
+	src := `package main
+
+import (
+	"os"
+	"sort"
+)
+
+func order(names []string) {
+	sort.Slice(names, func(i, j int) bool {
+		err := os.Remove(names[i])
+		os.Remove(names[j])
+		return err == nil
+	})
+}
+`
+	injector, dstFile, astFile := setupInjectorTest(t, src)
+	points, err := analysis.Detect([]*packages.Package{injector.Pkg}, nil, false)
+	if err != nil || len(points) != 1 {
+		t.Fatalf("expected 1 point, got %d (%v)", len(points), err)
+	}
+	var outer ast.Stmt
+	ast.Inspect(astFile, func(n ast.Node) bool {
+		if s, ok := n.(*ast.ExprStmt); ok && outer == nil {
+			outer = s
+		}
+		return outer == nil
+	})
+	// err is declared in the literal, so it cannot refer to the captured variable.
+	applied, err := injector.CaptureFile(dstFile, astFile, points[0], outer, "err", true, false)
+	if err != nil || applied {
+		t.Errorf("expected the capture to be refused: applied=%v err=%v", applied, err)
+	}
+}
@@ -0,0 +1,343 @@
+package rewrite
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/dave/dst"
+)
+
+const (
+	// ClosePolicyIgnore leaves the deferred calls releasing read-only resources unchanged, and does
+	// not report them (the default).
+	ClosePolicyIgnore = "ignore"
+	// ClosePolicyNolint marks the deferred calls releasing read-only resources with a
+	// "//nolint:errcheck" comment, for the linters that would report them.
+	ClosePolicyNolint = "nolint"
+	// ClosePolicyJoin joins the errors of all deferred calls into the error result, read-only or not.
+	ClosePolicyJoin = "join"
+)
+
+// nolintComments mark the deferred calls releasing a read-only resource or rolling back with
+// ClosePolicyNolint, by kind.
+var nolintComments = map[analysis.CloseKind]string{
+	analysis.CloseReadOnly: "//nolint:errcheck // read-only",
+	analysis.CloseRollback: "//nolint:errcheck // no-op after commit",
+}
+
+// ReadOnlyClose reports whether point is a deferred call releasing a read-only resource or rolling
+// back (see analysis.CloseKind.Lossless) which policy does not join into the error result. Such
+// calls never require an error result.
+//
+// point: The injection point.
+// policy: The close policy of the package.
+func ReadOnlyClose(point analysis.InjectionPoint, policy string) bool {
+	stmt, ok := point.Stmt.(*ast.DeferStmt)
+	if !ok || policy == ClosePolicyJoin || point.Pkg == nil {
+		return false
+	}
+	return analysis.ClassifyClose(point.Pkg.TypesInfo, point.File, stmt.Call).Lossless()
+}
+
+// LeavesClose reports whether policy leaves point, a deferred call releasing a read-only resource
+// (see ReadOnlyClose), as it is: always with ClosePolicyIgnore (or ""), and once marked with a
+// //nolint comment with ClosePolicyNolint. Such calls are not reported.
+//
+// point: The injection point.
+// policy: The close policy of the package.
+func LeavesClose(point analysis.InjectionPoint, policy string) bool {
+	if !ReadOnlyClose(point, policy) {
+		return false
+	}
+	return policy != ClosePolicyNolint || hasNolint(point.Pkg.Fset, point.File, point.Stmt)
+}
+
+// hasNolint reports whether a //nolint comment covering errcheck ends the line of stmt.
+func hasNolint(fset *token.FileSet, file *ast.File, stmt ast.Stmt) bool {
+	line := fset.Position(stmt.End()).Line
+	for _, group := range file.Comments {
+		for _, c := range group.List {
+			if fset.Position(c.Pos()).Line != line || !strings.HasPrefix(c.Text, "//nolint") {
+				continue
+			}
+			linters, ok := strings.CutPrefix(strings.Fields(c.Text)[0], "//nolint:")
+			if !ok || strings.Contains(","+linters+",", ",errcheck,") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// markNolintDST appends the comment of kind (see nolintComments) to the deferred call, unless it
+// has a //nolint comment.
+//
+// Returns true if the comment was added.
+func markNolintDST(stmt *dst.DeferStmt, kind analysis.CloseKind) bool {
+	for _, dec := range stmt.Decs.End {
+		if strings.HasPrefix(dec, "//nolint") {
+			return false
+		}
+	}
+	stmt.Decs.End.Append(nolintComments[kind])
+	return true
+}
+
+// nameResultsDST names the anonymous results of a function returning an error, so that deferred
+// calls can join their errors into it: the last error becomes err and the other results _.
+//
+// ft: The AST of the function type.
+// body: The AST of the function body.
+// dstType: The DST of the function type.
+//
+// Returns false, leaving the results unchanged, if they are named or do not end with an error, or
+// if err is a parameter or is declared again in the body. A top level "err := f()" would no longer
+// compile with err declared by the results.
+func (i *Injector) nameResultsDST(ft *ast.FuncType, body *ast.BlockStmt, dstType *dst.FuncType) bool {
+	if ft.Results == nil || len(ft.Results.List) == 0 || body == nil {
+		return false
+	}
+	// The error result added by a signature change has no type information.
+	last := ft.Results.List[len(ft.Results.List)-1]
+	if t := i.Pkg.TypesInfo.TypeOf(last.Type); t != nil && !i.isErrorType(t) {
+		return false
+	} else if id, ok := last.Type.(*ast.Ident); t == nil && (!ok || id.Name != "error") {
+		return false
+	}
+	for _, field := range ft.Results.List {
+		if len(field.Names) > 0 {
+			return false
+		}
+	}
+	for _, field := range ft.Params.List {
+		for _, name := range field.Names {
+			if name.Name == "err" {
+				return false
+			}
+		}
+	}
+	for _, stmt := range body.List {
+		if !i.keepsErr(stmt) {
+			return false
+		}
+	}
+
+	results := dstType.Results.List
+	for k, field := range results {
+		name := "_"
+		if k == len(results)-1 {
+			name = "err"
+		}
+		field.Names = []*dst.Ident{dst.NewIdent(name)}
+	}
+	return true
+}
+
+// keepsErr reports whether stmt, at the top level of a function body, still compiles once err is a
+// named result: it does not declare err, except alongside another new variable.
+func (i *Injector) keepsErr(stmt ast.Stmt) bool {
+	switch s := stmt.(type) {
+	case *ast.AssignStmt:
+		if s.Tok != token.DEFINE {
+			return true
+		}
+		declaresErr, declaresOther := false, false
+		for _, lhs := range s.Lhs {
+			id, ok := lhs.(*ast.Ident)
+			if !ok || id.Name == "_" {
+				continue
+			}
+			if id.Name == "err" {
+				declaresErr = true
+			} else if i.Pkg.TypesInfo.Defs[id] != nil {
+				declaresOther = true
+			}
+		}
+		return !declaresErr || declaresOther
+	case *ast.DeclStmt:
+		gen, ok := s.Decl.(*ast.GenDecl)
+		if !ok {
+			return true
+		}
+		for _, spec := range gen.Specs {
+			switch sp := spec.(type) {
+			case *ast.ValueSpec:
+				for _, name := range sp.Names {
+					if name.Name == "err" {
+						return false
+					}
+				}
+			case *ast.TypeSpec:
+				if sp.Name.Name == "err" {
+					return false
+				}
+			}
+		}
+	case *ast.LabeledStmt:
+		return i.keepsErr(s.Stmt)
+	}
+	return true
+}
+
+// syncsOnClose reports whether call, the deferred Close of a writable resource, is the Close of an
+// *os.File which SyncOnClose syncs first.
+func (i *Injector) syncsOnClose(call *ast.CallExpr) bool {
+	if !i.SyncOnClose {
+		return false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Close" || len(call.Args) > 0 {
+		return false
+	}
+	return types.TypeString(i.Pkg.TypesInfo.TypeOf(sel.X), nil) == "*os.File"
+}
+
+// unflushedWriters finds the *bufio.Writer variables of body wrapping one of files which are never
+// flushed in body. Their buffered data is lost when the file is closed.
+//
+// body: The body of the function.
+// files: The variables of the writable files closed by the function, with their deferred Close.
+//
+// Returns the top level statements of body after which the flush of a writer is deferred, with the
+// name of the writer: its declaration, or the deferred Close of the file if it comes later, so
+// that the flush runs first. Writers whose declaration or deferred Close is nested are left out.
+func (i *Injector) unflushedWriters(body *ast.BlockStmt, files map[types.Object]*ast.DeferStmt) map[ast.Stmt]string {
+	info := i.Pkg.TypesInfo
+	topLevel := make(map[ast.Stmt]bool, len(body.List))
+	for _, stmt := range body.List {
+		topLevel[stmt] = true
+	}
+	anchors := make(map[types.Object]ast.Stmt)
+	for _, stmt := range body.List {
+		as, ok := stmt.(*ast.AssignStmt)
+		if !ok || as.Tok != token.DEFINE || len(as.Lhs) != 1 || len(as.Rhs) != 1 {
+			continue
+		}
+		id, ok := as.Lhs[0].(*ast.Ident)
+		call, isCall := as.Rhs[0].(*ast.CallExpr)
+		if !ok || !isCall || len(call.Args) == 0 || info.Defs[id] == nil {
+			continue
+		}
+		if name := analysis.CalleeName(info, call); name != "bufio.NewWriter" && name != "bufio.NewWriterSize" {
+			continue
+		}
+		arg, ok := call.Args[0].(*ast.Ident)
+		if !ok {
+			continue
+		}
+		closer, ok := files[info.ObjectOf(arg)]
+		if !ok || !topLevel[closer] {
+			continue
+		}
+		anchor := stmt
+		if closer.Pos() > stmt.Pos() {
+			anchor = closer
+		}
+		anchors[info.Defs[id]] = anchor
+	}
+	if len(anchors) == 0 {
+		return nil
+	}
+
+	ast.Inspect(body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Flush" {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				delete(anchors, info.ObjectOf(id))
+			}
+		}
+		return true
+	})
+
+	out := make(map[ast.Stmt]string, len(anchors))
+	for obj, stmt := range anchors {
+		out[stmt] = obj.Name()
+	}
+	return out
+}
+
+// flushAnchorsDST resolves the statements returned by unflushedWriters to their index in body,
+// before the deferred calls of body are rewritten.
+//
+// Returns the indexes of the statements after which a flush is deferred, with the name of the
+// writer.
+func (i *Injector) flushAnchorsDST(dstFile *dst.File, astFile *ast.File, body *dst.BlockStmt, writers map[ast.Stmt]string) map[int]string {
+	anchors := make(map[int]string, len(writers))
+	for astStmt, name := range writers {
+		res, err := FindDstNode(i.Fset, dstFile, astFile, astStmt)
+		if err != nil {
+			continue
+		}
+		for k, stmt := range body.List {
+			if stmt == res.Node {
+				anchors[k] = name
+				break
+			}
+		}
+	}
+	return anchors
+}
+
+// insertFlushesDST defers the flush of the unflushed writers of a function (see unflushedWriters)
+// after their anchor, so that it runs before the deferred Close of the file:
+//
+//	w := bufio.NewWriter(f)
+//	defer func() { err = errors.Join(err, w.Flush()) }()
+//
+// body: The DST of the function body.
+// anchors: The writers by index of their anchor in body (see flushAnchorsDST).
+// errName: The name of the error result.
+//
+// Returns true if a flush was inserted.
+func (i *Injector) insertFlushesDST(body *dst.BlockStmt, anchors map[int]string, errName string) bool {
+	if len(anchors) == 0 {
+		return false
+	}
+	// The file is rewritten once per injection point: writers flushed by a previous pass are done.
+	flushed := make(map[string]bool)
+	dst.Inspect(body, func(n dst.Node) bool {
+		if sel, ok := n.(*dst.SelectorExpr); ok && sel.Sel.Name == "Flush" {
+			if id, ok := sel.X.(*dst.Ident); ok {
+				flushed[id.Name] = true
+			}
+		}
+		return true
+	})
+	changed := false
+	list := make([]dst.Stmt, 0, len(body.List)+len(anchors))
+	for k, stmt := range body.List {
+		list = append(list, stmt)
+		if name, ok := anchors[k]; ok && !flushed[name] {
+			changed = true
+			flush := &dst.CallExpr{Fun: &dst.SelectorExpr{X: dst.NewIdent(name), Sel: dst.NewIdent("Flush")}}
+			list = append(list, i.generateDeferRewriteDST(flush, errName))
+		}
+	}
+	body.List = list
+	return changed
+}
+
+// syncBeforeCloseDST syncs the file of a deferred Close joined by generateDeferRewriteDST before
+// closing it:
+//
+//	defer func() { err = errors.Join(err, f.Sync(), f.Close()) }()
+func syncBeforeCloseDST(stmt *dst.DeferStmt) {
+	assign := stmt.Call.Fun.(*dst.FuncLit).Body.List[0].(*dst.AssignStmt)
+	join := assign.Rhs[0].(*dst.CallExpr)
+	closeCall, ok := join.Args[1].(*dst.CallExpr)
+	if !ok {
+		return
+	}
+	sel, ok := closeCall.Fun.(*dst.SelectorExpr)
+	if !ok {
+		return
+	}
+	sync := &dst.CallExpr{Fun: &dst.SelectorExpr{X: dst.Clone(sel.X).(dst.Expr), Sel: dst.NewIdent("Sync")}}
+	join.Args = []dst.Expr{join.Args[0], sync, closeCall}
+}
@@ -0,0 +1,201 @@
+package rewrite
+
+import (
+	"go/ast"
+	"strings"
+	"testing"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/dstmap"
+)
+
+const closersSrc = `package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+)
+
+func read(name string) ([]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+func marked(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck
+	return nil
+}
+
+func write(name string, data []byte) (int, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	return w.Write(data)
+}
+
+func flushed(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	defer f.Close()
+	if _, err := w.WriteString("x"); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func redeclared(name string) error {
+	f, _ := os.Create(name)
+	defer f.Close()
+	err := os.Remove(name)
+	return err
+}
+`
+
+func TestRewriteDefers_Closers(t *testing.T) {
+	injector, _, astFile := setupInjectorTest(t, closersSrc)
+	// Mapped like the runner's files, so that the nodes are found again after the rewrite.
+	dstFile, err := dstmap.Decorate(injector.Fset, astFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dstmap.Forget(dstFile)
+	if _, err := injector.RewriteDefers(dstFile, astFile); err != nil {
+		t.Fatal(err)
+	}
+	out := render(t, dstFile)
+	for _, want := range []string{
+		// Read-only files are left alone.
+		"\tdefer f.Close()\n\treturn io.ReadAll(f)",
+		"defer f.Close() //nolint:errcheck\n",
+		// Writable files name the results to join the error, and flush their writer first.
+		"func write(name string, data []byte) (_ int, err error) {",
+		"defer func() {\n\t\terr = errors.Join(err, f.Close())\n\t}()\n\tw := bufio.NewWriter(f)\n\tdefer func() {\n\t\terr = errors.Join(err, w.Flush())\n\t}()\n\treturn w.Write(data)",
+		// Flushed writers are not flushed again.
+		"func flushed(name string) (err error) {",
+		"w := bufio.NewWriter(f)\n\tdefer func() {\n\t\terr = errors.Join(err, f.Close())\n\t}()\n\tif _, err",
+		// err cannot become a result while the body declares it.
+		"func redeclared(name string) error {\n\tf, _ := os.Create(name)\n\tdefer f.Close()",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "Flush()") != 2 {
+		t.Errorf("expected a single inserted flush:\n%s", out)
+	}
+
+	// The file is rewritten once per injection point.
+	if applied, err := injector.RewriteDefers(dstFile, astFile); err != nil || applied {
+		t.Errorf("expected a second pass to change nothing, got applied=%v err=%v", applied, err)
+	}
+}
+
+func TestRewriteDefers_ClosePolicy(t *testing.T) {
+	injector, dstFile, astFile := setupInjectorTest(t, closersSrc)
+	injector.ClosePolicy = ClosePolicyNolint
+	injector.SyncOnClose = true
+	if _, err := injector.RewriteDefers(dstFile, astFile); err != nil {
+		t.Fatal(err)
+	}
+	out := render(t, dstFile)
+	for _, want := range []string{
+		"\tdefer f.Close() //nolint:errcheck // read-only\n\treturn io.ReadAll(f)",
+		"defer f.Close() //nolint:errcheck\n",
+		"err = errors.Join(err, f.Sync(), f.Close())\n\t}()\n\tw := bufio.NewWriter(f)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+
+	injector, dstFile, astFile = setupInjectorTest(t, closersSrc)
+	injector.ClosePolicy = ClosePolicyJoin
+	if _, err := injector.RewriteDefers(dstFile, astFile); err != nil {
+		t.Fatal(err)
+	}
+	// Joining read-only files does not name results.
+	if out := render(t, dstFile); !strings.Contains(out, "\tdefer f.Close()\n\treturn io.ReadAll(f)") {
+		t.Errorf("expected the anonymous results to be kept:\n%s", out)
+	}
+}
+
+// TestRewriteDefers_RollbackNolint verifies that rollbacks are marked as such, not as read-only.
+func TestRewriteDefers_RollbackNolint(t *testing.T) {
+	src := `package main
+
+import "database/sql"
+
+func update(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return tx.Commit()
+}
+`
+	injector, dstFile, astFile := setupInjectorTest(t, src)
+	injector.ClosePolicy = ClosePolicyNolint
+	if _, err := injector.RewriteDefers(dstFile, astFile); err != nil {
+		t.Fatal(err)
+	}
+	out := render(t, dstFile)
+	if !strings.Contains(out, "defer tx.Rollback() //nolint:errcheck // no-op after commit\n") {
+		t.Errorf("rollback not marked:\n%s", out)
+	}
+}
+
+func TestLeavesClose(t *testing.T) {
+	injector, _, astFile := setupInjectorTest(t, closersSrc)
+	defers := make(map[string]analysis.InjectionPoint)
+	for _, decl := range astFile.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		ast.Inspect(fn, func(n ast.Node) bool {
+			if d, ok := n.(*ast.DeferStmt); ok {
+				defers[fn.Name.Name] = analysis.InjectionPoint{Pkg: injector.Pkg, File: astFile, Call: d.Call, Stmt: d, Pos: d.Call.Pos()}
+			}
+			return true
+		})
+	}
+
+	tests := []struct {
+		fn, policy      string
+		readOnly, leave bool
+	}{
+		{"read", "", true, true},
+		{"read", ClosePolicyIgnore, true, true},
+		{"read", ClosePolicyNolint, true, false},
+		{"read", ClosePolicyJoin, false, false},
+		{"marked", ClosePolicyNolint, true, true},
+		{"write", ClosePolicyIgnore, false, false},
+	}
+	for _, tt := range tests {
+		if got := ReadOnlyClose(defers[tt.fn], tt.policy); got != tt.readOnly {
+			t.Errorf("ReadOnlyClose(%s, %q) = %v, want %v", tt.fn, tt.policy, got, tt.readOnly)
+		}
+		if got := LeavesClose(defers[tt.fn], tt.policy); got != tt.leave {
+			t.Errorf("LeavesClose(%s, %q) = %v, want %v", tt.fn, tt.policy, got, tt.leave)
+		}
+	}
+	if LeavesClose(findPoint(t, astFile, "Remove"), ClosePolicyIgnore) {
+		t.Error("expected calls outside defers to be kept")
+	}
+}
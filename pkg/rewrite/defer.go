@@ -1,24 +1,51 @@
 package rewrite
 
 import (
+	"errors"
 	"fmt"
 	"go/ast"
 	"go/token"
+	"go/types"
 
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/astgen"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/dstmap"
 	"github.com/SamuelMarks/go-auto-err-handling/pkg/refactor"
 	"github.com/dave/dst"
+	"github.com/dave/dst/dstutil"
 	"golang.org/x/tools/go/ast/astutil"
 )
 
 // RewriteDefers scans the file for defer statements (including inside closures).
 // It converts defers that ignore errors into a pattern using errors.Join.
+//
+// Deferred calls releasing read-only resources follow ClosePolicy. Functions closing writable
+// resources have their anonymous results named to join the error (see nameResultsDST), and the
+// buffered writers they never flush are flushed first (see unflushedWriters).
 func (i *Injector) RewriteDefers(dstFile *dst.File, astFile *ast.File) (bool, error) {
+	return i.rewriteDefers(dstFile, astFile, nil)
+}
+
+// RewriteDefer rewrites a single defer statement using the same errors.Join strategy as RewriteDefers.
+// Other defers in the file are left untouched.
+func (i *Injector) RewriteDefer(dstFile *dst.File, astFile *ast.File, stmt *ast.DeferStmt) (bool, error) {
+	if stmt == nil {
+		return false, fmt.Errorf("defer statement cannot be nil")
+	}
+	return i.rewriteDefers(dstFile, astFile, map[*ast.DeferStmt]bool{stmt: true})
+}
+
+// rewriteDefers implements RewriteDefers. If only is non-nil, all other defers are ignored.
+func (i *Injector) rewriteDefers(dstFile *dst.File, astFile *ast.File, only map[*ast.DeferStmt]bool) (bool, error) {
 	if dstFile == nil || astFile == nil {
 		return false, fmt.Errorf("files cannot be nil")
 	}
 
 	targets := make(map[*ast.FuncDecl][]*ast.DeferStmt)
 	litTargets := make(map[*ast.FuncLit][]*ast.DeferStmt)
+	var logTargets, nolintTargets []*ast.DeferStmt
+	writable := make(map[*ast.DeferStmt]bool)
+	nolintKinds := make(map[*ast.DeferStmt]analysis.CloseKind)
 
 	type scopeCtx struct {
 		decl *ast.FuncDecl
@@ -34,9 +61,21 @@ func (i *Injector) RewriteDefers(dstFile *dst.File, astFile *ast.File) (bool, er
 		if lit, ok := node.(*ast.FuncLit); ok {
 			stack = append(stack, scopeCtx{lit: lit})
 		}
-		if deferStmt, ok := node.(*ast.DeferStmt); ok {
+		if deferStmt, ok := node.(*ast.DeferStmt); ok && (only == nil || only[deferStmt]) {
 			if i.isErrorReturningCall(deferStmt.Call) {
-				if len(stack) > 0 {
+				point := analysis.InjectionPoint{Pkg: i.Pkg, File: astFile, Call: deferStmt.Call, Stmt: deferStmt, Pos: deferStmt.Call.Pos()}
+				kind := analysis.ClassifyClose(i.Pkg.TypesInfo, astFile, deferStmt.Call)
+				if kind.Lossless() && i.ClosePolicy != ClosePolicyJoin {
+					// Closing a read-only resource or rolling back cannot lose data.
+					if i.ClosePolicy == ClosePolicyNolint {
+						nolintTargets = append(nolintTargets, deferStmt)
+						nolintKinds[deferStmt] = kind
+					}
+				} else if i.RuleFor(point).Action == ActionLog {
+					// Logged defers do not need a named error result.
+					logTargets = append(logTargets, deferStmt)
+				} else if len(stack) > 0 {
+					writable[deferStmt] = kind == analysis.CloseWritable
 					current := stack[len(stack)-1]
 					if current.decl != nil {
 						targets[current.decl] = append(targets[current.decl], deferStmt)
@@ -60,6 +99,58 @@ func (i *Injector) RewriteDefers(dstFile *dst.File, astFile *ast.File) (bool, er
 
 	applied := false
 
+	// Mark read-only and rollback defers
+	for _, astDefer := range nolintTargets {
+		res, err := FindDstNode(i.Fset, dstFile, astFile, astDefer)
+		if errors.Is(err, dstmap.ErrDetached) {
+			// Already rewritten, e.g. with the function gaining an error result.
+			continue
+		}
+		if err != nil {
+			return applied, err
+		}
+		if dstDefer, ok := res.Node.(*dst.DeferStmt); ok && markNolintDST(dstDefer, nolintKinds[astDefer]) {
+			applied = true
+		}
+	}
+
+	// Process logged defers
+	for _, astDefer := range logTargets {
+		res, err := FindDstNode(i.Fset, dstFile, astFile, astDefer)
+		if errors.Is(err, dstmap.ErrDetached) {
+			continue
+		}
+		if err != nil {
+			return applied, err
+		}
+		dstDefer, ok := res.Node.(*dst.DeferStmt)
+		if !ok {
+			continue
+		}
+		point := analysis.InjectionPoint{Pkg: i.Pkg, File: astFile, Call: astDefer.Call, Stmt: astDefer, Pos: astDefer.Call.Pos()}
+		newDefer, err := i.generateDeferLogDST(dstDefer.Call, point)
+		if err != nil {
+			return applied, err
+		}
+		replaced := false
+		dstutil.Apply(dstFile, func(c *dstutil.Cursor) bool {
+			if replaced {
+				return false
+			}
+			if c.Node() == dstDefer {
+				newDefer.Decs = dstDefer.Decs
+				c.Replace(newDefer)
+				replaced = true
+				return false
+			}
+			return true
+		}, nil)
+		if replaced {
+			i.addLogImportDST(dstFile, point)
+			applied = true
+		}
+	}
+
 	// Process FuncDecls
 	for astDecl, defers := range targets {
 		res, err := FindDstNode(i.Fset, dstFile, astFile, astDecl)
@@ -72,7 +163,11 @@ func (i *Injector) RewriteDefers(dstFile *dst.File, astFile *ast.File) (bool, er
 		}
 
 		if hasAnonymousReturnsDST(dstDecl.Type) {
-			continue
+			// Only the errors of writable resources are worth naming the results.
+			if !anyWritable(defers, writable) || !i.nameResultsDST(astDecl.Type, astDecl.Body, dstDecl.Type) {
+				continue
+			}
+			applied = true
 		}
 
 		changed, err := refactor.EnsureNamedReturnsDST(dstDecl)
@@ -88,7 +183,11 @@ func (i *Injector) RewriteDefers(dstFile *dst.File, astFile *ast.File) (bool, er
 			continue
 		}
 
-		if i.rewriteDefersInDST(dstDecl.Body, defers, astFile, dstFile, errName) {
+		anchors := i.flushAnchorsDST(dstFile, astFile, dstDecl.Body, i.unflushedWriters(astDecl.Body, i.writableFiles(defers, writable)))
+		if i.rewriteDefersInDST(dstDecl.Body, defers, writable, astFile, dstFile, errName) {
+			applied = true
+		}
+		if i.insertFlushesDST(dstDecl.Body, anchors, errName) {
 			applied = true
 		}
 	}
@@ -105,7 +204,10 @@ func (i *Injector) RewriteDefers(dstFile *dst.File, astFile *ast.File) (bool, er
 		}
 
 		if hasAnonymousReturnsDST(dstLit.Type) {
-			continue
+			if !anyWritable(defers, writable) || !i.nameResultsDST(astLit.Type, astLit.Body, dstLit.Type) {
+				continue
+			}
+			applied = true
 		}
 
 		changed, err := refactor.EnsureNamedReturnsDST(&dst.FuncDecl{Type: dstLit.Type})
@@ -121,7 +223,11 @@ func (i *Injector) RewriteDefers(dstFile *dst.File, astFile *ast.File) (bool, er
 			continue
 		}
 
-		if i.rewriteDefersInDST(dstLit.Body, defers, astFile, dstFile, errName) {
+		anchors := i.flushAnchorsDST(dstFile, astFile, dstLit.Body, i.unflushedWriters(astLit.Body, i.writableFiles(defers, writable)))
+		if i.rewriteDefersInDST(dstLit.Body, defers, writable, astFile, dstFile, errName) {
+			applied = true
+		}
+		if i.insertFlushesDST(dstLit.Body, anchors, errName) {
 			applied = true
 		}
 	}
@@ -129,7 +235,7 @@ func (i *Injector) RewriteDefers(dstFile *dst.File, astFile *ast.File) (bool, er
 	return applied, nil
 }
 
-func (i *Injector) rewriteDefersInDST(body *dst.BlockStmt, astDefers []*ast.DeferStmt, astFile *ast.File, dstFile *dst.File, errName string) bool {
+func (i *Injector) rewriteDefersInDST(body *dst.BlockStmt, astDefers []*ast.DeferStmt, writable map[*ast.DeferStmt]bool, astFile *ast.File, dstFile *dst.File, errName string) bool {
 	changed := false
 	for _, astDefer := range astDefers {
 		res, err := FindDstNode(i.Fset, dstFile, astFile, astDefer)
@@ -142,6 +248,9 @@ func (i *Injector) rewriteDefersInDST(body *dst.BlockStmt, astDefers []*ast.Defe
 		}
 
 		newDefer := i.generateDeferRewriteDST(dstDefer.Call, errName)
+		if writable[astDefer] && i.syncsOnClose(astDefer.Call) {
+			syncBeforeCloseDST(newDefer)
+		}
 
 		if replaceDstStmt(body, dstDefer, newDefer) {
 			changed = true
@@ -150,6 +259,35 @@ func (i *Injector) rewriteDefersInDST(body *dst.BlockStmt, astDefers []*ast.Defe
 	return changed
 }
 
+// anyWritable reports whether one of defers releases a writable resource.
+func anyWritable(defers []*ast.DeferStmt, writable map[*ast.DeferStmt]bool) bool {
+	for _, d := range defers {
+		if writable[d] {
+			return true
+		}
+	}
+	return false
+}
+
+// writableFiles returns the variables released by the deferred calls of writable resources among
+// defers (e.g. f in "defer f.Close()"), with their deferred call.
+func (i *Injector) writableFiles(defers []*ast.DeferStmt, writable map[*ast.DeferStmt]bool) map[types.Object]*ast.DeferStmt {
+	files := make(map[types.Object]*ast.DeferStmt)
+	for _, d := range defers {
+		if !writable[d] {
+			continue
+		}
+		if sel, ok := d.Call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Close" {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				if obj := i.Pkg.TypesInfo.ObjectOf(id); obj != nil {
+					files[obj] = d
+				}
+			}
+		}
+	}
+	return files
+}
+
 func hasAnonymousReturnsDST(ft *dst.FuncType) bool {
 	if ft.Results == nil {
 		return false
@@ -224,6 +362,50 @@ func (i *Injector) generateDeferRewriteDST(originalCall *dst.CallExpr, errName s
 	}
 }
 
+// generateDeferLogDST wraps the deferred call in a closure that logs its error:
+//
+//	defer func() {
+//		if err := f.Close(); err != nil {
+//			log.Printf("ignored error in Close: %v", err)
+//		}
+//	}()
+//
+// The log statement follows LogHandler (see generateLogStmtDST).
+func (i *Injector) generateDeferLogDST(originalCall *dst.CallExpr, point analysis.InjectionPoint) (*dst.DeferStmt, error) {
+	callClone := dst.Clone(originalCall).(*dst.CallExpr)
+	astgen.ClearDecorations(callClone)
+
+	logStmt, err := i.generateLogStmtDST(point, "err")
+	if err != nil {
+		return nil, err
+	}
+
+	check := &dst.IfStmt{
+		Init: &dst.AssignStmt{
+			Lhs: []dst.Expr{dst.NewIdent("err")},
+			Tok: token.DEFINE,
+			Rhs: []dst.Expr{callClone},
+		},
+		Cond: &dst.BinaryExpr{
+			X:  dst.NewIdent("err"),
+			Op: token.NEQ,
+			Y:  dst.NewIdent("nil"),
+		},
+		Body: &dst.BlockStmt{
+			List: []dst.Stmt{logStmt},
+		},
+	}
+
+	return &dst.DeferStmt{
+		Call: &dst.CallExpr{
+			Fun: &dst.FuncLit{
+				Type: &dst.FuncType{Params: &dst.FieldList{}},
+				Body: &dst.BlockStmt{List: []dst.Stmt{check}},
+			},
+		},
+	}, nil
+}
+
 // Reuse helper check
 func (i *Injector) isErrorReturningCall(call *ast.CallExpr) bool {
 	if i.Pkg.TypesInfo == nil {
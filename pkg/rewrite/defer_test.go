@@ -1,6 +1,7 @@
 package rewrite
 
 import (
+	"go/ast"
 	"strings"
 	"testing"
 )
@@ -97,3 +98,50 @@ func TestRewriteDefers_NilInputs(t *testing.T) {
 		t.Error("Expected error for nil")
 	}
 }
+
+// TestRewriteDefer_Single verifies that only the requested defer statement is rewritten.
+func TestRewriteDefer_Single(t *testing.T) {
+	src := `package main
+
+func Close() error { return nil }
+
+func First() (err error) {
+	defer Close()
+	return nil
+}
+
+func Second() (err error) {
+	defer Close()
+	return nil
+}
+`
+	injector, astFile, dstFile := setupDstEnv(t, src, false)
+
+	var target *ast.DeferStmt
+	ast.Inspect(astFile, func(n ast.Node) bool {
+		if d, ok := n.(*ast.DeferStmt); ok && target == nil {
+			target = d
+		}
+		return true
+	})
+
+	changed, err := injector.RewriteDefer(dstFile, astFile, target)
+	if err != nil {
+		t.Fatalf("RewriteDefer failed: %v", err)
+	}
+	if !changed {
+		t.Fatal("Expected changes")
+	}
+
+	norm := normalizeStr(renderDstFile(t, dstFile))
+	if strings.Count(norm, "errors.Join") != 1 {
+		t.Errorf("Expected exactly one rewritten defer. Got:\n%s", norm)
+	}
+	if !strings.Contains(norm, "func Second() (err error) { defer Close()") {
+		t.Errorf("Second defer should be untouched. Got:\n%s", norm)
+	}
+
+	if _, err := injector.RewriteDefer(dstFile, astFile, nil); err == nil {
+		t.Error("Expected error for nil defer statement")
+	}
+}
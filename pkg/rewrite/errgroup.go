@@ -0,0 +1,929 @@
+package rewrite
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/astgen"
+	"github.com/dave/dst"
+	"github.com/dave/dst/dstutil"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// Strategies for go statements whose call returns an error (see Injector.GoStrategy).
+const (
+	// GoStrategyHandler wraps each go statement in a closure that calls the terminal handler.
+	GoStrategyHandler = "handler"
+	// GoStrategyErrgroup runs the go statements of a function in an errgroup.Group and checks
+	// the error returned by Wait.
+	GoStrategyErrgroup = "errgroup"
+)
+
+// ErrgroupPath is the import path of the errgroup package used by GoStrategyErrgroup.
+const ErrgroupPath = "golang.org/x/sync/errgroup"
+
+// goGroup is a set of go statements of one function, rewritten to share an errgroup.Group.
+type goGroup struct {
+	// fn is the enclosing *ast.FuncDecl or *ast.FuncLit.
+	fn     ast.Node
+	points []analysis.InjectionPoint
+	// stmts are the go statements of the points (see GroupGoStmt).
+	stmts []*ast.GoStmt
+}
+
+// rewriteGoGroups applies GoStrategyErrgroup to the go statement points and returns the
+// remaining points. Groups that cannot be rewritten are returned as well, so that they fall
+// back to the handler closure.
+func (i *Injector) rewriteGoGroups(dstFile *dst.File, astFile *ast.File, points []analysis.InjectionPoint) ([]analysis.InjectionPoint, bool, error) {
+	var rest []analysis.InjectionPoint
+	var groups []*goGroup
+	byFunc := make(map[ast.Node]*goGroup)
+	seen := make(map[ast.Stmt]bool)
+
+	for _, p := range points {
+		gs := i.GroupGoStmt(p)
+		if gs == nil || i.RuleFor(p).Action == ActionLog {
+			rest = append(rest, p)
+			continue
+		}
+		if seen[p.Stmt] {
+			continue
+		}
+		seen[p.Stmt] = true
+		fn := enclosingFuncNode(astFile, gs)
+		if fn == nil {
+			rest = append(rest, p)
+			continue
+		}
+		g, ok := byFunc[fn]
+		if !ok {
+			g = &goGroup{fn: fn}
+			byFunc[fn] = g
+			groups = append(groups, g)
+		}
+		g.points = append(g.points, p)
+		g.stmts = append(g.stmts, gs)
+	}
+
+	applied := false
+	for _, g := range groups {
+		ok, err := i.rewriteGoGroup(dstFile, astFile, g)
+		if err != nil {
+			return nil, applied, err
+		}
+		if !ok {
+			rest = append(rest, g.points...)
+			continue
+		}
+		applied = true
+	}
+	return rest, applied, nil
+}
+
+// rewriteGoGroup converts the go statements of g into calls of g.Go on a shared errgroup.Group:
+//
+//	var g errgroup.Group
+//	g.Go(func() error { return work(x) })
+//	g.Go(other)
+//	if err := g.Wait(); err != nil {
+//		return err
+//	}
+//
+// A literal signalling a sync.WaitGroup (see GroupGoStmt) returns the errors of its points:
+//
+//	g.Go(func() error {
+//		defer wg.Done()
+//		return work()
+//	})
+//
+// The group is declared in the innermost block containing every go statement (outside of loops),
+// right before the first of them. The check follows a sync.WaitGroup Wait call in that block if
+// there is one, and is otherwise placed at the end of the block, before a final return, panic
+// or branch statement. It returns the error via the error template if the function returns an
+// error, and calls the terminal handler otherwise. A WaitGroup only signalled by the literals of
+// the group is removed, the check taking the place of its Wait call (see redundantWaitGroup).
+//
+// Returns false if no suitable place for the check exists.
+func (i *Injector) rewriteGoGroup(dstFile *dst.File, astFile *ast.File, g *goGroup) (bool, error) {
+	var body *ast.BlockStmt
+	var sig *types.Signature
+	switch fn := g.fn.(type) {
+	case *ast.FuncDecl:
+		body = fn.Body
+		if obj := i.Pkg.TypesInfo.ObjectOf(fn.Name); obj != nil {
+			sig, _ = obj.Type().(*types.Signature)
+		}
+	case *ast.FuncLit:
+		body = fn.Body
+		sig, _ = i.Pkg.TypesInfo.TypeOf(fn).(*types.Signature)
+	}
+	if body == nil {
+		return false, nil
+	}
+
+	// 1. Find the innermost block containing all go statements.
+	var common []*ast.BlockStmt
+	for k, gs := range g.stmts {
+		chain := enclosingBlocks(astFile, body, gs)
+		if k == 0 {
+			common = chain
+			continue
+		}
+		n := 0
+		for n < len(common) && n < len(chain) && common[n] == chain[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	if len(common) == 0 {
+		return false, nil
+	}
+	// Waiting inside a loop body would serialize the iterations.
+	for len(common) > 1 && isLoopBody(astFile, common[len(common)-1]) {
+		common = common[:len(common)-1]
+	}
+	block := common[len(common)-1]
+
+	// 2. Locate the statements of the block around which the group is inserted.
+	first, last := -1, -1
+	for _, gs := range g.stmts {
+		idx := stmtIndexContaining(block, gs)
+		if idx < 0 {
+			return false, nil
+		}
+		if first < 0 || idx < first {
+			first = idx
+		}
+		if idx > last {
+			last = idx
+		}
+	}
+	check := len(block.List)
+	wait := i.waitGroupWaitAfter(block, last)
+	if wait >= 0 {
+		check = wait + 1
+	} else if n := len(block.List); n-1 > last && isExitStmt(block.List[n-1]) {
+		check = n - 1
+	} else if block == body && sig != nil && sig.Results().Len() > 0 {
+		// The function body must keep ending in its terminating statement.
+		if n-1 <= last {
+			return false, nil
+		}
+		check = n - 1
+	}
+
+	// 3. Map every node before mutating the tree.
+	res, err := FindDstNode(i.Fset, dstFile, astFile, block)
+	if err != nil {
+		return false, nil
+	}
+	dstBlock, ok := res.Node.(*dst.BlockStmt)
+	if !ok {
+		return false, nil
+	}
+	res, err = FindDstNode(i.Fset, dstFile, astFile, block.List[first])
+	if err != nil || res.Parent != dstBlock {
+		return false, nil
+	}
+	dstFirst := res.Node
+	// The check is anchored after the preceding statement, since the following one may have been
+	// added by a signature change (e.g. a trailing "return nil") and has no DST counterpart.
+	res, err = FindDstNode(i.Fset, dstFile, astFile, block.List[check-1])
+	if err != nil || res.Parent != dstBlock {
+		return false, nil
+	}
+	dstCheckAfter := res.Node
+	// goStmts holds the go statements starting the call of their point, litStmts those running a
+	// literal, with the statements of the points in it.
+	goStmts := make(map[dst.Node]analysis.InjectionPoint)
+	litStmts := make(map[dst.Node]map[dst.Stmt]analysis.InjectionPoint)
+	for k, p := range g.points {
+		res, err := FindDstNode(i.Fset, dstFile, astFile, g.stmts[k])
+		if err != nil {
+			return false, nil
+		}
+		if g.stmts[k] == p.Stmt {
+			goStmts[res.Node] = p
+			continue
+		}
+		inner, err := FindDstNode(i.Fset, dstFile, astFile, p.Stmt)
+		if err != nil {
+			return false, nil
+		}
+		dstInner, ok := inner.Node.(dst.Stmt)
+		if !ok {
+			return false, nil
+		}
+		if litStmts[res.Node] == nil {
+			litStmts[res.Node] = make(map[dst.Stmt]analysis.InjectionPoint)
+		}
+		litStmts[res.Node][dstInner] = p
+	}
+	// removed holds the statements of a sync.WaitGroup made redundant by the group.
+	removed := make(map[dst.Node]bool)
+	for _, s := range i.redundantWaitGroup(body, block, wait, g) {
+		res, err := FindDstNode(i.Fset, dstFile, astFile, s)
+		if _, ok := res.Parent.(*dst.BlockStmt); err != nil || !ok {
+			removed = nil
+			break
+		}
+		removed[res.Node] = true
+	}
+
+	// 4. Build the group declaration and the check.
+	dstFn, err := FindDstNode(i.Fset, dstFile, astFile, g.fn)
+	if err != nil {
+		return false, nil
+	}
+	groupName := i.uniqueGroupName(astFile, block, g.stmts, dstFn.Node)
+
+	decl := &dst.DeclStmt{Decl: &dst.GenDecl{
+		Tok: token.VAR,
+		Specs: []dst.Spec{&dst.ValueSpec{
+			Names: []*dst.Ident{dst.NewIdent(groupName)},
+			Type:  &dst.SelectorExpr{X: dst.NewIdent("errgroup"), Sel: dst.NewIdent("Group")},
+		}},
+	}}
+	waitCheck, err := i.generateWaitCheckDST(g.points[0], groupName, sig)
+	if err != nil {
+		return false, err
+	}
+
+	// 5. Convert the go statements.
+	var convErr error
+	dstutil.Apply(dstBlock, func(c *dstutil.Cursor) bool {
+		if convErr != nil {
+			return false
+		}
+		var stmts []dst.Stmt
+		var err error
+		goStmt, _ := c.Node().(*dst.GoStmt)
+		if p, ok := goStmts[c.Node()]; ok {
+			var stmt dst.Stmt
+			stmt, err = i.generateGroupGoDST(p, goStmt, groupName)
+			stmts = []dst.Stmt{stmt}
+		} else if inner, ok := litStmts[c.Node()]; ok {
+			stmts, err = i.generateGroupLitDST(dstFile, goStmt, inner, groupName)
+		} else {
+			return true
+		}
+		if err != nil {
+			convErr = err
+			return false
+		}
+		i.transferTrivia(goStmt, stmts)
+		c.Replace(stmts[len(stmts)-1])
+		for _, s := range stmts[:len(stmts)-1] {
+			c.InsertBefore(s)
+		}
+		if goStmt == dstFirst {
+			dstFirst = stmts[0]
+		}
+		if goStmt == dstCheckAfter {
+			dstCheckAfter = stmts[len(stmts)-1]
+		}
+		return false
+	}, nil)
+	if convErr != nil {
+		return false, convErr
+	}
+
+	// 6. Insert the declaration and the check.
+	list := make([]dst.Stmt, 0, len(dstBlock.List)+2)
+	for _, s := range dstBlock.List {
+		if s == dstFirst {
+			// Leading comments and spacing now introduce the group.
+			decl.Decs.Before, decl.Decs.Start = s.Decorations().Before, s.Decorations().Start
+			s.Decorations().Before, s.Decorations().Start = dst.NewLine, nil
+			list = append(list, decl)
+		}
+		list = append(list, s)
+		if s == dstCheckAfter {
+			list = append(list, waitCheck)
+		}
+	}
+	dstBlock.List = list
+	if len(removed) > 0 {
+		dst.Inspect(dstFn.Node, func(n dst.Node) bool {
+			if b, ok := n.(*dst.BlockStmt); ok {
+				b.List = removeStmts(b.List, removed)
+			}
+			return true
+		})
+	}
+
+	i.addImportDST(dstFile, ErrgroupPath)
+	return true, nil
+}
+
+// generateGroupGoDST converts "go f(args)" into "group.Go(f)" (for "func() error" values) or
+// "group.Go(func() error { return f(args) })".
+func (i *Injector) generateGroupGoDST(point analysis.InjectionPoint, goStmt *dst.GoStmt, groupName string) (dst.Stmt, error) {
+	call := dst.Clone(goStmt.Call).(*dst.CallExpr)
+	astgen.ClearDecorations(call)
+
+	var arg dst.Expr
+	returnsOnlyErr := i.isErrorType(i.Pkg.TypesInfo.TypeOf(point.Call))
+	switch fun := call.Fun.(type) {
+	case *dst.Ident, *dst.SelectorExpr:
+		if returnsOnlyErr && len(call.Args) == 0 {
+			arg = fun
+		}
+	}
+	if arg == nil {
+		var body []dst.Stmt
+		if returnsOnlyErr {
+			body = []dst.Stmt{&dst.ReturnStmt{Results: []dst.Expr{call}}}
+		} else {
+			assign, err := i.generateAssignmentDST(point, call, "err", token.DEFINE)
+			if err != nil {
+				return nil, err
+			}
+			body = []dst.Stmt{assign, &dst.ReturnStmt{Results: []dst.Expr{dst.NewIdent("err")}}}
+		}
+		arg = &dst.FuncLit{
+			Type: &dst.FuncType{
+				Params:  &dst.FieldList{},
+				Results: &dst.FieldList{List: []*dst.Field{{Type: dst.NewIdent("error")}}},
+			},
+			Body: &dst.BlockStmt{List: body},
+		}
+	}
+
+	return &dst.ExprStmt{X: &dst.CallExpr{
+		Fun:  &dst.SelectorExpr{X: dst.NewIdent(groupName), Sel: dst.NewIdent("Go")},
+		Args: []dst.Expr{arg},
+	}}, nil
+}
+
+// generateGroupLitDST converts "go func() { ... }()" into "group.Go(func() error { ... })". The
+// literal returns the errors of the statements of points, and nil at its end; the call of a
+// final statement returning nothing but an error is returned directly. The parameters of the
+// literal are declared before the call, holding the arguments of goStmt (see rebindable).
+func (i *Injector) generateGroupLitDST(dstFile *dst.File, goStmt *dst.GoStmt, points map[dst.Stmt]analysis.InjectionPoint, groupName string) ([]dst.Stmt, error) {
+	lit, ok := goStmt.Call.Fun.(*dst.FuncLit)
+	if !ok || len(lit.Body.List) == 0 {
+		return nil, fmt.Errorf("go statement does not run a function literal")
+	}
+	last := lit.Body.List[len(lit.Body.List)-1]
+
+	var genErr error
+	dstutil.Apply(lit.Body, func(c *dstutil.Cursor) bool {
+		stmt, ok := c.Node().(dst.Stmt)
+		if !ok || genErr != nil {
+			return genErr == nil
+		}
+		p, ok := points[stmt]
+		if !ok {
+			return true
+		}
+		var stmts []dst.Stmt
+		if call := i.extractDstCall(stmt); stmt == last && call != nil && p.Assign == nil && i.isErrorType(i.Pkg.TypesInfo.TypeOf(p.Call)) {
+			call = dst.Clone(call).(*dst.CallExpr)
+			astgen.ClearDecorations(call)
+			stmts = []dst.Stmt{&dst.ReturnStmt{Results: []dst.Expr{call}}}
+		} else {
+			stmts, genErr = i.generateInPlaceDST(dstFile, p, stmt, func(errName string) ([]dst.Stmt, error) {
+				return []dst.Stmt{&dst.ReturnStmt{Results: []dst.Expr{dst.NewIdent(errName)}}}, nil
+			})
+			if genErr != nil {
+				return false
+			}
+		}
+		if c.Index() < 0 {
+			stmts = collapseInit(stmts)
+		}
+		i.transferTrivia(stmt, stmts)
+		c.Replace(stmts[0])
+		for k := len(stmts) - 1; k > 0; k-- {
+			c.InsertAfter(stmts[k])
+		}
+		return false
+	}, nil)
+	if genErr != nil {
+		return nil, genErr
+	}
+
+	if _, ok := lit.Body.List[len(lit.Body.List)-1].(*dst.ReturnStmt); !ok {
+		lit.Body.List = append(lit.Body.List, &dst.ReturnStmt{Results: []dst.Expr{dst.NewIdent("nil")}})
+	}
+	lit.Type.Results = &dst.FieldList{List: []*dst.Field{{Type: dst.NewIdent("error")}}}
+	lit.Decorations().Before, lit.Decorations().After = dst.None, dst.None
+
+	var stmts []dst.Stmt
+	if len(goStmt.Call.Args) > 0 {
+		rebind := &dst.AssignStmt{Tok: token.DEFINE}
+		for _, field := range lit.Type.Params.List {
+			for _, name := range field.Names {
+				rebind.Lhs = append(rebind.Lhs, dst.NewIdent(name.Name))
+			}
+		}
+		for _, arg := range goStmt.Call.Args {
+			arg = dst.Clone(arg).(dst.Expr)
+			astgen.ClearDecorations(arg)
+			rebind.Rhs = append(rebind.Rhs, arg)
+		}
+		lit.Type.Params = &dst.FieldList{}
+		stmts = append(stmts, rebind)
+	}
+	return append(stmts, &dst.ExprStmt{X: &dst.CallExpr{
+		Fun:  &dst.SelectorExpr{X: dst.NewIdent(groupName), Sel: dst.NewIdent("Go")},
+		Args: []dst.Expr{lit},
+	}}), nil
+}
+
+// generateWaitCheckDST creates "if err := group.Wait(); err != nil { ... }".
+// The error is returned if sig returns an error, and passed to the terminal handler otherwise.
+func (i *Injector) generateWaitCheckDST(point analysis.InjectionPoint, groupName string, sig *types.Signature) (dst.Stmt, error) {
+	var body *dst.BlockStmt
+	if sig != nil && sig.Results().Len() > 0 && i.isErrorType(sig.Results().At(sig.Results().Len()-1).Type()) {
+		zeroExprs, zeroDecls, err := i.zeroResultsDST(sig, i.getScope(point.Pos, point.File))
+		if err != nil {
+			return nil, err
+		}
+		vars := i.templateVars(point)
+		vars.FuncName, vars.Callee, vars.Args = "Wait", "Group.Wait", ""
+		retExprs, _, err := RenderTemplateVarsDST(i.RuleFor(point).Template, zeroExprs, "err", vars)
+		if err != nil {
+			return nil, err
+		}
+		body = &dst.BlockStmt{List: append(zeroDecls, &dst.ReturnStmt{Results: retExprs})}
+	} else {
+		var err error
+		if body, err = i.generateTerminalHandlerDST(point, "Wait", "err"); err != nil {
+			return nil, err
+		}
+	}
+
+	return &dst.IfStmt{
+		Init: &dst.AssignStmt{
+			Lhs: []dst.Expr{dst.NewIdent("err")},
+			Tok: token.DEFINE,
+			Rhs: []dst.Expr{&dst.CallExpr{
+				Fun: &dst.SelectorExpr{X: dst.NewIdent(groupName), Sel: dst.NewIdent("Wait")},
+			}},
+		},
+		Cond: &dst.BinaryExpr{X: dst.NewIdent("err"), Op: token.NEQ, Y: dst.NewIdent("nil")},
+		Body: body,
+	}, nil
+}
+
+// uniqueGroupName picks a name for the errgroup.Group that is not visible at the go statements
+// and not used anywhere in the function (including declarations added by earlier rewrites).
+func (i *Injector) uniqueGroupName(astFile *ast.File, block *ast.BlockStmt, stmts []*ast.GoStmt, dstFn dst.Node) string {
+	used := make(map[string]bool)
+	dst.Inspect(dstFn, func(n dst.Node) bool {
+		if id, ok := n.(*dst.Ident); ok {
+			used[id.Name] = true
+		}
+		return true
+	})
+
+	scopes := []*types.Scope{i.getScope(block.Lbrace, astFile)}
+	for _, gs := range stmts {
+		scopes = append(scopes, i.getScope(gs.Pos(), astFile))
+	}
+	visible := func(name string) bool {
+		for _, s := range scopes {
+			if s == nil {
+				continue
+			}
+			if _, obj := s.LookupParent(name, token.NoPos); obj != nil {
+				return true
+			}
+		}
+		return false
+	}
+
+	name := "g"
+	for k := 1; used[name] || visible(name); k++ {
+		name = fmt.Sprintf("g%d", k)
+	}
+	return name
+}
+
+// redundantWaitGroup returns the statements of the sync.WaitGroup signalled by the literals of g
+// which the group replaces: its declaration, its Add calls, the deferred Done calls of the
+// literals and its Wait call block.List[wait]. Returns nil if the WaitGroup is not declared in
+// body or is used otherwise, e.g. signalled by another goroutine or passed to a function.
+//
+// body: The body of the function of g.
+// block: The block holding the go statements of g.
+// wait: The index of the Wait call in block, or -1.
+// g: The group.
+func (i *Injector) redundantWaitGroup(body, block *ast.BlockStmt, wait int, g *goGroup) []ast.Stmt {
+	if wait < 0 {
+		return nil
+	}
+	var wg types.Object
+	dones := make(map[ast.Stmt]bool)
+	for _, gs := range g.stmts {
+		lit, ok := gs.Call.Fun.(*ast.FuncLit)
+		if !ok || len(lit.Body.List) == 0 {
+			continue
+		}
+		done, ok := lit.Body.List[0].(*ast.DeferStmt)
+		if !ok || !i.isWaitGroupCall(done.Call, "Done") {
+			continue
+		}
+		id, ok := done.Call.Fun.(*ast.SelectorExpr).X.(*ast.Ident)
+		if !ok || (wg != nil && i.Pkg.TypesInfo.Uses[id] != wg) {
+			return nil
+		}
+		wg = i.Pkg.TypesInfo.Uses[id]
+		dones[done] = true
+	}
+	if wg == nil {
+		return nil
+	}
+
+	var stmts []ast.Stmt
+	declared, other := false, false
+	var stack []ast.Node
+	ast.Inspect(body, func(n ast.Node) bool {
+		if other {
+			return false
+		}
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		stack = append(stack, n)
+		id, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		if i.Pkg.TypesInfo.Defs[id] == wg {
+			if decl := waitGroupDecl(stack); decl != nil {
+				stmts, declared = append(stmts, decl), true
+			} else {
+				other = true
+			}
+			return true
+		}
+		if i.Pkg.TypesInfo.Uses[id] != wg {
+			return true
+		}
+		// stack ends with the statement, the call, the selector and the identifier.
+		if len(stack) < 4 {
+			other = true
+			return true
+		}
+		stmt := stack[len(stack)-4]
+		call, ok := stack[len(stack)-3].(*ast.CallExpr)
+		sel, _ := stack[len(stack)-2].(*ast.SelectorExpr)
+		if !ok || sel == nil || call.Fun != sel || sel.X != id {
+			other = true
+			return true
+		}
+		switch s := stmt.(type) {
+		case *ast.DeferStmt:
+			other = !dones[s] || s.Call != call
+		case *ast.ExprStmt:
+			switch {
+			case s.X != call:
+				other = true
+			case sel.Sel.Name == "Wait":
+				other = s != block.List[wait]
+			case sel.Sel.Name == "Add":
+				other = !i.pureArgs(call)
+			default:
+				other = true
+			}
+		default:
+			other = true
+		}
+		if !other {
+			stmts = append(stmts, stmt.(ast.Stmt))
+		}
+		return true
+	})
+	if other || !declared {
+		return nil
+	}
+	return stmts
+}
+
+// waitGroupDecl returns the statement declaring the identifier at the end of stack, if it declares
+// nothing else: "var wg sync.WaitGroup", "wg := sync.WaitGroup{}" or "wg := &sync.WaitGroup{}".
+func waitGroupDecl(stack []ast.Node) ast.Stmt {
+	if len(stack) < 2 {
+		return nil
+	}
+	id := stack[len(stack)-1]
+	switch parent := stack[len(stack)-2].(type) {
+	case *ast.ValueSpec:
+		if len(stack) < 4 || len(parent.Names) != 1 || len(parent.Values) != 0 {
+			return nil
+		}
+		decl, ok := stack[len(stack)-3].(*ast.GenDecl)
+		if !ok || len(decl.Specs) != 1 {
+			return nil
+		}
+		if s, ok := stack[len(stack)-4].(*ast.DeclStmt); ok {
+			return s
+		}
+	case *ast.AssignStmt:
+		if len(parent.Lhs) != 1 || len(parent.Rhs) != 1 || parent.Lhs[0] != id {
+			return nil
+		}
+		rhs := parent.Rhs[0]
+		if u, ok := rhs.(*ast.UnaryExpr); ok && u.Op == token.AND {
+			rhs = u.X
+		}
+		if lit, ok := rhs.(*ast.CompositeLit); ok && len(lit.Elts) == 0 {
+			return parent
+		}
+	}
+	return nil
+}
+
+// pureArgs reports whether the arguments of call can be dropped: they call nothing but builtin
+// functions such as len.
+func (i *Injector) pureArgs(call *ast.CallExpr) bool {
+	pure := true
+	for _, arg := range call.Args {
+		ast.Inspect(arg, func(n ast.Node) bool {
+			if c, ok := n.(*ast.CallExpr); ok {
+				if tv, ok := i.Pkg.TypesInfo.Types[c.Fun]; !ok || !tv.IsBuiltin() {
+					pure = false
+				}
+			}
+			return pure
+		})
+	}
+	return pure
+}
+
+// removeStmts returns list without the statements of removed. The spacing and leading comments
+// of a removed statement are kept for the next one; a block does not start with an empty line.
+func removeStmts(list []dst.Stmt, removed map[dst.Node]bool) []dst.Stmt {
+	kept := list[:0]
+	spaced := false
+	var comments dst.Decorations
+	for _, s := range list {
+		decs := s.Decorations()
+		if removed[s] {
+			spaced = spaced || decs.Before == dst.EmptyLine
+			comments = append(comments, decs.Start...)
+			continue
+		}
+		if spaced {
+			decs.Before = dst.EmptyLine
+		}
+		if len(kept) == 0 && decs.Before == dst.EmptyLine {
+			decs.Before = dst.NewLine
+		}
+		decs.Start = append(comments, decs.Start...)
+		spaced, comments = false, nil
+		kept = append(kept, s)
+	}
+	return kept
+}
+
+// waitGroupWaitAfter returns the index of the first "wg.Wait()" (on a sync.WaitGroup) in block
+// after index after, or -1.
+func (i *Injector) waitGroupWaitAfter(block *ast.BlockStmt, after int) int {
+	for idx := after + 1; idx < len(block.List); idx++ {
+		es, ok := block.List[idx].(*ast.ExprStmt)
+		if !ok {
+			continue
+		}
+		if i.isWaitGroupCall(es.X, "Wait") {
+			return idx
+		}
+	}
+	return -1
+}
+
+// isWaitGroupCall reports whether expr calls method without arguments on a sync.WaitGroup.
+func (i *Injector) isWaitGroupCall(expr ast.Expr, method string) bool {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok || len(call.Args) != 0 {
+		return false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != method {
+		return false
+	}
+	t := i.Pkg.TypesInfo.TypeOf(sel.X)
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "sync" && named.Obj().Name() == "WaitGroup"
+}
+
+// GroupGoStmt returns the go statement converted to a call of errgroup.Group.Go for point with
+// GoStrategyErrgroup: the statement of point if it starts the call, or the statement running a
+// literal without results and return statements that signals a sync.WaitGroup:
+//
+//	go func(p string) {
+//		defer wg.Done()
+//		work(p)
+//	}(p)
+//
+// The parameters of the literal must be rebindable (see rebindable). Returns nil for other points.
+//
+// point: The injection point.
+func (i *Injector) GroupGoStmt(point analysis.InjectionPoint) *ast.GoStmt {
+	if gs, ok := point.Stmt.(*ast.GoStmt); ok {
+		if gs.Call == point.Call {
+			return gs
+		}
+		return nil
+	}
+	if !inPlaceCandidate(point) || point.ErrIdent != nil || point.File == nil || i.Pkg.TypesInfo == nil {
+		return nil
+	}
+	lit, ok := enclosingFuncNode(point.File, point.Stmt).(*ast.FuncLit)
+	if !ok || lit.Type.Results != nil || len(lit.Body.List) == 0 {
+		return nil
+	}
+	if done, ok := lit.Body.List[0].(*ast.DeferStmt); !ok || !i.isWaitGroupCall(done.Call, "Done") {
+		return nil
+	}
+	returns := false
+	ast.Inspect(lit.Body, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt:
+			returns = true
+		}
+		return !returns
+	})
+	if returns {
+		return nil
+	}
+	path, _ := astutil.PathEnclosingInterval(point.File, lit.Pos(), lit.End())
+	if len(path) < 3 || path[0] != lit {
+		return nil
+	}
+	call, ok := path[1].(*ast.CallExpr)
+	if !ok || call.Fun != lit {
+		return nil
+	}
+	if gs, ok := path[2].(*ast.GoStmt); ok && gs.Call == call && i.rebindable(point.File, gs, lit) {
+		return gs
+	}
+	return nil
+}
+
+// rebindable reports whether the parameters of lit, run by gs, can be declared right before the
+// group runs the literal, holding the arguments of gs for the literal to capture:
+//
+//	go func(p string) { ... }(x)  ->  p := x
+//	                                  g.Go(func() error { ... })
+//
+// Every parameter must be named and match one argument, and the names must not be declared in
+// the block of gs nor used by the statements following it, which the declaration would shadow.
+func (i *Injector) rebindable(file *ast.File, gs *ast.GoStmt, lit *ast.FuncLit) bool {
+	var names []*ast.Ident
+	for _, field := range lit.Type.Params.List {
+		if _, ok := field.Type.(*ast.Ellipsis); ok || len(field.Names) == 0 {
+			return false
+		}
+		names = append(names, field.Names...)
+	}
+	if len(names) == 0 {
+		return true
+	}
+	if len(names) != len(gs.Call.Args) || gs.Call.Ellipsis.IsValid() {
+		return false
+	}
+	following, ok := stmtsAfter(file, gs)
+	if !ok {
+		return false
+	}
+	scope := i.getScope(gs.Pos(), file)
+	for _, name := range names {
+		if name.Name == "_" || scope == nil || scope.Lookup(name.Name) != nil {
+			return false
+		}
+		for _, s := range following {
+			used := false
+			ast.Inspect(s, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok && id.Name == name.Name {
+					used = true
+				}
+				return !used
+			})
+			if used {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// stmtsAfter returns the statements following stmt in its block or clause, and false if stmt is
+// not part of a statement list.
+func stmtsAfter(file *ast.File, stmt ast.Stmt) ([]ast.Stmt, bool) {
+	path, _ := astutil.PathEnclosingInterval(file, stmt.Pos(), stmt.End())
+	for k, n := range path {
+		if n != stmt || k+1 >= len(path) {
+			continue
+		}
+		var list []ast.Stmt
+		switch parent := path[k+1].(type) {
+		case *ast.BlockStmt:
+			list = parent.List
+		case *ast.CaseClause:
+			list = parent.Body
+		case *ast.CommClause:
+			list = parent.Body
+		default:
+			return nil, false
+		}
+		for idx, s := range list {
+			if s == stmt {
+				return list[idx+1:], true
+			}
+		}
+		return nil, false
+	}
+	return nil, false
+}
+
+// enclosingFuncNode returns the innermost *ast.FuncDecl or *ast.FuncLit containing stmt.
+func enclosingFuncNode(file *ast.File, stmt ast.Stmt) ast.Node {
+	path, _ := astutil.PathEnclosingInterval(file, stmt.Pos(), stmt.End())
+	for _, n := range path {
+		switch n.(type) {
+		case *ast.FuncDecl, *ast.FuncLit:
+			return n
+		}
+	}
+	return nil
+}
+
+// enclosingBlocks returns the statement blocks from body down to the innermost one containing
+// stmt. The bodies of switch and select statements hold clauses rather than statements and are
+// skipped.
+func enclosingBlocks(file *ast.File, body *ast.BlockStmt, stmt ast.Stmt) []*ast.BlockStmt {
+	path, _ := astutil.PathEnclosingInterval(file, stmt.Pos(), stmt.End())
+	var chain []*ast.BlockStmt
+	for k, n := range path {
+		b, ok := n.(*ast.BlockStmt)
+		if !ok {
+			continue
+		}
+		if k+1 < len(path) {
+			switch path[k+1].(type) {
+			case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+				continue
+			}
+		}
+		chain = append([]*ast.BlockStmt{b}, chain...)
+		if b == body {
+			return chain
+		}
+	}
+	return nil
+}
+
+// isLoopBody reports whether block is the body of a for or range statement.
+func isLoopBody(file *ast.File, block *ast.BlockStmt) bool {
+	path, _ := astutil.PathEnclosingInterval(file, block.Pos(), block.End())
+	for k, n := range path {
+		if n != block || k+1 >= len(path) {
+			continue
+		}
+		switch loop := path[k+1].(type) {
+		case *ast.ForStmt:
+			return loop.Body == block
+		case *ast.RangeStmt:
+			return loop.Body == block
+		}
+	}
+	return false
+}
+
+// stmtIndexContaining returns the index of the statement of block containing stmt, or -1.
+func stmtIndexContaining(block *ast.BlockStmt, stmt ast.Stmt) int {
+	for idx, s := range block.List {
+		if s.Pos() <= stmt.Pos() && stmt.End() <= s.End() {
+			return idx
+		}
+	}
+	return -1
+}
+
+// isExitStmt reports whether stmt leaves the block: a return, branch or panic call.
+func isExitStmt(stmt ast.Stmt) bool {
+	switch s := stmt.(type) {
+	case *ast.ReturnStmt, *ast.BranchStmt:
+		return true
+	case *ast.ExprStmt:
+		if call, ok := s.X.(*ast.CallExpr); ok {
+			if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "panic" {
+				return true
+			}
+		}
+	}
+	return false
+}
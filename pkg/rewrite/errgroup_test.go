@@ -0,0 +1,253 @@
+package rewrite
+
+import (
+	"go/ast"
+	"strings"
+	"testing"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+// goPoints returns the injection points of the go statements in f that do not start a function literal.
+func goPoints(f *ast.File) []analysis.InjectionPoint {
+	var points []analysis.InjectionPoint
+	ast.Inspect(f, func(n ast.Node) bool {
+		if gs, ok := n.(*ast.GoStmt); ok {
+			if _, isLit := gs.Call.Fun.(*ast.FuncLit); isLit {
+				return true
+			}
+			points = append(points, analysis.InjectionPoint{File: f, Stmt: gs, Call: gs.Call, Pos: gs.Call.Pos()})
+		}
+		return true
+	})
+	return points
+}
+
+func TestRewriteFile_GoErrgroup(t *testing.T) {
+	src := `package main
+
+import "sync"
+
+func fetch(id int) error  { return nil }
+func ping() error         { return nil }
+func count() (int, error) { return 0, nil }
+
+func run(ids []int) (int, error) {
+	n := len(ids)
+	for _, id := range ids {
+		go fetch(id)
+	}
+	go ping()
+	go count()
+	return n, nil
+}
+
+func waitGroup(g int) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() { defer wg.Done() }()
+	go ping()
+	wg.Wait()
+	println(g)
+}
+`
+	injector, dstFile, astFile := setupInjectorTest(t, src)
+	injector.GoStrategy = GoStrategyErrgroup
+
+	changed, err := injector.RewriteFile(dstFile, astFile, goPoints(astFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatal("expected changes")
+	}
+	got := render(t, dstFile)
+
+	for _, want := range []string{
+		`"golang.org/x/sync/errgroup"`,
+		// The group is declared outside the loop and waited for before the final return.
+		"n := len(ids)\n\tvar g errgroup.Group\n\tfor _, id := range ids {",
+		"g.Go(func() error {\n\t\t\treturn fetch(id)\n\t\t})",
+		"g.Go(ping)",
+		"_, err := count()\n\t\treturn err",
+		"if err := g.Wait(); err != nil {\n\t\treturn 0, err\n\t}\n\treturn n, nil",
+		// The parameter g is not shadowed, and the check follows wg.Wait().
+		"var g1 errgroup.Group\n\tg1.Go(ping)\n\twg.Wait()\n\tif err := g1.Wait(); err != nil {\n\t\tlog.Fatal(err)\n\t}",
+		// Unrelated goroutines are kept.
+		"go func() { defer wg.Done() }()",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "go fetch") || strings.Contains(got, "go ping") {
+		t.Errorf("go statements not converted:\n%s", got)
+	}
+}
+
+func TestRewriteFile_GoErrgroup_NoPlaceForWait(t *testing.T) {
+	src := `package main
+
+func ping() error { return nil }
+
+func serve() error {
+	for {
+		go ping()
+	}
+}
+`
+	injector, dstFile, astFile := setupInjectorTest(t, src)
+	injector.GoStrategy = GoStrategyErrgroup
+
+	if _, err := injector.RewriteFile(dstFile, astFile, goPoints(astFile)); err != nil {
+		t.Fatal(err)
+	}
+	got := render(t, dstFile)
+
+	// Nothing may follow the terminating loop, so the handler closure is used instead.
+	if strings.Contains(got, "errgroup") || !strings.Contains(got, "go func() {") {
+		t.Errorf("expected the handler fallback:\n%s", got)
+	}
+}
+
+// TestRewriteFile_GoErrgroup_WaitGroupLit verifies that literals signalling a sync.WaitGroup join
+// the group and return the errors of their calls, and that the WaitGroup is removed.
+func TestRewriteFile_GoErrgroup_WaitGroupLit(t *testing.T) {
+	src := `package main
+
+import "sync"
+
+func work(id int) error      { return nil }
+func count(id int) (int, error) { return 0, nil }
+
+func run(ids []int) error {
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, _ := count(id)
+			println(n)
+			work(id)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+`
+	injector, dstFile, astFile := setupInjectorTest(t, src)
+	injector.GoStrategy = GoStrategyErrgroup
+
+	points, err := analysis.Detect([]*packages.Package{injector.Pkg}, nil, false)
+	if err != nil || len(points) != 2 {
+		t.Fatalf("expected 2 points, got %d (%v)", len(points), err)
+	}
+	for _, p := range points {
+		if injector.GroupGoStmt(p) == nil {
+			t.Fatalf("the point at %v is not in a go statement of the group", p.Pos)
+		}
+	}
+	changed, err := injector.RewriteFile(dstFile, astFile, points)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatal("expected changes")
+	}
+	got := render(t, dstFile)
+
+	for _, want := range []string{
+		"func run(ids []int) error {\n\tvar g errgroup.Group\n\tfor _, id := range ids {\n\t\tg.Go(func() error {",
+		"g.Go(func() error {\n\t\t\tn, err := count(id)\n\t\t\tif err != nil {\n\t\t\t\treturn err\n\t\t\t}",
+		"println(n)\n\t\t\treturn work(id)\n\t\t})\n\t}\n\tif err := g.Wait(); err != nil {\n\t\treturn err\n\t}\n\treturn nil",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "go func") {
+		t.Errorf("go statement not converted:\n%s", got)
+	}
+	if strings.Contains(got, "wg") {
+		t.Errorf("WaitGroup not removed:\n%s", got)
+	}
+}
+
+// TestRewriteFile_GoErrgroup_LitParams verifies that the parameters of a literal are declared
+// before the group runs it, holding the arguments of the go statement, and that a WaitGroup also
+// signalled outside of the group is kept.
+func TestRewriteFile_GoErrgroup_LitParams(t *testing.T) {
+	src := `package main
+
+import "sync"
+
+func work(name string, n int) error { return nil }
+
+func run(names []string) error {
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(s string, n int) {
+			defer wg.Done()
+			work(s, n)
+		}(name, i+1)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		println("done")
+	}()
+	wg.Wait()
+	return nil
+}
+
+func conflict(name string) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func(name string) {
+		defer wg.Done()
+		work(name, 0)
+	}(name + "!")
+	wg.Wait()
+}
+`
+	injector, dstFile, astFile := setupInjectorTest(t, src)
+	injector.GoStrategy = GoStrategyErrgroup
+
+	points, err := analysis.Detect([]*packages.Package{injector.Pkg}, nil, false)
+	if err != nil || len(points) != 2 {
+		t.Fatalf("expected 2 points, got %d (%v)", len(points), err)
+	}
+	conflict := astFile.Decls[len(astFile.Decls)-1]
+	var grouped []analysis.InjectionPoint
+	for _, p := range points {
+		// The parameter of the literal in conflict would be redeclared in its body.
+		inConflict := conflict.Pos() <= p.Pos && p.Pos < conflict.End()
+		if gs := injector.GroupGoStmt(p); (gs == nil) != inConflict {
+			t.Fatalf("GroupGoStmt of the point at %v = %v", injector.Fset.Position(p.Pos), gs)
+		}
+		if !inConflict {
+			grouped = append(grouped, p)
+		}
+	}
+	changed, err := injector.RewriteFile(dstFile, astFile, grouped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatal("expected changes")
+	}
+	got := render(t, dstFile)
+
+	for _, want := range []string{
+		"var wg sync.WaitGroup\n\tvar g errgroup.Group",
+		"wg.Add(1)\n\t\ts, n := name, i+1\n\t\tg.Go(func() error {\n\t\t\tdefer wg.Done()\n\t\t\treturn work(s, n)\n\t\t})",
+		"go func() {\n\t\tdefer wg.Done()",
+		"wg.Wait()\n\tif err := g.Wait(); err != nil {\n\t\treturn err\n\t}\n\treturn nil",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+}
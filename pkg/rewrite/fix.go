@@ -0,0 +1,117 @@
+package rewrite
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/dave/dst/decorator"
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+	"golang.org/x/tools/imports"
+)
+
+// TextEdit describes the replacement of the source range [Pos, End) with NewText.
+type TextEdit struct {
+	// Pos is the start of the replaced range.
+	Pos token.Pos
+	// End is the end of the replaced range (exclusive). Pos == End denotes an insertion.
+	End token.Pos
+	// NewText is the replacement content.
+	NewText []byte
+}
+
+// SuggestFix computes the source edits that handle a single InjectionPoint, without modifying
+// the loaded AST or any shared DST.
+//
+// The file is decorated afresh, the point is rewritten (defers via RewriteDefer, everything else via
+// RewritePoints) and the result is diffed line-by-line against the original source. Missing imports
+// required by the injected code are added.
+//
+// Fixes are only computed when the unmodified file round-trips through the DST restorer unchanged
+// (i.e. it is gofmt-formatted), ensuring that edits never contain unrelated formatting changes.
+//
+// point: The injection point to fix.
+// src: The current on-disk content of the point's file.
+//
+// Returns the edits, or nil if the point cannot be rewritten in place (e.g. the enclosing function
+// does not return an error).
+func (i *Injector) SuggestFix(point analysis.InjectionPoint, src []byte) ([]TextEdit, error) {
+	if point.Stmt == nil || point.File == nil {
+		return nil, nil
+	}
+	tokFile := i.Fset.File(point.File.Pos())
+	if tokFile == nil {
+		return nil, fmt.Errorf("file not found in fset")
+	}
+	filename := tokFile.Name()
+
+	dstFile, err := decorator.NewDecorator(i.Fset).DecorateFile(point.File)
+	if err != nil {
+		return nil, err
+	}
+
+	var baseline bytes.Buffer
+	if err := decorator.NewRestorer().Fprint(&baseline, dstFile); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(baseline.Bytes(), src) {
+		return nil, nil
+	}
+
+	var applied bool
+	if deferStmt, ok := point.Stmt.(*ast.DeferStmt); ok {
+		applied, err = i.RewriteDefer(dstFile, point.File, deferStmt)
+	} else {
+		applied, err = i.RewritePoints(dstFile, point.File, []analysis.InjectionPoint{point})
+	}
+	if err != nil || !applied {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := decorator.NewRestorer().Fprint(&buf, dstFile); err != nil {
+		return nil, err
+	}
+	// Add imports required by the injected code (e.g. "errors" for errors.Join).
+	fixed, err := imports.Process(filename, buf.Bytes(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffEdits(tokFile, string(src), string(fixed)), nil
+}
+
+// DiffEdits computes line based TextEdits transforming before into after.
+// Adjacent delete and insert operations are merged into a single replacement so the
+// resulting edits never overlap.
+//
+// tokFile: The token file of the original source, used to convert lines to positions.
+// before: The original source.
+// after: The rewritten source.
+func DiffEdits(tokFile *token.File, before, after string) []TextEdit {
+	lineStart := func(line int) token.Pos {
+		if line > tokFile.LineCount() {
+			return tokFile.Pos(tokFile.Size())
+		}
+		return tokFile.LineStart(line)
+	}
+
+	var edits []TextEdit
+	for _, e := range myers.ComputeEdits(span.URIFromPath(tokFile.Name()), before, after) {
+		edit := TextEdit{
+			Pos:     lineStart(e.Span.Start().Line()),
+			End:     lineStart(e.Span.End().Line()),
+			NewText: []byte(e.NewText),
+		}
+		if n := len(edits); n > 0 && edits[n-1].End == edit.Pos {
+			edits[n-1].End = edit.End
+			edits[n-1].NewText = append(edits[n-1].NewText, edit.NewText...)
+			continue
+		}
+		edits = append(edits, edit)
+	}
+	return edits
+}
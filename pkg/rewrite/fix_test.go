@@ -0,0 +1,65 @@
+package rewrite
+
+import (
+	"go/token"
+	"strings"
+	"testing"
+)
+
+// TestDiffEdits verifies that replacements are merged into non-overlapping edits.
+func TestDiffEdits(t *testing.T) {
+	before := "a\nb\nc\n"
+	after := "a\nx\ny\nc\n"
+
+	fset := token.NewFileSet()
+	tf := fset.AddFile("f.go", -1, len(before))
+	tf.SetLinesForContent([]byte(before))
+
+	edits := DiffEdits(tf, before, after)
+	if len(edits) != 1 {
+		t.Fatalf("Expected 1 merged edit, got %d", len(edits))
+	}
+	e := edits[0]
+	if start, end := tf.Offset(e.Pos), tf.Offset(e.End); start != 2 || end != 4 {
+		t.Errorf("Expected edit range [2,4), got [%d,%d)", start, end)
+	}
+	if string(e.NewText) != "x\ny\n" {
+		t.Errorf("Unexpected replacement text %q", e.NewText)
+	}
+}
+
+// TestSuggestFix verifies that a fix is computed for a single point without modifying the AST.
+func TestSuggestFix(t *testing.T) {
+	src := `package main
+
+func fail() error { return nil }
+
+func run() error {
+	fail()
+	return nil
+}
+
+func void() {
+	fail()
+}
+`
+	injector, _, astFile := setupInjectorTest(t, src)
+
+	point := findPoint(t, astFile, "fail")
+	edits, err := injector.SuggestFix(point, []byte(src))
+	if err != nil {
+		t.Fatalf("SuggestFix failed: %v", err)
+	}
+	if len(edits) != 1 {
+		t.Fatalf("Expected 1 edit, got %d", len(edits))
+	}
+	if got := string(edits[0].NewText); !strings.Contains(got, "if err := fail(); err != nil") {
+		t.Errorf("Unexpected fix text:\n%s", got)
+	}
+
+	// Source that does not round-trip cleanly yields no fix.
+	edits, err = injector.SuggestFix(point, []byte(src+"\n\n"))
+	if err != nil || edits != nil {
+		t.Errorf("Expected no fix for unformatted source, got %v (err %v)", edits, err)
+	}
+}
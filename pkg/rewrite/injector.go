@@ -5,9 +5,13 @@ import (
 	"go/ast"
 	"go/token"
 	"go/types"
+	"path/filepath"
+	"strings"
 
 	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
 	"github.com/SamuelMarks/go-auto-err-handling/pkg/astgen"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/dstmap"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/refactor"
 	"github.com/dave/dst"
 	"github.com/dave/dst/dstutil"
 	"golang.org/x/tools/go/ast/astutil"
@@ -20,14 +24,38 @@ type Injector struct {
 	Pkg                 *packages.Package
 	ErrorTemplate       string
 	MainHandlerStrategy string
+	// Rules select per-callee handling (see RuleFor). The first matching rule wins.
+	Rules []Rule
+	// GoStrategy selects the handling of go statements: GoStrategyHandler (the default) or
+	// GoStrategyErrgroup.
+	GoStrategy string
+	// LogHandler selects the logging of errors handled in place (ActionLog rules, LogFallback and
+	// deferred calls): LogHandlerLog (the default) or LogHandlerSlog.
+	LogHandler string
+	// Logger is the logger expression of the slog handling (e.g. "s.logger"), or "" to detect it
+	// from scope.
+	Logger string
+	// Callbacks are the fixed-signature shapes whose errors are handled in place (see CallbackFor).
+	// The first matching shape wins.
+	Callbacks []Callback
+	// ClosePolicy selects the handling of deferred calls releasing read-only resources (see
+	// analysis.ClassifyClose): ClosePolicyIgnore (the default), ClosePolicyNolint or ClosePolicyJoin.
+	ClosePolicy string
+	// SyncOnClose syncs writable files before the deferred Close joining their error.
+	SyncOnClose bool
+	// Defers restricts the defer statements rewritten by RewriteFile (e.g. to those of an errcheck
+	// report). Nil rewrites every defer of the file.
+	Defers map[*ast.DeferStmt]bool
 }
 
 // NewInjector creates a new Injector for the given package.
 //
 // pkg: The loaded package containing type info.
-// errorTemplate: Template string for converting errors to returns (e.g. "{return-zero}, fmt.Errorf(...)").
+// errorTemplate: Template string for converting errors to returns (e.g. "{return-zero}, fmt.Errorf(...)"),
+// or a preset name from TemplatePresets (e.g. "wrap").
 // mainHandler: Strategy for main/init functions ("log-fatal", "panic", etc).
 func NewInjector(pkg *packages.Package, errorTemplate, mainHandler string) *Injector {
+	errorTemplate = ExpandTemplate(errorTemplate)
 	if errorTemplate == "" {
 		errorTemplate = "{return-zero}, err"
 	}
@@ -39,6 +67,7 @@ func NewInjector(pkg *packages.Package, errorTemplate, mainHandler string) *Inje
 		Pkg:                 pkg,
 		ErrorTemplate:       errorTemplate,
 		MainHandlerStrategy: mainHandler,
+		Callbacks:           DefaultCallbacks,
 	}
 }
 
@@ -51,7 +80,7 @@ func NewInjector(pkg *packages.Package, errorTemplate, mainHandler string) *Inje
 // Returns true if any modification was made.
 func (i *Injector) RewriteFile(dstFile *dst.File, astFile *ast.File, points []analysis.InjectionPoint) (bool, error) {
 	// 1. Rewrite defers first
-	defersApplied, deferErr := i.RewriteDefers(dstFile, astFile)
+	defersApplied, deferErr := i.rewriteDefers(dstFile, astFile, i.Defers)
 	if deferErr != nil {
 		return false, deferErr
 	}
@@ -60,7 +89,30 @@ func (i *Injector) RewriteFile(dstFile *dst.File, astFile *ast.File, points []an
 		return defersApplied, nil
 	}
 
-	// 2. Map ASTInjectionPoints to DST Stmts
+	applied, err := i.RewritePoints(dstFile, astFile, points)
+	return applied || defersApplied, err
+}
+
+// RewritePoints applies the injection points to a single file without touching defer statements.
+// Unlike RewriteFile, it leaves unrelated defers intact, which allows callers (such as the
+// analyzer) to compute a fix scoped to exactly one InjectionPoint.
+//
+// dstFile: The Decorated Syntax Tree to modify.
+// astFile: The original AST file (used for type analysis and mapping).
+// points: List of detected unhandled errors.
+//
+// Returns true if any modification was made.
+func (i *Injector) RewritePoints(dstFile *dst.File, astFile *ast.File, points []analysis.InjectionPoint) (bool, error) {
+	groupsApplied := false
+	if i.GoStrategy == GoStrategyErrgroup {
+		var err error
+		points, groupsApplied, err = i.rewriteGoGroups(dstFile, astFile, points)
+		if err != nil {
+			return groupsApplied, err
+		}
+	}
+
+	// 1. Map ASTInjectionPoints to DST Stmts
 	targetMap := make(map[dst.Stmt]analysis.InjectionPoint)
 	for _, p := range points {
 		if p.Stmt == nil {
@@ -83,7 +135,7 @@ func (i *Injector) RewriteFile(dstFile *dst.File, astFile *ast.File, points []an
 	applied := false
 	var err error
 
-	// 3. Traverse and Apply
+	// 2. Traverse and Apply
 	dstutil.Apply(dstFile, func(c *dstutil.Cursor) bool {
 		if err != nil {
 			return false
@@ -110,6 +162,8 @@ func (i *Injector) RewriteFile(dstFile *dst.File, astFile *ast.File, points []an
 		var newNodes []dst.Stmt
 		var genErr error
 
+		rule := i.RuleFor(point)
+
 		switch s := stmt.(type) {
 		case *dst.GoStmt:
 			var converted *dst.GoStmt
@@ -118,8 +172,20 @@ func (i *Injector) RewriteFile(dstFile *dst.File, astFile *ast.File, points []an
 				newNodes = []dst.Stmt{converted}
 			}
 		default:
+			if point.Shadows != nil {
+				// The error only needs to reach the result it shadows, whatever the rule.
+				newNodes, genErr = i.generateUnshadowDST(dstFile, point, stmt)
+				break
+			}
+			if rule.Action == ActionLog {
+				newNodes, genErr = i.generateLogRewriteDST(dstFile, point, stmt)
+				if genErr == nil && len(newNodes) > 0 {
+					i.addLogImportDST(dstFile, point)
+				}
+				break
+			}
 			// Pass the DST statement to help extract the call
-			newNodes, genErr = i.generateRewriteDST(point, stmt, sig, decl)
+			newNodes, genErr = i.generateRewriteDST(dstFile, point, stmt, sig, decl)
 		}
 
 		if genErr != nil {
@@ -131,6 +197,15 @@ func (i *Injector) RewriteFile(dstFile *dst.File, astFile *ast.File, points []an
 			// Transfer Trivia
 			i.transferTrivia(stmt, newNodes)
 
+			if c.Index() < 0 {
+				// Not in a statement list (e.g. an if or switch init); a single statement is required.
+				newNodes = collapseInit(newNodes)
+			}
+			if !fitsSlot(c, newNodes) {
+				// The check cannot be an init statement; the call is left unhandled.
+				return true
+			}
+
 			// Replace logic
 			c.Replace(newNodes[0])
 			for k := len(newNodes) - 1; k > 0; k-- {
@@ -142,11 +217,29 @@ func (i *Injector) RewriteFile(dstFile *dst.File, astFile *ast.File, points []an
 		return true
 	}, nil)
 
-	return applied || defersApplied, err
+	return applied || groupsApplied, err
 }
 
 // LogFallback injects a logging statement for the given error instead of returning it.
 func (i *Injector) LogFallback(dstFile *dst.File, astFile *ast.File, point analysis.InjectionPoint) (bool, error) {
+	applied, err := i.handleInPlace(dstFile, astFile, point, func(point analysis.InjectionPoint, dstStmt dst.Stmt) ([]dst.Stmt, error) {
+		return i.generateLogRewriteDST(dstFile, point, dstStmt)
+	})
+	if applied {
+		i.addLogImportDST(dstFile, point)
+	}
+	return applied, err
+}
+
+// handleInPlace replaces the statement of point with the statements generated by gen.
+//
+// dstFile: The DST of the file.
+// astFile: The AST of the file.
+// point: The injection point.
+// gen: Generates the replacement of the DST statement of point.
+//
+// Returns true if the statement was replaced.
+func (i *Injector) handleInPlace(dstFile *dst.File, astFile *ast.File, point analysis.InjectionPoint, gen func(analysis.InjectionPoint, dst.Stmt) ([]dst.Stmt, error)) (bool, error) {
 	if point.Stmt == nil {
 		return false, nil
 	}
@@ -168,18 +261,23 @@ func (i *Injector) LogFallback(dstFile *dst.File, astFile *ast.File, point analy
 		}
 
 		var stmts []dst.Stmt
-		stmts, genErr = i.generateLogRewriteDST(point, dstStmt)
+		stmts, genErr = gen(point, dstStmt)
 		if genErr != nil {
 			return false
 		}
 
 		if len(stmts) > 0 {
+			if c.Index() < 0 {
+				stmts = collapseInit(stmts)
+			}
+			if !fitsSlot(c, stmts) {
+				return false
+			}
 			i.transferTrivia(dstStmt, stmts)
 			c.Replace(stmts[0])
 			for k := len(stmts) - 1; k > 0; k-- {
 				c.InsertAfter(stmts[k])
 			}
-			i.addImportDST(dstFile, "log")
 			applied = true
 		}
 		return false
@@ -200,6 +298,12 @@ func (i *Injector) transferTrivia(src dst.Stmt, newStmts []dst.Stmt) {
 	first.Decorations().Start = src.Decorations().Start
 	first.Decorations().End = src.Decorations().End
 	last.Decorations().After = src.Decorations().After
+	if last == src && first != src {
+		// The statement is kept after the new ones (e.g. a return); its comments moved to the first.
+		src.Decorations().Before = dst.None
+		src.Decorations().Start = nil
+		src.Decorations().End = nil
+	}
 
 	if len(newStmts) > 1 {
 		first.Decorations().After = dst.NewLine
@@ -233,7 +337,7 @@ func (i *Injector) getEnclosingContext(p analysis.InjectionPoint) contextInfo {
 }
 
 // generateRewriteDST creates the DST nodes for assignment and error checking.
-func (i *Injector) generateRewriteDST(point analysis.InjectionPoint, dstStmt dst.Stmt, sig *types.Signature, decl *ast.FuncDecl) ([]dst.Stmt, error) {
+func (i *Injector) generateRewriteDST(dstFile *dst.File, point analysis.InjectionPoint, dstStmt dst.Stmt, sig *types.Signature, decl *ast.FuncDecl) ([]dst.Stmt, error) {
 	useSig := false
 	if sig != nil && sig.Results().Len() > 0 {
 		last := sig.Results().At(sig.Results().Len() - 1)
@@ -250,36 +354,43 @@ func (i *Injector) generateRewriteDST(point analysis.InjectionPoint, dstStmt dst
 	}
 
 	if !useSig {
+		if point.ErrIdent != nil && i.isEntryPoint(decl) {
+			// The error is already in a variable; main and init handle it in place.
+			body, err := i.generateTerminalHandlerDST(point, i.resolveFuncName(point), point.ErrIdent.Name)
+			if err != nil {
+				return nil, err
+			}
+			return checkAfterDST(dstStmt, point.ErrIdent.Name, body), nil
+		}
 		return nil, nil // Cannot inject return if signature doesn't support error
 	}
 
 	scope := i.getScope(point.Pos, point.File)
 	errName, tok, declStmt := i.resolveErrorVar(point, scope)
-	funcName := i.resolveFuncName(point)
+	if point.ErrIdent != nil {
+		errName = point.ErrIdent.Name
+	}
 
 	// Generate Returns
-	var zeroExprs []dst.Expr
-	if sig != nil {
-		limit := sig.Results().Len()
-		if i.isErrorType(sig.Results().At(sig.Results().Len() - 1).Type()) {
-			limit--
-		}
-		for idx := 0; idx < limit; idx++ {
-			t := sig.Results().At(idx).Type()
-			z, err := astgen.ZeroExprDST(t, astgen.ZeroCtx{})
-			if err != nil {
-				return nil, err
-			}
-			zeroExprs = append(zeroExprs, z)
-		}
+	zeroExprs, zeroDecls, err := i.zeroResultsDST(sig, scope)
+	if err != nil {
+		return nil, err
 	}
 
-	retExprs, _, err := RenderTemplateDST(i.ErrorTemplate, zeroExprs, errName, funcName)
+	retExprs, _, err := RenderTemplateVarsDST(i.RuleFor(point).Template, zeroExprs, errName, i.templateVars(point))
 	if err != nil {
 		return nil, err
 	}
 
 	retStmt := &dst.ReturnStmt{Results: retExprs}
+	retBody := append(zeroDecls, retStmt)
+	if point.ErrIdent != nil {
+		return checkAfterDST(dstStmt, errName, &dst.BlockStmt{List: retBody}), nil
+	}
+
+	if !callIsStmt(point) {
+		return i.generateTempRewriteDST(dstFile, point, dstStmt, scope, errName, &dst.BlockStmt{List: retBody})
+	}
 
 	// Extract DST Call from DST Stmt
 	dstCall := i.extractDstCall(dstStmt)
@@ -304,7 +415,7 @@ func (i *Injector) generateRewriteDST(point analysis.InjectionPoint, dstStmt dst
 			Y:  dst.NewIdent("nil"),
 		},
 		Body: &dst.BlockStmt{
-			List: []dst.Stmt{retStmt},
+			List: retBody,
 		},
 	}
 
@@ -313,7 +424,7 @@ func (i *Injector) generateRewriteDST(point analysis.InjectionPoint, dstStmt dst
 		result = append(result, declStmt)
 	}
 
-	if as, ok := assignStmt.(*dst.AssignStmt); ok && declStmt == nil {
+	if as, ok := assignStmt.(*dst.AssignStmt); ok && declStmt == nil && assignsOnlyErr(as, errName) {
 		checkStmt.Init = as
 		result = append(result, checkStmt)
 	} else {
@@ -323,6 +434,287 @@ func (i *Injector) generateRewriteDST(point analysis.InjectionPoint, dstStmt dst
 	return result, nil
 }
 
+// generateTempRewriteDST handles a call which is an operand of an expression, e.g. in a return
+// ("return F(x) + 1"), an assignment ("y += F(x)") or the condition of an if statement. The results
+// of the call are assigned to temporaries, the error is checked, and the statement follows with the
+// call replaced by the temporaries:
+//
+//	v, err := F(x)
+//	if err != nil {
+//		return 0, err
+//	}
+//	return v + 1, nil
+//
+// The statement is kept, so that the other calls of the statement are found again.
+//
+// dstFile: The DST of the file.
+// point: The injection point.
+// stmt: The DST of the statement of point.
+// scope: The scope of the statement, which the temporaries must not shadow.
+// errName: The name of the error variable.
+// body: The body of the error check.
+//
+// Returns nil if the call has no result besides the error, if it cannot run before its statement
+// (see hoistable), or if its results cannot replace it (several results in an expression).
+func (i *Injector) generateTempRewriteDST(dstFile *dst.File, point analysis.InjectionPoint, stmt dst.Stmt, scope *types.Scope, errName string, body *dst.BlockStmt) ([]dst.Stmt, error) {
+	tv, ok := i.Pkg.TypesInfo.Types[point.Call]
+	if !ok {
+		return nil, fmt.Errorf("missing type info for call")
+	}
+	tuple, ok := tv.Type.(*types.Tuple)
+	if !ok || tuple.Len() < 2 || !hoistable(point) {
+		return nil, nil
+	}
+	res, err := FindDstNode(i.Fset, dstFile, point.File, point.Call)
+	if err != nil {
+		return nil, nil
+	}
+	dstCall, ok := res.Node.(*dst.CallExpr)
+	if !ok {
+		return nil, nil
+	}
+
+	names := make([]string, tuple.Len()-1)
+	// Temporaries of previous calls of the function are not in scope yet.
+	taken := i.namesInFunc(dstFile, point, stmt)
+	taken[errName] = true
+	for k := range names {
+		base := "v"
+		for n := 1; taken[base] || analysis.GenerateUniqueName(scope, base) != base; n++ {
+			base = fmt.Sprintf("v%d", n)
+		}
+		taken[base] = true
+		names[k] = base
+	}
+
+	replaced := false
+	dstutil.Apply(stmt, func(c *dstutil.Cursor) bool {
+		if replaced || c.Node() != dstCall {
+			return !replaced
+		}
+		if len(names) > 1 && c.Index() < 0 {
+			// Several results can only replace a call among the results or arguments.
+			return false
+		}
+		c.Replace(dst.NewIdent(names[0]))
+		for k := len(names) - 1; k > 0; k-- {
+			c.InsertAfter(dst.NewIdent(names[k]))
+		}
+		replaced = true
+		return false
+	}, nil)
+	if !replaced {
+		return nil, nil
+	}
+
+	astgen.ClearDecorations(dstCall)
+	lhs := make([]dst.Expr, 0, len(names)+1)
+	for _, name := range names {
+		lhs = append(lhs, dst.NewIdent(name))
+	}
+	lhs = append(lhs, dst.NewIdent(errName))
+	assign := &dst.AssignStmt{Lhs: lhs, Tok: token.DEFINE, Rhs: []dst.Expr{dstCall}}
+	check := &dst.IfStmt{
+		Cond: &dst.BinaryExpr{X: dst.NewIdent(errName), Op: token.NEQ, Y: dst.NewIdent("nil")},
+		Body: body,
+	}
+	stmts := []dst.Stmt{assign, check}
+	if later := i.laterChecks(dstFile, point, stmt); len(later) > 0 {
+		// The calls run in source order: the new check takes the place of the first later one.
+		seq := append(stmts, later...)
+		seq[0].Decorations().Before, seq[0].Decorations().Start = later[0].Decorations().Before, later[0].Decorations().Start
+		later[0].Decorations().Before, later[0].Decorations().Start = dst.NewLine, nil
+		stmts = seq[copy(later, seq):]
+	}
+	return append(stmts, stmt), nil
+}
+
+// laterChecks returns the temporaries and checks generated before stmt for the calls of point.Stmt
+// which follow the call of point in source order. The calls of a statement are not necessarily
+// handled in that order (e.g. the calls of different function values).
+//
+// dstFile: The DST of the file.
+// point: The injection point.
+// stmt: The DST of the statement of point.
+//
+// Returns a slice of the statement list holding stmt, or nil.
+func (i *Injector) laterChecks(dstFile *dst.File, point analysis.InjectionPoint, stmt dst.Stmt) []dst.Stmt {
+	_, parent, err := dstmap.Lookup(dstFile, point.Stmt)
+	if err != nil {
+		return nil
+	}
+	var list []dst.Stmt
+	switch p := parent.(type) {
+	case *dst.BlockStmt:
+		list = p.List
+	case *dst.CaseClause:
+		list = p.Body
+	case *dst.CommClause:
+		list = p.Body
+	}
+	idx := -1
+	for k, s := range list {
+		if s == stmt {
+			idx = k
+		}
+	}
+
+	// The positions of the calls of the statement, by their DST.
+	calls := make(map[dst.Node]token.Pos)
+	ast.Inspect(point.Stmt, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			if res, err := FindDstNode(i.Fset, dstFile, point.File, call); err == nil {
+				calls[res.Node] = call.Pos()
+			}
+		}
+		return true
+	})
+
+	first := idx
+	for k := idx; k >= 2; k -= 2 {
+		as, ok := list[k-2].(*dst.AssignStmt)
+		if _, isIf := list[k-1].(*dst.IfStmt); !ok || !isIf || len(as.Rhs) != 1 {
+			break
+		}
+		pos, ok := calls[as.Rhs[0]]
+		if !ok || pos < point.Call.Pos() {
+			break
+		}
+		first = k - 2
+	}
+	if idx < 0 || first == idx {
+		return nil
+	}
+	return list[first:idx]
+}
+
+// callIsStmt reports whether the call of point makes up its statement: a call statement, or the
+// single value of an assignment with = or :=. Other calls are operands of an expression, which
+// generateTempRewriteDST handles.
+func callIsStmt(point analysis.InjectionPoint) bool {
+	switch s := point.Stmt.(type) {
+	case *ast.ExprStmt:
+		return ast.Unparen(s.X) == point.Call
+	case *ast.AssignStmt:
+		return (s.Tok == token.ASSIGN || s.Tok == token.DEFINE) && len(s.Rhs) == 1 && ast.Unparen(s.Rhs[0]) == point.Call
+	case *ast.ReturnStmt, *ast.IfStmt, *ast.SwitchStmt, *ast.RangeStmt, *ast.SendStmt:
+		return false
+	}
+	return true
+}
+
+// hoistable reports whether the call of point can run before its statement: the statement
+// evaluates the expression containing the call once, before any of its own variables are declared,
+// and the call is not the right operand of && or ||, which only run conditionally.
+func hoistable(point analysis.InjectionPoint) bool {
+	var expr ast.Expr
+	switch s := point.Stmt.(type) {
+	case *ast.ExprStmt, *ast.AssignStmt, *ast.ReturnStmt, *ast.SendStmt:
+	case *ast.IfStmt:
+		if s.Init != nil {
+			return false
+		}
+		expr = s.Cond
+	case *ast.SwitchStmt:
+		if s.Init != nil {
+			return false
+		}
+		expr = s.Tag
+	case *ast.RangeStmt:
+		expr = s.X
+	default:
+		return false
+	}
+	if expr != nil && (point.Call.Pos() < expr.Pos() || point.Call.End() > expr.End()) {
+		return false
+	}
+	path, _ := astutil.PathEnclosingInterval(point.File, point.Call.Pos(), point.Call.End())
+	for k := 1; k < len(path) && path[k] != point.Stmt; k++ {
+		if b, ok := path[k].(*ast.BinaryExpr); ok && (b.Op == token.LAND || b.Op == token.LOR) && path[k-1] == b.Y {
+			return false
+		}
+	}
+	return true
+}
+
+// namesInFunc returns the identifiers used in the function enclosing point, including the
+// temporaries of earlier rewrites, which the scopes of the type checker do not know.
+//
+// dstFile: The DST of the file.
+// point: The injection point.
+// stmt: The DST of the statement of point, searched if the function cannot be mapped.
+func (i *Injector) namesInFunc(dstFile *dst.File, point analysis.InjectionPoint, stmt dst.Stmt) map[string]bool {
+	var root dst.Node = stmt
+	path, _ := astutil.PathEnclosingInterval(point.File, point.Pos, point.Pos)
+	for _, n := range path {
+		if _, ok := n.(*ast.FuncLit); !ok {
+			if _, ok := n.(*ast.FuncDecl); !ok {
+				continue
+			}
+		}
+		if res, err := FindDstNode(i.Fset, dstFile, point.File, n); err == nil {
+			root = res.Node
+		}
+		break
+	}
+	names := make(map[string]bool)
+	dst.Inspect(root, func(n dst.Node) bool {
+		if id, ok := n.(*dst.Ident); ok {
+			names[id.Name] = true
+		}
+		return true
+	})
+	return names
+}
+
+// zeroResultsDST returns the zero values of the results of sig, excluding a trailing error. Type
+// parameters have no literal zero value: "var zero T" declares one, in the returned statements
+// that must precede the return.
+//
+// sig: The signature of the function returning.
+// scope: The scope of the return, which the names of the declared variables must not shadow.
+func (i *Injector) zeroResultsDST(sig *types.Signature, scope *types.Scope) ([]dst.Expr, []dst.Stmt, error) {
+	if sig == nil || sig.Results().Len() == 0 {
+		return nil, nil, nil
+	}
+	limit := sig.Results().Len()
+	if i.isErrorType(sig.Results().At(limit - 1).Type()) {
+		limit--
+	}
+	var zeroExprs []dst.Expr
+	var decls []dst.Stmt
+	// declared maps the type parameters to their zero variables, so each is declared once.
+	declared := make(map[*types.TypeParam]string)
+	for idx := 0; idx < limit; idx++ {
+		t := sig.Results().At(idx).Type()
+		if tp, ok := t.(*types.TypeParam); ok {
+			name, ok := declared[tp]
+			if !ok {
+				base := "zero"
+				if len(declared) > 0 {
+					// e.g. zero and zeroV for (K, V).
+					base += tp.Obj().Name()
+				}
+				name = analysis.GenerateUniqueName(scope, base)
+				declared[tp] = name
+				decl, err := astgen.ZeroVarDST(name, tp)
+				if err != nil {
+					return nil, nil, err
+				}
+				decls = append(decls, decl)
+			}
+			zeroExprs = append(zeroExprs, dst.NewIdent(name))
+			continue
+		}
+		z, err := astgen.ZeroExprDST(t, astgen.ZeroCtx{})
+		if err != nil {
+			return nil, nil, err
+		}
+		zeroExprs = append(zeroExprs, z)
+	}
+	return zeroExprs, decls, nil
+}
+
 func (i *Injector) generateGoRewriteDST(point analysis.InjectionPoint, goStmt *dst.GoStmt) (*dst.GoStmt, error) {
 	call := dst.Clone(goStmt.Call).(*dst.CallExpr)
 	astgen.ClearDecorations(call)
@@ -336,7 +728,10 @@ func (i *Injector) generateGoRewriteDST(point analysis.InjectionPoint, goStmt *d
 		return nil, err
 	}
 
-	handlerBlock := i.generateTerminalHandlerDST(errName)
+	handlerBlock, err := i.generateTerminalHandlerDST(point, i.resolveFuncName(point), errName)
+	if err != nil {
+		return nil, err
+	}
 
 	checkStmt := &dst.IfStmt{
 		Cond: &dst.BinaryExpr{
@@ -367,11 +762,49 @@ func (i *Injector) generateGoRewriteDST(point analysis.InjectionPoint, goStmt *d
 	}, nil
 }
 
-func (i *Injector) generateLogRewriteDST(point analysis.InjectionPoint, dstStmt dst.Stmt) ([]dst.Stmt, error) {
+func (i *Injector) generateLogRewriteDST(dstFile *dst.File, point analysis.InjectionPoint, dstStmt dst.Stmt) ([]dst.Stmt, error) {
+	return i.generateInPlaceDST(dstFile, point, dstStmt, func(errName string) ([]dst.Stmt, error) {
+		logStmt, err := i.generateLogStmtDST(point, errName)
+		if err != nil {
+			return nil, err
+		}
+		return []dst.Stmt{logStmt}, nil
+	})
+}
+
+// generateInPlaceDST assigns the error of point to a variable and checks it with the statements
+// generated by handle, instead of returning it:
+//
+//	if err := f(); err != nil {
+//		<handle(err)>
+//	}
+//
+// A call which is an operand of an expression is moved to temporaries first (see
+// generateTempRewriteDST).
+//
+// dstFile: The DST of the file.
+// point: The injection point.
+// dstStmt: The DST statement of point.
+// handle: Generates the body of the check for the name of the error variable.
+//
+// Returns the statements replacing dstStmt.
+func (i *Injector) generateInPlaceDST(dstFile *dst.File, point analysis.InjectionPoint, dstStmt dst.Stmt, handle func(errName string) ([]dst.Stmt, error)) ([]dst.Stmt, error) {
+	if point.ErrIdent != nil {
+		body, err := handle(point.ErrIdent.Name)
+		if err != nil {
+			return nil, err
+		}
+		return checkAfterDST(dstStmt, point.ErrIdent.Name, &dst.BlockStmt{List: body}), nil
+	}
 	scope := i.getScope(point.Pos, point.File)
 	errName, tok, declStmt := i.resolveErrorVar(point, scope)
-	funcName := i.resolveFuncName(point)
-
+	if !callIsStmt(point) {
+		body, err := handle(errName)
+		if err != nil {
+			return nil, err
+		}
+		return i.generateTempRewriteDST(dstFile, point, dstStmt, scope, errName, &dst.BlockStmt{List: body})
+	}
 	dstCall := i.extractDstCall(dstStmt)
 	if dstCall == nil {
 		return nil, fmt.Errorf("no call in stmt")
@@ -384,15 +817,9 @@ func (i *Injector) generateLogRewriteDST(point analysis.InjectionPoint, dstStmt
 		return nil, err
 	}
 
-	logCall := &dst.CallExpr{
-		Fun: &dst.SelectorExpr{
-			X:   dst.NewIdent("log"),
-			Sel: dst.NewIdent("Printf"),
-		},
-		Args: []dst.Expr{
-			&dst.BasicLit{Kind: token.STRING, Value: fmt.Sprintf(`"ignored error in %s: %%v"`, funcName)},
-			dst.NewIdent(errName),
-		},
+	body, err := handle(errName)
+	if err != nil {
+		return nil, err
 	}
 
 	checkStmt := &dst.IfStmt{
@@ -402,9 +829,7 @@ func (i *Injector) generateLogRewriteDST(point analysis.InjectionPoint, dstStmt
 			Y:  dst.NewIdent("nil"),
 		},
 		Body: &dst.BlockStmt{
-			List: []dst.Stmt{
-				&dst.ExprStmt{X: logCall},
-			},
+			List: body,
 		},
 	}
 
@@ -413,7 +838,7 @@ func (i *Injector) generateLogRewriteDST(point analysis.InjectionPoint, dstStmt
 		result = append(result, declStmt)
 	}
 
-	if as, ok := assignStmt.(*dst.AssignStmt); ok && declStmt == nil {
+	if as, ok := assignStmt.(*dst.AssignStmt); ok && declStmt == nil && assignsOnlyErr(as, errName) {
 		checkStmt.Init = as
 		result = append(result, checkStmt)
 	} else {
@@ -423,6 +848,106 @@ func (i *Injector) generateLogRewriteDST(point analysis.InjectionPoint, dstStmt
 	return result, nil
 }
 
+// checkAfterDST keeps the statement assigning an error to errName and checks the variable right
+// after it.
+func checkAfterDST(stmt dst.Stmt, errName string, body *dst.BlockStmt) []dst.Stmt {
+	return []dst.Stmt{stmt, &dst.IfStmt{
+		Cond: &dst.BinaryExpr{
+			X:  dst.NewIdent(errName),
+			Op: token.NEQ,
+			Y:  dst.NewIdent("nil"),
+		},
+		Body: body,
+	}}
+}
+
+// isEntryPoint reports whether decl is main or an init function (see refactor.IsEntryPoint).
+func (i *Injector) isEntryPoint(decl *ast.FuncDecl) bool {
+	if decl == nil || i.Pkg.TypesInfo == nil {
+		return false
+	}
+	fn, ok := i.Pkg.TypesInfo.ObjectOf(decl.Name).(*types.Func)
+	return ok && refactor.IsEntryPoint(fn)
+}
+
+// assignsOnlyErr reports whether the assignment binds nothing but the error variable, so that it can
+// move into the init statement of the check without hiding other variables from the code that follows.
+func assignsOnlyErr(as *dst.AssignStmt, errName string) bool {
+	for _, lhs := range as.Lhs {
+		id, ok := lhs.(*dst.Ident)
+		if !ok || (id.Name != "_" && id.Name != errName) {
+			return false
+		}
+	}
+	return true
+}
+
+// collapseInit merges an assignment followed by its check into "if assign; check {}".
+func collapseInit(stmts []dst.Stmt) []dst.Stmt {
+	if len(stmts) != 2 {
+		return stmts
+	}
+	as, ok := stmts[0].(*dst.AssignStmt)
+	check, isIf := stmts[1].(*dst.IfStmt)
+	if !ok || !isIf || check.Init != nil {
+		return stmts
+	}
+	check.Init = as
+	return []dst.Stmt{check}
+}
+
+// InInitStmt reports whether the statement of point is the init statement of an if, switch or for
+// statement (or the post statement of a for statement). The check of the error cannot take its
+// place, so such points are left unhandled.
+//
+// point: The injection point.
+func InInitStmt(point analysis.InjectionPoint) bool {
+	if point.Stmt == nil || point.File == nil {
+		return false
+	}
+	path, _ := astutil.PathEnclosingInterval(point.File, point.Stmt.Pos(), point.Stmt.End())
+	for k, n := range path {
+		if n != point.Stmt {
+			continue
+		}
+		if k+1 == len(path) {
+			return false
+		}
+		switch parent := path[k+1].(type) {
+		case *ast.IfStmt:
+			return parent.Init == point.Stmt
+		case *ast.SwitchStmt:
+			return parent.Init == point.Stmt
+		case *ast.TypeSwitchStmt:
+			return parent.Init == point.Stmt
+		case *ast.ForStmt:
+			return parent.Init == point.Stmt || parent.Post == point.Stmt
+		}
+		return false
+	}
+	return false
+}
+
+// fitsSlot reports whether stmts can replace the statement at c. Statement lists take any number of
+// statements and other slots a single one, which must be a simple statement (e.g. an assignment,
+// but not an if) in the init and post slots of if, switch and for statements.
+func fitsSlot(c *dstutil.Cursor, stmts []dst.Stmt) bool {
+	if c.Index() >= 0 {
+		return true
+	}
+	if len(stmts) != 1 {
+		return false
+	}
+	if c.Name() != "Init" && c.Name() != "Post" {
+		return true
+	}
+	switch stmts[0].(type) {
+	case *dst.AssignStmt, *dst.ExprStmt, *dst.IncDecStmt, *dst.SendStmt:
+		return true
+	}
+	return false
+}
+
 // extractDstCall finds the CallExpr within a statement.
 func (i *Injector) extractDstCall(stmt dst.Stmt) *dst.CallExpr {
 	var call *dst.CallExpr
@@ -439,9 +964,31 @@ func (i *Injector) extractDstCall(stmt dst.Stmt) *dst.CallExpr {
 	return call
 }
 
-func (i *Injector) generateTerminalHandlerDST(errVar string) *dst.BlockStmt {
+// generateTerminalHandlerDST generates the handling of errVar where it cannot be returned,
+// according to MainHandlerStrategy.
+//
+// point: The injection point, locating the logger of the slog strategies.
+// op: The failed operation logged by the slog strategies, or "".
+// errVar: The name of the error variable.
+//
+// Returns the body of the error check, or an error if the logger is not a valid expression.
+func (i *Injector) generateTerminalHandlerDST(point analysis.InjectionPoint, op, errVar string) (*dst.BlockStmt, error) {
 	var stmts []dst.Stmt
 	switch i.MainHandlerStrategy {
+	case string(refactor.HandlerSlogError), string(refactor.HandlerSlogErrorExit):
+		logStmt, err := astgen.SlogErrorDST(i.loggerAt(point.File, point.Pos), terminalSlogMsg, op, errVar)
+		if err != nil {
+			return nil, err
+		}
+		stmts = []dst.Stmt{logStmt}
+		if i.MainHandlerStrategy == string(refactor.HandlerSlogErrorExit) {
+			stmts = append(stmts, &dst.ExprStmt{
+				X: &dst.CallExpr{
+					Fun:  &dst.SelectorExpr{X: dst.NewIdent("os"), Sel: dst.NewIdent("Exit")},
+					Args: []dst.Expr{&dst.BasicLit{Kind: token.INT, Value: "1"}},
+				},
+			})
+		}
 	case "panic":
 		stmts = []dst.Stmt{
 			&dst.ExprStmt{
@@ -476,7 +1023,7 @@ func (i *Injector) generateTerminalHandlerDST(errVar string) *dst.BlockStmt {
 			},
 		}
 	}
-	return &dst.BlockStmt{List: stmts}
+	return &dst.BlockStmt{List: stmts}, nil
 }
 
 func (i *Injector) generateAssignmentDST(point analysis.InjectionPoint, call *dst.CallExpr, errName string, tok token.Token) (dst.Stmt, error) {
@@ -497,8 +1044,11 @@ func (i *Injector) generateAssignmentDST(point analysis.InjectionPoint, call *ds
 
 	// Reconstruct LHS
 	if point.Assign != nil {
+		// The error is either the (ignored) last LHS value, or missing entirely if the callee has just
+		// gained an error result (propagation).
+		appendErr := len(point.Assign.Lhs) < resultLen
 		for idx, expr := range point.Assign.Lhs {
-			isLast := idx == len(point.Assign.Lhs)-1
+			isLast := idx == len(point.Assign.Lhs)-1 && !appendErr
 			if isLast {
 				lhs = append(lhs, dst.NewIdent(errName))
 			} else {
@@ -509,6 +1059,9 @@ func (i *Injector) generateAssignmentDST(point analysis.InjectionPoint, call *ds
 				}
 			}
 		}
+		if appendErr {
+			lhs = append(lhs, dst.NewIdent(errName))
+		}
 	} else {
 		// ExprStmt -> AssignStmt
 		for k := 0; k < resultLen-1; k++ {
@@ -572,12 +1125,104 @@ func (i *Injector) resolveFuncName(point analysis.InjectionPoint) string {
 	return "func"
 }
 
+// templateVars collects the call-site context substituted into the error template.
+func (i *Injector) templateVars(point analysis.InjectionPoint) TemplateVars {
+	vars := TemplateVars{
+		FuncName: i.resolveFuncName(point),
+		Pkg:      i.Pkg.Name,
+	}
+	if i.Pkg.Types != nil {
+		vars.Pkg = i.Pkg.Types.Name()
+	}
+	vars.Callee = vars.FuncName
+	if point.Pkg == nil {
+		point.Pkg = i.Pkg
+	}
+
+	if point.Call != nil {
+		if fn := point.Callee(); fn != nil {
+			vars.Callee = i.qualifiedFuncName(fn)
+		}
+		args := make([]string, 0, len(point.Call.Args))
+		for _, arg := range point.Call.Args {
+			args = append(args, types.ExprString(arg))
+		}
+		vars.Args = strings.Join(args, ", ")
+
+		pos := i.Fset.Position(point.Call.Pos())
+		vars.File = filepath.Base(pos.Filename)
+		vars.Line = pos.Line
+	}
+
+	if point.File != nil {
+		// The outermost declaration names the caller, even inside function literals.
+		path, _ := astutil.PathEnclosingInterval(point.File, point.Pos, point.Pos)
+		for _, n := range path {
+			if fn, ok := n.(*ast.FuncDecl); ok {
+				vars.Caller = fn.Name.Name
+				if fn.Recv != nil && len(fn.Recv.List) > 0 {
+					vars.Receiver = receiverTypeName(fn.Recv.List[0].Type)
+					vars.Caller = vars.Receiver + "." + vars.Caller
+				}
+			}
+		}
+	}
+	return vars
+}
+
+// qualifiedFuncName formats fn as "pkg.Func" or "Type.Method".
+// Functions of the current package are not qualified.
+func (i *Injector) qualifiedFuncName(fn *types.Func) string {
+	sig, _ := fn.Type().(*types.Signature)
+	if sig != nil && sig.Recv() != nil {
+		t := sig.Recv().Type()
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		if named, ok := t.(*types.Named); ok {
+			return named.Obj().Name() + "." + fn.Name()
+		}
+		return fn.Name()
+	}
+	if fn.Pkg() == nil || fn.Pkg() == i.Pkg.Types {
+		return fn.Name()
+	}
+	return fn.Pkg().Name() + "." + fn.Name()
+}
+
+// receiverTypeName extracts the type name from a receiver type expression (e.g. "*Server[T]" -> "Server").
+func receiverTypeName(expr ast.Expr) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return types.ExprString(expr)
+		}
+	}
+}
+
 func (i *Injector) getScope(pos token.Pos, file *ast.File) *types.Scope {
 	if i.Pkg.TypesInfo == nil {
 		return nil
 	}
 	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
 	for _, n := range path {
+		// The scope of a function body belongs to its type.
+		switch fn := n.(type) {
+		case *ast.FuncDecl:
+			n = fn.Type
+		case *ast.FuncLit:
+			n = fn.Type
+		}
 		if s := i.Pkg.TypesInfo.Scopes[n]; s != nil {
 			return s
 		}
@@ -597,19 +1242,32 @@ func (i *Injector) isErrorExpr(e ast.Expr) bool {
 	return false
 }
 
+// addImportDST adds an import of path to the file unless it is already imported.
+// The spec is appended to an existing import declaration where possible, so the positions of
+// the remaining declarations (used by FindDstNode) are unaffected.
 func (i *Injector) addImportDST(file *dst.File, path string) {
 	for _, imp := range file.Imports {
 		if imp.Path != nil && imp.Path.Value == fmt.Sprintf(`"%s"`, path) {
 			return
 		}
 	}
+	spec := &dst.ImportSpec{
+		Path: &dst.BasicLit{Kind: token.STRING, Value: fmt.Sprintf(`"%s"`, path)},
+	}
+	for _, d := range file.Decls {
+		if gen, ok := d.(*dst.GenDecl); ok && gen.Tok == token.IMPORT {
+			if !gen.Lparen && len(gen.Specs) > 0 {
+				gen.Lparen = true
+			}
+			gen.Specs = append(gen.Specs, spec)
+			file.Imports = append(file.Imports, spec)
+			return
+		}
+	}
 	decl := &dst.GenDecl{
-		Tok: token.IMPORT,
-		Specs: []dst.Spec{
-			&dst.ImportSpec{
-				Path: &dst.BasicLit{Kind: token.STRING, Value: fmt.Sprintf(`"%s"`, path)},
-			},
-		},
+		Tok:   token.IMPORT,
+		Specs: []dst.Spec{spec},
 	}
 	file.Decls = append([]dst.Decl{decl}, file.Decls...)
+	file.Imports = append(file.Imports, spec)
 }
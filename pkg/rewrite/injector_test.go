@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/dstmap"
 	"github.com/dave/dst"
 	"github.com/dave/dst/decorator"
 	"golang.org/x/tools/go/packages"
@@ -18,13 +19,18 @@ import (
 
 // Helper to setup everything
 func setupInjectorTest(t *testing.T, src string) (*Injector, *dst.File, *ast.File) {
+	return setupInjectorTestImporter(t, src, importer.Default())
+}
+
+// setupInjectorTestImporter is setupInjectorTest with the importer resolving the imports of src.
+func setupInjectorTestImporter(t *testing.T, src string, imp types.Importer) (*Injector, *dst.File, *ast.File) {
 	fset := token.NewFileSet()
 	astFile, err := parser.ParseFile(fset, "main.go", src, parser.ParseComments)
 	if err != nil {
 		t.Fatalf("parser: %v", err)
 	}
 
-	conf := types.Config{Importer: importer.Default()}
+	conf := types.Config{Importer: imp}
 	info := &types.Info{
 		Types:  make(map[ast.Expr]types.TypeAndValue),
 		Defs:   make(map[*ast.Ident]types.Object),
@@ -218,3 +224,325 @@ func main() {
 		t.Error("Import log missing")
 	}
 }
+
+func TestRewriteFile_TemplateVars(t *testing.T) {
+	src := `package main
+
+import "os"
+
+type Server struct{}
+
+func (s *Server) Load(name string) ([]byte, error) {
+	os.Chdir(name)
+	return nil, nil
+}
+`
+	injector, dstFile, astFile := setupInjectorTest(t, src)
+	injector.ErrorTemplate = ExpandTemplate("wrap-caller")
+	point := findPoint(t, astFile, "Chdir")
+
+	vars := injector.templateVars(point)
+	if vars.Callee != "os.Chdir" || vars.Caller != "Server.Load" || vars.Receiver != "Server" {
+		t.Errorf("unexpected vars: %+v", vars)
+	}
+	if vars.Pkg != "main" || vars.File != "main.go" || vars.Line != 8 || vars.Args != "name" {
+		t.Errorf("unexpected location vars: %+v", vars)
+	}
+
+	applied, err := injector.RewriteFile(dstFile, astFile, []analysis.InjectionPoint{point})
+	if err != nil || !applied {
+		t.Fatalf("RewriteFile failed: applied=%v err=%v", applied, err)
+	}
+	out := render(t, dstFile)
+	if !strings.Contains(out, `return nil, fmt.Errorf("Server.Load: os.Chdir: %w", err)`) {
+		t.Errorf("wrapped return not generated:\n%s", out)
+	}
+}
+
+// TestRewriteFile_AssignKeepsVars verifies that assigned values stay visible after the check,
+// both for ignored errors and for callees that have just gained an error result.
+func TestRewriteFile_AssignKeepsVars(t *testing.T) {
+	src := `package main
+
+import "strconv"
+
+func parse(s string) (int, error) {
+	x, _ := strconv.Atoi(s)
+	return x, nil
+}
+
+func count() int { return 1 }
+
+func total() (int, error) {
+	n := count()
+	return n, nil
+}
+`
+	injector, dstFile, astFile := setupInjectorTest(t, src)
+	injector.ErrorTemplate = "{return-zero}, err"
+
+	ignored := findPoint(t, astFile, "Atoi")
+	propagated := findPoint(t, astFile, "count")
+	// Simulate the propagation of a new error result from count.
+	tv := injector.Pkg.TypesInfo.Types[propagated.Call]
+	tv.Type = types.NewTuple(
+		types.NewVar(token.NoPos, nil, "", types.Typ[types.Int]),
+		types.NewVar(token.NoPos, nil, "", types.Universe.Lookup("error").Type()),
+	)
+	injector.Pkg.TypesInfo.Types[propagated.Call] = tv
+
+	applied, err := injector.RewriteFile(dstFile, astFile, []analysis.InjectionPoint{ignored, propagated})
+	if err != nil || !applied {
+		t.Fatalf("RewriteFile failed: applied=%v err=%v", applied, err)
+	}
+	out := render(t, dstFile)
+	for _, want := range []string{
+		"x, err := strconv.Atoi(s)\n\tif err != nil {",
+		"n, err := count()\n\tif err != nil {",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+// TestRewriteFile_ReturnCall verifies that calls in return statements, directly or in an
+// expression, keep the return once their results are checked.
+func TestRewriteFile_ReturnCall(t *testing.T) {
+	src := `package main
+
+func count(s string) int { return len(s) }
+
+func direct() (int, error) {
+	return count("d"), nil // forward
+}
+
+func nested() (int, string, error) {
+	return count("n") + 1, "x", nil
+}
+
+func twice() (int, error) {
+	return count("a") * count("b"), nil
+}
+`
+	injector, _, astFile := setupInjectorTest(t, src)
+	// Mapped like the runner's files, so that the calls of a return are found again after its rewrite.
+	dstFile, err := dstmap.Decorate(injector.Fset, astFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dstmap.Forget(dstFile)
+
+	// Simulate the propagation of a new error result from count.
+	var points []analysis.InjectionPoint
+	ast.Inspect(astFile, func(n ast.Node) bool {
+		ret, ok := n.(*ast.ReturnStmt)
+		if !ok {
+			return true
+		}
+		ast.Inspect(ret, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok && call.Fun.(*ast.Ident).Name == "count" {
+				tv := injector.Pkg.TypesInfo.Types[call]
+				tv.Type = types.NewTuple(
+					types.NewVar(token.NoPos, nil, "", types.Typ[types.Int]),
+					types.NewVar(token.NoPos, nil, "", types.Universe.Lookup("error").Type()),
+				)
+				injector.Pkg.TypesInfo.Types[call] = tv
+				points = append(points, analysis.InjectionPoint{File: astFile, Call: call, Stmt: ret, Pos: call.Pos()})
+			}
+			return true
+		})
+		return true
+	})
+	if len(points) != 4 {
+		t.Fatalf("expected 4 calls of count in returns, got %d", len(points))
+	}
+
+	// The points are handled one by one, like propagated calls.
+	for _, pt := range points {
+		applied, err := injector.RewriteFile(dstFile, astFile, []analysis.InjectionPoint{pt})
+		if err != nil || !applied {
+			t.Fatalf("RewriteFile failed at %s: applied=%v err=%v", injector.Fset.Position(pt.Pos), applied, err)
+		}
+	}
+	out := render(t, dstFile)
+	for _, want := range []string{
+		"v, err := count(\"d\") // forward\n\tif err != nil {\n\t\treturn 0, err\n\t}\n\treturn v, nil\n}",
+		"v, err := count(\"n\")\n\tif err != nil {\n\t\treturn 0, \"\", err\n\t}\n\treturn v + 1, \"x\", nil\n}",
+		"v, err := count(\"a\")\n\tif err != nil {\n\t\treturn 0, err\n\t}\n\tv1, err := count(\"b\")\n\tif err != nil {\n\t\treturn 0, err\n\t}\n\treturn v * v1, nil\n}",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+// TestRewriteFile_ExprCall verifies that calls which are operands of an expression are moved to
+// temporaries, keeping the rest of their statement, and that conditionally evaluated calls are left.
+func TestRewriteFile_ExprCall(t *testing.T) {
+	src := `package main
+
+func count(s string) int { return len(s) }
+
+func binary() (int, error) {
+	x := count("x") + 1
+	return x, nil
+}
+
+func assignOp() (int, error) {
+	y := 0
+	y = count("y") * 2
+	y += count("z")
+	return y, nil
+}
+
+func cond() (bool, error) {
+	if count("c") > 0 {
+		return true, nil
+	}
+	return false, nil
+}
+
+func shortCircuit(ok bool) (bool, error) {
+	b := ok && count("k") > 0
+	return b, nil
+}
+`
+	injector, _, astFile := setupInjectorTest(t, src)
+	dstFile, err := dstmap.Decorate(injector.Fset, astFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dstmap.Forget(dstFile)
+
+	// Simulate the propagation of a new error result from count.
+	var points []analysis.InjectionPoint
+	for _, decl := range astFile.Decls[1:] {
+		for _, stmt := range decl.(*ast.FuncDecl).Body.List {
+			ast.Inspect(stmt, func(n ast.Node) bool {
+				if _, ok := n.(*ast.BlockStmt); ok {
+					return false
+				}
+				call, ok := n.(*ast.CallExpr)
+				if !ok || call.Fun.(*ast.Ident).Name != "count" {
+					return true
+				}
+				tv := injector.Pkg.TypesInfo.Types[call]
+				tv.Type = types.NewTuple(
+					types.NewVar(token.NoPos, nil, "", types.Typ[types.Int]),
+					types.NewVar(token.NoPos, nil, "", types.Universe.Lookup("error").Type()),
+				)
+				injector.Pkg.TypesInfo.Types[call] = tv
+				assign, _ := stmt.(*ast.AssignStmt)
+				points = append(points, analysis.InjectionPoint{File: astFile, Call: call, Stmt: stmt, Assign: assign, Pos: call.Pos()})
+				return true
+			})
+		}
+	}
+	if len(points) != 5 {
+		t.Fatalf("expected 5 calls of count, got %d", len(points))
+	}
+
+	for _, pt := range points {
+		applied, err := injector.RewriteFile(dstFile, astFile, []analysis.InjectionPoint{pt})
+		if err != nil {
+			t.Fatalf("RewriteFile failed at %s: %v", injector.Fset.Position(pt.Pos), err)
+		}
+		// The call of shortCircuit only runs if ok is true.
+		if want := pt.Call.Args[0].(*ast.BasicLit).Value != `"k"`; applied != want {
+			t.Errorf("applied at %s = %v, want %v", injector.Fset.Position(pt.Pos), applied, want)
+		}
+	}
+	out := render(t, dstFile)
+	for _, want := range []string{
+		"v, err := count(\"x\")\n\tif err != nil {\n\t\treturn 0, err\n\t}\n\tx := v + 1\n",
+		"v, err := count(\"y\")\n\tif err != nil {\n\t\treturn 0, err\n\t}\n\ty = v * 2\n" +
+			"\tv1, err := count(\"z\")\n\tif err != nil {\n\t\treturn 0, err\n\t}\n\ty += v1\n",
+		"v, err := count(\"c\")\n\tif err != nil {\n\t\treturn false, err\n\t}\n\tif v > 0 {\n\t\treturn true, nil\n\t}\n",
+		"b := ok && count(\"k\") > 0\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRewriteFile_UncheckedAssign(t *testing.T) {
+	src := `package main
+
+import "strconv"
+
+func parse(a, b string) (int, error) {
+	first, err := strconv.Atoi(a) // first
+	second, err := strconv.Atoi(b)
+	if err != nil {
+		return 0, err
+	}
+	return first + second, nil
+}
+
+func main() {
+	count, err := strconv.Atoi("1")
+	total, err := strconv.Atoi("2")
+	println(count, total, err)
+}
+`
+	injector, dstFile, astFile := setupInjectorTest(t, src)
+	var points []analysis.InjectionPoint
+	for _, name := range []string{"first", "count"} {
+		pt := findPoint(t, astFile, name)
+		pt.ErrIdent = pt.Assign.Lhs[1].(*ast.Ident)
+		points = append(points, pt)
+	}
+
+	applied, err := injector.RewriteFile(dstFile, astFile, points)
+	if err != nil || !applied {
+		t.Fatalf("RewriteFile failed: applied=%v err=%v", applied, err)
+	}
+	out := render(t, dstFile)
+	for _, want := range []string{
+		"first, err := strconv.Atoi(a) // first\n\tif err != nil {\n\t\treturn 0, err\n\t}\n\tsecond, err := strconv.Atoi(b)",
+		"count, err := strconv.Atoi(\"1\")\n\tif err != nil {\n\t\tlog.Fatal(err)\n\t}\n\ttotal, err",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	// LogFallback checks the variable in place too.
+	injector, dstFile, astFile = setupInjectorTest(t, src)
+	pt := findPoint(t, astFile, "first")
+	pt.ErrIdent = pt.Assign.Lhs[1].(*ast.Ident)
+	if changed, err := injector.LogFallback(dstFile, astFile, pt); err != nil || !changed {
+		t.Fatalf("LogFallback failed: changed=%v err=%v", changed, err)
+	}
+	if out := render(t, dstFile); !strings.Contains(out, "first, err := strconv.Atoi(a) // first\n\tif err != nil {\n\t\tlog.Printf(") {
+		t.Errorf("log fallback did not check err in place:\n%s", out)
+	}
+}
+
+// TestRewriteFile_TypeParams verifies that results of type parameter types return declared zero
+// values, one per type parameter.
+func TestRewriteFile_TypeParams(t *testing.T) {
+	src := `package main
+
+import "os"
+
+func pair[K comparable, V any](k K, v V) (K, V, []V, error) {
+	os.Remove("x")
+	return k, v, nil, nil
+}
+`
+	injector, dstFile, astFile := setupInjectorTest(t, src)
+	pt := findPoint(t, astFile, "Remove")
+
+	applied, err := injector.RewriteFile(dstFile, astFile, []analysis.InjectionPoint{pt})
+	if err != nil || !applied {
+		t.Fatalf("RewriteFile failed: applied=%v err=%v", applied, err)
+	}
+	out := render(t, dstFile)
+	want := "if err := os.Remove(\"x\"); err != nil {\n\t\tvar zero K\n\t\tvar zeroV V\n\t\treturn zero, zeroV, nil, err\n\t}"
+	if !strings.Contains(out, want) {
+		t.Errorf("output missing %q:\n%s", want, out)
+	}
+}
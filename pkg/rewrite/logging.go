@@ -0,0 +1,73 @@
+package rewrite
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/astgen"
+	"github.com/dave/dst"
+)
+
+const (
+	// LogHandlerLog logs errors handled in place with log.Printf.
+	LogHandlerLog = "log"
+	// LogHandlerSlog logs errors handled in place with slog at the Error level.
+	LogHandlerSlog = "slog-error"
+)
+
+const (
+	// ignoredSlogMsg is the slog message of errors handled in place.
+	ignoredSlogMsg = "ignored error"
+	// terminalSlogMsg is the slog message of the slog terminal handlers.
+	terminalSlogMsg = "operation failed"
+)
+
+// loggerAt returns the logger expression used by the slog handling at pos: the configured
+// Logger, or the logger found in scope by analysis.FindLogger.
+//
+// file: The AST file containing pos.
+// pos: The position of the handled call.
+//
+// Returns the logger expression, or "" for the functions of the slog package.
+func (i *Injector) loggerAt(file *ast.File, pos token.Pos) string {
+	if i.Logger != "" {
+		return i.Logger
+	}
+	return analysis.FindLogger(i.Pkg, file, pos)
+}
+
+// generateLogStmtDST generates the log of an error handled in place, according to LogHandler:
+//
+//	log.Printf("ignored error in Close: %v", err)
+//	slog.Error("ignored error", "op", "Close", "err", err)
+//
+// point: The injection point whose error is logged.
+// errName: The name of the error variable.
+//
+// Returns the statement, or an error if the logger is not a valid expression.
+func (i *Injector) generateLogStmtDST(point analysis.InjectionPoint, errName string) (dst.Stmt, error) {
+	funcName := i.resolveFuncName(point)
+	if i.LogHandler == LogHandlerSlog {
+		return astgen.SlogErrorDST(i.loggerAt(point.File, point.Pos), ignoredSlogMsg, funcName, errName)
+	}
+	return &dst.ExprStmt{X: &dst.CallExpr{
+		Fun: &dst.SelectorExpr{X: dst.NewIdent("log"), Sel: dst.NewIdent("Printf")},
+		Args: []dst.Expr{
+			&dst.BasicLit{Kind: token.STRING, Value: fmt.Sprintf(`"ignored error in %s: %%v"`, funcName)},
+			dst.NewIdent(errName),
+		},
+	}}, nil
+}
+
+// addLogImportDST adds the import required by generateLogStmtDST for point.
+func (i *Injector) addLogImportDST(dstFile *dst.File, point analysis.InjectionPoint) {
+	if i.LogHandler != LogHandlerSlog {
+		i.addImportDST(dstFile, "log")
+		return
+	}
+	if astgen.SlogNeedsImport(i.loggerAt(point.File, point.Pos)) {
+		i.addImportDST(dstFile, astgen.SlogPath)
+	}
+}
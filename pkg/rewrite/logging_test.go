@@ -0,0 +1,107 @@
+package rewrite
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+)
+
+func TestLogFallback_Slog(t *testing.T) {
+	src := `package main
+
+import "log/slog"
+
+type server struct {
+	logger *slog.Logger
+}
+
+func task() error { return nil }
+
+func (s *server) run() {
+	task()
+}
+`
+	tests := []struct {
+		name   string
+		logger string
+		want   string
+	}{
+		{"Detected", "", `if err := task(); err != nil {
+		s.logger.Error("ignored error", "op", "task", "err", err)
+	}`},
+		{"Explicit", "slog.Default()", `slog.Default().Error("ignored error", "op", "task", "err", err)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			injector, dstFile, astFile := setupInjectorTest(t, src)
+			injector.LogHandler = LogHandlerSlog
+			injector.Logger = tt.logger
+			pt := findPoint(t, astFile, "task")
+
+			changed, err := injector.LogFallback(dstFile, astFile, pt)
+			if err != nil || !changed {
+				t.Fatalf("LogFallback failed: changed=%v err=%v", changed, err)
+			}
+			out := render(t, dstFile)
+			if !strings.Contains(out, tt.want) {
+				t.Errorf("output missing %q:\n%s", tt.want, out)
+			}
+			if strings.Contains(out, `"log"`) {
+				t.Errorf("log import should not be added:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestRewriteDefer_SlogRule(t *testing.T) {
+	src := `package main
+
+import "os"
+
+func read(f *os.File) {
+	defer f.Close()
+}
+`
+	injector, dstFile, astFile := setupInjectorTest(t, src)
+	injector.LogHandler = LogHandlerSlog
+	injector.Rules = []Rule{{Symbol: "(*os.File).Close", Action: ActionLog}}
+
+	applied, err := injector.RewriteDefers(dstFile, astFile)
+	if err != nil || !applied {
+		t.Fatalf("RewriteDefers failed: applied=%v err=%v", applied, err)
+	}
+	out := render(t, dstFile)
+	if !strings.Contains(out, `slog.Error("ignored error", "op", "Close", "err", err)`) {
+		t.Errorf("defer not rewritten to slog:\n%s", out)
+	}
+	if !strings.Contains(out, `"log/slog"`) {
+		t.Errorf("log/slog import missing:\n%s", out)
+	}
+}
+
+func TestRewriteFile_GoStmtSlog(t *testing.T) {
+	src := `package main
+func task() error { return nil }
+func main() {
+	go task()
+}
+`
+	injector, dstFile, astFile := setupInjectorTest(t, src)
+	injector.MainHandlerStrategy = "slog-error-exit"
+	pt := findPoint(t, astFile, "task")
+
+	if _, err := injector.RewriteFile(dstFile, astFile, []analysis.InjectionPoint{pt}); err != nil {
+		t.Fatal(err)
+	}
+	out := render(t, dstFile)
+	want := "slog.Error(\"operation failed\", \"op\", \"task\", \"err\", err)\n\t\t\tos.Exit(1)"
+	if !strings.Contains(out, want) {
+		t.Errorf("output missing %q:\n%s", want, out)
+	}
+
+	injector.Logger = "s.("
+	if _, err := injector.generateTerminalHandlerDST(pt, "task", "err"); err == nil {
+		t.Error("expected error for invalid logger expression")
+	}
+}
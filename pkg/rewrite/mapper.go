@@ -1,13 +1,14 @@
 package rewrite
 
 import (
+	"errors"
 	"fmt"
 	"go/ast"
 	"go/token"
 	"reflect"
 
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/dstmap"
 	"github.com/dave/dst"
-	"github.com/dave/dst/decorator"
 	"golang.org/x/tools/go/ast/astutil"
 )
 
@@ -22,7 +23,9 @@ type DstMapResult struct {
 
 // FindDstNode locates the equivalent 'dst.Node' for a specific 'ast.Node'.
 //
-// It uses the provided FileSet to compute the exact AST path enclosing the node,
+// Files decorated with dstmap.Decorate (or DecorateFile) are resolved through the node table of
+// the decorator, which stays exact after earlier rewrites inserted statements; nodes that were
+// replaced since are reported as an error. Otherwise it uses the provided FileSet to compute the exact AST path enclosing the node,
 // then traverses the 'dst.File' structure in an isomorphic manner (matching field names and list indices)
 // to find the corresponding node in the Concrete Syntax Tree.
 //
@@ -38,6 +41,13 @@ func FindDstNode(fset *token.FileSet, dstFile *dst.File, astFile *ast.File, targ
 	if targetNode == nil {
 		return DstMapResult{}, fmt.Errorf("targetNode cannot be nil")
 	}
+	n, parent, err := dstmap.Lookup(dstFile, targetNode)
+	if err == nil {
+		return DstMapResult{Node: n, Parent: parent}, nil
+	}
+	if !errors.Is(err, dstmap.ErrNotDecorated) {
+		return DstMapResult{}, err
+	}
 
 	// 1. Calculate the path in the AST.
 	path, _ := astutil.PathEnclosingInterval(astFile, targetNode.Pos(), targetNode.End())
@@ -172,6 +182,5 @@ func applyStep(node dst.Node, step traversalStep) (dst.Node, error) {
 
 // DecorateFile converts a standard Go AST file into a DST file, preserving comments/spacing.
 func DecorateFile(fset *token.FileSet, file *ast.File) (*dst.File, error) {
-	dec := decorator.NewDecorator(fset)
-	return dec.DecorateFile(file)
+	return dstmap.Decorate(fset, file)
 }
@@ -0,0 +1,84 @@
+package rewrite
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/filter"
+)
+
+// Rule actions.
+const (
+	// ActionReturn returns the error from the enclosing function using the rule (or global) template.
+	ActionReturn = "return"
+	// ActionLog logs the error and continues, without changing the enclosing function's signature.
+	ActionLog = "log"
+)
+
+// Rule selects the handling applied to errors returned by matching callees.
+type Rule struct {
+	// Symbol is a glob matched against the callee using filter.MatchSymbol
+	// (e.g. "(*os.File).Close", "database/sql.*").
+	Symbol string `yaml:"symbol"`
+	// Action is ActionReturn (default) or ActionLog.
+	Action string `yaml:"action,omitempty"`
+	// Template is the error template (or preset name) used by ActionReturn.
+	// Empty means the Injector's ErrorTemplate.
+	Template string `yaml:"template,omitempty"`
+}
+
+// ParseRule parses the CLI form of a rule: "SYMBOL=log" or "SYMBOL=TEMPLATE".
+//
+// s: The rule specification.
+func ParseRule(s string) (Rule, error) {
+	symbol, value, ok := strings.Cut(s, "=")
+	symbol, value = strings.TrimSpace(symbol), strings.TrimSpace(value)
+	if !ok || symbol == "" || value == "" {
+		return Rule{}, fmt.Errorf("invalid rule %q: expected SYMBOL=log or SYMBOL=TEMPLATE", s)
+	}
+	if value == ActionLog || value == ActionReturn {
+		return Rule{Symbol: symbol, Action: value}, nil
+	}
+	return Rule{Symbol: symbol, Action: ActionReturn, Template: value}, nil
+}
+
+// Validate checks the rule for unknown actions and malformed globs.
+func (r Rule) Validate() error {
+	switch r.Action {
+	case "", ActionReturn, ActionLog:
+	default:
+		return fmt.Errorf("rule %q: unknown action %q (expected %q or %q)", r.Symbol, r.Action, ActionReturn, ActionLog)
+	}
+	if r.Symbol == "" {
+		return fmt.Errorf("rule: symbol is required")
+	}
+	if _, err := filepath.Match(r.Symbol, ""); err != nil {
+		return fmt.Errorf("rule %q: %w", r.Symbol, err)
+	}
+	return nil
+}
+
+// RuleFor returns the first rule matching the callee of the point.
+// If no rule matches, the returned rule has ActionReturn and the Injector's template.
+//
+// point: The injection point being rewritten.
+func (i *Injector) RuleFor(point analysis.InjectionPoint) Rule {
+	if point.Pkg == nil {
+		point.Pkg = i.Pkg
+	}
+	fn := point.Callee()
+	for _, r := range i.Rules {
+		if fn != nil && filter.MatchSymbol(r.Symbol, fn) {
+			if r.Action == "" {
+				r.Action = ActionReturn
+			}
+			if r.Template == "" {
+				r.Template = i.ErrorTemplate
+			}
+			return r
+		}
+	}
+	return Rule{Action: ActionReturn, Template: i.ErrorTemplate}
+}
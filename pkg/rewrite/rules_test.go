@@ -0,0 +1,123 @@
+package rewrite
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Rule
+		wantErr bool
+	}{
+		{spec: "(*os.File).Close=log", want: Rule{Symbol: "(*os.File).Close", Action: ActionLog}},
+		{spec: "os.*=return", want: Rule{Symbol: "os.*", Action: ActionReturn}},
+		{
+			spec: `encoding/json.*={return-zero}, fmt.Errorf("decode: %w", err)`,
+			want: Rule{Symbol: "encoding/json.*", Action: ActionReturn, Template: `{return-zero}, fmt.Errorf("decode: %w", err)`},
+		},
+		{spec: "os.Remove", wantErr: true},
+		{spec: "=log", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRule(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRule(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRule(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestRule_Validate(t *testing.T) {
+	if err := (Rule{Symbol: "os.*", Action: "retry"}).Validate(); err == nil {
+		t.Error("expected error for unknown action")
+	}
+	if err := (Rule{Symbol: "[os"}).Validate(); err == nil {
+		t.Error("expected error for malformed glob")
+	}
+	if err := (Rule{Action: ActionLog}).Validate(); err == nil {
+		t.Error("expected error for missing symbol")
+	}
+	if err := (Rule{Symbol: "(*os.File).Close", Action: ActionLog}).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRewriteFile_Rules(t *testing.T) {
+	src := `package main
+
+import (
+	"os"
+	"strconv"
+)
+
+func run(f *os.File) (int, error) {
+	f.Sync()
+	os.Chdir("/")
+	_, _ = strconv.Atoi("1")
+	return 0, nil
+}
+`
+	injector, dstFile, astFile := setupInjectorTest(t, src)
+	injector.Rules = []Rule{
+		{Symbol: "(*os.File).Sync", Action: ActionLog},
+		{Symbol: "strconv.*", Template: `{return-zero}, fmt.Errorf("parse: %w", err)`},
+	}
+
+	points := []analysis.InjectionPoint{
+		findPoint(t, astFile, "Sync"),
+		findPoint(t, astFile, "Chdir"),
+		findPoint(t, astFile, "Atoi"),
+	}
+	if rule := injector.RuleFor(points[1]); rule.Action != ActionReturn || rule.Template != injector.ErrorTemplate {
+		t.Errorf("unmatched point should use the default rule, got %+v", rule)
+	}
+
+	applied, err := injector.RewriteFile(dstFile, astFile, points)
+	if err != nil || !applied {
+		t.Fatalf("RewriteFile failed: applied=%v err=%v", applied, err)
+	}
+	out := render(t, dstFile)
+
+	for _, want := range []string{
+		`log.Printf("ignored error in Sync: %v", err)`,
+		"if err := os.Chdir(\"/\"); err != nil {\n\t\treturn 0, err",
+		`return 0, fmt.Errorf("parse: %w", err)`,
+		`"log"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRewriteDefer_LogRule(t *testing.T) {
+	src := `package main
+
+import "os"
+
+func read(f *os.File) {
+	defer f.Close()
+}
+`
+	injector, dstFile, astFile := setupInjectorTest(t, src)
+	injector.Rules = []Rule{{Symbol: "(*os.File).Close", Action: ActionLog}}
+
+	applied, err := injector.RewriteDefers(dstFile, astFile)
+	if err != nil || !applied {
+		t.Fatalf("RewriteDefers failed: applied=%v err=%v", applied, err)
+	}
+	out := render(t, dstFile)
+	if !strings.Contains(out, "defer func() {\n\t\tif err := f.Close(); err != nil {\n\t\t\tlog.Printf(\"ignored error in Close: %v\", err)") {
+		t.Errorf("defer not rewritten to log:\n%s", out)
+	}
+	if strings.Contains(out, "func read(f *os.File) (err error)") {
+		t.Errorf("logged defer must not change the signature:\n%s", out)
+	}
+}
@@ -0,0 +1,163 @@
+package rewrite
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/astgen"
+	"github.com/dave/dst"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// generateUnshadowDST turns the ":=" assignment of point into "=", so that the error is stored in
+// the named result the new variable would shadow (see analysis.InjectionPoint.Shadows). The other
+// variables declared by the assignment are declared with var right before it, importing the
+// packages their types need.
+//
+// dstFile: The file containing the assignment.
+// point: The injection point (Shadows and Assign are set).
+// dstStmt: The DST node of the assignment.
+//
+// Returns nil if the assignment cannot be converted: a variable it declares is read by its right
+// hand side, the type of such a variable cannot be written in the file, or the assignment is the
+// init statement of an if, for or switch statement and needs declarations.
+func (i *Injector) generateUnshadowDST(dstFile *dst.File, point analysis.InjectionPoint, dstStmt dst.Stmt) ([]dst.Stmt, error) {
+	as, ok := dstStmt.(*dst.AssignStmt)
+	if !ok || point.Assign == nil || as.Tok != token.DEFINE || len(as.Lhs) != len(point.Assign.Lhs) {
+		return nil, nil
+	}
+
+	q := &fileQualifier{pkg: i.Pkg, file: point.File}
+	var decls []dst.Stmt
+	for _, lhs := range point.Assign.Lhs {
+		id, ok := lhs.(*ast.Ident)
+		if !ok {
+			return nil, nil
+		}
+		v, declared := i.Pkg.TypesInfo.Defs[id].(*types.Var)
+		if !declared || id.Name == "_" || id.Name == point.Shadows.Name() {
+			continue
+		}
+		if readsName(point.Assign.Rhs, id.Name) {
+			return nil, nil
+		}
+		typ, err := astgen.TypeExprDST(v.Type(), q.qualify)
+		if err != nil || q.conflict {
+			return nil, nil
+		}
+		decls = append(decls, &dst.DeclStmt{Decl: &dst.GenDecl{
+			Tok:   token.VAR,
+			Specs: []dst.Spec{&dst.ValueSpec{Names: []*dst.Ident{dst.NewIdent(id.Name)}, Type: typ}},
+		}})
+	}
+	if len(decls) > 0 && !inStmtList(point.File, point.Assign) {
+		return nil, nil
+	}
+
+	for _, path := range q.missing {
+		i.addImportDST(dstFile, path)
+	}
+	assign := dst.Clone(as).(*dst.AssignStmt)
+	assign.Tok = token.ASSIGN
+	if len(decls) > 0 {
+		// The declarations take over the comments of the statement.
+		assign.Decorations().Before = dst.NewLine
+		assign.Decorations().Start.Clear()
+		assign.Decorations().End.Clear()
+	}
+	return append(decls, assign), nil
+}
+
+// fileQualifier writes packages as they are imported by a file, collecting the packages the file
+// does not import yet.
+type fileQualifier struct {
+	pkg  *packages.Package
+	file *ast.File
+	// missing are the import paths to add to the file.
+	missing []string
+	// conflict is set when a missing package has the name of a package the file imports.
+	conflict bool
+}
+
+// qualify implements types.Qualifier.
+func (q *fileQualifier) qualify(p *types.Package) string {
+	if q.pkg.Types != nil && p.Path() == q.pkg.Types.Path() {
+		return ""
+	}
+	for _, spec := range q.file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || path != p.Path() {
+			continue
+		}
+		if spec.Name == nil {
+			return p.Name()
+		}
+		switch spec.Name.Name {
+		case "_":
+			continue
+		case ".":
+			return ""
+		}
+		return spec.Name.Name
+	}
+
+	if !slices.Contains(q.missing, p.Path()) {
+		for _, spec := range q.file.Imports {
+			if importName(q.pkg, spec) == p.Name() {
+				q.conflict = true
+			}
+		}
+		q.missing = append(q.missing, p.Path())
+	}
+	return p.Name()
+}
+
+// importName returns the name an import spec binds in the file.
+func importName(pkg *packages.Package, spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	path, _ := strconv.Unquote(spec.Path.Value)
+	if imp, ok := pkg.Imports[path]; ok && imp.Name != "" {
+		return imp.Name
+	}
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// readsName reports whether one of exprs refers to an identifier called name.
+func readsName(exprs []ast.Expr, name string) bool {
+	found := false
+	for _, e := range exprs {
+		ast.Inspect(e, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && id.Name == name {
+				found = true
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+// inStmtList reports whether stmt is an element of a statement list (rather than, e.g., the init
+// statement of an if statement).
+func inStmtList(file *ast.File, stmt ast.Stmt) bool {
+	path, _ := astutil.PathEnclosingInterval(file, stmt.Pos(), stmt.End())
+	// Expression statements share their extent with the expression, which comes first.
+	for k := 0; k+1 < len(path); k++ {
+		if path[k] != stmt {
+			continue
+		}
+		switch path[k+1].(type) {
+		case *ast.BlockStmt, *ast.CaseClause, *ast.CommClause:
+			return true
+		}
+		return false
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package rewrite
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestRewriteFile_Shadowed(t *testing.T) {
+	src := `package main
+
+import (
+	"os"
+	"strconv"
+)
+
+func sum(items []string) (n int, err error) {
+	for _, s := range items {
+		// parse the item
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			break
+		}
+		n += v
+	}
+	return
+}
+
+func stat(name string) (err error) {
+	if name != "" {
+		_, err := os.Stat(name)
+		if err != nil {
+			return nil
+		}
+	}
+	return
+}
+
+func first(s string) (err error) {
+	if s != "" {
+		if s, err := strconv.Atoi(s); err != nil {
+			println(s)
+		}
+	}
+	return
+}
+`
+	injector, dstFile, astFile := setupInjectorTest(t, src)
+	detected, err := analysis.Detect([]*packages.Package{injector.Pkg}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var points []analysis.InjectionPoint
+	for _, p := range detected {
+		if p.Shadows != nil {
+			points = append(points, p)
+		}
+	}
+	if len(points) != 3 {
+		t.Fatalf("expected 3 shadowing assignments, got %d", len(points))
+	}
+
+	applied, err := injector.RewriteFile(dstFile, astFile, points)
+	if err != nil || !applied {
+		t.Fatalf("RewriteFile failed: applied=%v err=%v", applied, err)
+	}
+	out := render(t, dstFile)
+	for _, want := range []string{
+		"// parse the item\n\t\tvar v int\n\t\tv, err = strconv.Atoi(s)\n",
+		"_, err = os.Stat(name)",
+		// s is read by the call, so it cannot be declared before it.
+		"if s, err := strconv.Atoi(s); err != nil {",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"go/printer"
 	"go/token"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/SamuelMarks/go-auto-err-handling/pkg/astgen"
@@ -15,6 +16,50 @@ import (
 	"github.com/dave/dst/decorator"
 )
 
+// TemplatePresets are named templates accepted in place of a literal error template.
+var TemplatePresets = map[string]string{
+	// "return" propagates the error unchanged (the default).
+	"return": "{return-zero}, err",
+	// "wrap" annotates the error with the called function.
+	"wrap": `{return-zero}, fmt.Errorf("{callee}: %w", err)`,
+	// "wrap-caller" annotates the error with the calling and called functions.
+	"wrap-caller": `{return-zero}, fmt.Errorf("{caller}: {callee}: %w", err)`,
+}
+
+// ExpandTemplate resolves a preset name (see TemplatePresets) to its template.
+// Any other value is returned unchanged.
+//
+// tmpl: The template or preset name.
+func ExpandTemplate(tmpl string) string {
+	if preset, ok := TemplatePresets[tmpl]; ok {
+		return preset
+	}
+	return tmpl
+}
+
+// TemplateVars describes the call site substituted into error templates.
+//
+// All values except FuncName are escaped for use inside an interpreted string literal
+// of a format string, i.e. quotes and backslashes are escaped and '%' is doubled.
+type TemplateVars struct {
+	// FuncName is the short name of the called function ({func_name}). Inserted verbatim.
+	FuncName string
+	// Callee is the called function, qualified by package or receiver type (e.g. "os.Open", "File.Close") ({callee}).
+	Callee string
+	// Caller is the enclosing function declaration, qualified by receiver type for methods (e.g. "Server.Start") ({caller}).
+	Caller string
+	// Pkg is the name of the package containing the call ({pkg}).
+	Pkg string
+	// Receiver is the receiver type name of the enclosing method, or empty ({receiver}).
+	Receiver string
+	// File is the base name of the file containing the call ({file}).
+	File string
+	// Line is the line of the call ({line}).
+	Line int
+	// Args is the source text of the call arguments (e.g. `"config.yaml", 0644`) ({args}).
+	Args string
+}
+
 // RenderTemplate transforms a template into a list of AST expressions (Legacy).
 func RenderTemplate(tmpl string, zeroExprs []ast.Expr, errName string, funcName string) ([]ast.Expr, []string, error) {
 	// Reusing logic via temporary source string is easiest but we have existing logic.
@@ -33,7 +78,7 @@ func RenderTemplate(tmpl string, zeroExprs []ast.Expr, errName string, funcName
 		zerosParts = append(zerosParts, buf.String())
 	}
 	zerosStr := strings.Join(zerosParts, ", ")
-	processed := applyTemplateReplacement(tmpl, zerosStr, funcName, errName)
+	processed := applyTemplateReplacement(tmpl, zerosStr, TemplateVars{FuncName: funcName, Callee: funcName}, errName)
 
 	dummySrc := fmt.Sprintf("package p; func _() { return %s }", processed)
 	file, err := parser.ParseFile(fset, "", dummySrc, 0)
@@ -70,43 +115,29 @@ func RenderTemplate(tmpl string, zeroExprs []ast.Expr, errName string, funcName
 
 // RenderTemplateDST transforms a template into a list of DST expressions (New).
 func RenderTemplateDST(tmpl string, zeroExprs []dst.Expr, errName string, funcName string) ([]dst.Expr, []string, error) {
+	return RenderTemplateVarsDST(tmpl, zeroExprs, errName, TemplateVars{FuncName: funcName, Callee: funcName})
+}
+
+// RenderTemplateVarsDST transforms a template into a list of DST expressions, substituting the
+// call-site placeholders ({callee}, {caller}, {pkg}, {receiver}, {file}, {line}, {args}) from vars.
+//
+// tmpl: The template (or preset name). Defaults to "{return-zero}, err".
+// zeroExprs: The zero values for the non-error results.
+// errName: The name of the error variable, substituted for `err`.
+// vars: The call-site context.
+//
+// Returns the expressions and the package identifiers referenced by them (e.g. "fmt").
+func RenderTemplateVarsDST(tmpl string, zeroExprs []dst.Expr, errName string, vars TemplateVars) ([]dst.Expr, []string, error) {
+	tmpl = ExpandTemplate(tmpl)
 	if tmpl == "" {
 		tmpl = "{return-zero}, err"
 	}
 
-	var zerosParts []string
-	restorer := decorator.NewRestorer()
-	for _, z := range zeroExprs {
-		var buf bytes.Buffer
-		// Wrap z in a dummy file to satisfy Restorer.Fprint strict check
-		file := &dst.File{
-			Name: dst.NewIdent("p"),
-			Decls: []dst.Decl{
-				&dst.GenDecl{
-					Tok: token.VAR,
-					Specs: []dst.Spec{
-						&dst.ValueSpec{
-							Names:  []*dst.Ident{dst.NewIdent("_")},
-							Values: []dst.Expr{z},
-						},
-					},
-				},
-			},
-		}
-		if err := restorer.Fprint(&buf, file); err != nil {
-			return nil, nil, fmt.Errorf("failed to render zero expr: %w", err)
-		}
-		// Extract cleaned string from "package p\n\nvar _ = expr"
-		s := buf.String()
-		s = strings.TrimSpace(s)
-		s = strings.TrimPrefix(s, "package p")
-		s = strings.TrimSpace(s)
-		s = strings.TrimPrefix(s, "var _ =")
-		s = strings.TrimSpace(s)
-		zerosParts = append(zerosParts, s)
+	zerosStr, err := renderExprsDST(zeroExprs)
+	if err != nil {
+		return nil, nil, err
 	}
-	zerosStr := strings.Join(zerosParts, ", ")
-	processed := applyTemplateReplacement(tmpl, zerosStr, funcName, errName)
+	processed := applyTemplateReplacement(tmpl, zerosStr, vars, errName)
 
 	dummySrc := fmt.Sprintf("package p; func _() { return %s }", processed)
 	// decorator.Parse accepts just source string
@@ -142,8 +173,46 @@ func RenderTemplateDST(tmpl string, zeroExprs []dst.Expr, errName string, funcNa
 	return returnResults, uniqueStrings(importsFound), nil
 }
 
-func applyTemplateReplacement(tmpl, zerosStr, funcName, errName string) string {
-	processed := tmpl
+// renderExprsDST renders exprs as a comma separated list, as substituted for {return-zero}.
+func renderExprsDST(exprs []dst.Expr) (string, error) {
+	var parts []string
+	restorer := decorator.NewRestorer()
+	for _, z := range exprs {
+		var buf bytes.Buffer
+		// Wrap z in a dummy file to satisfy Restorer.Fprint strict check
+		file := &dst.File{
+			Name: dst.NewIdent("p"),
+			Decls: []dst.Decl{
+				&dst.GenDecl{
+					Tok: token.VAR,
+					Specs: []dst.Spec{
+						&dst.ValueSpec{
+							Names:  []*dst.Ident{dst.NewIdent("_")},
+							Values: []dst.Expr{z},
+						},
+					},
+				},
+			},
+		}
+		if err := restorer.Fprint(&buf, file); err != nil {
+			return "", fmt.Errorf("failed to render zero expr: %w", err)
+		}
+		// Extract cleaned string from "package p\n\nvar _ = expr"
+		s := buf.String()
+		s = strings.TrimSpace(s)
+		s = strings.TrimPrefix(s, "package p")
+		s = strings.TrimSpace(s)
+		s = strings.TrimPrefix(s, "var _ =")
+		s = strings.TrimSpace(s)
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, ", "), nil
+}
+
+func applyTemplateReplacement(tmpl, zerosStr string, vars TemplateVars, errName string) string {
+	// Rename the error variable first so substituted values (e.g. {args}) are left untouched.
+	reErr := regexp.MustCompile(`\berr\b`)
+	processed := reErr.ReplaceAllString(tmpl, errName)
 	if zerosStr == "" {
 		reTrailing := regexp.MustCompile(`\{return-zero\}\s*,\s*`)
 		processed = reTrailing.ReplaceAllString(processed, "")
@@ -153,12 +222,25 @@ func applyTemplateReplacement(tmpl, zerosStr, funcName, errName string) string {
 	} else {
 		processed = strings.ReplaceAll(processed, "{return-zero}", zerosStr)
 	}
-	processed = strings.ReplaceAll(processed, "{func_name}", funcName)
-	reErr := regexp.MustCompile(`\berr\b`)
-	processed = reErr.ReplaceAllString(processed, errName)
+	processed = strings.NewReplacer(
+		"{func_name}", vars.FuncName,
+		"{callee}", escapeTemplateValue(vars.Callee),
+		"{caller}", escapeTemplateValue(vars.Caller),
+		"{pkg}", escapeTemplateValue(vars.Pkg),
+		"{receiver}", escapeTemplateValue(vars.Receiver),
+		"{file}", escapeTemplateValue(vars.File),
+		"{line}", strconv.Itoa(vars.Line),
+		"{args}", escapeTemplateValue(vars.Args),
+	).Replace(processed)
 	return processed
 }
 
+// escapeTemplateValue escapes s for use inside a double quoted format string.
+func escapeTemplateValue(s string) string {
+	quoted := strconv.Quote(s)
+	return strings.ReplaceAll(quoted[1:len(quoted)-1], "%", "%%")
+}
+
 func uniqueStrings(s []string) []string {
 	seen := make(map[string]struct{})
 	var out []string
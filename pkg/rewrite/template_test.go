@@ -155,3 +155,87 @@ func equalStrings(a, b []string) bool {
 	}
 	return true
 }
+
+func TestRenderTemplateVarsDST(t *testing.T) {
+	zeroStr, _ := astgen.ZeroExprDST(types.Typ[types.String], astgen.ZeroCtx{})
+	vars := TemplateVars{
+		FuncName: "Open",
+		Callee:   "os.Open",
+		Caller:   "Server.Load",
+		Pkg:      "srv",
+		Receiver: "Server",
+		File:     "load.go",
+		Line:     42,
+		Args:     `"100%.txt", err`,
+	}
+
+	tests := []struct {
+		name     string
+		tmpl     string
+		expected string
+	}{
+		{
+			name:     "PresetWrapCaller",
+			tmpl:     "wrap-caller",
+			expected: `"", fmt.Errorf("Server.Load: os.Open: %w", e)`,
+		},
+		{
+			name:     "Location",
+			tmpl:     `{return-zero}, fmt.Errorf("{pkg}/{file}:{line} ({receiver}): %w", err)`,
+			expected: `"", fmt.Errorf("srv/load.go:42 (Server): %w", e)`,
+		},
+		{
+			// Values are escaped for format strings; `err` inside {args} is not renamed.
+			name:     "ArgsEscaped",
+			tmpl:     `{return-zero}, fmt.Errorf("{callee}({args}): %w", err)`,
+			expected: `"", fmt.Errorf("os.Open(\"100%%.txt\", err): %w", e)`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exprs, imports, err := RenderTemplateVarsDST(tt.tmpl, []dst.Expr{dst.Clone(zeroStr).(dst.Expr)}, "e", vars)
+			if err != nil {
+				t.Fatalf("RenderTemplateVarsDST() error = %v", err)
+			}
+			if !equalStrings(imports, []string{"fmt"}) {
+				t.Errorf("Imports mismatch. Got %v", imports)
+			}
+			if got := renderExprs(t, exprs); got != tt.expected {
+				t.Errorf("Rendered Code mismatch.\nGot:  %s\nWant: %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	if got := ExpandTemplate("wrap"); got != TemplatePresets["wrap"] {
+		t.Errorf("preset not expanded: %q", got)
+	}
+	custom := "{return-zero}, errors.Join(err)"
+	if got := ExpandTemplate(custom); got != custom {
+		t.Errorf("custom template changed: %q", got)
+	}
+}
+
+// renderExprs prints a list of DST expressions separated by commas.
+func renderExprs(t *testing.T, exprs []dst.Expr) string {
+	var parts []string
+	for _, e := range exprs {
+		file := &dst.File{
+			Name: dst.NewIdent("p"),
+			Decls: []dst.Decl{&dst.GenDecl{
+				Tok:   token.VAR,
+				Specs: []dst.Spec{&dst.ValueSpec{Names: []*dst.Ident{dst.NewIdent("_")}, Values: []dst.Expr{e}}},
+			}},
+		}
+		var buf bytes.Buffer
+		if err := decorator.NewRestorer().Fprint(&buf, file); err != nil {
+			t.Fatalf("Fprint failed: %v", err)
+		}
+		s := strings.TrimSpace(buf.String())
+		s = strings.TrimSpace(strings.TrimPrefix(s, "package p"))
+		parts = append(parts, strings.TrimSpace(strings.TrimPrefix(s, "var _ =")))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,195 @@
+package rewrite
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/filter"
+	"github.com/dave/dst"
+	"golang.org/x/tools/go/packages"
+)
+
+// testifyRequire is the import path of the testify assertions that stop the test on failure.
+const testifyRequire = "github.com/stretchr/testify/require"
+
+// testingParamTypes are the parameter types of tests, benchmarks, fuzz targets and their helpers.
+var testingParamTypes = map[string]bool{
+	"*testing.T": true,
+	"*testing.B": true,
+	"*testing.F": true,
+	"testing.TB": true,
+}
+
+// TestingParam returns the name of the first parameter of sig of type *testing.T, *testing.B,
+// *testing.F or testing.TB, which can stop the test.
+//
+// sig: The signature of a function or literal.
+//
+// Returns "" if there is no such parameter, or if it is unnamed.
+func TestingParam(sig *types.Signature) string {
+	if sig == nil {
+		return ""
+	}
+	for k := 0; k < sig.Params().Len(); k++ {
+		p := sig.Params().At(k)
+		if testingParamTypes[types.TypeString(p.Type(), nil)] {
+			if p.Name() == "_" {
+				return ""
+			}
+			return p.Name()
+		}
+	}
+	return ""
+}
+
+// HandleTest handles point in place in a test, benchmark, fuzz target or test helper, stopping the
+// test instead of returning the error:
+//
+//	if err := os.Remove(path); err != nil {
+//		t.Fatalf("os.Remove: %v", err)
+//	}
+//
+// Files already importing testify's require package use it instead:
+//
+//	require.NoError(t, os.Remove(path))
+//
+// Helpers (functions with a testing parameter which are not tests themselves) gaining a check call
+// t.Helper() first, so that failures are reported at the line of their caller.
+//
+// dstFile: The DST of the file.
+// astFile: The AST of the file.
+// point: The injection point.
+//
+// Returns false if the point cannot be handled: the enclosing function returns an error or has no
+// named testing parameter (see TestingParam), or the point is not a plain call or assignment.
+func (i *Injector) HandleTest(dstFile *dst.File, astFile *ast.File, point analysis.InjectionPoint) (bool, error) {
+	if !inPlaceCandidate(point) {
+		return false, nil
+	}
+	ctx := i.getEnclosingContext(point)
+	param := TestingParam(ctx.sig)
+	if param == "" || hasErrorResult(ctx.sig) {
+		return false, nil
+	}
+
+	msg := strconv.Quote(strings.ReplaceAll(i.templateVars(point).Callee, "%", "%%") + ": %v")
+	fatal := func(errName string) ([]dst.Stmt, error) {
+		return parseStmtsDST(fmt.Sprintf("%s.Fatalf(%s, %s)", param, msg, errName))
+	}
+	gen := func(point analysis.InjectionPoint, dstStmt dst.Stmt) ([]dst.Stmt, error) {
+		return i.generateInPlaceDST(dstFile, point, dstStmt, fatal)
+	}
+	if req := requireName(i.Pkg, astFile); req != "" {
+		gen = func(point analysis.InjectionPoint, dstStmt dst.Stmt) ([]dst.Stmt, error) {
+			stmts, err := i.generateInPlaceDST(dstFile, point, dstStmt, func(name string) ([]dst.Stmt, error) {
+				return parseStmtsDST(fmt.Sprintf("%s.NoError(%s, %s)", req, param, name))
+			})
+			if err != nil {
+				return nil, err
+			}
+			if flat := flattenCheck(stmts); flat != nil {
+				return flat, nil
+			}
+			return i.generateInPlaceDST(dstFile, point, dstStmt, fatal)
+		}
+	}
+
+	applied, err := i.handleInPlace(dstFile, astFile, point, gen)
+	if applied && ctx.decl != nil && !filter.IsTestHandler(ctx.decl) {
+		i.addHelperCallDST(dstFile, astFile, ctx.decl, param)
+	}
+	return applied, err
+}
+
+// inPlaceCandidate reports whether point is a plain call, an assignment or an error held in a
+// variable, which can be checked where it occurs.
+func inPlaceCandidate(point analysis.InjectionPoint) bool {
+	if point.Stmt == nil || point.Call == nil || point.Shadows != nil || InInitStmt(point) {
+		return false
+	}
+	if point.ErrIdent == nil && point.Assign == nil {
+		_, ok := point.Stmt.(*ast.ExprStmt)
+		return ok
+	}
+	return true
+}
+
+// requireName returns the name of the testify require package in file, or "" if it is not imported.
+func requireName(pkg *packages.Package, file *ast.File) string {
+	for _, spec := range file.Imports {
+		if path, _ := strconv.Unquote(spec.Path.Value); path == testifyRequire {
+			if name := importName(pkg, spec); name != "_" && name != "." {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// flattenCheck replaces the check generated by generateInPlaceDST with its body, for assertions
+// which check the error themselves (require.NoError). A call returning nothing but the error becomes
+// the argument of the assertion.
+//
+// stmts: The generated statements, ending with the check.
+//
+// Returns nil if the check has another shape. In particular, moving "_, err := f()" out of the
+// check could declare err twice in its block.
+func flattenCheck(stmts []dst.Stmt) []dst.Stmt {
+	n := len(stmts) - 1
+	check, ok := stmts[n].(*dst.IfStmt)
+	if !ok || len(check.Body.List) != 1 {
+		return nil
+	}
+	assert := check.Body.List[0]
+	flat := stmts[:n:n]
+	if check.Init == nil {
+		return append(flat, assert)
+	}
+	as, ok := check.Init.(*dst.AssignStmt)
+	if !ok {
+		return nil
+	}
+	call, ok := assert.(*dst.ExprStmt).X.(*dst.CallExpr)
+	if !ok || len(as.Lhs) != 1 || len(as.Rhs) != 1 {
+		return nil
+	}
+	call.Args[len(call.Args)-1] = as.Rhs[0]
+	return append(flat, assert)
+}
+
+// addHelperCallDST calls param.Helper() first in decl, unless it already does.
+func (i *Injector) addHelperCallDST(dstFile *dst.File, astFile *ast.File, decl *ast.FuncDecl, param string) {
+	res, err := FindDstNode(i.Fset, dstFile, astFile, decl)
+	if err != nil {
+		return
+	}
+	fn, ok := res.Node.(*dst.FuncDecl)
+	if !ok || fn.Body == nil {
+		return
+	}
+	found := false
+	dst.Inspect(fn.Body, func(n dst.Node) bool {
+		if _, ok := n.(*dst.FuncLit); ok || found {
+			return false
+		}
+		if call, ok := n.(*dst.CallExpr); ok {
+			if sel, ok := call.Fun.(*dst.SelectorExpr); ok && sel.Sel.Name == "Helper" {
+				if id, ok := sel.X.(*dst.Ident); ok && id.Name == param {
+					found = true
+				}
+			}
+		}
+		return !found
+	})
+	if found {
+		return
+	}
+	helper := &dst.ExprStmt{X: &dst.CallExpr{
+		Fun: &dst.SelectorExpr{X: dst.NewIdent(param), Sel: dst.NewIdent("Helper")},
+	}}
+	fn.Body.List = append([]dst.Stmt{helper}, fn.Body.List...)
+}
@@ -0,0 +1,262 @@
+package rewrite
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+)
+
+func TestTestingParam(t *testing.T) {
+	src := `package main
+
+import "testing"
+
+func TestA(t *testing.T)                 {}
+func BenchmarkA(b *testing.B)            {}
+func FuzzA(f *testing.F)                 {}
+func helper(name string, tb testing.TB)  {}
+func blank(_ *testing.T)                 {}
+func plain(name string)                  {}
+func TestMain(m *testing.M)              {}
+`
+	injector, _, astFile := setupInjectorTest(t, src)
+	want := map[string]string{
+		"TestA":      "t",
+		"BenchmarkA": "b",
+		"FuzzA":      "f",
+		"helper":     "tb",
+		"blank":      "",
+		"plain":      "",
+		"TestMain":   "",
+	}
+	for _, decl := range astFile.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		sig := injector.Pkg.TypesInfo.Defs[fn.Name].Type().(*types.Signature)
+		if got := TestingParam(sig); got != want[fn.Name.Name] {
+			t.Errorf("TestingParam(%s) = %q, want %q", fn.Name.Name, got, want[fn.Name.Name])
+		}
+	}
+	if got := TestingParam(nil); got != "" {
+		t.Errorf("TestingParam(nil) = %q", got)
+	}
+}
+
+func TestHandleTest(t *testing.T) {
+	src := `package main
+
+import (
+	"os"
+	"testing"
+)
+
+func load() (string, error) { return "", nil }
+
+func TestA(t *testing.T) {
+	os.Remove("x")
+	v, _ := load()
+	_ = v
+}
+
+func setup(tb testing.TB, dir string) {
+	os.MkdirAll(dir, 0o755)
+}
+
+func plain() {
+	os.Chdir("y")
+}
+`
+	injector, dstFile, astFile := setupInjectorTest(t, src)
+	for _, name := range []string{"MkdirAll", "load", "Remove"} {
+		applied, err := injector.HandleTest(dstFile, astFile, findPoint(t, astFile, name))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !applied {
+			t.Errorf("expected the call of %s to be handled", name)
+		}
+	}
+	applied, err := injector.HandleTest(dstFile, astFile, findPoint(t, astFile, "Chdir"))
+	if err != nil || applied {
+		t.Errorf("expected no handling outside tests, got applied=%v err=%v", applied, err)
+	}
+
+	out := render(t, dstFile)
+	for _, want := range []string{
+		"func TestA(t *testing.T) {\n\tif err := os.Remove(\"x\"); err != nil {\n\t\tt.Fatalf(\"os.Remove: %v\", err)\n\t}",
+		"v, err := load()\n\tif err != nil {\n\t\tt.Fatalf(\"load: %v\", err)\n\t}",
+		"func setup(tb testing.TB, dir string) {\n\ttb.Helper()\n\tif err := os.MkdirAll(dir, 0o755); err != nil {\n\t\ttb.Fatalf(\"os.MkdirAll: %v\", err)\n\t}",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "Helper()") != 1 {
+		t.Errorf("expected t.Helper() in the helper only:\n%s", out)
+	}
+}
+
+// requireImporter resolves testify's require package to a stub, and other imports with the default
+// importer.
+type requireImporter struct {
+	t *testing.T
+}
+
+func (imp requireImporter) Import(path string) (*types.Package, error) {
+	if path != testifyRequire {
+		return importer.Default().Import(path)
+	}
+	src := `package require
+
+type TestingT interface {
+	Errorf(format string, args ...any)
+	FailNow()
+}
+
+func NoError(t TestingT, err error, msgAndArgs ...any) {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "require.go", src, 0)
+	if err != nil {
+		imp.t.Fatal(err)
+	}
+	return (&types.Config{}).Check(path, fset, []*ast.File{f}, nil)
+}
+
+func TestHandleTest_Require(t *testing.T) {
+	src := `package main
+
+import (
+	"os"
+	"testing"
+
+	must "github.com/stretchr/testify/require"
+)
+
+func load() (string, error) { return "", nil }
+
+func pair() (int, string, error) { return 0, "", nil }
+
+func TestA(t *testing.T) {
+	os.Remove("x")
+	v, _ := load()
+	_ = v
+	pair()
+	must.NoError(t, nil)
+}
+`
+	injector, dstFile, astFile := setupInjectorTestImporter(t, src, requireImporter{t})
+	// Bottom-up, so that the statements inserted do not shift the statements left to handle.
+	for _, name := range []string{"pair", "load", "Remove"} {
+		applied, err := injector.HandleTest(dstFile, astFile, findPoint(t, astFile, name))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !applied {
+			t.Errorf("expected the call of %s to be handled", name)
+		}
+	}
+
+	out := render(t, dstFile)
+	for _, want := range []string{
+		"must.NoError(t, os.Remove(\"x\"))",
+		"v, err := load()\n\tmust.NoError(t, err)",
+		// The assignment cannot leave the check without risking a second declaration of err.
+		"if _, _, err := pair(); err != nil {\n\t\tt.Fatalf(\"pair: %v\", err)\n\t}",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestHandleTest_InitStmt(t *testing.T) {
+	src := `package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func load() (string, error) { return "", nil }
+
+func TestA(t *testing.T) {
+	if v, _ := load(); v == "" {
+		t.Log("empty")
+	}
+}
+
+func handle(w http.ResponseWriter, r *http.Request) {
+	switch v, _ := load(); v {
+	case "":
+	}
+}
+
+func run() {
+	for v, _ := load(); v != ""; v, _ = load() {
+	}
+}
+`
+	injector, dstFile, astFile := setupInjectorTest(t, src)
+	var points []analysis.InjectionPoint
+	ast.Inspect(astFile, func(n ast.Node) bool {
+		var stmts []ast.Stmt
+		switch s := n.(type) {
+		case *ast.IfStmt:
+			stmts = append(stmts, s.Init)
+		case *ast.SwitchStmt:
+			stmts = append(stmts, s.Init)
+		case *ast.ForStmt:
+			stmts = append(stmts, s.Init, s.Post)
+		}
+		for _, stmt := range stmts {
+			assign := stmt.(*ast.AssignStmt)
+			points = append(points, analysis.InjectionPoint{
+				Pkg: injector.Pkg, File: astFile, Stmt: stmt, Assign: assign, Call: assign.Rhs[0].(*ast.CallExpr), Pos: stmt.Pos(),
+			})
+		}
+		return true
+	})
+	if len(points) != 4 {
+		t.Fatalf("expected 4 init and post statements, got %d", len(points))
+	}
+
+	cb := injector.CallbackFor(injector.getEnclosingContext(points[1]).sig)
+	if cb == nil {
+		t.Fatal("expected the net/http handler shape")
+	}
+	for _, pt := range points {
+		if !InInitStmt(pt) {
+			t.Errorf("expected %s to be an init statement", injector.Fset.Position(pt.Pos))
+		}
+		// The check cannot take the place of the statement.
+		if applied, err := injector.HandleTest(dstFile, astFile, pt); err != nil || applied {
+			t.Errorf("HandleTest: applied=%v err=%v", applied, err)
+		}
+		if applied, err := injector.HandleCallback(dstFile, astFile, pt, *cb); err != nil || applied {
+			t.Errorf("HandleCallback: applied=%v err=%v", applied, err)
+		}
+		if applied, err := injector.LogFallback(dstFile, astFile, pt); err != nil || applied {
+			t.Errorf("LogFallback: applied=%v err=%v", applied, err)
+		}
+		if applied, err := injector.RewriteFile(dstFile, astFile, []analysis.InjectionPoint{pt}); err != nil || applied {
+			t.Errorf("RewriteFile: applied=%v err=%v", applied, err)
+		}
+	}
+	if InInitStmt(findPoint(t, astFile, "Log")) {
+		t.Error("expected a statement of a block not to be an init statement")
+	}
+
+	out := render(t, dstFile)
+	if out != src {
+		t.Errorf("expected the file to be unchanged:\n%s", out)
+	}
+}
@@ -0,0 +1,146 @@
+package runner
+
+import (
+	"fmt"
+	"go/ast"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/report"
+)
+
+// fingerprints computes the position independent fingerprints of findings (see
+// report.Fingerprint). The occurrence index counts findings with the same package, function,
+// callee and shape in source order.
+//
+// findings: The findings (see summarize).
+//
+// Returns the fingerprints, in the order of findings.
+func fingerprints(findings []checkFinding) []report.Fingerprint {
+	wd, _ := os.Getwd()
+
+	order := make([]int, len(findings))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		fa, fb := findings[order[a]], findings[order[b]]
+		if fa.File != fb.File {
+			return fa.File < fb.File
+		}
+		if fa.Line != fb.Line {
+			return fa.Line < fb.Line
+		}
+		return fa.Column < fb.Column
+	})
+
+	out := make([]report.Fingerprint, len(findings))
+	counts := make(map[report.Fingerprint]int)
+	for _, i := range order {
+		f := findings[i]
+		fp := report.Fingerprint{
+			Package:  f.Package,
+			Function: f.Function,
+			Callee:   f.Callee,
+			Shape:    f.Shape,
+		}
+		key := fp
+		fp.Index = counts[key]
+		counts[key]++
+		fp.Location = fmt.Sprintf("%s:%d", relPath(wd, f.File), f.Line)
+		out[i] = fp
+	}
+	return out
+}
+
+// pointFingerprint returns the fingerprint of p without its occurrence index and location.
+func pointFingerprint(p analysis.InjectionPoint) report.Fingerprint {
+	return report.Fingerprint{
+		Package:  p.Pkg.PkgPath,
+		Function: funcNameAt(p.File, p.Call.Pos()),
+		Callee:   p.CalleeName(),
+		Shape:    pointShape(p),
+	}
+}
+
+// pointShape names the kind of statement ignoring the error of p (see report.Fingerprint.Shape).
+func pointShape(p analysis.InjectionPoint) string {
+	if p.ErrIdent != nil {
+		return "unchecked"
+	}
+	if p.Shadows != nil {
+		return "shadow"
+	}
+	return stmtShape(p.Stmt)
+}
+
+// stmtShape names the kind of statement ignoring an error (see report.Fingerprint.Shape).
+func stmtShape(stmt ast.Stmt) string {
+	switch stmt.(type) {
+	case nil:
+		return "decl"
+	case *ast.AssignStmt:
+		return "assign"
+	case *ast.DeferStmt:
+		return "defer"
+	case *ast.GoStmt:
+		return "go"
+	case *ast.IfStmt:
+		return "if"
+	case *ast.SwitchStmt:
+		return "switch"
+	default:
+		return "call"
+	}
+}
+
+// writeBaseline writes the fingerprints of findings to path.
+func writeBaseline(path string, findings []checkFinding) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create baseline: %w", err)
+	}
+	if err := report.NewBaseline(fingerprints(findings)).Write(f); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write baseline: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write baseline: %w", err)
+	}
+	log.Printf("Wrote baseline with %d findings to %s.", len(findings), path)
+	return nil
+}
+
+// applyBaseline drops the findings recorded in the baseline at path and logs the baseline entries
+// that are fixed, so that the baseline can be shrunk.
+//
+// path: The baseline file.
+// findings: The findings (see summarize).
+// rep: The reporter receiving the fixed entries, or nil.
+//
+// Returns the findings that are not in the baseline.
+func applyBaseline(path string, findings []checkFinding, rep *report.Reporter) ([]checkFinding, error) {
+	baseline, err := report.ReadBaseline(path)
+	if err != nil {
+		return nil, err
+	}
+	fresh, fixed := baseline.Compare(fingerprints(findings))
+
+	out := make([]checkFinding, 0, len(fresh))
+	for _, i := range fresh {
+		out = append(out, findings[i])
+	}
+	log.Printf("Baseline %s: %d known findings suppressed, %d new.", path, len(findings)-len(out), len(out))
+	if len(fixed) > 0 {
+		log.Printf("%d baseline findings are fixed; shrink the baseline with --write-baseline:", len(fixed))
+		for _, f := range fixed {
+			log.Printf("  fixed: %s", f)
+		}
+		if rep != nil {
+			rep.AddBaselineFixed(fixed)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,168 @@
+package runner
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/report"
+)
+
+// TestRun_Baseline verifies that baselined findings survive unrelated edits, that only new
+// findings fail the check, and that fixed entries are reported.
+func TestRun_Baseline(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, map[string]string{
+		"go.mod": "module example.com/baseline\ngo 1.22\n",
+		"main.go": `package main
+
+import "os"
+
+func cleanup() {
+	os.Remove("a")
+	os.Remove("b")
+}
+
+func main() {
+	os.Chdir("/")
+}
+`,
+	})
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		Paths:                []string{"."},
+	}
+	write := opts
+	write.WriteBaseline = "baseline.json"
+	if err := Run(write); err != nil {
+		t.Fatalf("writing the baseline failed: %v", err)
+	}
+	b, err := report.ReadBaseline(filepath.Join(tmpDir, "baseline.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Findings) != 3 || b.Findings[1].Function != "cleanup" || b.Findings[1].Index != 1 {
+		t.Fatalf("unexpected baseline: %+v", b.Findings)
+	}
+
+	// Shift every line, fix os.Chdir and add a new finding.
+	_ = os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte(`package main
+
+import "os"
+
+// cleanup removes the files.
+func cleanup() {
+	os.Remove("a")
+	os.Remove("b")
+	os.Remove("c")
+}
+
+func main() {
+	if err := os.Chdir("/"); err != nil {
+		panic(err)
+	}
+}
+`), 0644)
+
+	check := opts
+	check.Check = true
+	check.Baseline = "baseline.json"
+	check.Reporter = report.New()
+	err = Run(check)
+	if err == nil || !strings.Contains(err.Error(), "1 unhandled errors") {
+		t.Errorf("only the new finding should fail the check, got %v", err)
+	}
+	fixed := check.Reporter.GetData().BaselineFixed
+	if len(fixed) != 1 || fixed[0].Callee != "os.Chdir" {
+		t.Errorf("expected the os.Chdir entry to be fixed, got %+v", fixed)
+	}
+}
+
+func TestStmtShape(t *testing.T) {
+	if got := stmtShape(nil); got != "decl" {
+		t.Errorf("stmtShape(nil) = %q, want decl", got)
+	}
+	p := analysis.InjectionPoint{Stmt: &ast.AssignStmt{}, ErrIdent: ast.NewIdent("err")}
+	if got := pointShape(p); got != "unchecked" {
+		t.Errorf("pointShape(unchecked assignment) = %q, want unchecked", got)
+	}
+	p = analysis.InjectionPoint{Stmt: &ast.AssignStmt{}, Shadows: types.NewVar(token.NoPos, nil, "err", nil)}
+	if got := pointShape(p); got != "shadow" {
+		t.Errorf("pointShape(shadowing assignment) = %q, want shadow", got)
+	}
+}
+
+// TestRun_CheckTestVariants verifies that the calls of a package with tests, reported by both its
+// regular and test variants, are counted, reported and fingerprinted once.
+func TestRun_CheckTestVariants(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, map[string]string{
+		"go.mod": "module example.com/variants\ngo 1.22\n",
+		"lib.go": `package lib
+
+import "os"
+
+func Clean() {
+	os.Remove("a")
+}
+`,
+		"lib_test.go": `package lib
+
+import "testing"
+
+func TestClean(t *testing.T) {
+	Clean()
+}
+`,
+	})
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	for _, cacheDir := range []string{"", filepath.Join(tmpDir, "cache")} {
+		opts := Options{
+			EnablePreexistingErr: true,
+			EnableNonExistingErr: true,
+			Paths:                []string{"./..."},
+			CacheDir:             cacheDir,
+		}
+		write := opts
+		write.WriteBaseline = "baseline.json"
+		if err := Run(write); err != nil {
+			t.Fatalf("writing the baseline failed: %v", err)
+		}
+		b, err := report.ReadBaseline(filepath.Join(tmpDir, "baseline.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b.Findings) != 1 || b.Findings[0].Index != 0 {
+			t.Errorf("cache %q: expected a single baseline entry, got %+v", cacheDir, b.Findings)
+		}
+
+		check := opts
+		check.Check = true
+		check.Format = FormatSARIF
+		check.Output = "report.sarif"
+		err = Run(check)
+		if err == nil || !strings.Contains(err.Error(), "check failed: 1 unhandled errors") {
+			t.Errorf("cache %q: expected a single finding, got %v", cacheDir, err)
+		}
+		data, _ := os.ReadFile(filepath.Join(tmpDir, "report.sarif"))
+		if n := strings.Count(string(data), `"ruleId"`); n != 1 {
+			t.Errorf("cache %q: expected a single SARIF result, got %d:\n%s", cacheDir, n, data)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package runner
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun_Callbacks(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, map[string]string{
+		"go.mod": "module example.com/web\ngo 1.22\n",
+		"web.go": `package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+)
+
+type Item struct{ Name string }
+
+func save(it Item) error { return nil }
+
+func load(name string) (Item, error) { return Item{Name: name}, nil }
+
+func handleSave(w http.ResponseWriter, r *http.Request) {
+	var it Item
+	json.NewDecoder(r.Body).Decode(&it)
+	save(it)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type Server struct{}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	it, _ := load(r.URL.Path)
+	json.NewEncoder(w).Encode(it)
+}
+
+func Routes() {
+	http.HandleFunc("/save", handleSave)
+	http.HandleFunc("/rm", func(w http.ResponseWriter, _ *http.Request) {
+		os.Remove("x")
+	})
+	http.Handle("/", &Server{})
+}
+`,
+		"web_test.go": `package web
+
+import "testing"
+
+func TestItems(t *testing.T) {
+	t.Run("save", func(t *testing.T) {
+		save(Item{})
+	})
+}
+`,
+	})
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		EnableTestRefactor:   true,
+		Paths:                []string{"./..."},
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	web, _ := os.ReadFile(filepath.Join(tmpDir, "web.go"))
+	for _, want := range []string{
+		"func handleSave(w http.ResponseWriter, r *http.Request) {",
+		"if err := save(it); err != nil {\n\t\thttp.Error(w, err.Error(), http.StatusInternalServerError)\n\t\treturn\n\t}",
+		"it, err := load(r.URL.Path)\n\tif err != nil {\n\t\thttp.Error(w, err.Error(), http.StatusInternalServerError)\n\t\treturn\n\t}",
+		"if err := os.Remove(\"x\"); err != nil {\n\t\t\thttp.Error(w, err.Error(), http.StatusInternalServerError)\n\t\t\treturn\n\t\t}",
+		// The response has started; the error of the write is logged.
+		"if err := json.NewEncoder(w).Encode(it); err != nil {\n\t\tlog.Printf(",
+		"func Routes() {",
+	} {
+		if !strings.Contains(string(web), want) {
+			t.Errorf("web.go missing %q:\n%s", want, web)
+		}
+	}
+	if strings.Count(string(web), "http.Error(") != 4 {
+		t.Errorf("expected every other call of the handlers to be handled:\n%s", web)
+	}
+
+	test, _ := os.ReadFile(filepath.Join(tmpDir, "web_test.go"))
+	if !strings.Contains(string(test), "if err := save(Item{}); err != nil {\n\t\t\tt.Fatalf(\"save: %v\", err)\n\t\t}") {
+		t.Errorf("web_test.go not handled in place:\n%s", test)
+	}
+
+	cmd := exec.Command("go", "vet", "./...")
+	cmd.Dir = tmpDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("rewritten module does not vet: %v\n%s", err, out)
+	}
+}
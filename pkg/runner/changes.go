@@ -0,0 +1,99 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/gitdiff"
+)
+
+// loadChanges reads the changed lines selected by opts.DiffBase or opts.NewFromPatch.
+//
+// opts: The options.
+// stdin: Reader used when NewFromPatch is "-".
+//
+// Returns nil if neither is set, or an error if the diff cannot be computed or read.
+func loadChanges(opts Options, stdin io.Reader) (*gitdiff.Changes, error) {
+	switch {
+	case opts.DiffBase != "" && opts.NewFromPatch != "":
+		return nil, fmt.Errorf("--diff-base and --new-from-patch are mutually exclusive")
+	case opts.DiffBase != "":
+		changes, err := gitdiff.FromGit(".", opts.DiffBase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff against %s: %w", opts.DiffBase, err)
+		}
+		return changes, nil
+	case opts.NewFromPatch != "":
+		var data []byte
+		var err error
+		if opts.NewFromPatch == "-" {
+			data, err = io.ReadAll(stdin)
+		} else {
+			data, err = os.ReadFile(opts.NewFromPatch)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read patch: %w", err)
+		}
+		// Patches produced by git name files relative to the repository root.
+		root, err := gitdiff.Root(".")
+		if err != nil {
+			if root, err = filepath.Abs("."); err != nil {
+				return nil, err
+			}
+		}
+		changes, err := gitdiff.Parse(bytes.NewReader(data), root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse patch: %w", err)
+		}
+		return changes, nil
+	}
+	return nil, nil
+}
+
+// inChanges filters points to those whose statement overlaps a changed line.
+//
+// points: The detected points.
+// changes: The changed lines.
+//
+// Returns the points in changed code.
+func inChanges(points []analysis.InjectionPoint, changes *gitdiff.Changes) []analysis.InjectionPoint {
+	var out []analysis.InjectionPoint
+	for _, p := range points {
+		if changes.Overlaps(changeRange(p)) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// changeRange returns the file and the lines of the statement of p that are matched against
+// changed lines. Conditions of if and switch statements span their whole body; the call is used
+// instead.
+func changeRange(p analysis.InjectionPoint) (file string, from, to int) {
+	var node ast.Node = p.Call
+	switch p.Stmt.(type) {
+	case *ast.ExprStmt, *ast.AssignStmt, *ast.DeferStmt, *ast.GoStmt:
+		node = p.Stmt
+	}
+	start := p.Pkg.Fset.Position(node.Pos())
+	end := p.Pkg.Fset.Position(node.End())
+	return start.Filename, start.Line, end.Line
+}
+
+// readFiles returns the content of the files in paths.
+func readFiles(paths map[string]bool) (map[string][]byte, error) {
+	out := make(map[string][]byte, len(paths))
+	for path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		out[path] = data
+	}
+	return out, nil
+}
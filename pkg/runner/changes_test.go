@@ -0,0 +1,129 @@
+package runner
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRun_DiffBase verifies that only the points in lines changed against the base revision are
+// reported and fixed.
+func TestRun_DiffBase(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	tmpDir := t.TempDir()
+	legacy := `package main
+
+import "os"
+
+func legacy() {
+	os.Remove("old")
+}
+
+func save() error {
+	f, err := os.Create("out")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(nil)
+	return err
+}
+
+func main() {
+	legacy()
+}
+`
+	writeModule(t, tmpDir, map[string]string{
+		"go.mod":  "module example.com/diffbase\ngo 1.22\n",
+		"main.go": legacy,
+	})
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = tmpDir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "base")
+
+	src := strings.Replace(legacy, "func main() {", "func added() {\n\tos.Chdir(\"/\")\n}\n\nfunc main() {\n\tadded()", 1)
+	path := filepath.Join(tmpDir, "main.go")
+	_ = os.WriteFile(path, []byte(src), 0644)
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		Paths:                []string{"."},
+		DiffBase:             "HEAD",
+	}
+	check := opts
+	check.Check = true
+	err := Run(check)
+	if err == nil || !strings.Contains(err.Error(), "1 unhandled errors") {
+		t.Errorf("check should report only the added error, got %v", err)
+	}
+
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	out, _ := os.ReadFile(path)
+	got := string(out)
+	if !strings.Contains(got, "func added() error {") {
+		t.Errorf("added code was not fixed:\n%s", got)
+	}
+	if !strings.Contains(got, "func legacy() {\n\tos.Remove(\"old\")") {
+		t.Errorf("legacy code should be left alone:\n%s", got)
+	}
+	if !strings.Contains(got, "func save() error {") || !strings.Contains(got, "\tdefer f.Close()\n") {
+		t.Errorf("the unchanged defer should be left alone:\n%s", got)
+	}
+}
+
+// TestRun_NewFromPatch verifies the restriction to the lines of a patch file.
+func TestRun_NewFromPatch(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, map[string]string{
+		"go.mod": "module example.com/patch\ngo 1.22\n",
+		"main.go": `package main
+
+import "os"
+
+func main() {
+	os.Remove("old")
+	os.Chdir("/")
+}
+`,
+		"change.diff": "--- a/main.go\n+++ b/main.go\n@@ -6,0 +7 @@\n+\tos.Chdir(\"/\")\n",
+	})
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	err := Run(Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		Paths:                []string{"."},
+		Check:                true,
+		NewFromPatch:         "change.diff",
+	})
+	if err == nil || !strings.Contains(err.Error(), "1 unhandled errors") {
+		t.Errorf("check should report only the patched line, got %v", err)
+	}
+
+	if err := Run(Options{Paths: []string{"."}, DiffBase: "HEAD", NewFromPatch: "change.diff"}); err == nil {
+		t.Error("expected error for both --diff-base and --new-from-patch")
+	}
+}
@@ -0,0 +1,284 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/cache"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/gitdiff"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/loader"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/report"
+)
+
+// checkFinding is a finding of check mode. Unlike an InjectionPoint it does not refer to syntax
+// or types, so it can be cached (see cachedFindings).
+type checkFinding struct {
+	// Finding is the reported finding, with the absolute path of the file.
+	report.Finding
+	// Package, Function and Shape identify the finding in baselines (see report.Fingerprint).
+	Package  string
+	Function string
+	Shape    string
+	// StmtLine and StmtEndLine are the lines matched against changed lines (see changeRange).
+	StmtLine    int
+	StmtEndLine int
+}
+
+// runCheck reports the unhandled errors without changing any file.
+//
+// opts: The options (Check is set).
+// errcheckReport: The errcheck report replacing detection, or nil.
+// changes: The changed lines findings are restricted to, or nil.
+//
+// Returns an error if findings remain or the analysis fails.
+func runCheck(opts Options, errcheckReport []byte, changes *gitdiff.Changes) error {
+	log.Printf("Analysis mode...")
+	var (
+		findings []checkFinding
+		err      error
+	)
+	if opts.CacheDir != "" && errcheckReport == nil {
+		findings, err = cachedFindings(opts, cache.Open(opts.CacheDir))
+	} else {
+		findings, err = loadFindings(opts, errcheckReport)
+	}
+	if err != nil {
+		return err
+	}
+	if findings == nil {
+		return nil
+	}
+	findings = uniqueFindings(findings)
+
+	if changes != nil {
+		n := len(findings)
+		var in []checkFinding
+		for _, f := range findings {
+			if changes.Overlaps(f.File, f.StmtLine, f.StmtEndLine) {
+				in = append(in, f)
+			}
+		}
+		findings = in
+		log.Printf("%d of %d unhandled errors are in changed lines.", len(findings), n)
+	}
+
+	if opts.WriteBaseline != "" {
+		return writeBaseline(opts.WriteBaseline, findings)
+	}
+	if opts.Baseline != "" {
+		if findings, err = applyBaseline(opts.Baseline, findings, opts.Reporter); err != nil {
+			return err
+		}
+	}
+	if err := writeCheckReport(findings, opts); err != nil {
+		return err
+	}
+	if len(findings) > 0 {
+		log.Printf("[FAIL] Found %d unhandled errors.", len(findings))
+		return fmt.Errorf("check failed: %d unhandled errors found", len(findings))
+	}
+	log.Println("[PASS] No unhandled errors.")
+	return nil
+}
+
+// loadFindings loads and analyzes all packages.
+//
+// Returns nil (and logs) if no package matches the paths.
+func loadFindings(opts Options, errcheckReport []byte) ([]checkFinding, error) {
+	pkgs, err := loader.LoadPackages(opts.Paths, ".")
+	if err != nil {
+		return nil, fmt.Errorf("load failed: %w", err)
+	}
+	if len(pkgs) == 0 {
+		log.Println("No packages found.")
+		return nil, nil
+	}
+	var points []analysis.InjectionPoint
+	if errcheckReport != nil {
+		points, err = reportPoints(pkgs, errcheckReport)
+	} else {
+		points, err = detect(pkgs, opts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("analysis failed: %w", err)
+	}
+	return summarize(points, opts), nil
+}
+
+// cachedFindings analyzes the packages like loadFindings, reusing the cached findings of the
+// packages whose files, dependencies and options did not change. Only the other packages are
+// loaded and type checked; their findings are cached. Packages with errors are not cached.
+//
+// opts: The options.
+// c: The cache.
+//
+// Returns the findings in package order, like loadFindings.
+func cachedFindings(opts Options, c *cache.Cache) ([]checkFinding, error) {
+	roots, err := loader.ListPackages(opts.Paths, ".")
+	if err != nil {
+		return nil, fmt.Errorf("load failed: %w", err)
+	}
+	if len(roots) == 0 {
+		log.Println("No packages found.")
+		return nil, nil
+	}
+	for _, pkg := range roots {
+		if len(pkg.Errors) > 0 {
+			// Let the full load report the problem.
+			log.Printf("Cache disabled: package %s has errors.", pkg.ID)
+			return loadFindings(opts, nil)
+		}
+	}
+
+	salt, err := cacheSalt(opts)
+	if err != nil {
+		return nil, err
+	}
+	keys := cache.PackageKeys(roots, salt)
+
+	found := make([][]checkFinding, len(roots))
+	missed := make(map[string]int)
+	var patterns []string
+	seen := make(map[string]bool)
+	for i, pkg := range roots {
+		if c.Get(keys[pkg.ID], &found[i]) {
+			continue
+		}
+		missed[pkg.ID] = i
+		if path := basePackage(pkg.ID); !seen[path] {
+			seen[path] = true
+			patterns = append(patterns, path)
+		}
+	}
+	hits := len(roots) - len(missed)
+	log.Printf("Cache: reusing %d of %d packages.", hits, len(roots))
+	if opts.Reporter != nil {
+		opts.Reporter.SetCacheStats(hits, len(missed))
+	}
+
+	if len(patterns) > 0 {
+		pkgs, err := loader.LoadPackages(patterns, ".")
+		if err != nil {
+			return nil, fmt.Errorf("load failed: %w", err)
+		}
+		points, err := detect(pkgs, opts)
+		if err != nil {
+			return nil, fmt.Errorf("analysis failed: %w", err)
+		}
+		byPkg := make(map[string][]analysis.InjectionPoint)
+		for _, p := range points {
+			byPkg[p.Pkg.ID] = append(byPkg[p.Pkg.ID], p)
+		}
+		for _, pkg := range pkgs {
+			i, ok := missed[pkg.ID]
+			if !ok {
+				continue
+			}
+			found[i] = summarize(byPkg[pkg.ID], opts)
+			if found[i] == nil {
+				found[i] = []checkFinding{}
+			}
+			if len(pkg.Errors) > 0 || keys[pkg.ID] == "" {
+				continue
+			}
+			if err := c.Put(keys[pkg.ID], found[i]); err != nil {
+				log.Printf("[WARN] Failed to cache the findings of %s: %v", pkg.ID, err)
+			}
+		}
+	}
+
+	var findings []checkFinding
+	for _, f := range found {
+		findings = append(findings, f...)
+	}
+	return findings, nil
+}
+
+// basePackage returns the import path of the package a (test) package ID belongs to, e.g.
+// "p" for "p [p.test]", "p_test [p.test]" and "p.test".
+func basePackage(id string) string {
+	if _, variant, ok := strings.Cut(id, " ["); ok {
+		return strings.TrimSuffix(strings.TrimSuffix(variant, "]"), ".test")
+	}
+	return strings.TrimSuffix(id, ".test")
+}
+
+// cacheSalt describes everything besides the packages that the findings depend on: the tool,
+// the build environment and the options affecting detection and suggested fixes.
+func cacheSalt(opts Options) (string, error) {
+	// Options that only select or format the output are applied after the cache.
+	opts.Reporter = nil
+	opts.Paths = nil
+	opts.Output = ""
+	opts.Format = ""
+	opts.Check, opts.DryRun = false, false
+	opts.DiffBase, opts.NewFromPatch = "", ""
+	opts.Baseline, opts.WriteBaseline = "", ""
+	opts.CacheDir, opts.JournalDir = "", ""
+	opts.Jobs, opts.MaxIterations = 0, 0
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute cache key: %w", err)
+	}
+
+	wd, _ := os.Getwd()
+	tool := opts.Version
+	if tool == "" || tool == "dev" {
+		// Development builds share a version; tell them apart by the binary.
+		if exe, err := os.Executable(); err == nil {
+			if info, err := os.Stat(exe); err == nil {
+				tool = fmt.Sprintf("%s %s %d %d", tool, exe, info.Size(), info.ModTime().UnixNano())
+			}
+		}
+	}
+	var env []string
+	for _, name := range []string{"GOOS", "GOARCH", "GOFLAGS", "CGO_ENABLED", "GOEXPERIMENT"} {
+		env = append(env, name+"="+os.Getenv(name))
+	}
+	return fmt.Sprintf("%s\x00%s\x00%s/%s\x00%s\x00%s\x00%s", tool, runtime.Version(), runtime.GOOS, runtime.GOARCH, strings.Join(env, " "), wd, data), nil
+}
+
+// summarize converts points into check findings, including the fix the Injector would apply in
+// place (where available).
+func summarize(points []analysis.InjectionPoint, opts Options) []checkFinding {
+	findings := buildFindings(points, opts)
+	out := make([]checkFinding, len(points))
+	for i, p := range points {
+		_, from, to := changeRange(p)
+		fp := pointFingerprint(p)
+		out[i] = checkFinding{
+			Finding:     findings[i],
+			Package:     fp.Package,
+			Function:    fp.Function,
+			Shape:       fp.Shape,
+			StmtLine:    from,
+			StmtEndLine: to,
+		}
+	}
+	return out
+}
+
+// uniqueFindings drops the findings at the call of an earlier finding: the regular and test
+// variants of a package both report the calls in its non-test files.
+func uniqueFindings(findings []checkFinding) []checkFinding {
+	type callPos struct {
+		file         string
+		line, column int
+	}
+	seen := make(map[callPos]bool, len(findings))
+	out := findings[:0:0]
+	for _, f := range findings {
+		key := callPos{f.File, f.Line, f.Column}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, f)
+	}
+	return out
+}
@@ -0,0 +1,90 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/report"
+)
+
+// TestRun_CheckCache verifies that cached checks report the same findings and that changing a
+// package invalidates it and its importers.
+func TestRun_CheckCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, map[string]string{
+		"go.mod":     "module example.com/cached\ngo 1.22\n",
+		"lib/lib.go": "package lib\n\nimport \"os\"\n\nfunc Clean() error {\n\tos.Remove(\"x\")\n\treturn nil\n}\n",
+		"app/app.go": "package app\n\nimport \"example.com/cached/lib\"\n\nfunc Run() {\n\tlib.Clean()\n}\n",
+		"other/o.go": "package other\n\nimport \"os\"\n\nfunc Go() {\n\tos.Chdir(\"x\")\n}\n",
+	})
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	cacheDir := filepath.Join(tmpDir, ".cache")
+	out := filepath.Join(tmpDir, "report.sarif")
+	check := func() (*report.CacheStats, string, error) {
+		opts := Options{
+			Check:                true,
+			EnablePreexistingErr: true,
+			EnableNonExistingErr: true,
+			EnableThirdPartyErr:  true,
+			Paths:                []string{"./..."},
+			Format:               FormatSARIF,
+			Output:               out,
+			CacheDir:             cacheDir,
+			Reporter:             report.New(),
+		}
+		err := Run(opts)
+		sarif, _ := os.ReadFile(out)
+		return opts.Reporter.GetData().Cache, string(sarif), err
+	}
+
+	stats, first, err := check()
+	if err == nil || !strings.Contains(err.Error(), "3 unhandled errors") {
+		t.Fatalf("expected 3 findings, got %v", err)
+	}
+	if stats == nil || stats.Hits != 0 || stats.Misses != 3 {
+		t.Fatalf("first check should miss every package, got %+v", stats)
+	}
+
+	stats, second, err := check()
+	if err == nil || !strings.Contains(err.Error(), "3 unhandled errors") {
+		t.Fatalf("expected 3 cached findings, got %v", err)
+	}
+	if stats.Hits != 3 || stats.Misses != 0 {
+		t.Errorf("second check should hit every package, got %+v", stats)
+	}
+	if second != first {
+		t.Errorf("cached report differs:\n%s\n---\n%s", first, second)
+	}
+
+	// Handling the error in lib changes lib and invalidates app, which imports it.
+	writeModule(t, tmpDir, map[string]string{
+		"lib/lib.go": "package lib\n\nimport \"os\"\n\nfunc Clean() error {\n\treturn os.Remove(\"x\")\n}\n",
+	})
+	stats, _, err = check()
+	if err == nil || !strings.Contains(err.Error(), "2 unhandled errors") {
+		t.Fatalf("expected 2 findings, got %v", err)
+	}
+	if stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("expected lib and app to be analyzed again, got %+v", stats)
+	}
+}
+
+// TestBasePackage verifies the mapping of test variants to their package.
+func TestBasePackage(t *testing.T) {
+	for id, want := range map[string]string{
+		"example.com/p":                           "example.com/p",
+		"example.com/p [example.com/p.test]":      "example.com/p",
+		"example.com/p_test [example.com/p.test]": "example.com/p",
+		"example.com/p.test":                      "example.com/p",
+	} {
+		if got := basePackage(id); got != want {
+			t.Errorf("basePackage(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package runner
+
+import (
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/rewrite"
+)
+
+// withoutLeftCloses drops the deferred calls that the close policy of their package leaves as they
+// are (see rewrite.LeavesClose), so that they are neither fixed nor reported.
+//
+// points: The detected injection points.
+// opts: The options, with the close policies of the packages.
+//
+// Returns the remaining points.
+func withoutLeftCloses(points []analysis.InjectionPoint, opts Options) []analysis.InjectionPoint {
+	out := points[:0:0]
+	for _, p := range points {
+		if !rewrite.LeavesClose(p, opts.forPackage(p.Pkg).ClosePolicy) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,144 @@
+package runner
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// closersModule closes read-only and writable files in functions with and without error results.
+var closersModule = map[string]string{
+	"go.mod": "module example.com/closers\ngo 1.22\n",
+	"files.go": `package files
+
+import (
+	"bufio"
+	"io"
+	"os"
+)
+
+func Read(name string) ([]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+func Peek(name string) int {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	return 1
+}
+
+func Write(name string, data []byte) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	_, err = w.Write(data)
+	return err
+}
+
+func Save(name string) {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	_, _ = f.WriteString("x")
+}
+`,
+}
+
+// TestRun_Closers verifies that deferred Close errors are joined for writable files only, and that
+// read-only files are neither changed nor reported.
+func TestRun_Closers(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, closersModule)
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		SyncOnClose:          true,
+		Paths:                []string{"./..."},
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	got, _ := os.ReadFile(filepath.Join(tmpDir, "files.go"))
+	for _, want := range []string{
+		"func Read(name string) ([]byte, error) {\n\tf, err := os.Open(name)\n\tif err != nil {\n\t\treturn nil, err\n\t}\n\tdefer f.Close()",
+		"func Peek(name string) int {\n\tf, err := os.Open(name)\n\tif err != nil {\n\t\treturn 0\n\t}\n\tdefer f.Close()",
+		"func Write(name string, data []byte) (err error) {",
+		"err = errors.Join(err, f.Sync(), f.Close())\n\t}()\n\tw := bufio.NewWriter(f)\n\tdefer func() {\n\t\terr = errors.Join(err, w.Flush())\n\t}()",
+		// Save gains an error result for the Close of the file.
+		"func Save(name string) (err error) {",
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("files.go missing %q:\n%s", want, got)
+		}
+	}
+	if n := strings.Count(string(got), "f.Sync(), f.Close()"); n != 2 {
+		t.Errorf("expected the 2 writable files to be joined, got %d:\n%s", n, got)
+	}
+
+	cmd := exec.Command("go", "vet", "./...")
+	cmd.Dir = tmpDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("rewritten module does not vet: %v\n%s", err, out)
+	}
+
+	opts.Check = true
+	if err := Run(opts); err != nil {
+		t.Errorf("expected the read-only files not to be reported: %v", err)
+	}
+}
+
+// TestRun_ClosePolicyNolint verifies that read-only files are marked for other linters, once.
+func TestRun_ClosePolicyNolint(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, closersModule)
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		ClosePolicy:          "nolint",
+		Paths:                []string{"./..."},
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	got, _ := os.ReadFile(filepath.Join(tmpDir, "files.go"))
+	if n := strings.Count(string(got), "defer f.Close() //nolint:errcheck // read-only\n"); n != 2 {
+		t.Errorf("expected the 2 read-only files to be marked, got %d:\n%s", n, got)
+	}
+	if !strings.Contains(string(got), "func Peek(name string) int {") {
+		t.Errorf("expected Peek to keep its signature:\n%s", got)
+	}
+
+	opts.Check = true
+	if err := Run(opts); err != nil {
+		t.Errorf("expected the marked files not to be reported: %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package runner
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"log"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/filter"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/refactor"
+	"github.com/dave/dst"
+	"golang.org/x/tools/go/packages"
+)
+
+// defaultCompatSuffix is appended to the name of the error-returning variant when CompatSuffix is unset.
+const defaultCompatSuffix = "E"
+
+// compatSplit is a function that received an error result in place and is split into a
+// deprecated wrapper and an error-returning variant once all rewrites of the pass are applied.
+type compatSplit struct {
+	file    *dst.File
+	astFile *ast.File
+	decl    *dst.FuncDecl
+	orig    *dst.FuncType
+	opts    Options
+	// logger is the logger expression of the slog handlers in the wrapper.
+	logger string
+	// origin is the point whose handling added the error result.
+	origin *analysis.InjectionPoint
+}
+
+// newCompatSplit records decl before its error result is added.
+//
+// file: The DST file containing decl.
+// pkg: The package containing the declaration.
+// astFile: The corresponding AST file.
+// astDecl: The AST of the declaration, locating the logger of the slog handlers.
+// decl: The declaration about to receive an error result.
+// opts: The effective options of the package.
+// origin: The point whose handling adds the error result.
+func newCompatSplit(file *dst.File, pkg *packages.Package, astFile *ast.File, astDecl *ast.FuncDecl, decl *dst.FuncDecl, opts Options, origin *analysis.InjectionPoint) compatSplit {
+	logger := opts.Logger
+	if logger == "" && refactor.MainHandlerStrategy(opts.CompatHandler).IsSlog() && astDecl.Body != nil {
+		logger = analysis.FindLogger(pkg, astFile, astDecl.Body.Lbrace)
+	}
+	return compatSplit{file: file, astFile: astFile, decl: decl, orig: dst.Clone(decl.Type).(*dst.FuncType), opts: opts, logger: logger, origin: origin}
+}
+
+// wantsCompat reports whether fn keeps its signature and gains an error-returning variant
+// instead of an in-place signature change.
+//
+// fn: The function about to receive an error result.
+func (opts Options) wantsCompat(fn *types.Func) bool {
+	for _, pattern := range opts.CompatGlob {
+		if filter.MatchSymbol(pattern, fn) {
+			return true
+		}
+	}
+	if !opts.CompatWrappers || !fn.Exported() || fn.Pkg() == nil || fn.Pkg().Name() == "main" {
+		return false
+	}
+	// Methods of unexported types are not part of the importable API.
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		t := recv.Type()
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		if named, ok := t.(*types.Named); ok && !named.Obj().Exported() {
+			return false
+		}
+	}
+	return true
+}
+
+// compatSuffix returns the configured variant suffix.
+func (opts Options) compatSuffix() string {
+	if opts.CompatSuffix == "" {
+		return defaultCompatSuffix
+	}
+	return opts.CompatSuffix
+}
+
+// compatVariantFree reports whether the variant name of fn is not yet declared, either in the
+// package scope (functions) or in the method set of the receiver (methods).
+//
+// fn: The function to split.
+// suffix: The variant suffix.
+func compatVariantFree(fn *types.Func, suffix string) bool {
+	name := fn.Name() + suffix
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		obj, _, _ := types.LookupFieldOrMethod(recv.Type(), true, fn.Pkg(), name)
+		return obj == nil
+	}
+	return fn.Pkg() == nil || fn.Pkg().Scope().Lookup(name) == nil
+}
+
+// applyCompatSplits turns the recorded functions into wrappers around their new variants.
+//
+// mgr: The DST manager owning the files.
+// splits: The recorded functions.
+//
+// Returns the number of variants added.
+func applyCompatSplits(mgr *dstManager, splits []compatSplit) int {
+	count := 0
+	for _, s := range splits {
+		handler := refactor.MainHandlerStrategy(s.opts.CompatHandler)
+		variant, err := refactor.AddCompatWrapperDST(s.file, s.decl, s.orig, s.opts.compatSuffix(), handler, s.logger)
+		if err != nil {
+			log.Printf("[WARN] Failed to add compatibility wrapper: %v", err)
+			continue
+		}
+		log.Printf("Added %s; %s is kept as a deprecated wrapper.", variant.Name.Name, s.decl.Name.Name)
+		mgr.beginEdit(s.origin, token.NoPos)
+		mgr.MarkModified(s.astFile)
+		count++
+	}
+	return count
+}
@@ -0,0 +1,145 @@
+package runner
+
+import (
+	"go/types"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// TestOptions_WantsCompat verifies which functions are split into wrappers.
+func TestOptions_WantsCompat(t *testing.T) {
+	src := `package lib
+
+type Store struct{}
+type cache struct{}
+
+func Open()             {}
+func open()             {}
+func (Store) Get()      {}
+func (*cache) Get()     {}
+func (*Store) Open()    {}
+func (*Store) OpenE()   {}
+`
+	tmpDir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module example.com/lib\ngo 1.22\n"), 0644)
+	_ = os.WriteFile(filepath.Join(tmpDir, "lib.go"), []byte(src), 0644)
+
+	cfg := &packages.Config{Mode: packages.NeedTypes | packages.NeedName | packages.NeedSyntax | packages.NeedFiles, Dir: tmpDir}
+	pkgs, err := packages.Load(cfg, ".")
+	if err != nil || len(pkgs) != 1 {
+		t.Fatalf("load failed: %v", err)
+	}
+	scope := pkgs[0].Types.Scope()
+	fn := func(name string) *types.Func { return scope.Lookup(name).(*types.Func) }
+	method := func(typ, name string) *types.Func {
+		obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(scope.Lookup(typ).Type()), true, pkgs[0].Types, name)
+		return obj.(*types.Func)
+	}
+
+	opts := Options{CompatWrappers: true}
+	if !opts.wantsCompat(fn("Open")) || !opts.wantsCompat(method("Store", "Get")) {
+		t.Error("exported functions and methods should be wrapped")
+	}
+	if opts.wantsCompat(fn("open")) || opts.wantsCompat(method("cache", "Get")) {
+		t.Error("unexported API should change in place")
+	}
+	if (Options{}).wantsCompat(fn("Open")) {
+		t.Error("wrappers are disabled by default")
+	}
+	if !(Options{CompatGlob: []string{"example.com/lib.open"}}).wantsCompat(fn("open")) {
+		t.Error("glob should select unexported functions")
+	}
+
+	if !compatVariantFree(fn("Open"), "E") {
+		t.Error("OpenE is not declared at package level")
+	}
+	if compatVariantFree(method("Store", "Open"), "E") {
+		t.Error("(*Store).OpenE already exists")
+	}
+}
+
+// TestRun_CompatWrappers verifies that exported signatures are preserved end-to-end and the
+// result still compiles.
+func TestRun_CompatWrappers(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/lib\ngo 1.22\n",
+		"lib.go": `package lib
+
+import "os"
+
+// Save writes the marker file.
+func Save(name string) int {
+	os.WriteFile(name, nil, 0644)
+	return len(name)
+}
+
+func helper() {
+	os.Remove("x")
+}
+
+func Run() {
+	helper()
+}
+`,
+		// Simulates a downstream importer which is not rewritten.
+		"client/client.go": `package client
+
+import lib "example.com/lib"
+
+func Use() int {
+	lib.Run()
+	return lib.Save("f")
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		_ = os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		CompatWrappers:       true,
+		Paths:                []string{"."},
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	out, _ := os.ReadFile(filepath.Join(tmpDir, "lib.go"))
+	got := string(out)
+	for _, want := range []string{
+		"func Save(name string) int {",
+		"// Deprecated: Use SaveE, which returns the error instead of handling it.",
+		"func SaveE(name string) (int, error) {",
+		"func Run() {",
+		"func RunE() error {",
+		"func helper() error {",
+		`log.Printf("Run: %v", err)`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+
+	cmd := exec.Command("go", "build", "./...")
+	cmd.Dir = tmpDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("rewritten module does not build: %v\n%s\n%s", err, out, got)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"go/token"
 	"go/types"
 
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/filter"
 	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/go/packages"
 )
@@ -78,3 +79,15 @@ func FindEnclosingFunc(pkg *packages.Package, file *ast.File, pos token.Pos) *Fu
 
 	return nil
 }
+
+// inTestHandler reports whether pos is in a test handler (see filter.IsTestHandler), directly or in
+// one of its function literals.
+func inTestHandler(file *ast.File, pos token.Pos) bool {
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	for _, n := range path {
+		if decl, ok := n.(*ast.FuncDecl); ok {
+			return filter.IsTestHandler(decl)
+		}
+	}
+	return false
+}
@@ -216,3 +216,30 @@ func TestFindEnclosingFunc_MissingTypes(t *testing.T) {
 		t.Error("Expected nil context when TypesInfo is missing")
 	}
 }
+
+// TestInTestHandler verifies that literals inside test functions count as part of them.
+func TestInTestHandler(t *testing.T) {
+	src := `package main
+
+import "testing"
+
+func TestX(t *testing.T) {
+	defer func() { println("lit") }()
+}
+
+func helper() {
+	func() { println("helper") }()
+}
+`
+	_, file, _ := setupEnclosingEnv(t, src)
+
+	for name, want := range map[string]bool{`"lit"`: true, `"helper"`: false} {
+		pos := findNodePos(file, func(n ast.Node) bool {
+			lit, ok := n.(*ast.BasicLit)
+			return ok && lit.Value == name
+		})
+		if got := inTestHandler(file, pos); got != want {
+			t.Errorf("inTestHandler(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+// readErrcheckReport reads the errcheck (or golangci-lint JSON) report named by source.
+// A source of "-" reads from stdin.
+//
+// source: Path to the report, or "-".
+// stdin: Reader used when source is "-".
+func readErrcheckReport(source string, stdin io.Reader) ([]byte, error) {
+	if source == "-" {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read errcheck report from stdin: %w", err)
+		}
+		return data, nil
+	}
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read errcheck report: %w", err)
+	}
+	return data, nil
+}
+
+// reportPoints maps a previously read errcheck report onto the loaded packages.
+// Locations that do not resolve to a loaded call statement are dropped.
+//
+// pkgs: The loaded packages.
+// report: Raw errcheck text or golangci-lint JSON output.
+func reportPoints(pkgs []*packages.Package, report []byte) ([]analysis.InjectionPoint, error) {
+	points, err := analysis.NewErrcheckParser(pkgs).ParseReport(bytes.NewReader(report))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse errcheck report: %w", err)
+	}
+	return points, nil
+}
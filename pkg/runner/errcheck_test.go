@@ -0,0 +1,86 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRun_FromErrcheck verifies that only the locations listed in an errcheck report are fixed.
+func TestRun_FromErrcheck(t *testing.T) {
+	tmpDir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module errchecktest\ngo 1.22\n"), 0644)
+
+	src := `package main
+
+import "os"
+
+func fail() error { return nil }
+
+func reported() error {
+	fail()
+	return nil
+}
+
+func unreported() error {
+	fail()
+	return nil
+}
+
+func main() {}
+
+func deferred() error {
+	f, err := os.Create("out")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(nil)
+	return err
+}
+`
+	srcPath := filepath.Join(tmpDir, "main.go")
+	_ = os.WriteFile(srcPath, []byte(src), 0644)
+
+	reportPath := filepath.Join(tmpDir, "errcheck.txt")
+	_ = os.WriteFile(reportPath, []byte("main.go:8:6:\tfail()\n"), 0644)
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer interface{}(func() { _ = os.Chdir(oldWd) }).(func())()
+
+	err := Run(Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		Paths:                []string{"."},
+		FromErrcheck:         reportPath,
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(srcPath)
+	out := string(content)
+	if strings.Count(out, "if err := fail(); err != nil") != 1 {
+		t.Fatalf("Expected exactly one fix. Got:\n%s", out)
+	}
+	if !strings.Contains(out, "func unreported() error {\n\tfail()") {
+		t.Errorf("Unreported call should be untouched. Got:\n%s", out)
+	}
+	if !strings.Contains(out, "func deferred() error {") || !strings.Contains(out, "\tdefer f.Close()\n") {
+		t.Errorf("Unreported defer should be untouched. Got:\n%s", out)
+	}
+}
+
+// TestReadErrcheckReport verifies reading from files and stdin.
+func TestReadErrcheckReport(t *testing.T) {
+	data, err := readErrcheckReport("-", strings.NewReader("main.go:1:1: f()"))
+	if err != nil || string(data) != "main.go:1:1: f()" {
+		t.Errorf("Unexpected stdin read: %q, %v", data, err)
+	}
+
+	if _, err := readErrcheckReport(filepath.Join(t.TempDir(), "missing.txt"), nil); err == nil {
+		t.Error("Expected error for missing report file")
+	}
+}
@@ -0,0 +1,86 @@
+package runner
+
+import (
+	"go/ast"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/rewrite"
+)
+
+// errgroupModule is the module providing rewrite.ErrgroupPath.
+const errgroupModule = "golang.org/x/sync"
+
+// goGroups batches the go statement points handled with rewrite.GoStrategyErrgroup by enclosing
+// function, since the statements of a function share one errgroup.Group. The points in the
+// literals run by go statements (see rewrite.Injector.GroupGoStmt) belong to the function of the
+// statement.
+//
+// points: The detected points.
+// baseOpts: The base options, resolved per package.
+//
+// Returns the batches keyed by the call of their first point. The calls of the other points
+// map to nil.
+func goGroups(points []analysis.InjectionPoint, baseOpts Options) map[*ast.CallExpr][]analysis.InjectionPoint {
+	groups := make(map[*ast.CallExpr][]analysis.InjectionPoint)
+	first := make(map[ast.Node]*ast.CallExpr)
+	for _, p := range points {
+		if _, done := groups[p.Call]; done {
+			continue
+		}
+		opts := baseOpts.forPackage(p.Pkg)
+		if opts.GoStrategy != rewrite.GoStrategyErrgroup {
+			continue
+		}
+		inj := newInjector(p.Pkg, opts)
+		gs := inj.GroupGoStmt(p)
+		if gs == nil || inj.RuleFor(p).Action == rewrite.ActionLog {
+			continue
+		}
+		ctx := FindEnclosingFunc(p.Pkg, p.File, gs.Pos())
+		if ctx == nil {
+			continue
+		}
+		if key, ok := first[ctx.Node]; ok {
+			groups[key] = append(groups[key], p)
+			groups[p.Call] = nil
+			continue
+		}
+		first[ctx.Node] = p.Call
+		groups[p.Call] = []analysis.InjectionPoint{p}
+	}
+	return groups
+}
+
+// withoutErrgroup returns the go.mod files of the modules of the points handled with
+// rewrite.GoStrategyErrgroup that do not require errgroupModule, logging a warning for each. The
+// errgroup import would not resolve there, so their go statements use rewrite.GoStrategyHandler.
+//
+// points: The detected points.
+// baseOpts: The base options, resolved per package.
+func withoutErrgroup(points []analysis.InjectionPoint, baseOpts Options) map[string]bool {
+	missing := make(map[string]bool)
+	seen := make(map[string]bool)
+	for _, p := range points {
+		pkg := p.Pkg
+		if _, ok := p.Stmt.(*ast.GoStmt); !ok || pkg.Module == nil || pkg.Module.GoMod == "" || seen[pkg.Module.GoMod] {
+			continue
+		}
+		if baseOpts.forPackage(pkg).GoStrategy != rewrite.GoStrategyErrgroup {
+			continue
+		}
+		seen[pkg.Module.GoMod] = true
+		if pkg.Module.Path == errgroupModule {
+			continue
+		}
+		data, err := os.ReadFile(pkg.Module.GoMod)
+		if err == nil && strings.Contains(string(data), errgroupModule+" ") {
+			continue
+		}
+		missing[pkg.Module.GoMod] = true
+		log.Printf("[WARN] %s does not require %s; wrapping its go statements in handler closures instead. Run 'go get %s' in %s to use errgroups.", pkg.Module.Path, errgroupModule, rewrite.ErrgroupPath, pkg.Module.Dir)
+	}
+	return missing
+}
@@ -0,0 +1,140 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/report"
+)
+
+// TestRun_GoStrategyErrgroup verifies that the go statements of a function share one errgroup,
+// that the function gains an error result for Wait, and that entry points handle the error.
+func TestRun_GoStrategyErrgroup(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, map[string]string{
+		// The rewrite imports errgroup, so the module requires it to pass verification.
+		"go.mod": "module example.com/app\ngo 1.22\nrequire golang.org/x/sync v0.19.0\n",
+		"go.sum": "golang.org/x/sync v0.19.0 h1:vV+1eWNmZ5geRlYjzm2adRgW2/mcpevXNg50YZtPCE4=\n" +
+			"golang.org/x/sync v0.19.0/go.mod h1:9KTHXmSnoGruLpwFjVSX0lNNA75CykiMECbovNTZqGI=\n",
+		"main.go": `package main
+
+import "sync"
+
+func fetch(id int) error { return nil }
+
+func fetchAll(ids []int) error {
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			fetch(n)
+		}(id)
+	}
+	wg.Wait()
+	return nil
+}
+
+func loadAll(ids []int) {
+	for _, id := range ids {
+		go fetch(id)
+	}
+}
+
+func main() {
+	go fetch(0)
+	loadAll(nil)
+}
+`,
+	})
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		GoStrategy:           "errgroup",
+		Paths:                []string{"."},
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	out, _ := os.ReadFile(filepath.Join(tmpDir, "main.go"))
+	got := string(out)
+	for _, want := range []string{
+		`"golang.org/x/sync/errgroup"`,
+		"func loadAll(ids []int) error {",
+		"var g errgroup.Group",
+		"if err := g.Wait(); err != nil {\n\t\treturn err\n\t}",
+		"g.Go(func() error {\n\t\treturn fetch(0)\n\t})",
+		"log.Fatal(err)",
+		// The literal signalling the WaitGroup joins a group replacing it, capturing its argument.
+		"var g errgroup.Group\n\tfor _, id := range ids {\n\t\tn := id\n\t\tg.Go(func() error {\n\t\t\treturn fetch(n)\n\t\t})\n\t}",
+		"\t}\n\tif err := g.Wait(); err != nil {\n\t\treturn err\n\t}\n\treturn nil",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "go fetch") || strings.Contains(got, "go func") {
+		t.Errorf("go statements not converted:\n%s", got)
+	}
+	if strings.Contains(got, "wg") || strings.Contains(got, `"sync"`) {
+		t.Errorf("WaitGroup not removed:\n%s", got)
+	}
+}
+
+// TestRun_GoStrategyErrgroup_NotRequired verifies that modules without golang.org/x/sync keep
+// the handler closures, since the errgroup import would not build.
+func TestRun_GoStrategyErrgroup_NotRequired(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, map[string]string{
+		"go.mod": "module example.com/app\ngo 1.22\n",
+		"main.go": `package main
+
+func fetch(id int) error { return nil }
+
+func loadAll(ids []int) {
+	for _, id := range ids {
+		go fetch(id)
+	}
+}
+
+func main() {
+	loadAll(nil)
+}
+`,
+	})
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	reporter := report.New()
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		GoStrategy:           "errgroup",
+		Paths:                []string{"."},
+		Reporter:             reporter,
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	out, _ := os.ReadFile(filepath.Join(tmpDir, "main.go"))
+	got := string(out)
+	if strings.Contains(got, "errgroup") || !strings.Contains(got, "go func() {\n\t\t\terr := fetch(id)\n\t\t\tif err != nil {") {
+		t.Errorf("expected the handler closure:\n%s", got)
+	}
+	if data := reporter.GetData(); len(data.Reverted) > 0 {
+		t.Errorf("expected no reverted file, got %+v", data.Reverted)
+	}
+}
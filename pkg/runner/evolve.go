@@ -0,0 +1,167 @@
+package runner
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/refactor"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/rewrite"
+	"github.com/dave/dst"
+	"golang.org/x/tools/go/packages"
+)
+
+// evolvedInterfaces is the outcome of applying an analysis.Evolution.
+type evolvedInterfaces struct {
+	// methods are the patched implementations (excluding the method the plan started from).
+	methods []*types.Func
+	// ifaceMethods maps the interface methods to their new signatures. The objects are not
+	// replaced, so call sites still refer to them.
+	ifaceMethods map[*types.Func]*types.Signature
+}
+
+// evolveInterfaces adds an error result to the interface methods and implementations of plan.
+//
+// mgr: The DST manager owning the files.
+// plan: The evolution computed by analysis.InterfaceRegistry.PlanEvolution.
+// skip: The method the plan started from, which the caller rewrites itself.
+//
+// Returns the changed declarations for propagation.
+func evolveInterfaces(mgr *dstManager, plan *analysis.Evolution, skip *types.Func) (*evolvedInterfaces, error) {
+	out := &evolvedInterfaces{ifaceMethods: make(map[*types.Func]*types.Signature)}
+
+	for _, im := range plan.InterfaceMethods {
+		pkg, file := mgr.fileAt(im.Pos())
+		if file == nil {
+			return nil, fmt.Errorf("declaration of %s not found", im.FullName())
+		}
+		field := findInterfaceMethod(file, im.Pos())
+		if field == nil {
+			return nil, fmt.Errorf("declaration of %s not found", im.FullName())
+		}
+		dstFile, err := mgr.Get(pkg, file)
+		if err != nil {
+			return nil, err
+		}
+		res, err := rewrite.FindDstNode(mgr.fset, dstFile, file, field)
+		if err != nil {
+			return nil, err
+		}
+		dstField, ok := res.Node.(*dst.Field)
+		if !ok {
+			return nil, fmt.Errorf("declaration of %s not found in DST", im.FullName())
+		}
+		if _, err := refactor.AddErrorToInterfaceMethodDST(dstField); err != nil {
+			return nil, err
+		}
+		mgr.MarkModified(file)
+		out.ifaceMethods[im] = refactor.WithErrorResult(im.Type().(*types.Signature), im.Pkg())
+	}
+
+	for _, m := range plan.Methods {
+		if m.Pos() == skip.Pos() {
+			continue
+		}
+		obj, err := addErrorToFunc(mgr, m)
+		if err != nil {
+			return nil, err
+		}
+		out.methods = append(out.methods, obj)
+	}
+	return out, nil
+}
+
+// addErrorToFunc adds an error result to the declaration of fn (see refactor.AddErrorToSignature)
+// and patches its type information.
+//
+// mgr: The DST manager owning the files.
+// fn: The function or method to change.
+//
+// Returns the new object of fn.
+func addErrorToFunc(mgr *dstManager, fn *types.Func) (*types.Func, error) {
+	pkg, file := mgr.fileAt(fn.Pos())
+	if file == nil {
+		return nil, fmt.Errorf("declaration of %s not found", fn.FullName())
+	}
+	var decl *ast.FuncDecl
+	for _, d := range file.Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok && fd.Name.Pos() == fn.Pos() {
+			decl = fd
+			break
+		}
+	}
+	if decl == nil {
+		return nil, fmt.Errorf("declaration of %s not found", fn.FullName())
+	}
+	dstFile, err := mgr.Get(pkg, file)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := refactor.AddErrorToSignature(pkg.Fset, decl); err != nil {
+		return nil, err
+	}
+	if err := refactor.PatchSignature(pkg.TypesInfo, decl, pkg.Types); err != nil {
+		return nil, err
+	}
+	res, _ := rewrite.FindDstNode(mgr.fset, dstFile, file, decl)
+	if dstDecl, ok := res.Node.(*dst.FuncDecl); ok {
+		refactor.AddErrorToSignatureDST(dstDecl)
+	}
+	mgr.MarkModified(file)
+	obj, ok := pkg.TypesInfo.ObjectOf(decl.Name).(*types.Func)
+	if !ok {
+		return nil, fmt.Errorf("declaration of %s not found", fn.FullName())
+	}
+	return obj, nil
+}
+
+// findInterfaceMethod returns the interface method field whose name is declared at pos.
+func findInterfaceMethod(file *ast.File, pos token.Pos) *ast.Field {
+	var found *ast.Field
+	ast.Inspect(file, func(n ast.Node) bool {
+		if found != nil {
+			return false
+		}
+		it, ok := n.(*ast.InterfaceType)
+		if !ok {
+			return true
+		}
+		for _, field := range it.Methods.List {
+			for _, name := range field.Names {
+				if name.Pos() == pos {
+					found = field
+				}
+			}
+		}
+		return true
+	})
+	return found
+}
+
+// packages returns the managed packages ordered by ID.
+func (m *dstManager) packages() []*packages.Package {
+	ids := make([]string, 0, len(m.pkgs))
+	for id := range m.pkgs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	out := make([]*packages.Package, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, m.pkgs[id])
+	}
+	return out
+}
+
+// fileAt returns the first package (by ID) and file containing pos.
+func (m *dstManager) fileAt(pos token.Pos) (*packages.Package, *ast.File) {
+	for _, pkg := range m.packages() {
+		if f := findFileInPkg(pkg, pos); f != nil {
+			return pkg, f
+		}
+	}
+	return nil, nil
+}
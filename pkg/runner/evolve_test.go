@@ -0,0 +1,129 @@
+package runner
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// evolveFiles is a module with a local interface, two implementations and an interface call site.
+var evolveFiles = map[string]string{
+	"go.mod": "module evolvetest\ngo 1.22\n",
+	"store/store.go": `package store
+
+import "os"
+
+// Store persists values.
+type Store interface {
+	Save(key string)
+}
+
+type File struct{}
+
+func (f *File) Save(key string) {
+	os.WriteFile(key, nil, 0644)
+}
+
+type Memory struct{ m map[string]string }
+
+func (m Memory) Save(key string) {
+	m.m[key] = key
+}
+`,
+	"app/app.go": `package app
+
+import "evolvetest/store"
+
+func Persist(s store.Store, keys []string) int {
+	for _, k := range keys {
+		s.Save(k)
+	}
+	return len(keys)
+}
+`,
+}
+
+// writeModule writes files below dir.
+func writeModule(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		_ = os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestRun_EvolveInterfaces verifies that the interface, every implementation and the interface
+// call sites gain the error together, and that the result compiles.
+func TestRun_EvolveInterfaces(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, evolveFiles)
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		EvolveInterfaces:     true,
+		Paths:                []string{"./..."},
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	storeSrc, _ := os.ReadFile(filepath.Join(tmpDir, "store", "store.go"))
+	appSrc, _ := os.ReadFile(filepath.Join(tmpDir, "app", "app.go"))
+	got := string(storeSrc) + string(appSrc)
+	for _, want := range []string{
+		"Save(key string) error\n}",
+		"func (f *File) Save(key string) error {",
+		"func (m Memory) Save(key string) error {",
+		"func Persist(s store.Store, keys []string) (int, error) {",
+		"if err := s.Save(k); err != nil {",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "ignored error") {
+		t.Errorf("unexpected log fallback:\n%s", got)
+	}
+
+	cmd := exec.Command("go", "build", "./...")
+	cmd.Dir = tmpDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("rewritten module does not build: %v\n%s", err, out)
+	}
+}
+
+// TestRun_EvolveInterfaces_Disabled verifies the log fallback remains the default.
+func TestRun_EvolveInterfaces_Disabled(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, evolveFiles)
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		Paths:                []string{"./..."},
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	got, _ := os.ReadFile(filepath.Join(tmpDir, "store", "store.go"))
+	if strings.Contains(string(got), "Save(key string) error") || !strings.Contains(string(got), "ignored error") {
+		t.Errorf("expected log fallback without evolution:\n%s", got)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"go/parser"
 	"go/token"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -129,3 +131,43 @@ func formatASTToBuf(fset *token.FileSet, node interface{}, filename string, buf
 
 	return nil
 }
+
+// TestRun_WrapTemplateAddsImport verifies that wrapping templates produce compilable code,
+// i.e. "fmt" is imported when fmt.Errorf is injected.
+func TestRun_WrapTemplateAddsImport(t *testing.T) {
+	tmpDir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module wraptest\ngo 1.22\n"), 0644)
+	src := `package wraptest
+
+import "os"
+
+func Load(name string) error {
+	os.Chdir(name)
+	return nil
+}
+`
+	path := filepath.Join(tmpDir, "load.go")
+	_ = os.WriteFile(path, []byte(src), 0644)
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableThirdPartyErr:  true,
+		ErrorTemplate:        "wrap-caller",
+		Paths:                []string{"."},
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	out, _ := os.ReadFile(path)
+	if !strings.Contains(string(out), `return fmt.Errorf("Load: os.Chdir: %w", err)`) {
+		t.Errorf("expected wrapped return:\n%s", out)
+	}
+	if !strings.Contains(string(out), `"fmt"`) {
+		t.Errorf("expected fmt import:\n%s", out)
+	}
+}
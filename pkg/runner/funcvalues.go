@@ -0,0 +1,156 @@
+package runner
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/refactor"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/rewrite"
+	"github.com/dave/dst"
+)
+
+// changedFuncValues is the outcome of applying an analysis.FuncValues plan.
+type changedFuncValues struct {
+	// funcs are the patched functions.
+	funcs []*types.Func
+	// slots maps the variables, fields and parameters holding the values to the new signature of
+	// their calls. The objects are not replaced, so call sites still refer to them.
+	slots map[*types.Var]*types.Signature
+}
+
+// planFuncValues computes the changes required by the values of fn (see
+// analysis.PlanFuncValues) before fn gains an error result.
+//
+// mgr: The DST manager owning the files.
+// registry: The interfaces, since methods stored with fn must not implement one.
+// fn: The function about to receive an error result.
+//
+// Returns an error if the values of fn cannot follow the new signature.
+func planFuncValues(mgr *dstManager, registry *analysis.InterfaceRegistry, fn *types.Func) (*analysis.FuncValues, error) {
+	plan, err := analysis.PlanFuncValues(fn, mgr.packages())
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range plan.Funcs {
+		if conflicts, _ := registry.CheckCompliance(f); len(conflicts) > 0 {
+			return nil, fmt.Errorf("%s is stored with %s and implements %s", f.FullName(), fn.Name(), conflicts[0].Interface.Name())
+		}
+	}
+	return plan, nil
+}
+
+// applyFuncValues adds the error result to the function types, literals and functions of plan.
+//
+// mgr: The DST manager owning the files.
+// plan: The changes computed by planFuncValues.
+// done: The declarations already changed during the pass; updated with the changes of plan.
+//
+// Returns the changed functions and slots for propagation.
+func applyFuncValues(mgr *dstManager, plan *analysis.FuncValues, done map[token.Pos]bool) (*changedFuncValues, error) {
+	out := &changedFuncValues{slots: make(map[*types.Var]*types.Signature)}
+
+	for _, ft := range plan.TypeExprs {
+		if done[ft.Pos()] {
+			continue
+		}
+		done[ft.Pos()] = true
+		node, file, err := mgr.dstNode(ft)
+		if err != nil {
+			return nil, err
+		}
+		dstType, ok := node.(*dst.FuncType)
+		if !ok {
+			return nil, fmt.Errorf("function type at %s not found in DST", mgr.fset.Position(ft.Pos()))
+		}
+		if _, err := refactor.AddErrorToFuncTypeDST(dstType); err != nil {
+			return nil, err
+		}
+		mgr.MarkModified(file)
+	}
+
+	for _, lit := range plan.Lits {
+		if done[lit.Pos()] {
+			continue
+		}
+		done[lit.Pos()] = true
+		node, file, err := mgr.dstNode(lit)
+		if err != nil {
+			return nil, err
+		}
+		dstLit, ok := node.(*dst.FuncLit)
+		if !ok {
+			return nil, fmt.Errorf("function literal at %s not found in DST", mgr.fset.Position(lit.Pos()))
+		}
+		if _, err := refactor.AddErrorToFuncLitDST(dstLit); err != nil {
+			return nil, err
+		}
+		mgr.MarkModified(file)
+		// Points inside the literal return the error from now on.
+		for _, pkg := range mgr.pkgs {
+			if tv, ok := pkg.TypesInfo.Types[lit]; ok {
+				if sig, ok := tv.Type.(*types.Signature); ok {
+					tv.Type = refactor.WithErrorResult(sig, pkg.Types)
+					pkg.TypesInfo.Types[lit] = tv
+				}
+			}
+		}
+	}
+
+	for _, fn := range plan.Funcs {
+		if done[fn.Pos()] {
+			continue
+		}
+		done[fn.Pos()] = true
+		obj, err := addErrorToFunc(mgr, fn)
+		if err != nil {
+			return nil, err
+		}
+		out.funcs = append(out.funcs, obj)
+	}
+
+	for _, v := range plan.Slots {
+		if sig := elemSignature(v.Type()); sig != nil {
+			out.slots[v] = refactor.WithErrorResult(sig, v.Pkg())
+		}
+	}
+	return out, nil
+}
+
+// dstNode returns the DST node of the AST node n and the file containing it.
+func (m *dstManager) dstNode(n ast.Node) (dst.Node, *ast.File, error) {
+	pkg, file := m.fileAt(n.Pos())
+	if file == nil {
+		return nil, nil, fmt.Errorf("file of %s not found", m.fset.Position(n.Pos()))
+	}
+	dstFile, err := m.Get(pkg, file)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := rewrite.FindDstNode(m.fset, dstFile, file, n)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res.Node, file, nil
+}
+
+// elemSignature returns the signature of t, a function type or a (nested) map, slice or array of
+// them, or nil.
+func elemSignature(t types.Type) *types.Signature {
+	for {
+		switch u := t.Underlying().(type) {
+		case *types.Signature:
+			return u
+		case *types.Map:
+			t = u.Elem()
+		case *types.Slice:
+			t = u.Elem()
+		case *types.Array:
+			t = u.Elem()
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,455 @@
+package runner
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// TestRun_FuncValues verifies that method values, struct fields, maps of functions and function
+// parameters follow a function gaining an error result, and that the result compiles.
+func TestRun_FuncValues(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, map[string]string{
+		"go.mod": "module fvtest\ngo 1.22\n",
+		"lib/lib.go": `package lib
+
+import "os"
+
+type Store struct{ Dir string }
+
+func (s *Store) Load() {
+	os.ReadFile(s.Dir)
+}
+
+func clean() {
+	os.Remove("tmp")
+}
+
+func noop() {}
+
+type Task struct {
+	Name string
+	Run  func()
+}
+
+var handlers = map[string]func(){
+	"clean": clean,
+	"noop":  noop,
+}
+
+func retry(f func()) {
+	f()
+}
+
+func Sync(s *Store, name string) int {
+	cb := s.Load
+	cb()
+	t := Task{Name: "load", Run: s.Load}
+	t.Run()
+	retry(clean)
+	handlers[name]()
+	handlers["lit"] = func() { println("lit") }
+	return len(handlers)
+}
+`,
+	})
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		Paths:                []string{"./..."},
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	got, _ := os.ReadFile(filepath.Join(tmpDir, "lib", "lib.go"))
+	for _, want := range []string{
+		"func (s *Store) Load() error {",
+		"func noop() error { return nil }",
+		"Run  func() error\n",
+		"var handlers = map[string]func() error{",
+		"func retry(f func() error) error {",
+		"func Sync(s *Store, name string) (int, error) {",
+		"cb := s.Load\n\tif err := cb(); err != nil {",
+		"if err := t.Run(); err != nil {",
+		"if err := retry(clean); err != nil {",
+		"if err := handlers[name](); err != nil {",
+		`handlers["lit"] = func() error { println("lit"); return nil }`,
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("lib.go missing %q:\n%s", want, got)
+		}
+	}
+
+	cmd := exec.Command("go", "build", "./...")
+	cmd.Dir = tmpDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("rewritten module does not build: %v\n%s", err, out)
+	}
+}
+
+// TestRun_FuncValues_Expr verifies that the calls of function values in an expression are each
+// checked in a temporary, in source order, keeping the rest of the expression.
+func TestRun_FuncValues_Expr(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, map[string]string{
+		"go.mod": "module fvtest\ngo 1.22\n",
+		"lib/lib.go": `package lib
+
+import "os"
+
+type Service struct{ hook func(string) int }
+
+func load(s string) int {
+	os.Remove(s)
+	return len(s)
+}
+
+func apply(f func(string) int, s string) int {
+	return f(s)
+}
+
+func Sum(s *Service, handlers map[string]func(string) int) int {
+	cb := load
+	s.hook = load
+	handlers["x"] = load
+	total := cb("a") + handlers["x"]("b") + s.hook("c")
+	total += apply(load, "d")
+	return total
+}
+`,
+	})
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		Paths:                []string{"./..."},
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	got, _ := os.ReadFile(filepath.Join(tmpDir, "lib", "lib.go"))
+	out := string(got)
+	last := -1
+	for _, call := range []string{`cb("a")`, `handlers["x"]("b")`, `s.hook("c")`, `apply(load, "d")`} {
+		idx := strings.Index(out, ", err := "+call+"\n\tif err != nil {\n\t\treturn 0, err\n\t}\n")
+		if idx < 0 {
+			t.Errorf("lib.go missing the check of %s:\n%s", call, out)
+			continue
+		}
+		if idx < last {
+			t.Errorf("the check of %s is out of source order:\n%s", call, out)
+		}
+		last = idx
+	}
+	for _, want := range []string{"\ttotal := v", "\ttotal += v"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("lib.go missing %q:\n%s", want, out)
+		}
+	}
+
+	cmd := exec.Command("go", "build", "./...")
+	cmd.Dir = tmpDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("rewritten module does not build: %v\n%s", err, out)
+	}
+}
+
+// TestRun_FuncValues_Fallback verifies that functions stored where the type cannot change log the
+// error instead.
+func TestRun_FuncValues_Fallback(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, map[string]string{
+		"go.mod": "module fvtest\ngo 1.22\n",
+		"lib/lib.go": `package lib
+
+import (
+	"os"
+	"time"
+)
+
+type Hook func()
+
+var hooks []Hook
+
+func tidy() {
+	os.Remove("x")
+}
+
+func prune() {
+	os.Remove("y")
+}
+
+func Start() {
+	time.AfterFunc(time.Second, tidy)
+	hooks = append(hooks, prune)
+}
+`,
+	})
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		Paths:                []string{"./..."},
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	got, _ := os.ReadFile(filepath.Join(tmpDir, "lib", "lib.go"))
+	if strings.Contains(string(got), "error {") || strings.Count(string(got), "ignored error") != 2 {
+		t.Errorf("expected both errors to be logged:\n%s", got)
+	}
+}
+
+// TestCalleeCall verifies that only calls of the identifier are returned.
+func TestCalleeCall(t *testing.T) {
+	src := `package p
+
+func f() {
+	load()
+	s.Load()
+	run[int]()
+	handlers["x"]()
+	(load)()
+	retry(load)
+	cb := s.Load
+	wrap(load)()
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	ast.Inspect(file, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok || (id.Name != "load" && id.Name != "Load" && id.Name != "run" && id.Name != "handlers") {
+			return true
+		}
+		path, _ := astutil.PathEnclosingInterval(file, id.Pos(), id.End())
+		if call := calleeCall(path); call != nil {
+			got = append(got, fset.Position(call.Pos()).String())
+		}
+		return true
+	})
+	want := []string{"p.go:4:2", "p.go:5:2", "p.go:6:2", "p.go:7:2", "p.go:8:2"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+}
+
+// TestRun_FuncLits verifies that function literals gain an error result where their values can
+// follow, capture it for synchronous callbacks, handle it in framework callbacks, and log it
+// otherwise.
+func TestRun_FuncLits(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, map[string]string{
+		"go.mod": "module littest\ngo 1.22\n",
+		"lib/lib.go": `package lib
+
+import (
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+type Conn struct {
+	once sync.Once
+}
+
+func (c *Conn) connect() error { return nil }
+
+func (c *Conn) Open() {
+	c.once.Do(func() {
+		c.connect()
+	})
+}
+
+func Clean(dirs []string) {
+	remove := func(d string) {
+		os.Remove(d)
+	}
+	for _, d := range dirs {
+		remove(d)
+	}
+}
+
+func Now() {
+	func() {
+		os.Remove("x")
+	}()
+}
+
+func Serve() {
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		os.Remove("y")
+	})
+}
+
+func Later() {
+	time.AfterFunc(time.Second, func() {
+		os.Remove("z")
+	})
+}
+`,
+	})
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		Paths:                []string{"./..."},
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	got, _ := os.ReadFile(filepath.Join(tmpDir, "lib", "lib.go"))
+	for _, want := range []string{
+		"func (c *Conn) Open() error {\n\tvar err error\n\tc.once.Do(func() {\n\t\tif err = c.connect(); err != nil {\n\t\t\treturn\n\t\t}\n\t})\n\tif err != nil {",
+		"func Clean(dirs []string) error {",
+		"remove := func(d string) error {",
+		"if err := remove(d); err != nil {",
+		"func Now() error {\n\tif err := func() error {",
+		"if err := os.Remove(\"y\"); err != nil {\n\t\t\thttp.Error(w, err.Error(), http.StatusInternalServerError)",
+		"func Later() {",
+		"ignored error in Remove",
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("lib.go missing %q:\n%s", want, got)
+		}
+	}
+
+	cmd := exec.Command("go", "build", "./...")
+	cmd.Dir = tmpDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("rewritten module does not build: %v\n%s", err, out)
+	}
+}
+
+// TestRun_FuncLitsFirstError verifies that literals passed to functions calling them repeatedly keep
+// the first error: a later successful call must not clear it.
+func TestRun_FuncLitsFirstError(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, map[string]string{
+		"go.mod": "module firsterr\ngo 1.22\n",
+		"lib/lib.go": `package lib
+
+import (
+	"errors"
+	"slices"
+	"sort"
+)
+
+var calls int
+
+// check fails on its first call only.
+func check(s string) error {
+	calls++
+	if calls == 1 {
+		return errors.New("first")
+	}
+	return nil
+}
+
+func weight(s string) (int, error) {
+	return len(s), check(s)
+}
+
+func Order(names []string) {
+	sort.Slice(names, func(i, j int) bool {
+		check(names[i])
+		return names[i] < names[j]
+	})
+}
+
+func ByWeight(names []string) {
+	slices.SortFunc(names, func(a, b string) int {
+		var n int
+		n, _ = weight(a)
+		return n - len(b)
+	})
+}
+`,
+	})
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		Paths:                []string{"./..."},
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	got, _ := os.ReadFile(filepath.Join(tmpDir, "lib", "lib.go"))
+	for _, want := range []string{
+		"if e := check(names[i]); e != nil && err == nil {\n\t\t\terr = e\n\t\t}\n\t\treturn names[i] < names[j]",
+		"var e error\n\t\tn, e = weight(a)\n\t\tif e != nil && err == nil {\n\t\t\terr = e\n\t\t}",
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("lib.go missing %q:\n%s", want, got)
+		}
+	}
+
+	// The first comparison fails and the following ones succeed.
+	writeModule(t, tmpDir, map[string]string{
+		"lib/first_test.go": `package lib
+
+import "testing"
+
+func TestFirstError(t *testing.T) {
+	calls = 0
+	if err := Order([]string{"c", "b", "a"}); err == nil {
+		t.Error("Order lost the first error")
+	}
+	calls = 0
+	if err := ByWeight([]string{"c", "bb", "a"}); err == nil {
+		t.Error("ByWeight lost the first error")
+	}
+}
+`,
+	})
+	cmd := exec.Command("go", "test", "./lib")
+	cmd.Dir = tmpDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("rewritten module fails: %v\n%s", err, out)
+	}
+}
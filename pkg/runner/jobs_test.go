@@ -0,0 +1,63 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// jobsFiles is a module with several packages importing each other.
+func jobsFiles() map[string]string {
+	files := map[string]string{
+		"go.mod":  "module example.com/jobs\ngo 1.22\n",
+		"main.go": "package main\n\nimport (\n\t\"example.com/jobs/p0\"\n\t\"example.com/jobs/p1\"\n\t\"example.com/jobs/p2\"\n\t\"example.com/jobs/p3\"\n)\n\nfunc main() {\n\tp0.Run()\n\tp1.Run()\n\tp2.Run()\n\tp3.Run()\n}\n",
+	}
+	for i := 0; i < 4; i++ {
+		files[fmt.Sprintf("p%d/p.go", i)] = fmt.Sprintf("package p%d\n\nimport \"os\"\n\nfunc Run() {\n\tos.Remove(\"a\")\n\tclean()\n}\n\nfunc clean() {\n\t_ = os.Chdir(\"b\")\n}\n", i)
+	}
+	return files
+}
+
+// TestRun_JobsDeterministic verifies that the rewritten files do not depend on the number of jobs.
+func TestRun_JobsDeterministic(t *testing.T) {
+	oldWd, _ := os.Getwd()
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	results := make([]map[string]string, 0, 2)
+	for _, jobs := range []int{1, 8} {
+		dir := t.TempDir()
+		writeModule(t, dir, jobsFiles())
+		_ = os.Chdir(dir)
+
+		opts := Options{
+			EnablePreexistingErr: true,
+			EnableNonExistingErr: true,
+			EnableThirdPartyErr:  true,
+			Paths:                []string{"./..."},
+			Jobs:                 jobs,
+		}
+		if err := Run(opts); err != nil {
+			t.Fatalf("Run with %d jobs failed: %v", jobs, err)
+		}
+
+		out := make(map[string]string)
+		for name := range jobsFiles() {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			out[name] = string(data)
+		}
+		results = append(results, out)
+	}
+
+	for name, serial := range results[0] {
+		if results[1][name] != serial {
+			t.Errorf("%s differs between 1 and 8 jobs:\n%s\n---\n%s", name, serial, results[1][name])
+		}
+	}
+	if results[0]["main.go"] == jobsFiles()["main.go"] {
+		t.Error("expected the callers in main.go to be updated")
+	}
+}
@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/journal"
+)
+
+// TestRun_Journal verifies that the files written by a run are journaled with their original
+// content and written without leaving temporary files behind.
+func TestRun_Journal(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := "package main\n\nimport \"os\"\n\nfunc main() {\n\tos.Remove(\"x\")\n}\n"
+	writeModule(t, tmpDir, map[string]string{
+		"go.mod":  "module example.com/journal\ngo 1.22\n",
+		"main.go": src,
+	})
+	_ = os.Chmod(filepath.Join(tmpDir, "main.go"), 0600)
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		Paths:                []string{"."},
+		JournalDir:           journal.DefaultRoot,
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	run, err := journal.Latest(filepath.Join(tmpDir, journal.DefaultRoot))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(run.Files) != 1 || run.Files[0].Path != filepath.Join(tmpDir, "main.go") {
+		t.Fatalf("unexpected journal: %+v", run.Files)
+	}
+	if run.Files[0].Before != journal.Hash([]byte(src)) {
+		t.Error("journal should record the hash of the original content")
+	}
+	backup, _ := os.ReadFile(filepath.Join(run.Dir(), run.Files[0].Backup))
+	if string(backup) != src {
+		t.Errorf("backup = %q, want original content", backup)
+	}
+
+	info, _ := os.Stat(filepath.Join(tmpDir, "main.go"))
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("file mode changed to %v", info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(tmpDir)
+	for _, e := range entries {
+		if filepath.Ext(e.Name()) != ".go" && e.Name() != "go.mod" && e.Name() != journal.DefaultRoot {
+			t.Errorf("unexpected file left behind: %s", e.Name())
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/rewrite"
+)
+
+// TestRun_SlogHandlers verifies the slog strategies for log rules and entry points.
+func TestRun_SlogHandlers(t *testing.T) {
+	tmpDir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module slogtest\ngo 1.22\n"), 0644)
+	src := `package main
+
+import (
+	"log/slog"
+	"os"
+)
+
+type server struct {
+	logger *slog.Logger
+}
+
+func (s *server) flush(f *os.File) {
+	f.Sync()
+}
+
+func main() {
+	os.Chdir("/")
+}
+`
+	path := filepath.Join(tmpDir, "main.go")
+	_ = os.WriteFile(path, []byte(src), 0644)
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		Paths:                []string{"."},
+		MainHandler:          "slog-error-exit",
+		LogHandler:           rewrite.LogHandlerSlog,
+		Rules:                []rewrite.Rule{{Symbol: "(*os.File).Sync", Action: rewrite.ActionLog}},
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	out, _ := os.ReadFile(path)
+	got := string(out)
+	for _, want := range []string{
+		`s.logger.Error("ignored error", "op", "Sync", "err", err)`,
+		"slog.Error(\"operation failed\", \"op\", \"Chdir\", \"err\", err)\n\t\tos.Exit(1)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, `"log"`) {
+		t.Errorf("log import should not be added:\n%s", got)
+	}
+}
+
+// TestRun_InvalidLogger verifies that an invalid logger expression is rejected before loading.
+func TestRun_InvalidLogger(t *testing.T) {
+	err := Run(Options{Paths: []string{"."}, Overrides: []Override{{Logger: "s.("}}})
+	if err == nil || !strings.Contains(err.Error(), "invalid logger expression") {
+		t.Errorf("expected invalid logger error, got %v", err)
+	}
+}
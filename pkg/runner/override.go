@@ -0,0 +1,229 @@
+package runner
+
+import (
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/filter"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/rewrite"
+	"golang.org/x/tools/go/packages"
+)
+
+// Override adjusts the Options for the packages matching Patterns.
+// Zero-valued fields (empty strings, nil slices and nil pointers) inherit the base Options.
+type Override struct {
+	// Patterns select the packages the override applies to. A pattern is either a directory
+	// relative to Dir (e.g. "internal/api/...", "./cmd/*") or an import path pattern
+	// (e.g. "example.com/mod/internal/..."). A trailing "/..." matches the directory and all
+	// sub-directories; other patterns use path.Match syntax.
+	Patterns []string
+	// Dir is the directory relative patterns are resolved against (usually the config file directory).
+	Dir string
+
+	ErrorTemplate        string
+	MainHandler          string
+	ExcludeGlob          []string
+	ExcludeSymbolGlob    []string
+	EnablePreexistingErr *bool
+	EnableNonExistingErr *bool
+	EnableThirdPartyErr  *bool
+	EnableTestRefactor   *bool
+	UseDefaultExclusions *bool
+	CompatWrappers       *bool
+	CompatGlob           []string
+	CompatHandler        string
+	EvolveInterfaces     *bool
+	GoStrategy           string
+	LogHandler           string
+	Logger               string
+	ClosePolicy          string
+	SyncOnClose          *bool
+	// Rules take precedence over the base rules for matching packages.
+	Rules []rewrite.Rule
+}
+
+// Matches reports whether the override applies to pkg.
+//
+// pkg: The package to check.
+func (o Override) Matches(pkg *packages.Package) bool {
+	dir := packageDir(pkg)
+	for _, pattern := range o.Patterns {
+		if matchPattern(pattern, pkg.PkgPath) {
+			return true
+		}
+		if dir == "" {
+			continue
+		}
+		base := o.Dir
+		if base == "" {
+			base = "."
+		}
+		absBase, err := filepath.Abs(base)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(absBase, dir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if matchPattern(path.Clean(strings.TrimPrefix(filepath.ToSlash(pattern), "./")), filepath.ToSlash(rel)) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply overlays the set fields of the override onto opts.
+func (o Override) apply(opts Options) Options {
+	if o.ErrorTemplate != "" {
+		opts.ErrorTemplate = o.ErrorTemplate
+	}
+	if o.MainHandler != "" {
+		opts.MainHandler = o.MainHandler
+	}
+	if o.CompatHandler != "" {
+		opts.CompatHandler = o.CompatHandler
+	}
+	if o.GoStrategy != "" {
+		opts.GoStrategy = o.GoStrategy
+	}
+	if o.LogHandler != "" {
+		opts.LogHandler = o.LogHandler
+	}
+	if o.Logger != "" {
+		opts.Logger = o.Logger
+	}
+	if o.ClosePolicy != "" {
+		opts.ClosePolicy = o.ClosePolicy
+	}
+	if o.SyncOnClose != nil {
+		opts.SyncOnClose = *o.SyncOnClose
+	}
+	if o.CompatGlob != nil {
+		opts.CompatGlob = append(append([]string{}, opts.CompatGlob...), o.CompatGlob...)
+	}
+	if o.Rules != nil {
+		opts.Rules = append(append([]rewrite.Rule{}, o.Rules...), opts.Rules...)
+	}
+	if o.ExcludeGlob != nil {
+		opts.ExcludeGlob = append(append([]string{}, opts.ExcludeGlob...), o.ExcludeGlob...)
+	}
+	if o.ExcludeSymbolGlob != nil {
+		opts.ExcludeSymbolGlob = append(append([]string{}, opts.ExcludeSymbolGlob...), o.ExcludeSymbolGlob...)
+	}
+	setBool(&opts.EnablePreexistingErr, o.EnablePreexistingErr)
+	setBool(&opts.EnableNonExistingErr, o.EnableNonExistingErr)
+	setBool(&opts.EnableThirdPartyErr, o.EnableThirdPartyErr)
+	setBool(&opts.EnableTestRefactor, o.EnableTestRefactor)
+	setBool(&opts.UseDefaultExclusions, o.UseDefaultExclusions)
+	setBool(&opts.CompatWrappers, o.CompatWrappers)
+	setBool(&opts.EvolveInterfaces, o.EvolveInterfaces)
+	return opts
+}
+
+// forPackage resolves the effective options for pkg by applying every matching override in order.
+//
+// pkg: The package being processed.
+func (opts Options) forPackage(pkg *packages.Package) Options {
+	if pkg == nil {
+		return opts
+	}
+	eff := opts
+	for _, o := range opts.Overrides {
+		if o.Matches(pkg) {
+			eff = o.apply(eff)
+		}
+	}
+	if eff.GoStrategy == rewrite.GoStrategyErrgroup && pkg.Module != nil && opts.noErrgroup[pkg.Module.GoMod] {
+		eff.GoStrategy = rewrite.GoStrategyHandler
+	}
+	return eff
+}
+
+// filter builds the exclusion filter described by the options.
+func (opts Options) filter() *filter.Filter {
+	globs := append([]string{}, opts.ExcludeSymbolGlob...)
+	if opts.UseDefaultExclusions {
+		globs = append(globs, filter.GetDefaults()...)
+	}
+	return filter.New(opts.ExcludeGlob, globs)
+}
+
+// detect runs detection with the filters that apply to each package.
+// Packages sharing the same set of matching overrides are analyzed together.
+//
+// pkgs: The loaded packages.
+// opts: The base options.
+func detect(pkgs []*packages.Package, opts Options) ([]analysis.InjectionPoint, error) {
+	if len(opts.Overrides) == 0 {
+		points, err := analysis.DetectParallel(pkgs, opts.filter(), opts.DryRun, opts.Jobs)
+		return withoutLeftCloses(points, opts), err
+	}
+
+	var order []string
+	groups := make(map[string][]*packages.Package)
+	for _, pkg := range pkgs {
+		var key strings.Builder
+		for i, o := range opts.Overrides {
+			if o.Matches(pkg) {
+				key.WriteString(strconv.Itoa(i))
+				key.WriteByte(',')
+			}
+		}
+		k := key.String()
+		if _, ok := groups[k]; !ok {
+			order = append(order, k)
+		}
+		groups[k] = append(groups[k], pkg)
+	}
+
+	var points []analysis.InjectionPoint
+	for _, k := range order {
+		group := groups[k]
+		eff := opts.forPackage(group[0])
+		found, err := analysis.DetectParallel(group, eff.filter(), opts.DryRun, opts.Jobs)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, found...)
+	}
+	return withoutLeftCloses(points, opts), nil
+}
+
+// packageDir returns the absolute directory of the package sources, or "" if unknown.
+func packageDir(pkg *packages.Package) string {
+	if pkg.Dir != "" {
+		return pkg.Dir
+	}
+	files := pkg.GoFiles
+	if len(files) == 0 {
+		files = pkg.CompiledGoFiles
+	}
+	if len(files) == 0 {
+		return ""
+	}
+	return filepath.Dir(files[0])
+}
+
+// matchPattern matches a slash separated name against a package pattern.
+// "x/..." matches "x" and everything below it; "..." alone matches everything.
+func matchPattern(pattern, name string) bool {
+	if pattern == "..." {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "/..."); ok {
+		return name == prefix || strings.HasPrefix(name, prefix+"/")
+	}
+	ok, err := path.Match(pattern, name)
+	return err == nil && ok
+}
+
+// setBool assigns *v to *dst when v is set.
+func setBool(dst *bool, v *bool) {
+	if v != nil {
+		*dst = *v
+	}
+}
@@ -0,0 +1,155 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// TestOverride_Matches verifies directory and import path pattern matching.
+func TestOverride_Matches(t *testing.T) {
+	root := filepath.FromSlash("/repo")
+	pkg := &packages.Package{
+		PkgPath: "example.com/mod/internal/api/v1",
+		GoFiles: []string{filepath.Join(root, "internal", "api", "v1", "a.go")},
+	}
+
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{"internal/api/...", true},
+		{"./internal/api/...", true},
+		{"internal/api", false},
+		{"internal/*/v1", true},
+		{"example.com/mod/internal/...", true},
+		{"cmd/...", false},
+		{"./...", true},
+	}
+	for _, tt := range tests {
+		o := Override{Patterns: []string{tt.pattern}, Dir: root}
+		if got := o.Matches(pkg); got != tt.want {
+			t.Errorf("pattern %q: expected %v, got %v", tt.pattern, tt.want, got)
+		}
+	}
+
+	outside := Override{Patterns: []string{"..."}, Dir: filepath.Join(root, "cmd")}
+	if !outside.Matches(pkg) {
+		t.Error("bare '...' should match every package")
+	}
+	sibling := Override{Patterns: []string{"./*"}, Dir: filepath.Join(root, "cmd")}
+	if sibling.Matches(pkg) {
+		t.Error("relative pattern must not match packages outside Dir")
+	}
+}
+
+// TestOptions_ForPackage verifies overrides are applied in order on top of the base options.
+func TestOptions_ForPackage(t *testing.T) {
+	off := false
+	opts := Options{
+		ErrorTemplate:        "{return-zero}, err",
+		MainHandler:          "log-fatal",
+		EnableNonExistingErr: true,
+		ExcludeSymbolGlob:    []string{"os.*"},
+		Overrides: []Override{
+			{Patterns: []string{"example.com/mod/..."}, ErrorTemplate: "first", ExcludeSymbolGlob: []string{"io.*"}},
+			{Patterns: []string{"example.com/mod/api"}, ErrorTemplate: "second", EnableNonExistingErr: &off},
+		},
+	}
+
+	api := opts.forPackage(&packages.Package{PkgPath: "example.com/mod/api"})
+	if api.ErrorTemplate != "second" || api.EnableNonExistingErr || api.MainHandler != "log-fatal" {
+		t.Errorf("unexpected effective options: %+v", api)
+	}
+	if strings.Join(api.ExcludeSymbolGlob, ",") != "os.*,io.*" {
+		t.Errorf("unexpected globs: %v", api.ExcludeSymbolGlob)
+	}
+	if strings.Join(opts.ExcludeSymbolGlob, ",") != "os.*" {
+		t.Error("base options must not be mutated")
+	}
+
+	other := opts.forPackage(&packages.Package{PkgPath: "example.org/other"})
+	if other.ErrorTemplate != "{return-zero}, err" || !other.EnableNonExistingErr {
+		t.Errorf("non-matching package should keep base options: %+v", other)
+	}
+}
+
+// TestRun_Overrides verifies per-package templates and disabled signature changes end-to-end.
+func TestRun_Overrides(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module overridetest\ngo 1.22\n",
+		"api/api.go": `package api
+
+import (
+	"fmt"
+	"os"
+)
+
+var _ = fmt.Sprint
+
+func Cleanup() {
+	os.Remove("x")
+}
+
+func Wrapped() error {
+	os.Remove("y")
+	return nil
+}
+`,
+		"core/core.go": `package core
+
+import "os"
+
+func Cleanup() error {
+	os.Remove("z")
+	return nil
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		_ = os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	off := false
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		ErrorTemplate:        "{return-zero}, err",
+		Paths:                []string{"./..."},
+		Overrides: []Override{{
+			Patterns:             []string{"api/..."},
+			Dir:                  tmpDir,
+			ErrorTemplate:        `{return-zero}, fmt.Errorf("api: %w", err)`,
+			EnableNonExistingErr: &off,
+		}},
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	api, _ := os.ReadFile(filepath.Join(tmpDir, "api", "api.go"))
+	if strings.Contains(string(api), "func Cleanup() error") {
+		t.Errorf("signature changed despite override:\n%s", api)
+	}
+	if !strings.Contains(string(api), `fmt.Errorf("api: %w", err)`) {
+		t.Errorf("override template not applied:\n%s", api)
+	}
+
+	core, _ := os.ReadFile(filepath.Join(tmpDir, "core", "core.go"))
+	if strings.Contains(string(core), "api:") || !strings.Contains(string(core), "return err") {
+		t.Errorf("base template not applied outside override:\n%s", core)
+	}
+}
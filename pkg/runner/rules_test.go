@@ -0,0 +1,126 @@
+package runner
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/rewrite"
+)
+
+// TestRun_Rules verifies that per-callee rules select the handling for each call in one run.
+func TestRun_Rules(t *testing.T) {
+	tmpDir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module rulestest\ngo 1.22\n"), 0644)
+	src := `package rulestest
+
+import (
+	"os"
+	"strconv"
+)
+
+func Flush(f *os.File) {
+	f.Sync()
+}
+
+func Parse(s string) (int, error) {
+	_, _ = strconv.Atoi(s)
+	os.Chdir(s)
+	return 0, nil
+}
+`
+	path := filepath.Join(tmpDir, "rules.go")
+	_ = os.WriteFile(path, []byte(src), 0644)
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		Paths:                []string{"."},
+		Rules: []rewrite.Rule{
+			{Symbol: "(*os.File).Sync", Action: rewrite.ActionLog},
+			{Symbol: "strconv.*", Template: `{return-zero}, fmt.Errorf("parse %q: %w", s, err)`},
+		},
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	out, _ := os.ReadFile(path)
+	got := string(out)
+	if !strings.Contains(got, "func Flush(f *os.File) {") {
+		t.Errorf("log rule must not change the signature:\n%s", got)
+	}
+	for _, want := range []string{
+		`log.Printf("ignored error in Sync: %v", err)`,
+		`return 0, fmt.Errorf("parse %q: %w", s, err)`,
+		"return 0, err",
+		`"fmt"`,
+		`"log"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+}
+
+// TestRun_RulesDeferLog verifies that a log rule for a deferred call applies to its defer once the
+// enclosing function gains an error result for another call.
+func TestRun_RulesDeferLog(t *testing.T) {
+	tmpDir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module rulestest\ngo 1.22\n"), 0644)
+	src := `package rulestest
+
+import "os"
+
+func Save(p string, d []byte) {
+	f, _ := os.Create(p)
+	defer f.Close()
+	f.Write(d)
+}
+`
+	path := filepath.Join(tmpDir, "save.go")
+	_ = os.WriteFile(path, []byte(src), 0644)
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		Paths:                []string{"."},
+		Rules:                []rewrite.Rule{{Symbol: "(*os.File).Close", Action: rewrite.ActionLog}},
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	out, _ := os.ReadFile(path)
+	got := string(out)
+	for _, want := range []string{
+		"func Save(p string, d []byte) error {",
+		`log.Printf("ignored error in Close: %v", err)`,
+		"if _, err := f.Write(d); err != nil {",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "errors.Join") {
+		t.Errorf("the logged Close must not be joined to the result:\n%s", got)
+	}
+
+	cmd := exec.Command("go", "vet", "./...")
+	cmd.Dir = tmpDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("rewritten module does not vet: %v\n%s", err, out)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"go/ast"
 	"go/format"
+	"go/parser"
 	"go/token"
 	"go/types"
 	"log"
@@ -12,8 +13,11 @@ import (
 	"sort"
 
 	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/dstmap"
 	"github.com/SamuelMarks/go-auto-err-handling/pkg/filter"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/journal"
 	"github.com/SamuelMarks/go-auto-err-handling/pkg/loader"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/parallel"
 	"github.com/SamuelMarks/go-auto-err-handling/pkg/refactor"
 	"github.com/SamuelMarks/go-auto-err-handling/pkg/report"
 	"github.com/SamuelMarks/go-auto-err-handling/pkg/rewrite"
@@ -42,10 +46,98 @@ type Options struct {
 	Paths                []string
 	MainHandler          string
 	ErrorTemplate        string
-	Reporter             *report.Reporter
+	// Format selects the report emitted in Check mode ("text" or "sarif").
+	Format string
+	// Output is the file the Check mode report is written to. Defaults to stdout.
+	Output string
+	// Version is the tool version recorded in machine-readable reports.
+	Version string
+	// FromErrcheck replaces detection with the locations listed in an errcheck (or golangci-lint JSON)
+	// report. "-" reads the report from stdin. Reports are applied in a single pass, since positions
+	// become stale once files are rewritten.
+	FromErrcheck string
+	// Rules select per-callee templates or handling strategies. The first matching rule wins.
+	Rules []rewrite.Rule
+	// Callbacks are fixed-signature shapes handled in place, matched before rewrite.DefaultCallbacks.
+	Callbacks []rewrite.Callback
+	// CompatWrappers keeps the signatures of exported functions that need an error result: the body
+	// moves to a new error-returning variant (e.g. FooE) and the original becomes a deprecated wrapper.
+	CompatWrappers bool
+	// CompatGlob selects additional functions (symbol globs, see filter.MatchSymbol) handled like CompatWrappers.
+	CompatGlob []string
+	// CompatSuffix is appended to the variant name. Defaults to "E".
+	CompatSuffix string
+	// CompatHandler is the error handling in the wrappers: "log" (default), "log-fatal", "os-exit",
+	// "panic", "slog-error" or "slog-error-exit".
+	CompatHandler string
+	// EvolveInterfaces adds the error result to conflicting interface methods and all of their
+	// implementations together, instead of logging the error, when they are all declared in the
+	// loaded packages.
+	EvolveInterfaces bool
+	// GoStrategy selects the handling of go statements: "handler" (default) wraps each in a closure
+	// calling MainHandler, "errgroup" runs the go statements of a function in an errgroup.Group and
+	// returns the error of Wait.
+	GoStrategy string
+	// LogHandler selects the logging of errors handled in place (log rules and fallbacks):
+	// "log" (default) uses log.Printf, "slog-error" logs with slog at the Error level.
+	LogHandler string
+	// Logger is the logger expression used by the slog handlers (e.g. "s.logger" or
+	// "slog.Default()"). When empty, a *slog.Logger in scope is used, or the slog package functions.
+	Logger string
+	// ClosePolicy selects the handling of deferred calls releasing read-only resources, such as the
+	// Close of a file opened with os.Open: "ignore" (default) leaves them alone and does not report
+	// them, "nolint" marks them with a //nolint:errcheck comment, "join" joins their error like
+	// other deferred calls.
+	ClosePolicy string
+	// SyncOnClose syncs writable files before closing them in the deferred calls joining their error.
+	SyncOnClose bool
+	// NoVerify skips the type check of the rewritten files before they are written. By default,
+	// files that no longer compile are reverted and their injection points are skipped.
+	NoVerify bool
+	// JournalDir is the directory of the undo journal (see package journal). Every run that writes
+	// files records their original content there. Empty disables the journal.
+	JournalDir string
+	// DiffBase restricts the fixes to lines changed against this git revision (e.g. "origin/main"),
+	// including uncommitted and untracked files.
+	DiffBase string
+	// NewFromPatch restricts the fixes to lines added by this unified diff ("-" reads stdin).
+	NewFromPatch string
+	// Baseline is a baseline file (see report.Baseline); check mode only fails on findings that are
+	// not in it.
+	Baseline string
+	// WriteBaseline writes the fingerprints of the current findings to this file instead of
+	// failing. Implies Check.
+	WriteBaseline string
+	// MaxIterations caps the detect-and-fix iterations (DefaultMaxIterations if <= 0). Each
+	// iteration handles the errors exposed by the signature changes of the previous one.
+	MaxIterations int
+	// CacheDir is the directory of the check mode cache (see package cache). Empty disables it.
+	// Fix runs do not use the cache.
+	CacheDir string
+	// Jobs is the maximum number of packages analyzed and files decorated, rendered or type
+	// checked at a time (GOMAXPROCS if <= 0). The handling of the points and the propagation of
+	// signature changes stay sequential, and the output does not depend on it.
+	Jobs int
+	// Overrides adjust the options for specific packages, applied in order (later entries win).
+	Overrides []Override
+	Reporter  *report.Reporter
+
+	// noErrgroup holds the go.mod files of the modules that do not require golang.org/x/sync,
+	// whose go statements use GoStrategyHandler (see withoutErrgroup).
+	noErrgroup map[string]bool
+	// defers restricts the defer statements rewritten with the other points of a file to the
+	// points of the iteration, when those come from a report or are limited to changed lines (see
+	// rewrite.Injector.Defers). Nil rewrites every defer.
+	defers map[*ast.DeferStmt]bool
 }
 
+// DefaultMaxIterations is the default of Options.MaxIterations.
+const DefaultMaxIterations = 5
+
 func Run(opts Options) error {
+	if opts.WriteBaseline != "" {
+		opts.Check = true
+	}
 	if opts.Check {
 		opts.DryRun = true
 	}
@@ -53,44 +145,99 @@ func Run(opts Options) error {
 		opts.Reporter = report.New()
 	}
 
-	const maxIterations = 5
-	for i := 0; i < maxIterations; i++ {
-		prefix := fmt.Sprintf("[%d/%d]", i+1, maxIterations)
-		if opts.Check {
-			log.Printf("%s Analysis mode...", prefix)
-		} else {
-			log.Printf("%s Loading packages...", prefix)
+	var errcheckReport []byte
+	if opts.FromErrcheck != "" {
+		data, err := readErrcheckReport(opts.FromErrcheck, os.Stdin)
+		if err != nil {
+			return err
 		}
+		errcheckReport = data
+	}
 
-		pkgs, err := loader.LoadPackages(opts.Paths, ".")
-		if err != nil {
-			return fmt.Errorf("load failed: %w", err)
+	if err := validateLoggers(opts); err != nil {
+		return err
+	}
+
+	changes, err := loadChanges(opts, os.Stdin)
+	if err != nil {
+		return err
+	}
+	if changes != nil {
+		log.Printf("Restricting fixes to changed lines in %d files.", changes.Files())
+	}
+
+	if opts.Check {
+		return runCheck(opts, errcheckReport, changes)
+	}
+
+	var jrnl *journal.Journal
+	if opts.JournalDir != "" && !opts.DryRun {
+		jrnl = journal.New(opts.JournalDir)
+		defer func() {
+			if run := jrnl.Run(); run != nil {
+				log.Printf("Journaled %d files as run %s (revert with: auto-err undo %s)", len(run.Files), run.ID, run.ID)
+			}
+		}()
+	}
+
+	// rejected holds the points whose handling did not compile (see verify), by pointKey.
+	rejected := make(map[string]bool)
+
+	maxIterations := opts.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = DefaultMaxIterations
+	}
+	var (
+		session *loader.Session
+		touched []string // files decorated by the previous iteration
+		pending int      // unhandled errors found by the previous iteration
+	)
+	// Every break below means that there is nothing left to do; only running out of iterations
+	// leaves the code unconverged.
+	for i := 0; ; i++ {
+		if i == maxIterations {
+			log.Printf("[WARN] Stopped after %d iterations without converging: the last iteration handled %d errors and may have exposed new ones. Run again or raise --max-iterations.", maxIterations, pending)
+			opts.Reporter.SetIterations(i, false)
+			break
+		}
+		opts.Reporter.SetIterations(i+1, true)
+		prefix := fmt.Sprintf("[%d/%d]", i+1, maxIterations)
+
+		var pkgs []*packages.Package
+		if session == nil {
+			log.Printf("%s Loading packages...", prefix)
+			if session, err = loader.NewSession(opts.Paths, ".", opts.Jobs); err != nil {
+				return fmt.Errorf("load failed: %w", err)
+			}
+			pkgs = session.Packages()
+		} else {
+			var n int
+			if pkgs, n, err = session.Reload(touched); err != nil {
+				return fmt.Errorf("load failed: %w", err)
+			}
+			log.Printf("%s Reloaded %d of %d packages.", prefix, n, len(pkgs))
 		}
 		if len(pkgs) == 0 {
 			log.Println("No packages found.")
 			return nil
 		}
 
-		globs := opts.ExcludeSymbolGlob
-		if opts.UseDefaultExclusions {
-			globs = append(globs, filter.GetDefaults()...)
-		}
-		flt := filter.New(opts.ExcludeGlob, globs)
-
 		registry := analysis.NewInterfaceRegistry(pkgs)
 
-		points, err := analysis.Detect(pkgs, flt, opts.DryRun)
+		var points []analysis.InjectionPoint
+		if errcheckReport != nil {
+			points, err = reportPoints(pkgs, errcheckReport)
+		} else {
+			points, err = detect(pkgs, opts)
+		}
 		if err != nil {
 			return fmt.Errorf("analysis failed: %w", err)
 		}
-
-		if opts.Check {
-			if len(points) > 0 {
-				log.Printf("[FAIL] Found %d unhandled errors.", len(points))
-				return fmt.Errorf("check failed: %d unhandled errors found", len(points))
-			}
-			log.Println("[PASS] No unhandled errors.")
-			return nil
+		points = withoutRejected(points, rejected)
+		if changes != nil {
+			n := len(points)
+			points = inChanges(points, changes)
+			log.Printf("%d of %d unhandled errors are in changed lines.", len(points), n)
 		}
 
 		hasPanics := false
@@ -99,49 +246,114 @@ func Run(opts Options) error {
 		}
 
 		if len(points) == 0 && !hasPanics {
-			log.Println("Codebase is stable.")
+			if len(rejected) == 0 {
+				log.Println("Codebase is stable.")
+			}
 			break
 		}
 
 		log.Printf("Found %d unhandled errors.", len(points))
+		pending = len(points)
 
-		mgr := newDstManager(pkgs)
+		mgr := newDstManager(pkgs, opts.Jobs)
+		if err := mgr.prepare(points); err != nil {
+			return err
+		}
+		if errcheckReport != nil || changes != nil {
+			opts.defers = deferStmts(points)
+		}
 		count, err := applyRefactors(mgr, points, opts, registry)
 		if err != nil {
 			return err
 		}
 
+		// The rewrites mutate the syntax and type information of the decorated files, even those
+		// that end up reverted; the next iteration checks them again.
+		touched = mgr.decorated()
+
 		if count == 0 {
 			log.Println("No changes applied (filtered or stable).")
 			break
 		}
 
+		if !opts.NoVerify {
+			culprits, err := verify(mgr, session, opts, collectErrors(pkgs))
+			if err != nil {
+				return err
+			}
+			for _, p := range culprits {
+				rejected[pointKey(*p)] = true
+			}
+			if len(mgr.modified) == 0 {
+				log.Println("All changes were reverted.")
+				if len(culprits) == 0 {
+					break
+				}
+				continue
+			}
+		}
+
 		if opts.DryRun {
 			if err := mgr.PrintDiffs(os.Stdout); err != nil {
 				return err
 			}
 			break
 		} else {
-			if err := mgr.Save(); err != nil {
+			var before map[string][]byte
+			if changes != nil {
+				if before, err = readFiles(mgr.modified); err != nil {
+					return err
+				}
+			}
+			if err := mgr.Save(jrnl); err != nil {
 				return err
 			}
+			// Keep the changed lines aligned with the rewritten files for the next iteration.
+			for path, content := range before {
+				after, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				changes.Remap(path, content, after)
+			}
+		}
+
+		if errcheckReport != nil {
+			// Report positions are stale after the first rewrite.
+			break
 		}
 	}
+	if len(rejected) > 0 {
+		log.Printf("[WARN] %d unhandled errors were left as is: their handling does not compile (see the reverted files above).", len(rejected))
+		opts.Reporter.SetRejected(len(rejected))
+	}
 	return nil
 }
 
 type dstManager struct {
-	pkgs     map[string]*packages.Package
-	cache    map[string]*dst.File
+	pkgs  map[string]*packages.Package
+	cache map[string]*dst.File
+	// prepared holds files decorated ahead of use (see prepare); Get moves them to cache.
+	prepared map[string]*dst.File
 	fset     *token.FileSet
 	modified map[string]bool
+	// jobs is the maximum number of files decorated or rendered at a time.
+	jobs int
+	// origin is the point whose handling is being applied and originPos the position of the
+	// current edit; MarkModified records them in edits to attribute regressions (see verify).
+	origin    *analysis.InjectionPoint
+	originPos token.Pos
+	edits     map[string][]fileEdit
 }
 
-func newDstManager(pkgs []*packages.Package) *dstManager {
+func newDstManager(pkgs []*packages.Package, jobs int) *dstManager {
 	m := &dstManager{
 		pkgs:     make(map[string]*packages.Package),
 		cache:    make(map[string]*dst.File),
+		prepared: make(map[string]*dst.File),
 		modified: make(map[string]bool),
+		jobs:     jobs,
+		edits:    make(map[string][]fileEdit),
 	}
 	if len(pkgs) > 0 {
 		m.fset = pkgs[0].Fset
@@ -162,9 +374,13 @@ func (m *dstManager) Get(pkg *packages.Package, astFile *ast.File) (*dst.File, e
 	if d, ok := m.cache[name]; ok {
 		return d, nil
 	}
+	if d, ok := m.prepared[name]; ok {
+		delete(m.prepared, name)
+		m.cache[name] = d
+		return d, nil
+	}
 
-	dec := decorator.NewDecorator(m.fset)
-	d, err := dec.DecorateFile(astFile)
+	d, err := dstmap.Decorate(m.fset, astFile)
 	if err != nil {
 		return nil, err
 	}
@@ -172,10 +388,51 @@ func (m *dstManager) Get(pkg *packages.Package, astFile *ast.File) (*dst.File, e
 	return d, nil
 }
 
+// prepare decorates the files containing points concurrently, so that the sequential handling
+// of the points finds them ready. Files that end up unused are not reported by decorated.
+//
+// points: The points about to be handled.
+//
+// Returns an error if a file cannot be decorated.
+func (m *dstManager) prepare(points []analysis.InjectionPoint) error {
+	var (
+		names []string
+		files []*ast.File
+	)
+	seen := make(map[string]bool)
+	for _, p := range points {
+		tokFile := m.fset.File(p.File.Pos())
+		if tokFile == nil || seen[tokFile.Name()] {
+			continue
+		}
+		seen[tokFile.Name()] = true
+		if _, ok := m.cache[tokFile.Name()]; ok {
+			continue
+		}
+		names = append(names, tokFile.Name())
+		files = append(files, p.File)
+	}
+
+	decorated := make([]*dst.File, len(files))
+	err := parallel.ForEach(len(files), m.jobs, func(i int) error {
+		d, err := dstmap.Decorate(m.fset, files[i])
+		decorated[i] = d
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for i, name := range names {
+		m.prepared[name] = decorated[i]
+	}
+	return nil
+}
+
 func (m *dstManager) MarkModified(astFile *ast.File) {
 	tokFile := m.fset.File(astFile.Pos())
 	if tokFile != nil {
 		m.modified[tokFile.Name()] = true
+		m.recordEdit(tokFile.Name(), astFile)
 	}
 }
 
@@ -186,47 +443,445 @@ func (m *dstManager) PrintDiffs(w *os.File) error {
 	}
 	sort.Strings(paths)
 
-	for _, path := range paths {
+	outs, err := m.renderAll(paths)
+	if err != nil {
+		return err
+	}
+	for i, path := range paths {
 		orig, err := os.ReadFile(path)
 		if err != nil {
 			return err
 		}
 
-		d := m.cache[path]
-		var buf bytes.Buffer
-		if err := decorator.NewRestorer().Fprint(&buf, d); err != nil {
-			return err
-		}
-
-		edits := myers.ComputeEdits(span.URIFromPath(path), string(orig), buf.String())
+		out := outs[i]
+		edits := myers.ComputeEdits(span.URIFromPath(path), string(orig), string(out))
 		unified := gotextdiff.ToUnified(path, path, string(orig), edits)
 		fmt.Fprint(w, unified)
 	}
 	return nil
 }
 
-func (m *dstManager) Save() error {
+// Save writes the modified files. All files are rendered before the first write, each write is
+// atomic (see journal.WriteFile), and if a write fails the files written so far are restored.
+//
+// jrnl: The journal recording the original contents, or nil.
+//
+// Returns an error if a file cannot be rendered, journaled or written.
+func (m *dstManager) Save(jrnl *journal.Journal) error {
+	paths := make([]string, 0, len(m.modified))
 	for path := range m.modified {
-		d := m.cache[path]
-		f, err := os.Create(path)
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	outs, err := m.renderAll(paths)
+	if err != nil {
+		return err
+	}
+
+	type written struct {
+		path string
+		orig []byte
+		mode os.FileMode
+	}
+	var done []written
+	rollback := func() {
+		for _, w := range done {
+			if err := journal.WriteFile(w.path, w.orig, w.mode); err != nil {
+				log.Printf("[ERROR] Failed to restore %s: %v", w.path, err)
+			}
+		}
+	}
+	for i, path := range paths {
+		info, err := os.Stat(path)
 		if err != nil {
+			rollback()
 			return err
 		}
-		defer f.Close()
-		if err := decorator.NewRestorer().Fprint(f, d); err != nil {
+		orig, err := os.ReadFile(path)
+		if err != nil {
+			rollback()
 			return err
 		}
+		mode := info.Mode().Perm()
+		if jrnl != nil {
+			if err := jrnl.Record(path, orig, outs[i], mode); err != nil {
+				rollback()
+				return err
+			}
+		}
+		if err := journal.WriteFile(path, outs[i], mode); err != nil {
+			rollback()
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		done = append(done, written{path: path, orig: orig, mode: mode})
 	}
 	return nil
 }
 
-func applyRefactors(mgr *dstManager, points []analysis.InjectionPoint, opts Options, registry *analysis.InterfaceRegistry) (int, error) {
+// renderAll renders paths concurrently (see render).
+//
+// Returns the contents in the order of paths, or the error of the first file that cannot be
+// rendered.
+func (m *dstManager) renderAll(paths []string) ([][]byte, error) {
+	outs, errs := m.renderEach(paths)
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return outs, nil
+}
+
+// renderEach renders paths concurrently (see render).
+//
+// Returns the contents and the rendering errors in the order of paths.
+func (m *dstManager) renderEach(paths []string) ([][]byte, []error) {
+	outs := make([][]byte, len(paths))
+	errs := make([]error, len(paths))
+	_ = parallel.ForEach(len(paths), m.jobs, func(i int) error {
+		outs[i], errs[i] = m.render(paths[i])
+		return nil
+	})
+	return outs, errs
+}
+
+// render prints the modified file and adds the imports required by injected code
+// (e.g. "fmt" for fmt.Errorf in wrapping templates).
+func (m *dstManager) render(path string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := decorator.NewRestorer().Fprint(&buf, m.cache[path]); err != nil {
+		return nil, err
+	}
+	out, err := imports.Process(path, buf.Bytes(), nil)
+	if err != nil {
+		// Keep the restorer output if import resolution fails; the next load reports the problem.
+		log.Printf("[WARN] Failed to process imports for %s: %v", path, err)
+		return buf.Bytes(), nil
+	}
+	return out, nil
+}
+
+func applyRefactors(mgr *dstManager, points []analysis.InjectionPoint, baseOpts Options, registry *analysis.InterfaceRegistry) (int, error) {
 	totalChanges := 0
+	baseOpts.noErrgroup = withoutErrgroup(points, baseOpts)
+
+	// propQueue holds the functions whose calls return a new error, and the variables, fields and
+	// parameters holding such functions.
+	propQueue := make([]types.Object, 0)
+	visited := make(map[types.Object]bool)
+	// origins maps the queued objects to the point whose handling changed their signature.
+	origins := make(map[types.Object]*analysis.InjectionPoint)
 
-	propQueue := make([]*types.Func, 0)
-	visited := make(map[*types.Func]bool)
+	// Interface methods and function values are propagated without replacing their objects;
+	// evolved holds the new signatures of their calls.
+	evolved := make(map[types.Object]*types.Signature)
+
+	// valueDecls holds the declarations changed for function values during the pass.
+	valueDecls := make(map[token.Pos]bool)
+	// queueValues applies the plan of a function or literal gaining an error result and queues the
+	// changed functions and slots. It reports false if the plan cannot be applied.
+	queueValues := func(plan *analysis.FuncValues) bool {
+		res, err := applyFuncValues(mgr, plan, valueDecls)
+		if err != nil {
+			log.Printf("[WARN] Cannot update function values: %v", err)
+			return false
+		}
+		for _, fn := range res.funcs {
+			if !visited[fn] {
+				visited[fn] = true
+				origins[fn] = mgr.origin
+				propQueue = append(propQueue, fn)
+			}
+		}
+		// In source order, so that the calls of a statement are rewritten the same way on every run.
+		slots := make([]*types.Var, 0, len(res.slots))
+		for v := range res.slots {
+			slots = append(slots, v)
+		}
+		sort.Slice(slots, func(a, b int) bool { return slots[a].Pos() < slots[b].Pos() })
+		for _, v := range slots {
+			if !visited[v] {
+				visited[v] = true
+				evolved[v] = res.slots[v]
+				origins[v] = mgr.origin
+				propQueue = append(propQueue, v)
+			}
+		}
+		totalChanges += len(res.funcs) + len(res.slots)
+		return true
+	}
+
+	// evolve adds the error result to the interfaces of fn and their other implementations,
+	// queueing them for propagation. It reports false if the interfaces cannot be evolved.
+	evolvedDecls := make(map[token.Pos]bool)
+	evolve := func(fn *types.Func) bool {
+		plan, err := registry.PlanEvolution(fn, mgr.packages())
+		if err == nil {
+			// Package variants (e.g. test packages) share declarations; change each one once.
+			plan.InterfaceMethods = unevolved(plan.InterfaceMethods, evolvedDecls)
+			plan.Methods = unevolved(plan.Methods, evolvedDecls)
+			var res *evolvedInterfaces
+			if res, err = evolveInterfaces(mgr, plan, fn); err == nil {
+				for _, m := range res.methods {
+					if !visited[m] {
+						visited[m] = true
+						origins[m] = mgr.origin
+						propQueue = append(propQueue, m)
+					}
+				}
+				for im, sig := range res.ifaceMethods {
+					evolved[im] = sig
+					origins[im] = mgr.origin
+					propQueue = append(propQueue, im)
+				}
+				totalChanges += len(res.methods) + len(res.ifaceMethods)
+				return true
+			}
+		}
+		log.Printf("[WARN] Cannot evolve the interfaces of %s: %v", fn.FullName(), err)
+		return false
+	}
+
+	// Compatibility splits are applied last, so that every rewrite of the pass still maps onto
+	// the original declarations.
+	var splits []compatSplit
+	split := make(map[*dst.FuncDecl]bool)
+
+	// Package variants (e.g. including tests) share syntax, so a call can be reported more than once.
+	seenPoints := make(map[*ast.CallExpr]bool)
+
+	// Go statements rewritten to an errgroup are handled per function.
+	groups := goGroups(points, baseOpts)
+
+	// seenCalls holds the calls propagated to, by the position of their parenthesis: each package
+	// variant reports its uses of a function, but a call is rewritten once.
+	seenCalls := make(map[token.Pos]bool)
+	// captured maps the statements passing function literals to synchronous callbacks (see
+	// analysis.SyncCallbackCall) to the variables capturing their errors.
+	captured := make(map[ast.Stmt]string)
+
+	// propagateCall handles a point of the propagation: a call returning a new error or, with
+	// ErrIdent set, a statement after which a captured error must be checked.
+	var propagateCall func(point analysis.InjectionPoint)
+
+	// liftLit handles point in the function literal lit, which has no error result. A literal passed
+	// to a synchronous callback stores the error in a variable of the enclosing function; another
+	// gains an error result with the variables, fields and parameters storing it, and its immediate
+	// invocation is propagated. Otherwise the error is logged.
+	liftLit := func(point analysis.InjectionPoint, lit *ast.FuncLit) {
+		pkg, f := point.Pkg, point.File
+		dstFile, err := mgr.Get(pkg, f)
+		if err != nil {
+			return
+		}
+		inj := newInjector(pkg, baseOpts.forPackage(pkg))
+		pos := mgr.fset.Position(lit.Pos())
+
+		if api, outer := analysis.SyncCallbackCall(pkg.TypesInfo, f, lit); api != nil {
+			name, declared := captured[outer]
+			if !declared {
+				name = inj.CaptureName(f, point)
+			}
+			if applied, _ := inj.CaptureFile(dstFile, f, point, outer, name, !declared, analysis.CallsOnce(pkg.TypesInfo, api)); applied {
+				mgr.MarkModified(f)
+				totalChanges++
+				if !declared {
+					captured[outer] = name
+					seenCalls[api.Lparen] = true
+					propagateCall(analysis.InjectionPoint{
+						Pkg: pkg, File: f, Call: api, Stmt: outer, Pos: api.Pos(), ErrIdent: ast.NewIdent(name),
+					})
+				}
+				return
+			}
+			log.Printf("[WARN] Cannot capture the error in the function literal at %s; logging it instead.", pos)
+		} else if call, stmt, assign := litInvocation(f, lit); isGoOrDefer(stmt, call) {
+			log.Printf("[WARN] The function literal at %s is run by a go or defer statement; logging the error instead.", pos)
+		} else if plan, err := analysis.PlanFuncLit(lit, mgr.packages()); err != nil {
+			log.Printf("[WARN] The function literal at %s cannot return an error (%v); logging the error instead.", pos, err)
+		} else if queueValues(plan) {
+			if applied, _ := inj.RewriteFile(dstFile, f, []analysis.InjectionPoint{point}); applied {
+				mgr.MarkModified(f)
+				totalChanges++
+			}
+			if call != nil && stmt != nil && !seenCalls[call.Lparen] {
+				seenCalls[call.Lparen] = true
+				if tv, ok := pkg.TypesInfo.Types[call]; ok {
+					tv.Type = resultType(pkg.TypesInfo.Types[lit].Type.(*types.Signature))
+					pkg.TypesInfo.Types[call] = tv
+				}
+				// The call starts inside the literal; its parenthesis is in the enclosing function.
+				propagateCall(analysis.InjectionPoint{
+					Pkg: pkg, File: f, Call: call, Stmt: stmt, Assign: assign, Pos: call.Lparen,
+				})
+			}
+			return
+		}
+
+		if applied, _ := inj.LogFallback(dstFile, f, point); applied {
+			mgr.MarkModified(f)
+			totalChanges++
+		}
+	}
+
+	propagateCall = func(point analysis.InjectionPoint) {
+		pkg, f, call, stmt := point.Pkg, point.File, point.Call, point.Stmt
+		dstFile, err := mgr.Get(pkg, f)
+		if err != nil {
+			return
+		}
+		ctx := FindEnclosingFunc(pkg, f, point.Pos)
+		if ctx == nil {
+			return
+		}
+		opts := baseOpts.forPackage(pkg)
+
+		isTerm := false
+		if ctx.Decl != nil {
+			fnObj := pkg.TypesInfo.ObjectOf(ctx.Decl.Name).(*types.Func)
+			if refactor.IsEntryPoint(fnObj) || filter.IsTestHandler(ctx.Decl) {
+				isTerm = true
+			}
+		}
+
+		inj := newInjector(pkg, opts)
+
+		if gs, ok := stmt.(*ast.GoStmt); ok && gs.Call == call && opts.GoStrategy == rewrite.GoStrategyErrgroup {
+			if isTerm {
+				// The entry point waits for the group and handles the error.
+				if applied, _ := inj.RewriteFile(dstFile, f, []analysis.InjectionPoint{point}); applied {
+					mgr.MarkModified(f)
+					totalChanges++
+				}
+				return
+			}
+		}
+
+		// Tests and their helpers stop the test instead of returning the error.
+		if applied, _ := inj.HandleTest(dstFile, f, point); applied {
+			mgr.MarkModified(f)
+			totalChanges++
+			return
+		}
+
+		if isTerm {
+			switch {
+			case point.ErrIdent == nil:
+				refactor.HandleEntryPointLogger(pkg, dstFile, call, stmt, opts.MainHandler, opts.Logger)
+			case filter.IsTestHandler(ctx.Decl):
+				inj.LogFallback(dstFile, f, point)
+			default:
+				// The captured error is handled in place.
+				inj.RewriteFile(dstFile, f, []analysis.InjectionPoint{point})
+			}
+			mgr.MarkModified(f)
+			totalChanges++
+			return
+		}
+
+		if cb := inj.CallbackFor(ctx.Sig); cb != nil {
+			// The signature is imposed by a framework; the error is handled in place.
+			if applied, _ := inj.HandleCallback(dstFile, f, point, *cb); applied {
+				mgr.MarkModified(f)
+				totalChanges++
+				return
+			}
+		}
+
+		if !hasErrorReturn(ctx.Sig) && !opts.EnableNonExistingErr {
+			// Signature changes are disabled for this package; handle the error locally.
+			if applied, _ := inj.LogFallback(dstFile, f, point); applied {
+				mgr.MarkModified(f)
+				totalChanges++
+			}
+			return
+		}
+
+		if ctx.IsLiteral() && !hasErrorReturn(ctx.Sig) {
+			if !opts.EnableTestRefactor && inTestHandler(f, point.Pos) {
+				// Like the test itself, the literal handles the error in place.
+				if applied, _ := inj.LogFallback(dstFile, f, point); applied {
+					mgr.MarkModified(f)
+					totalChanges++
+				}
+				return
+			}
+			liftLit(point, ctx.Lit)
+			return
+		}
+
+		if ctx.Decl != nil && !hasErrorReturn(ctx.Sig) {
+			fnObj := pkg.TypesInfo.ObjectOf(ctx.Decl.Name).(*types.Func)
+			compat := opts.wantsCompat(fnObj)
+			if compat && !compatVariantFree(fnObj, opts.compatSuffix()) {
+				log.Printf("[WARN] %s%s already exists; logging the error in %s instead.", fnObj.Name(), opts.compatSuffix(), fnObj.Name())
+				if applied, _ := inj.LogFallback(dstFile, f, point); applied {
+					mgr.MarkModified(f)
+					totalChanges++
+				}
+				return
+			}
+
+			var values *analysis.FuncValues
+			if !compat {
+				var err error
+				if values, err = planFuncValues(mgr, registry, fnObj); err != nil {
+					log.Printf("[WARN] %s is used as a value that cannot return an error (%v); logging the error instead.", fnObj.Name(), err)
+					if applied, _ := inj.LogFallback(dstFile, f, point); applied {
+						mgr.MarkModified(f)
+						totalChanges++
+					}
+					return
+				}
+			}
+
+			refactor.AddErrorToSignature(pkg.Fset, ctx.Decl)
+			valueDecls[fnObj.Pos()] = true
+			refactor.PatchSignature(pkg.TypesInfo, ctx.Decl, pkg.Types)
+
+			res, _ := rewrite.FindDstNode(mgr.fset, dstFile, f, ctx.Decl)
+			if dstDecl, ok := res.Node.(*dst.FuncDecl); ok {
+				if compat && !split[dstDecl] {
+					split[dstDecl] = true
+					splits = append(splits, newCompatSplit(dstFile, pkg, f, ctx.Decl, dstDecl, opts, mgr.origin))
+				}
+				refactor.AddErrorToSignatureDST(dstDecl)
+			}
+
+			newObj := pkg.TypesInfo.ObjectOf(ctx.Decl.Name).(*types.Func)
+			if !visited[newObj] && !compat {
+				visited[newObj] = true
+				origins[newObj] = mgr.origin
+				propQueue = append(propQueue, newObj)
+			}
+			if values != nil {
+				queueValues(values)
+			}
+		}
+
+		applied, err := inj.RewriteFile(dstFile, f, []analysis.InjectionPoint{point})
+		if err == nil && applied {
+			mgr.MarkModified(f)
+			totalChanges++
+		}
+	}
 
 	for _, p := range points {
+		if seenPoints[p.Call] {
+			continue
+		}
+		seenPoints[p.Call] = true
+		mgr.beginEdit(&p, p.Pos)
+		batch := []analysis.InjectionPoint{p}
+		if group, ok := groups[p.Call]; ok {
+			if group == nil {
+				// Rewritten together with the first go statement of the function.
+				continue
+			}
+			batch = group
+		}
+		opts := baseOpts.forPackage(p.Pkg)
 		if !opts.EnableThirdPartyErr && isThirdParty(p) {
 			continue
 		}
@@ -236,42 +891,139 @@ func applyRefactors(mgr *dstManager, points []analysis.InjectionPoint, opts Opti
 			return totalChanges, err
 		}
 
-		ctx := FindEnclosingFunc(p.Pkg, p.File, p.Pos)
+		// A batch of go statements belongs to the function running them, even if its first point is
+		// in a literal run by one of them.
+		pos := p.Pos
+		if groups[p.Call] != nil {
+			if gs := newInjector(p.Pkg, opts).GroupGoStmt(p); gs != nil {
+				pos = gs.Pos()
+			}
+		}
+		ctx := FindEnclosingFunc(p.Pkg, p.File, pos)
 		if ctx == nil {
 			continue
 		}
 
-		if !opts.EnableTestRefactor {
-			if ctx.Decl != nil && filter.IsTestHandler(ctx.Decl) {
+		if !opts.EnableTestRefactor && inTestHandler(p.File, p.Pos) {
+			continue
+		}
+
+		if rewrite.InInitStmt(p) {
+			log.Printf("[WARN] Cannot handle the error at %s: the call is in the init statement of an if, switch or for statement.", mgr.fset.Position(p.Pos))
+			continue
+		}
+
+		hasErr := hasErrorReturn(ctx.Sig)
+		injector := newInjector(p.Pkg, opts)
+
+		if deferStmt, ok := p.Stmt.(*ast.DeferStmt); ok && rewrite.ReadOnlyClose(p, opts.ClosePolicy) {
+			// Neither do read-only resources, which are at most marked for other linters.
+			applied, err := injector.RewriteDefer(dstFile, p.File, deferStmt)
+			if err != nil {
+				return totalChanges, err
+			}
+			if applied {
+				totalChanges++
+				mgr.MarkModified(p.File)
+				opts.Reporter.IncHandled()
+				opts.Reporter.AddFile(mgr.fset.Position(p.File.Pos()).Filename)
+			}
+			continue
+		}
+
+		if injector.RuleFor(p).Action == rewrite.ActionLog {
+			// Logged errors never require a signature change. Only the point itself is rewritten: the
+			// other defers of the file follow their own rules.
+			var applied bool
+			if deferStmt, ok := p.Stmt.(*ast.DeferStmt); ok {
+				applied, err = injector.RewriteDefer(dstFile, p.File, deferStmt)
+			} else {
+				applied, err = injector.RewritePoints(dstFile, p.File, []analysis.InjectionPoint{p})
+			}
+			if err != nil {
+				return totalChanges, err
+			}
+			if applied {
+				totalChanges++
+				mgr.MarkModified(p.File)
+				opts.Reporter.IncHandled()
+				opts.Reporter.AddFile(mgr.fset.Position(p.File.Pos()).Filename)
+			}
+			continue
+		}
+
+		// Tests and their helpers stop the test instead of returning the error. Helpers are handled
+		// at the level that would otherwise change their signature.
+		if len(batch) == 1 && !hasErr && (opts.EnableNonExistingErr || inTestHandler(p.File, p.Pos)) {
+			applied, err := injector.HandleTest(dstFile, p.File, p)
+			if err != nil {
+				return totalChanges, err
+			}
+			if applied {
+				totalChanges++
+				mgr.MarkModified(p.File)
+				opts.Reporter.IncHandled()
+				opts.Reporter.AddFile(mgr.fset.Position(p.File.Pos()).Filename)
 				continue
 			}
 		}
 
-		hasErr := hasErrorReturn(ctx.Sig)
-		injector := rewrite.NewInjector(p.Pkg, opts.ErrorTemplate, opts.MainHandler)
+		// Functions and literals whose signature is imposed by a framework handle the error in place,
+		// at the level that would otherwise handle it.
+		if cb := injector.CallbackFor(ctx.Sig); cb != nil && len(batch) == 1 && (ctx.Decl == nil || !filter.IsTestHandler(ctx.Decl)) &&
+			((hasErr && opts.EnablePreexistingErr) || (!hasErr && opts.EnableNonExistingErr)) {
+			applied, err := injector.HandleCallback(dstFile, p.File, p, *cb)
+			if err != nil {
+				return totalChanges, err
+			}
+			if applied {
+				totalChanges++
+				mgr.MarkModified(p.File)
+				opts.Reporter.IncHandled()
+				opts.Reporter.AddFile(mgr.fset.Position(p.File.Pos()).Filename)
+				continue
+			}
+		}
 
 		if hasErr {
 			if opts.EnablePreexistingErr {
-				applied, err := injector.RewriteFile(dstFile, p.File, []analysis.InjectionPoint{p})
+				applied, err := injector.RewriteFile(dstFile, p.File, batch)
 				if err != nil {
 					return totalChanges, err
 				}
 				if applied {
 					totalChanges++
 					mgr.MarkModified(p.File)
-					opts.Reporter.IncHandled()
+					for range batch {
+						opts.Reporter.IncHandled()
+					}
 					opts.Reporter.AddFile(mgr.fset.Position(p.File.Pos()).Filename)
 				}
 			}
 		} else if opts.EnableNonExistingErr {
-			if ctx.Decl == nil || ctx.IsLiteral() {
+			if ctx.IsLiteral() {
+				liftLit(p, ctx.Lit)
 				continue
 			}
-			if filter.IsTestHandler(ctx.Decl) {
+			if ctx.Decl == nil || filter.IsTestHandler(ctx.Decl) {
 				continue
 			}
 			if refactor.IsEntryPoint(p.Pkg.TypesInfo.ObjectOf(ctx.Decl.Name).(*types.Func)) {
-				if err := refactor.HandleEntryPoint(p.Pkg, dstFile, p.Call, p.Stmt, opts.MainHandler); err == nil {
+				if len(groups[p.Call]) > 0 || p.ErrIdent != nil {
+					// The errgroup is waited for in the entry point, which handles the error. An
+					// error already held in a variable is checked in place.
+					if applied, err := injector.RewriteFile(dstFile, p.File, batch); err != nil {
+						return totalChanges, err
+					} else if applied {
+						totalChanges++
+						mgr.MarkModified(p.File)
+						for range batch {
+							opts.Reporter.IncHandled()
+						}
+					}
+					continue
+				}
+				if err := refactor.HandleEntryPointLogger(p.Pkg, dstFile, p.Call, p.Stmt, opts.MainHandler, opts.Logger); err == nil {
 					totalChanges++
 					mgr.MarkModified(p.File)
 					opts.Reporter.IncHandled()
@@ -280,8 +1032,20 @@ func applyRefactors(mgr *dstManager, points []analysis.InjectionPoint, opts Opti
 			}
 
 			fnObj := p.Pkg.TypesInfo.ObjectOf(ctx.Decl.Name).(*types.Func)
+			compat := opts.wantsCompat(fnObj)
+			if compat && !compatVariantFree(fnObj, opts.compatSuffix()) {
+				log.Printf("[WARN] %s%s already exists; logging the error in %s instead.", fnObj.Name(), opts.compatSuffix(), fnObj.Name())
+				if applied, _ := injector.LogFallback(dstFile, p.File, p); applied {
+					totalChanges++
+					mgr.MarkModified(p.File)
+				}
+				continue
+			}
+			// A wrapper keeps the original signature, so interface compliance is unaffected.
 			conflicts, _ := registry.CheckCompliance(fnObj)
-			if len(conflicts) > 0 {
+			// With EvolveInterfaces, the interfaces and their other implementations gain the error
+			// together with this method; otherwise the error is logged.
+			if len(conflicts) > 0 && !compat && !(opts.EvolveInterfaces && evolve(fnObj)) {
 				applied, _ := injector.LogFallback(dstFile, p.File, p)
 				if applied {
 					totalChanges++
@@ -290,15 +1054,37 @@ func applyRefactors(mgr *dstManager, points []analysis.InjectionPoint, opts Opti
 				continue
 			}
 
+			// The variables, fields and parameters storing the function change with it. A wrapper
+			// keeps the original signature for them.
+			var values *analysis.FuncValues
+			if !compat {
+				if values, err = planFuncValues(mgr, registry, fnObj); err != nil {
+					log.Printf("[WARN] %s is used as a value that cannot return an error (%v); logging the error instead.", fnObj.Name(), err)
+					if applied, _ := injector.LogFallback(dstFile, p.File, p); applied {
+						totalChanges++
+						mgr.MarkModified(p.File)
+					}
+					continue
+				}
+			}
+
 			changed, _ := refactor.AddErrorToSignature(p.Pkg.Fset, ctx.Decl)
 			if changed {
+				valueDecls[fnObj.Pos()] = true
 				refactor.PatchSignature(p.Pkg.TypesInfo, ctx.Decl, fnObj.Pkg())
 				res, _ := rewrite.FindDstNode(mgr.fset, dstFile, p.File, ctx.Decl)
 				if dstDecl, ok := res.Node.(*dst.FuncDecl); ok {
+					if compat && !split[dstDecl] {
+						split[dstDecl] = true
+						splits = append(splits, newCompatSplit(dstFile, p.Pkg, p.File, ctx.Decl, dstDecl, opts, mgr.origin))
+					}
 					refactor.AddErrorToSignatureDST(dstDecl)
 				}
+				if values != nil {
+					queueValues(values)
+				}
 
-				applied, err := injector.RewriteFile(dstFile, p.File, []analysis.InjectionPoint{p})
+				applied, err := injector.RewriteFile(dstFile, p.File, batch)
 				if err != nil {
 					return totalChanges, err
 				}
@@ -307,8 +1093,9 @@ func applyRefactors(mgr *dstManager, points []analysis.InjectionPoint, opts Opti
 					mgr.MarkModified(p.File)
 
 					newObj := p.Pkg.TypesInfo.ObjectOf(ctx.Decl.Name).(*types.Func)
-					if !visited[newObj] {
+					if !visited[newObj] && !compat {
 						visited[newObj] = true
+						origins[newObj] = mgr.origin
 						propQueue = append(propQueue, newObj)
 					}
 				}
@@ -316,10 +1103,12 @@ func applyRefactors(mgr *dstManager, points []analysis.InjectionPoint, opts Opti
 		}
 	}
 
-	if opts.PanicToReturn {
+	if baseOpts.PanicToReturn {
+		mgr.beginEdit(nil, token.NoPos)
 		for id, pkg := range mgr.pkgs {
 			_ = id
-			inj := rewrite.NewInjector(pkg, opts.ErrorTemplate, opts.MainHandler)
+			opts := baseOpts.forPackage(pkg)
+			inj := newInjector(pkg, opts)
 			for _, f := range pkg.Syntax {
 				dstFile, err := mgr.Get(pkg, f)
 				if err != nil {
@@ -341,90 +1130,235 @@ func applyRefactors(mgr *dstManager, points []analysis.InjectionPoint, opts Opti
 		target := propQueue[0]
 		propQueue = propQueue[1:]
 
+		targetSig, _ := target.Type().(*types.Signature)
+		newSig, isEvolved := evolved[target]
+		if isEvolved {
+			targetSig = newSig
+		}
+
 		for _, pkg := range mgr.pkgs {
+			// Instances of generic functions and methods resolve to their origin. Every package
+			// variant (e.g. "p [p.test]" for callers in in-package tests) has its own objects, so
+			// they are matched by declaration. The calls are handled in source order, so that the
+			// calls of a statement keep their order of evaluation.
+			var ids []*ast.Ident
 			for id, obj := range pkg.TypesInfo.Uses {
-				if obj == target {
-					f := findFileInPkg(pkg, id.Pos())
-					if f == nil {
-						continue
-					}
-					dstFile, _ := mgr.Get(pkg, f)
+				if origin := refactor.OriginOf(obj); origin == target || (origin.Pos() == target.Pos() && origin.Name() == target.Name()) {
+					ids = append(ids, id)
+				}
+			}
+			sort.Slice(ids, func(a, b int) bool { return ids[a].Pos() < ids[b].Pos() })
+			for _, id := range ids {
+				f := findFileInPkg(pkg, id.Pos())
+				if f == nil {
+					continue
+				}
 
-					ctx := FindEnclosingFunc(pkg, f, id.Pos())
-					if ctx == nil {
-						continue
-					}
+				path, _ := astutil.PathEnclosingInterval(f, id.Pos(), id.End())
+				// Other uses store the function as a value (see planFuncValues).
+				call := calleeCall(path)
+				if call == nil {
+					continue
+				}
+				stmt, assign := enclosingStmt(path)
+				if stmt == nil || seenCalls[call.Lparen] {
+					continue
+				}
+				seenCalls[call.Lparen] = true
+				mgr.beginEdit(origins[target], call.Pos())
 
-					path, _ := astutil.PathEnclosingInterval(f, id.Pos(), id.Pos())
-					var call *ast.CallExpr
-					var stmt ast.Stmt
-					var assign *ast.AssignStmt
+				// Record the new result types of the call for the rewrite.
+				if tv, ok := pkg.TypesInfo.Types[call]; ok && targetSig != nil {
+					tv.Type = resultType(targetSig)
+					pkg.TypesInfo.Types[call] = tv
+				}
 
-					for _, n := range path {
-						if c, ok := n.(*ast.CallExpr); ok && call == nil {
-							call = c
-						}
-						if s, ok := n.(ast.Stmt); ok && call != nil {
-							stmt = s
-							if a, ok := n.(*ast.AssignStmt); ok {
-								assign = a
-							}
-							break
-						}
-					}
+				propagateCall(analysis.InjectionPoint{
+					Pkg: pkg, File: f, Call: call, Stmt: stmt, Assign: assign, Pos: call.Pos(),
+				})
+			}
+		}
+	}
 
-					if call == nil || stmt == nil {
-						continue
-					}
+	totalChanges += applyCompatSplits(mgr, splits)
 
-					point := analysis.InjectionPoint{
-						Pkg: pkg, File: f, Call: call, Stmt: stmt, Assign: assign, Pos: call.Pos(),
-					}
+	return totalChanges, nil
+}
 
-					isTerm := false
-					if ctx.Decl != nil {
-						fnObj := pkg.TypesInfo.ObjectOf(ctx.Decl.Name).(*types.Func)
-						if refactor.IsEntryPoint(fnObj) || filter.IsTestHandler(ctx.Decl) {
-							isTerm = true
-						}
-					}
+// calleeCall returns the call of the function named by path[0], or nil if the function is used as
+// a value. The name may be selected ("pkg.Fn", "s.Method"), instantiated ("Fn[int]") or, for maps,
+// slices and arrays of functions, indexed ("handlers[name]").
+//
+// path: The AST path from the identifier to the file root.
+func calleeCall(path []ast.Node) *ast.CallExpr {
+	if len(path) == 0 {
+		return nil
+	}
+	fun := path[0]
+	for _, n := range path[1:] {
+		switch e := n.(type) {
+		case *ast.SelectorExpr:
+			if e.Sel != fun {
+				return nil
+			}
+		case *ast.IndexExpr:
+			if e.X != fun {
+				return nil
+			}
+		case *ast.IndexListExpr:
+			if e.X != fun {
+				return nil
+			}
+		case *ast.ParenExpr:
+		case *ast.CallExpr:
+			if e.Fun != fun {
+				return nil
+			}
+			return e
+		default:
+			return nil
+		}
+		fun = n
+	}
+	return nil
+}
 
-					inj := rewrite.NewInjector(pkg, opts.ErrorTemplate, opts.MainHandler)
+// enclosingStmt returns the statement containing path[0], and the statement as an assignment if
+// it is one.
+//
+// path: The AST path from the node to the file root.
+func enclosingStmt(path []ast.Node) (ast.Stmt, *ast.AssignStmt) {
+	for _, n := range path {
+		if s, ok := n.(ast.Stmt); ok {
+			assign, _ := n.(*ast.AssignStmt)
+			return s, assign
+		}
+	}
+	return nil, nil
+}
 
-					if isTerm {
-						refactor.HandleEntryPoint(pkg, dstFile, call, stmt, opts.MainHandler)
-						mgr.MarkModified(f)
-						totalChanges++
-						continue
-					}
+// litInvocation returns the immediate invocation of lit ("func() { ... }()"), with its statement
+// and the statement as an assignment, or nil if lit is not invoked where it is written.
+//
+// file: The file containing lit.
+// lit: The function literal.
+func litInvocation(file *ast.File, lit *ast.FuncLit) (*ast.CallExpr, ast.Stmt, *ast.AssignStmt) {
+	path, _ := astutil.PathEnclosingInterval(file, lit.Pos(), lit.End())
+	var fun ast.Node = lit
+	for k, n := range path[1:] {
+		switch e := n.(type) {
+		case *ast.ParenExpr:
+			fun = e
+			continue
+		case *ast.CallExpr:
+			if e.Fun == fun {
+				stmt, assign := enclosingStmt(path[k+1:])
+				return e, stmt, assign
+			}
+		}
+		break
+	}
+	return nil, nil, nil
+}
 
-					if ctx.Decl != nil && !hasErrorReturn(ctx.Sig) {
-						refactor.AddErrorToSignature(pkg.Fset, ctx.Decl)
-						refactor.PatchSignature(pkg.TypesInfo, ctx.Decl, pkg.Types)
+// isGoOrDefer reports whether stmt is a go or defer statement running call.
+func isGoOrDefer(stmt ast.Stmt, call *ast.CallExpr) bool {
+	switch s := stmt.(type) {
+	case *ast.GoStmt:
+		return s.Call == call
+	case *ast.DeferStmt:
+		return s.Call == call
+	}
+	return false
+}
 
-						res, _ := rewrite.FindDstNode(mgr.fset, dstFile, f, ctx.Decl)
-						if dstDecl, ok := res.Node.(*dst.FuncDecl); ok {
-							refactor.AddErrorToSignatureDST(dstDecl)
-						}
+// pointKey identifies p across iterations by its file, enclosing function and call text, which
+// stay the same when other parts of the file are rewritten (unlike its position).
+func pointKey(p analysis.InjectionPoint) string {
+	pos := p.Pkg.Fset.Position(p.Call.Pos())
+	return fmt.Sprintf("%s|%s|%s", pos.Filename, funcNameAt(p.File, p.Call.Pos()), types.ExprString(p.Call))
+}
 
-						newObj := pkg.TypesInfo.ObjectOf(ctx.Decl.Name).(*types.Func)
-						if !visited[newObj] {
-							visited[newObj] = true
-							propQueue = append(propQueue, newObj)
-						}
-					}
+// withoutRejected filters out the points in rejected (see pointKey).
+func withoutRejected(points []analysis.InjectionPoint, rejected map[string]bool) []analysis.InjectionPoint {
+	if len(rejected) == 0 {
+		return points
+	}
+	out := points[:0]
+	for _, p := range points {
+		if !rejected[pointKey(p)] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
 
-					applied, err := inj.RewriteFile(dstFile, f, []analysis.InjectionPoint{point})
-					if err == nil && applied {
-						mgr.MarkModified(f)
-						totalChanges++
-					}
-				}
-			}
+// validateLoggers checks that the logger expressions of opts and its overrides are valid Go.
+//
+// opts: The options to check.
+//
+// Returns an error naming the first invalid expression.
+func validateLoggers(opts Options) error {
+	loggers := []string{opts.Logger}
+	for _, o := range opts.Overrides {
+		loggers = append(loggers, o.Logger)
+	}
+	for _, logger := range loggers {
+		if logger == "" {
+			continue
+		}
+		if _, err := parser.ParseExpr(logger); err != nil {
+			return fmt.Errorf("invalid logger expression %q: %w", logger, err)
 		}
 	}
+	return nil
+}
 
-	return totalChanges, nil
+// newInjector creates an Injector configured with the template, handler and rules of opts.
+func newInjector(pkg *packages.Package, opts Options) *rewrite.Injector {
+	inj := rewrite.NewInjector(pkg, opts.ErrorTemplate, opts.MainHandler)
+	inj.Rules = opts.Rules
+	if len(opts.Callbacks) > 0 {
+		inj.Callbacks = append(append([]rewrite.Callback{}, opts.Callbacks...), rewrite.DefaultCallbacks...)
+	}
+	inj.GoStrategy = opts.GoStrategy
+	inj.LogHandler = opts.LogHandler
+	inj.Logger = opts.Logger
+	inj.ClosePolicy = opts.ClosePolicy
+	inj.SyncOnClose = opts.SyncOnClose
+	inj.Defers = opts.defers
+	return inj
+}
+
+// deferStmts returns the defer statements of points.
+func deferStmts(points []analysis.InjectionPoint) map[*ast.DeferStmt]bool {
+	defers := make(map[*ast.DeferStmt]bool)
+	for _, p := range points {
+		if d, ok := p.Stmt.(*ast.DeferStmt); ok {
+			defers[d] = true
+		}
+	}
+	return defers
+}
+
+// unevolved filters out the functions declared at a position in done, marking the others as done.
+func unevolved(fns []*types.Func, done map[token.Pos]bool) []*types.Func {
+	var out []*types.Func
+	for _, fn := range fns {
+		if !done[fn.Pos()] {
+			done[fn.Pos()] = true
+			out = append(out, fn)
+		}
+	}
+	return out
+}
+
+// resultType returns the type of a call to a function with signature sig.
+func resultType(sig *types.Signature) types.Type {
+	if sig.Results().Len() == 1 {
+		return sig.Results().At(0).Type()
+	}
+	return sig.Results()
 }
 
 func isThirdParty(p analysis.InjectionPoint) bool {
@@ -477,3 +1411,13 @@ func formatAST(fset *token.FileSet, node interface{}, filename string) ([]byte,
 	// Use imports process to fix missing imports like "fmt"
 	return imports.Process(filename, buf.Bytes(), nil)
 }
+
+// decorated returns the files decorated by m, sorted.
+func (m *dstManager) decorated() []string {
+	paths := make([]string, 0, len(m.cache))
+	for path := range m.cache {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
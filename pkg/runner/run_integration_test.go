@@ -2,9 +2,12 @@ package runner
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/report"
 )
 
 // TestRunner_Integration verifies the full analysis and refactoring cycle using DST.
@@ -141,3 +144,384 @@ func do() {
 		t.Error("Panic not rewritten to return")
 	}
 }
+
+// TestRun_SequentialAssignments verifies that points following a multi-statement rewrite in the
+// same block are still mapped to their own statements, and that points reported by both the
+// package and its test variant are rewritten once.
+func TestRun_SequentialAssignments(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, map[string]string{
+		"go.mod": "module example.com/seq\ngo 1.22\n",
+		"seq.go": `package seq
+
+import "strconv"
+
+func Sum() (int, error) {
+	x, _ := strconv.Atoi("1")
+	y, _ := strconv.Atoi("2")
+	strconv.Atoi("3")
+	return x + y, nil
+}
+`,
+		"seq_test.go": `package seq
+
+import "testing"
+
+func TestSum(t *testing.T) {}
+`,
+	})
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{EnablePreexistingErr: true, EnableThirdPartyErr: true, Paths: []string{"."}}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	out, _ := os.ReadFile(filepath.Join(tmpDir, "seq.go"))
+	got := string(out)
+	for _, want := range []string{
+		`x, err := strconv.Atoi("1")`,
+		`y, err := strconv.Atoi("2")`,
+		`if _, err := strconv.Atoi("3"); err != nil {`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+}
+
+// TestRun_MaxIterations verifies the iteration cap and the convergence report.
+func TestRun_MaxIterations(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, map[string]string{
+		"go.mod":     "module example.com/iterations\ngo 1.22\n",
+		"lib/lib.go": "package lib\n\nimport \"os\"\n\nfunc Clean() {\n\tos.Remove(\"x\")\n}\n",
+		"main.go":    "package main\n\nimport \"example.com/iterations/lib\"\n\nfunc main() {\n\tlib.Clean()\n}\n",
+	})
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		Paths:                []string{"./..."},
+		MaxIterations:        1,
+		Reporter:             report.New(),
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if data := opts.Reporter.GetData(); data.Converged || data.Iterations != 1 {
+		t.Errorf("expected 1 unconverged iteration, got %d (converged=%v)", data.Iterations, data.Converged)
+	}
+
+	// The second run starts from the rewritten tree and reuses the unchanged packages.
+	opts.MaxIterations = 0
+	opts.Reporter = report.New()
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if data := opts.Reporter.GetData(); !data.Converged {
+		t.Errorf("expected convergence, got %+v", data)
+	}
+	out, _ := os.ReadFile(filepath.Join(tmpDir, "main.go"))
+	if !strings.Contains(string(out), "if err := lib.Clean(); err != nil") {
+		t.Errorf("caller not updated:\n%s", out)
+	}
+}
+
+func TestRun_UncheckedAssign(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, map[string]string{
+		"go.mod": "module example.com/unchecked\ngo 1.22\n",
+		"lib/lib.go": `package lib
+
+import "strconv"
+
+func Sum(a, b string) (int, error) {
+	x, err := strconv.Atoi(a)
+	y, err := strconv.Atoi(b)
+	if err != nil {
+		return 0, err
+	}
+	return x + y, nil
+}
+`,
+		"main.go": `package main
+
+import "os"
+
+func main() {
+	err := os.Remove("a")
+	err = os.Remove("b")
+	println(err)
+}
+`,
+	})
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		Paths:                []string{"./..."},
+		Reporter:             report.New(),
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if data := opts.Reporter.GetData(); !data.Converged {
+		t.Errorf("expected convergence, got %+v", data)
+	}
+
+	lib, _ := os.ReadFile(filepath.Join(tmpDir, "lib", "lib.go"))
+	if !strings.Contains(string(lib), "x, err := strconv.Atoi(a)\n\tif err != nil {\n\t\treturn 0, err\n\t}\n\ty, err := strconv.Atoi(b)") {
+		t.Errorf("overwritten error not checked:\n%s", lib)
+	}
+	main, _ := os.ReadFile(filepath.Join(tmpDir, "main.go"))
+	if !strings.Contains(string(main), "err := os.Remove(\"a\")\n\tif err != nil {\n\t\tlog.Fatal(err)\n\t}\n\terr = os.Remove(\"b\")") {
+		t.Errorf("overwritten error not checked in main:\n%s", main)
+	}
+}
+
+func TestRun_Shadowed(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, map[string]string{
+		"go.mod": "module example.com/shadowed\ngo 1.22\n",
+		"lib/lib.go": `package lib
+
+import "os"
+
+func Size(name string) (size int64, err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if name != "" {
+		info, err := f.Stat()
+		if err == nil {
+			size = info.Size()
+		}
+	}
+	return
+}
+`,
+	})
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		Paths:                []string{"./..."},
+		// The file is read-only: its Close is only joined on request.
+		ClosePolicy: "join",
+		Reporter:    report.New(),
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if data := opts.Reporter.GetData(); !data.Converged {
+		t.Errorf("expected convergence, got %+v", data)
+	}
+
+	lib, _ := os.ReadFile(filepath.Join(tmpDir, "lib", "lib.go"))
+	for _, want := range []string{
+		"err = errors.Join(err, f.Close())",
+		"var info os.FileInfo\n\t\tinfo, err = f.Stat()",
+	} {
+		if !strings.Contains(string(lib), want) {
+			t.Errorf("lib.go missing %q:\n%s", want, lib)
+		}
+	}
+}
+
+// TestRun_Generics verifies that errors propagate through instantiations of generic functions,
+// methods and interfaces, and that results of type parameter types return declared zero values.
+func TestRun_Generics(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, map[string]string{
+		"go.mod": "module example.com/generics\ngo 1.22\n",
+		"lib/lib.go": `package lib
+
+import "os"
+
+type Number interface {
+	~int | ~float64
+}
+
+type Stack[T any] struct {
+	items []T
+}
+
+func (s *Stack[T]) Pop() T {
+	os.Remove("log")
+	v := s.items[len(s.items)-1]
+	s.items = s.items[:len(s.items)-1]
+	return v
+}
+
+func Sum[N Number](xs []N) N {
+	os.Remove("sum")
+	var total N
+	for _, x := range xs {
+		total += x
+	}
+	return total
+}
+
+func First[K comparable, V any](m map[K]V, k K) (K, V) {
+	os.Remove("first")
+	return k, m[k]
+}
+
+type Store[T any] interface {
+	Put(v T)
+}
+
+type MemStore[T any] struct{ items []T }
+
+func (m *MemStore[T]) Put(v T) {
+	os.Remove("put")
+	m.items = append(m.items, v)
+}
+
+type NopStore[T any] struct{}
+
+func (NopStore[T]) Put(v T) {}
+
+func Decode[T any](path string) (T, error) {
+	var v T
+	_, err := os.ReadFile(path)
+	return v, err
+}
+
+func Use(s Store[int]) int {
+	st := &Stack[int]{items: []int{1}}
+	n := st.Pop()
+	t := Sum[float64]([]float64{1, 2})
+	_, v := First(map[string]int{"a": 1}, "a")
+	s.Put(n)
+	Decode[string]("x")
+	return n + int(t) + v
+}
+`,
+	})
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		EvolveInterfaces:     true,
+		Paths:                []string{"./..."},
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	lib, _ := os.ReadFile(filepath.Join(tmpDir, "lib", "lib.go"))
+	for _, want := range []string{
+		"func (s *Stack[T]) Pop() (T, error) {\n\tif err := os.Remove(\"log\"); err != nil {\n\t\tvar zero T\n\t\treturn zero, err\n\t}",
+		"func Sum[N Number](xs []N) (N, error) {",
+		"var zero K\n\t\tvar zeroV V\n\t\treturn zero, zeroV, err",
+		"Put(v T) error\n",
+		"func (NopStore[T]) Put(v T) error",
+		"func Use(s Store[int]) (int, error) {",
+		"n, err := st.Pop()",
+		"t, err := Sum[float64]([]float64{1, 2})",
+		"_, v, err := First(map[string]int{\"a\": 1}, \"a\")",
+		"if err := s.Put(n); err != nil {",
+		"if _, err := Decode[string](\"x\"); err != nil {",
+	} {
+		if !strings.Contains(string(lib), want) {
+			t.Errorf("lib.go missing %q:\n%s", want, lib)
+		}
+	}
+
+	cmd := exec.Command("go", "build", "./...")
+	cmd.Dir = tmpDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("rewritten module does not build: %v\n%s", err, out)
+	}
+}
+
+// TestRun_TestCallers verifies that callers in the tests of the package, which belong to its test
+// variant, are updated with the function gaining an error result.
+func TestRun_TestCallers(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, map[string]string{
+		"go.mod": "module example.com/testcallers\ngo 1.22\n",
+		"lib/lib.go": `package lib
+
+import "os"
+
+func Prepare(name string) int {
+	os.Remove(name)
+	return 1
+}
+
+func Run() {
+	Prepare("y")
+}
+`,
+		"lib/lib_test.go": `package lib
+
+import "testing"
+
+func TestPrepare(t *testing.T) {
+	n := Prepare("a")
+	_ = n
+}
+`,
+	})
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		EnableTestRefactor:   true,
+		Paths:                []string{"./..."},
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	lib, _ := os.ReadFile(filepath.Join(tmpDir, "lib", "lib.go"))
+	if !strings.Contains(string(lib), "func Prepare(name string) (int, error) {") {
+		t.Errorf("expected Prepare to gain an error result:\n%s", lib)
+	}
+	test, _ := os.ReadFile(filepath.Join(tmpDir, "lib", "lib_test.go"))
+	if want := "n, err := Prepare(\"a\")\n\tif err != nil {\n\t\tt.Fatalf(\"Prepare: %v\", err)\n\t}"; !strings.Contains(string(test), want) {
+		t.Errorf("lib_test.go missing %q:\n%s", want, test)
+	}
+	if n := strings.Count(string(test), "if err != nil"); n != 1 {
+		t.Errorf("expected the test caller to be rewritten once, got %d checks:\n%s", n, test)
+	}
+
+	cmd := exec.Command("go", "vet", "./...")
+	cmd.Dir = tmpDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("rewritten module does not vet: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,115 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/report"
+)
+
+// Supported values for Options.Format.
+const (
+	// FormatText logs a human readable summary (default).
+	FormatText = "text"
+	// FormatSARIF emits a SARIF 2.1.0 log describing every finding.
+	FormatSARIF = "sarif"
+)
+
+// writeCheckReport emits the machine-readable report for check mode, if one was requested.
+//
+// findings: The findings (see summarize).
+// opts: Runner options (Format, Output, Version).
+func writeCheckReport(findings []checkFinding, opts Options) error {
+	switch opts.Format {
+	case "", FormatText:
+		return nil
+	case FormatSARIF:
+	default:
+		return fmt.Errorf("unsupported format %q", opts.Format)
+	}
+
+	wd, _ := os.Getwd()
+	out := make([]report.Finding, len(findings))
+	for i, f := range findings {
+		out[i] = f.Finding
+		out[i].File = relPath(wd, f.File)
+	}
+
+	if opts.Output == "" {
+		return report.WriteSARIF(os.Stdout, opts.Version, out)
+	}
+	f, err := os.Create(opts.Output)
+	if err != nil {
+		return fmt.Errorf("failed to create report: %w", err)
+	}
+	if err := report.WriteSARIF(f, opts.Version, out); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// buildFindings converts injection points into report findings, including the fix the Injector
+// would apply in place (where available). Files are absolute paths.
+func buildFindings(points []analysis.InjectionPoint, opts Options) []report.Finding {
+	sources := make(map[string][]byte)
+
+	findings := make([]report.Finding, 0, len(points))
+	for _, p := range points {
+		start := p.Pkg.Fset.Position(p.Call.Pos())
+		end := p.Pkg.Fset.Position(p.Call.End())
+
+		callee := p.CalleeName()
+		f := report.Finding{
+			File:      start.Filename,
+			Line:      start.Line,
+			Column:    start.Column,
+			EndLine:   end.Line,
+			EndColumn: end.Column,
+			Callee:    callee,
+			Message:   p.Message(),
+		}
+
+		src, ok := sources[start.Filename]
+		if !ok {
+			src, _ = os.ReadFile(start.Filename)
+			sources[start.Filename] = src
+		}
+		if src != nil {
+			injector := newInjector(p.Pkg, opts.forPackage(p.Pkg))
+			edits, err := injector.SuggestFix(p, src)
+			if err == nil {
+				for _, e := range edits {
+					from := p.Pkg.Fset.Position(e.Pos)
+					to := p.Pkg.Fset.Position(e.End)
+					f.Fix = append(f.Fix, report.Replacement{
+						StartLine:   from.Line,
+						StartColumn: from.Column,
+						EndLine:     to.Line,
+						EndColumn:   to.Column,
+						Text:        string(e.NewText),
+					})
+				}
+			}
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// relPath returns path relative to base using forward slashes, or path unchanged if that is not possible.
+func relPath(base, path string) string {
+	if base == "" {
+		return filepath.ToSlash(path)
+	}
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,115 @@
+package runner
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRun_CheckMode_SARIF verifies that check mode emits a SARIF log with locations, callee and fixes.
+func TestRun_CheckMode_SARIF(t *testing.T) {
+	tmpDir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module sariftest\ngo 1.22\n"), 0644)
+
+	src := `package main
+
+func fail() error { return nil }
+
+func run() error {
+	fail()
+	return nil
+}
+
+func main() {
+	_ = run()
+}
+`
+	_ = os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte(src), 0644)
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer interface{}(func() { _ = os.Chdir(oldWd) }).(func())()
+
+	out := filepath.Join(tmpDir, "report.sarif")
+	err := Run(Options{
+		Check:                true,
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		Paths:                []string{"."},
+		Format:               FormatSARIF,
+		Output:               out,
+		Version:              "test",
+	})
+	if err == nil || !strings.Contains(err.Error(), "check failed: 2 unhandled errors found") {
+		t.Fatalf("Expected check failure, got %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("report not written: %v", err)
+	}
+
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Results []struct {
+				Message   struct{ Text string } `json:"message"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string } `json:"artifactLocation"`
+						Region           struct {
+							StartLine int `json:"startLine"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+				Fixes      []json.RawMessage `json:"fixes"`
+				Properties map[string]string `json:"properties"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatalf("invalid SARIF: %v\n%s", err, data)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("Unexpected SARIF envelope:\n%s", data)
+	}
+
+	results := log.Runs[0].Results
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	first := results[0]
+	loc := first.Locations[0].PhysicalLocation
+	if loc.ArtifactLocation.URI != "main.go" || loc.Region.StartLine != 6 {
+		t.Errorf("Unexpected location: %+v", loc)
+	}
+	if first.Properties["callee"] != "sariftest.fail" {
+		t.Errorf("Unexpected callee: %v", first.Properties)
+	}
+	if len(first.Fixes) != 1 {
+		t.Errorf("Expected a fix for preexisting error return, got %d", len(first.Fixes))
+	}
+
+	// main() cannot return an error, so no in-place fix is proposed.
+	if len(results[1].Fixes) != 0 {
+		t.Errorf("Expected no fix inside main, got %d", len(results[1].Fixes))
+	}
+
+	// Source must be untouched.
+	if content, _ := os.ReadFile(filepath.Join(tmpDir, "main.go")); string(content) != src {
+		t.Error("File was modified in Check mode")
+	}
+}
+
+// TestWriteCheckReport_UnsupportedFormat verifies invalid formats are rejected.
+func TestWriteCheckReport_UnsupportedFormat(t *testing.T) {
+	if err := writeCheckReport(nil, Options{Format: "xml"}); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+	if err := writeCheckReport(nil, Options{Format: FormatText}); err != nil {
+		t.Errorf("Text format should be a no-op, got %v", err)
+	}
+}
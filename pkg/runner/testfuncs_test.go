@@ -0,0 +1,162 @@
+package runner
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRun_TestFuncs verifies that tests, benchmarks, fuzz targets and helpers stop the test instead
+// of returning errors, including errors propagated into them.
+func TestRun_TestFuncs(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, map[string]string{
+		"go.mod": "module example.com/tt\ngo 1.22\n",
+		"lib/lib.go": `package lib
+
+import "os"
+
+func Save(name string) error { return nil }
+
+func Load(name string) (string, error) { return name, nil }
+
+func Prepare() {
+	os.Remove("tmp")
+}
+`,
+		"lib/lib_test.go": `package lib
+
+import (
+	"os"
+	"testing"
+)
+
+func setup(t *testing.T, name string) string {
+	Save(name)
+	return name
+}
+
+func mustLoad(tb testing.TB) string {
+	tb.Helper()
+	v, _ := Load("x")
+	return v
+}
+
+func TestSave(t *testing.T) {
+	name := setup(t, "a")
+	Save(name)
+	Prepare()
+	t.Run("sub", func(t *testing.T) {
+		os.Remove("x")
+	})
+}
+
+func BenchmarkLoad(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Load("x")
+	}
+}
+
+func FuzzSave(f *testing.F) {
+	f.Fuzz(func(t *testing.T, s string) {
+		Save(s)
+	})
+}
+`,
+	})
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableTestRefactor:   true,
+		Paths:                []string{"./..."},
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	got, _ := os.ReadFile(filepath.Join(tmpDir, "lib", "lib_test.go"))
+	for _, want := range []string{
+		"func setup(t *testing.T, name string) string {\n\tt.Helper()\n\tif err := Save(name); err != nil {\n\t\tt.Fatalf(\"Save: %v\", err)\n\t}",
+		"func mustLoad(tb testing.TB) string {\n\ttb.Helper()\n\tv, err := Load(\"x\")\n\tif err != nil {\n\t\ttb.Fatalf(\"Load: %v\", err)\n\t}",
+		"if err := Save(name); err != nil {\n\t\tt.Fatalf(\"Save: %v\", err)\n\t}",
+		// Prepare gains an error result; its caller stops the test.
+		"if err := Prepare(); err != nil {\n\t\tt.Fatalf(\"Prepare: %v\", err)\n\t}",
+		"if err := os.Remove(\"x\"); err != nil {\n\t\t\tt.Fatalf(\"os.Remove: %v\", err)\n\t\t}",
+		"if _, err := Load(\"x\"); err != nil {\n\t\t\tb.Fatalf(\"Load: %v\", err)\n\t\t}",
+		"if err := Save(s); err != nil {\n\t\t\tt.Fatalf(\"Save: %v\", err)\n\t\t}",
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("lib_test.go missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Count(string(got), "Helper()") != 2 {
+		t.Errorf("expected a single t.Helper() per helper:\n%s", got)
+	}
+
+	cmd := exec.Command("go", "vet", "./...")
+	cmd.Dir = tmpDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("rewritten module does not vet: %v\n%s", err, out)
+	}
+}
+
+// TestRun_TestFuncsInitStmt verifies that calls in the init statement of an if statement, which the
+// check cannot replace, are left alone while the rest of the test file is fixed.
+func TestRun_TestFuncsInitStmt(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, map[string]string{
+		"go.mod": "module example.com/tt\ngo 1.22\n",
+		"lib/lib.go": `package lib
+
+func Load(name string) (string, error) { return name, nil }
+`,
+		"lib/lib_test.go": `package lib
+
+import "testing"
+
+func TestLoad(t *testing.T) {
+	if v, _ := Load("x"); v != "x" {
+		t.Errorf("got %q", v)
+	}
+	Load("y")
+}
+`,
+	})
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableTestRefactor:   true,
+		Paths:                []string{"./..."},
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	got, _ := os.ReadFile(filepath.Join(tmpDir, "lib", "lib_test.go"))
+	for _, want := range []string{
+		"if v, _ := Load(\"x\"); v != \"x\" {",
+		"if _, err := Load(\"y\"); err != nil {\n\t\tt.Fatalf(\"Load: %v\", err)\n\t}",
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("lib_test.go missing %q:\n%s", want, got)
+		}
+	}
+
+	cmd := exec.Command("go", "vet", "./...")
+	cmd.Dir = tmpDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("rewritten module does not vet: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,345 @@
+package runner
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/loader"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/report"
+	"golang.org/x/tools/go/packages"
+)
+
+// fileEdit is an edit of a file, recorded to attribute regressions to injection points.
+type fileEdit struct {
+	// point is the injection point whose handling caused the edit, or nil (e.g. panic rewrites).
+	point *analysis.InjectionPoint
+	// fn is the function containing the edit ("Type.Method" for methods), or "" if unknown.
+	fn string
+}
+
+// errorSet holds the distinct errors of a file, or of a package for errors without position.
+type errorSet struct {
+	pkg   *packages.Package
+	msgs  []string
+	lines []int
+}
+
+// beginEdit sets the point that the following edits belong to.
+//
+// p: The injection point being handled, or nil.
+// pos: The position of the edit (e.g. the rewritten call), or token.NoPos.
+func (m *dstManager) beginEdit(p *analysis.InjectionPoint, pos token.Pos) {
+	m.origin = p
+	m.originPos = pos
+}
+
+// recordEdit records the current edit for the file named name.
+func (m *dstManager) recordEdit(name string, astFile *ast.File) {
+	edit := fileEdit{point: m.origin}
+	if m.originPos.IsValid() && astFile.Pos() <= m.originPos && m.originPos < astFile.End() {
+		edit.fn = funcNameAt(astFile, m.originPos)
+	}
+	for _, e := range m.edits[name] {
+		if e == edit {
+			return
+		}
+	}
+	m.edits[name] = append(m.edits[name], edit)
+}
+
+// verify type checks the rendered files of mgr in memory (see loader.Session.Check) and
+// reverts the files that introduce errors, until the remaining rewrites compile.
+//
+// A modified file with new errors, or which cannot be rendered, is reverted. New errors in an unmodified file (e.g. a caller
+// that could not be updated to a new signature) revert the modified files of its package and of
+// the packages it imports.
+//
+// mgr: The DST manager holding the rewrites.
+// session: The session holding the packages before the rewrites.
+// opts: The options, providing the reporter.
+// baseline: The errors of the packages before the rewrites (see collectErrors).
+//
+// Returns the injection points whose handling was reverted, or an error if the packages cannot
+// be loaded.
+func verify(mgr *dstManager, session *loader.Session, opts Options, baseline map[string]*errorSet) ([]*analysis.InjectionPoint, error) {
+	var culprits []*analysis.InjectionPoint
+	for len(mgr.modified) > 0 {
+		modified := make([]string, 0, len(mgr.modified))
+		for path := range mgr.modified {
+			modified = append(modified, path)
+		}
+		sort.Strings(modified)
+		outs, errs := mgr.renderEach(modified)
+		overlay := make(map[string][]byte, len(modified))
+		unrendered := false
+		for i, path := range modified {
+			if errs[i] != nil {
+				// The rewritten file cannot be printed (e.g. a statement in an invalid position),
+				// so it does not compile either.
+				set := &errorSet{msgs: []string{fmt.Sprintf("%s: cannot render the rewrite: %v", path, errs[i])}}
+				culprits = append(culprits, mgr.revert(path, set, nil, opts.Reporter)...)
+				unrendered = true
+				continue
+			}
+			overlay[path] = outs[i]
+		}
+		if unrendered {
+			continue
+		}
+
+		pkgs, err := session.Check(overlay)
+		if err != nil {
+			return culprits, fmt.Errorf("verification load failed: %w", err)
+		}
+		current := collectErrors(pkgs)
+
+		revert := make(map[string]bool)
+		for key, set := range current {
+			if base := baseline[key]; base != nil && len(set.msgs) <= len(base.msgs) {
+				continue
+			}
+			if mgr.modified[key] {
+				revert[key] = true
+				continue
+			}
+			for _, path := range modifiedFiles(mgr, set.pkg) {
+				revert[path] = true
+			}
+		}
+		if len(revert) == 0 {
+			// Either everything compiles, or the remaining errors are not caused by the rewrites.
+			if !hasNewErrors(current, baseline) {
+				return culprits, nil
+			}
+			for path := range mgr.modified {
+				revert[path] = true
+			}
+		}
+
+		paths := make([]string, 0, len(revert))
+		for path := range revert {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			culprits = append(culprits, mgr.revert(path, current[path], overlay[path], opts.Reporter)...)
+		}
+	}
+	return culprits, nil
+}
+
+// revert drops the rewrite of path and reports the points that caused errs.
+//
+// path: The file to revert.
+// errs: The errors of the rewritten file, or nil if the file is reverted for errors elsewhere.
+// src: The rewritten source of the file, or nil if it cannot be rendered.
+// rep: The reporter receiving the regression.
+//
+// Returns the points that caused the errors: those editing the functions containing errs, or
+// all points that edited the file if the errors are outside of these functions.
+func (m *dstManager) revert(path string, errs *errorSet, src []byte, rep *report.Reporter) []*analysis.InjectionPoint {
+	delete(m.modified, path)
+
+	edits := m.edits[path]
+	if errs != nil && src != nil {
+		fns := make(map[string]bool)
+		fset := token.NewFileSet()
+		if f, err := parser.ParseFile(fset, path, src, parser.SkipObjectResolution); err == nil {
+			for _, line := range errs.lines {
+				if fn := funcNameAtLine(fset, f, line); fn != "" {
+					fns[fn] = true
+				}
+			}
+		}
+		var matched []fileEdit
+		for _, e := range edits {
+			if e.fn != "" && fns[e.fn] {
+				matched = append(matched, e)
+			}
+		}
+		if len(matched) > 0 {
+			edits = matched
+		}
+	}
+
+	reg := report.Regression{File: path, Points: []string{}, Errors: []string{}}
+	var points []*analysis.InjectionPoint
+	seen := make(map[*analysis.InjectionPoint]bool)
+	for _, e := range edits {
+		if e.point == nil || seen[e.point] {
+			continue
+		}
+		seen[e.point] = true
+		points = append(points, e.point)
+		reg.Points = append(reg.Points, describePoint(e.point))
+	}
+
+	if errs != nil {
+		reg.Errors = append(reg.Errors, errs.msgs...)
+		log.Printf("[WARN] Reverted %s: the rewritten file does not compile.", path)
+	} else {
+		log.Printf("[WARN] Reverted %s: the rewrite breaks code depending on it.", path)
+	}
+	for _, msg := range reg.Errors {
+		log.Printf("[WARN]   %s", msg)
+	}
+	for _, p := range reg.Points {
+		log.Printf("[WARN]   caused by handling %s", p)
+	}
+	if rep != nil {
+		rep.AddRegression(reg)
+	}
+	return points
+}
+
+// collectErrors groups the distinct errors of pkgs by file. Errors without a position are
+// grouped under "package <path>".
+//
+// pkgs: The loaded packages, including test variants, which repeat the errors of shared files.
+//
+// Returns the errors keyed by absolute file path.
+func collectErrors(pkgs []*packages.Package) map[string]*errorSet {
+	out := make(map[string]*errorSet)
+	seen := make(map[string]bool)
+	for _, pkg := range pkgs {
+		for _, e := range pkg.Errors {
+			msg := e.Error()
+			if seen[msg] {
+				continue
+			}
+			seen[msg] = true
+			file, line := splitErrorPos(e.Pos)
+			key := file
+			if key == "" {
+				key = "package " + pkg.PkgPath
+			}
+			set := out[key]
+			if set == nil {
+				set = &errorSet{pkg: pkg}
+				out[key] = set
+			}
+			set.msgs = append(set.msgs, msg)
+			set.lines = append(set.lines, line)
+		}
+	}
+	return out
+}
+
+// hasNewErrors reports whether current has more errors than baseline for any file or package.
+func hasNewErrors(current, baseline map[string]*errorSet) bool {
+	for key, set := range current {
+		if base := baseline[key]; base == nil || len(set.msgs) > len(base.msgs) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorPos matches the position of a packages.Error: "file:line:col" or "file:line".
+var errorPos = regexp.MustCompile(`^(.*?):(\d+)(?::\d+)?$`)
+
+// splitErrorPos splits the position of a packages.Error.
+//
+// Returns the file name and line, or "" and 0 if the position is unknown (e.g. "" or "-").
+func splitErrorPos(pos string) (string, int) {
+	m := errorPos.FindStringSubmatch(pos)
+	if m == nil {
+		return "", 0
+	}
+	line, _ := strconv.Atoi(m[2])
+	return m[1], line
+}
+
+// modifiedFiles returns the modified files of pkg and of the packages it imports, transitively.
+func modifiedFiles(mgr *dstManager, pkg *packages.Package) []string {
+	if pkg == nil {
+		return nil
+	}
+	var out []string
+	seen := make(map[string]bool)
+	queue := []*packages.Package{pkg}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if seen[p.ID] {
+			continue
+		}
+		seen[p.ID] = true
+		for _, f := range p.CompiledGoFiles {
+			if mgr.modified[f] && !seen[f] {
+				seen[f] = true
+				out = append(out, f)
+			}
+		}
+		for _, imp := range p.Imports {
+			queue = append(queue, imp)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// funcNameAt returns the name of the function declaration of file containing pos
+// ("Type.Method" for methods), or "" if there is none.
+func funcNameAt(file *ast.File, pos token.Pos) string {
+	for _, d := range file.Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok && fd.Pos() <= pos && pos < fd.End() {
+			return funcDeclName(fd)
+		}
+	}
+	return ""
+}
+
+// funcNameAtLine is like funcNameAt for a line of a file parsed with fset.
+func funcNameAtLine(fset *token.FileSet, file *ast.File, line int) string {
+	for _, d := range file.Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok && fset.Position(fd.Pos()).Line <= line && line <= fset.Position(fd.End()).Line {
+			return funcDeclName(fd)
+		}
+	}
+	return ""
+}
+
+// funcDeclName returns the name of fd, qualified by its receiver type for methods.
+func funcDeclName(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return fd.Name.Name
+	}
+	t := fd.Recv.List[0].Type
+	for {
+		switch x := t.(type) {
+		case *ast.StarExpr:
+			t = x.X
+			continue
+		case *ast.IndexExpr:
+			t = x.X
+			continue
+		case *ast.IndexListExpr:
+			t = x.X
+			continue
+		case *ast.ParenExpr:
+			t = x.X
+			continue
+		}
+		break
+	}
+	if id, ok := t.(*ast.Ident); ok {
+		return id.Name + "." + fd.Name.Name
+	}
+	return fd.Name.Name
+}
+
+// describePoint formats p as "file:line:col (callee)".
+func describePoint(p *analysis.InjectionPoint) string {
+	if p.Pkg == nil || p.Pkg.Fset == nil {
+		return p.CalleeName()
+	}
+	return fmt.Sprintf("%s (%s)", p.Pkg.Fset.Position(p.Pos), p.CalleeName())
+}
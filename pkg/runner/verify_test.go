@@ -0,0 +1,282 @@
+package runner
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/analysis"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/loader"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/report"
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/rewrite"
+	"github.com/dave/dst"
+	"golang.org/x/tools/go/packages"
+)
+
+// TestRun_VerifyRevertsBrokenRewrite verifies that a rewrite that does not compile is reverted and
+// reported, and that the other points of the file are still handled in the next iteration.
+func TestRun_VerifyRevertsBrokenRewrite(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := `package main
+
+import "os"
+
+func cleanup() {
+	os.Remove("a")
+}
+
+func setup() {
+	os.Chdir("/")
+}
+
+func main() {
+	cleanup()
+	setup()
+}
+`
+	writeModule(t, tmpDir, map[string]string{
+		"go.mod":  "module example.com/verify\ngo 1.22\n",
+		"main.go": src,
+	})
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	rep := report.New()
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		EnableThirdPartyErr:  true,
+		Paths:                []string{"."},
+		Reporter:             rep,
+		Rules:                []rewrite.Rule{{Symbol: "os.Remove", Template: "undefinedWrap(err)"}},
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	out, _ := os.ReadFile(filepath.Join(tmpDir, "main.go"))
+	got := string(out)
+	if strings.Contains(got, "undefinedWrap") {
+		t.Errorf("broken rewrite was written:\n%s", got)
+	}
+	if !strings.Contains(got, "func setup() error {") {
+		t.Errorf("independent point was not handled:\n%s", got)
+	}
+
+	regs := rep.GetData().Reverted
+	if len(regs) != 1 {
+		t.Fatalf("expected 1 regression, got %+v", regs)
+	}
+	if len(regs[0].Points) != 1 || !strings.Contains(regs[0].Points[0], "main.go:6:2 (os.Remove)") {
+		t.Errorf("regression should name the os.Remove point, got %v", regs[0].Points)
+	}
+	if len(regs[0].Errors) == 0 || !strings.Contains(strings.Join(regs[0].Errors, "\n"), "undefinedWrap") {
+		t.Errorf("regression should list the compiler error, got %v", regs[0].Errors)
+	}
+	// The os.Remove error is still unhandled.
+	if data := rep.GetData(); data.Rejected != 1 || data.Converged {
+		t.Errorf("expected 1 rejected error and no convergence, got %d (converged=%v)", data.Rejected, data.Converged)
+	}
+}
+
+// TestRun_NoVerify verifies that NoVerify writes rewrites without type checking them.
+func TestRun_NoVerify(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, map[string]string{
+		"go.mod": "module example.com/noverify\ngo 1.22\n",
+		"main.go": `package main
+
+import "os"
+
+func cleanup() {
+	os.Remove("a")
+}
+
+func main() {
+	cleanup()
+}
+`,
+	})
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	rep := report.New()
+	opts := Options{
+		EnablePreexistingErr: true,
+		EnableNonExistingErr: true,
+		Paths:                []string{"."},
+		Reporter:             rep,
+		NoVerify:             true,
+		Rules:                []rewrite.Rule{{Symbol: "os.Remove", Template: "undefinedWrap(err)"}},
+	}
+	if err := Run(opts); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	out, _ := os.ReadFile(filepath.Join(tmpDir, "main.go"))
+	if !strings.Contains(string(out), "return undefinedWrap(err)") {
+		t.Errorf("rewrite should be written without verification:\n%s", out)
+	}
+	if regs := rep.GetData().Reverted; len(regs) != 0 {
+		t.Errorf("expected no regressions, got %+v", regs)
+	}
+}
+
+func TestSplitErrorPos(t *testing.T) {
+	tests := []struct {
+		pos      string
+		wantFile string
+		wantLine int
+	}{
+		{"/src/main.go:12:3", "/src/main.go", 12},
+		{"/src/main.go:12", "/src/main.go", 12},
+		{`C:\src\main.go:4:1`, `C:\src\main.go`, 4},
+		{"-", "", 0},
+		{"", "", 0},
+	}
+	for _, tt := range tests {
+		file, line := splitErrorPos(tt.pos)
+		if file != tt.wantFile || line != tt.wantLine {
+			t.Errorf("splitErrorPos(%q) = %q, %d; want %q, %d", tt.pos, file, line, tt.wantFile, tt.wantLine)
+		}
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	pkg := &packages.Package{PkgPath: "example.com/a", Errors: []packages.Error{
+		{Pos: "/src/a.go:3:2", Msg: "undefined: x"},
+		{Pos: "/src/a.go:5:2", Msg: "undefined: y"},
+		{Pos: "-", Msg: "missing module"},
+	}}
+	// The test variant repeats the errors of the shared file.
+	test := &packages.Package{PkgPath: "example.com/a", Errors: []packages.Error{
+		{Pos: "/src/a.go:3:2", Msg: "undefined: x"},
+	}}
+
+	got := collectErrors([]*packages.Package{pkg, test})
+	if set := got["/src/a.go"]; set == nil || len(set.msgs) != 2 || set.lines[1] != 5 {
+		t.Errorf("unexpected file errors: %+v", set)
+	}
+	if set := got["package example.com/a"]; set == nil || len(set.msgs) != 1 {
+		t.Errorf("unexpected package errors: %+v", set)
+	}
+	if len(got) != 2 {
+		t.Errorf("expected 2 keys, got %d", len(got))
+	}
+
+	if hasNewErrors(got, got) {
+		t.Error("identical error sets should not have new errors")
+	}
+	if !hasNewErrors(got, nil) {
+		t.Error("errors without baseline should be new")
+	}
+}
+
+func TestFuncDeclName(t *testing.T) {
+	src := `package p
+
+type T[K any] struct{}
+
+func plain() {}
+
+func (t *T[K]) generic() {}
+
+func (s server) value() {
+	_ = 1
+}
+
+type server struct{}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, d := range f.Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok {
+			names = append(names, funcDeclName(fd))
+		}
+	}
+	if got, want := strings.Join(names, ","), "plain,T.generic,server.value"; got != want {
+		t.Errorf("funcDeclName = %s, want %s", got, want)
+	}
+
+	if got := funcNameAtLine(fset, f, 10); got != "server.value" {
+		t.Errorf("funcNameAtLine(10) = %q, want server.value", got)
+	}
+	if got := funcNameAtLine(fset, f, 3); got != "" {
+		t.Errorf("funcNameAtLine(3) = %q, want empty", got)
+	}
+}
+
+// TestVerify_RenderFailure verifies that a rewrite that cannot be rendered is reverted and reported
+// like a rewrite that does not compile, and that the other files are still written.
+func TestVerify_RenderFailure(t *testing.T) {
+	// The imports are sorted when the file is rendered, which parses the printed file again.
+	const bSrc = "package render\n\nimport (\n\t\"strings\"\n\t\"fmt\"\n)\n\nfunc B() {\n\tif true {\n\t\tfmt.Println(strings.ToUpper(\"b\"))\n\t}\n}\n"
+	tmpDir := t.TempDir()
+	writeModule(t, tmpDir, map[string]string{
+		"go.mod": "module example.com/render\ngo 1.22\n",
+		"a.go":   "package render\n\nfunc A() {}\n",
+		"b.go":   bSrc,
+	})
+	session, err := loader.NewSession([]string{"./..."}, tmpDir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgs := session.Packages()
+	mgr := newDstManager(pkgs, 1)
+	files := make(map[string]*ast.File)
+	for _, f := range pkgs[0].Syntax {
+		files[filepath.Base(pkgs[0].Fset.File(f.Pos()).Name())] = f
+	}
+
+	dstA, err := mgr.Get(pkgs[0], files["a.go"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstA.Decls = append(dstA.Decls, &dst.FuncDecl{Name: dst.NewIdent("C"), Type: &dst.FuncType{}, Body: &dst.BlockStmt{}})
+	mgr.MarkModified(files["a.go"])
+
+	// An if statement cannot be the init statement of another.
+	dstB, err := mgr.Get(pkgs[0], files["b.go"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ifStmt := dstB.Decls[1].(*dst.FuncDecl).Body.List[0].(*dst.IfStmt)
+	ifStmt.Init = &dst.IfStmt{Cond: dst.NewIdent("true"), Body: &dst.BlockStmt{}}
+	point := &analysis.InjectionPoint{Pkg: pkgs[0], File: files["b.go"], Pos: files["b.go"].Decls[1].Pos()}
+	mgr.beginEdit(point, point.Pos)
+	mgr.MarkModified(files["b.go"])
+
+	rep := report.New()
+	culprits, err := verify(mgr, session, Options{Reporter: rep}, collectErrors(pkgs))
+	if err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	if len(culprits) != 1 || culprits[0] != point {
+		t.Errorf("expected the point of b.go to be reported, got %v", culprits)
+	}
+	if regs := rep.GetData().Reverted; len(regs) != 1 || !strings.HasSuffix(regs[0].File, "b.go") {
+		t.Errorf("expected b.go to be reverted, got %+v", regs)
+	}
+
+	if err := mgr.Save(nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(tmpDir, "a.go")); !strings.Contains(string(got), "func C() {}") {
+		t.Errorf("expected a.go to be written:\n%s", got)
+	}
+	if got, _ := os.ReadFile(filepath.Join(tmpDir, "b.go")); string(got) != bSrc {
+		t.Errorf("expected b.go to be unchanged:\n%s", got)
+	}
+}
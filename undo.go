@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/SamuelMarks/go-auto-err-handling/pkg/journal"
+	"github.com/alecthomas/kong"
+)
+
+// UndoConfig holds the flags of the undo command.
+type UndoConfig struct {
+	// RunID is the run to undo. Defaults to the latest run that has not been undone.
+	RunID string `arg:"" optional:"" name:"run-id" help:"Run to undo (default: the latest run not undone yet)."`
+
+	// JournalDir is the directory of the undo journal.
+	// Defaults to the `journal-dir` of the configuration file, or ".auto-err".
+	JournalDir string `name:"journal-dir" help:"Directory of the undo journal." default:"${journal_dir}"`
+
+	// List prints the recorded runs instead of undoing one.
+	List bool `name:"list" help:"List the recorded runs and exit."`
+}
+
+// runUndo restores the files written by a run, unless they were edited since.
+//
+// args: Command line arguments following "undo".
+// stdout: Writer for the output.
+//
+// Returns an error if the run cannot be found or a file cannot be restored.
+func runUndo(args []string, stdout io.Writer) error {
+	journalDir, err := configPath("journal-dir", journal.DefaultRoot)
+	if err != nil {
+		return err
+	}
+
+	var cfg UndoConfig
+	parser, err := kong.New(&cfg,
+		kong.Name("auto-err undo"),
+		kong.Description("Restore the files written by a run of auto-err, unless they were edited since."),
+		kong.Writers(stdout, io.Discard),
+		kong.Vars{"journal_dir": journalDir},
+	)
+	if err != nil {
+		return err
+	}
+	if _, err := parser.Parse(args); err != nil {
+		return err
+	}
+
+	if cfg.List {
+		runs, err := journal.List(cfg.JournalDir)
+		if err != nil {
+			return err
+		}
+		for _, run := range runs {
+			status := ""
+			if run.Undone {
+				status = " (undone)"
+			}
+			fmt.Fprintf(stdout, "%s\t%s\t%d files%s\n", run.ID, run.Created.Format("2006-01-02 15:04:05"), len(run.Files), status)
+		}
+		return nil
+	}
+
+	var run *journal.Run
+	if cfg.RunID != "" {
+		run, err = journal.Load(cfg.JournalDir, cfg.RunID)
+	} else {
+		run, err = journal.Latest(cfg.JournalDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	res, err := journal.Undo(run)
+	for _, path := range res.Restored {
+		fmt.Fprintf(stdout, "restored %s\n", path)
+	}
+	for _, path := range res.Skipped {
+		fmt.Fprintf(stdout, "skipped %s: edited or removed since run %s\n", path, run.ID)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Undid run %s: %d restored, %d skipped.\n", run.ID, len(res.Restored), len(res.Skipped))
+	return nil
+}
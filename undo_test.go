@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRunUndo verifies that a run writing files can be undone from the command line.
+func TestRunUndo(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := `package main
+
+import "os"
+
+func main() {
+	os.Remove("x")
+}
+`
+	_ = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module example.com/undo\ngo 1.22\n"), 0644)
+	path := filepath.Join(tmpDir, "main.go")
+	_ = os.WriteFile(path, []byte(src), 0644)
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	var buf bytes.Buffer
+	if err := run([]string{"."}, &buf); err != nil {
+		t.Fatalf("run failed: %v\n%s", err, buf.String())
+	}
+	if got, _ := os.ReadFile(path); string(got) == src {
+		t.Fatal("expected main.go to be rewritten")
+	}
+	if !strings.Contains(buf.String(), "auto-err undo") {
+		t.Errorf("run should print the undo command:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := run([]string{"undo", "--list"}, &buf); err != nil || !strings.Contains(buf.String(), "1 files") {
+		t.Errorf("undo --list = %q, %v", buf.String(), err)
+	}
+
+	buf.Reset()
+	if err := run([]string{"undo"}, &buf); err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != src {
+		t.Errorf("main.go not restored:\n%s", got)
+	}
+	if !strings.Contains(buf.String(), "restored "+path) {
+		t.Errorf("undo output missing restored file:\n%s", buf.String())
+	}
+
+	if err := run([]string{"undo"}, &buf); err == nil {
+		t.Error("expected error when nothing is left to undo")
+	}
+}
+
+// TestRunUndo_Subdirectory verifies that the journal-dir of the configuration file is found from a
+// subdirectory.
+func TestRunUndo_Subdirectory(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := "package main\n\nimport \"os\"\n\nfunc main() {\n\tos.Remove(\"x\")\n}\n"
+	_ = os.WriteFile(filepath.Join(tmpDir, ".auto-err.yaml"), []byte("journal-dir: .journal\n"), 0644)
+	_ = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module example.com/undo\ngo 1.22\n"), 0644)
+	path := filepath.Join(tmpDir, "main.go")
+	_ = os.WriteFile(path, []byte(src), 0644)
+	sub := filepath.Join(tmpDir, "sub")
+	_ = os.Mkdir(sub, 0755)
+
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(tmpDir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	var buf bytes.Buffer
+	if err := run([]string{"."}, &buf); err != nil {
+		t.Fatalf("run failed: %v\n%s", err, buf.String())
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, ".journal")); err != nil {
+		t.Fatalf("expected the journal in .journal: %v", err)
+	}
+
+	_ = os.Chdir(sub)
+	buf.Reset()
+	if err := run([]string{"undo"}, &buf); err != nil {
+		t.Fatalf("undo from a subdirectory failed: %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != src {
+		t.Errorf("main.go not restored:\n%s", got)
+	}
+}